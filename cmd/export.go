@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/export"
+	"github.com/JackFazackerley/photo-grouping/internal/store"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	log "github.com/sirupsen/logrus"
+)
+
+// outputFormats are the formats the groups can be printed in with --output, other than as lines of suggested titles.
+var outputFormats = map[string]func(io.Writer, []export.Titled) error{
+	"markdown": export.WriteMarkdown,
+	"table":    export.WriteTable,
+	"geojson":  export.WriteGeoJSON,
+	"kml":      export.WriteKML,
+	"ics": func(w io.Writer, groups []export.Titled) error {
+		return export.WriteICS(w, groups, time.Now())
+	},
+}
+
+// outputLines is the default --output, which logs the suggested titles of each group.
+const outputLines = "lines"
+
+// checkOutput exits if format isn't one of the formats accepted by --output.
+func checkOutput(format string) {
+	if _, ok := outputFormats[format]; ok || format == outputLines {
+		return
+	}
+
+	names := []string{outputLines}
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+
+	log.Fatalf("unknown --output %q, must be one of %s", format, strings.Join(names, ", "))
+}
+
+// printGroups prints the groups to stdout in the given format, which mustn't be outputLines.
+func printGroups(format string, groups []export.Titled) {
+	if err := outputFormats[format](os.Stdout, groups); err != nil {
+		log.WithError(err).Error("printing groups")
+	}
+}
+
+// titled returns the groups along with the title chosen for each, which is the stored title if the groups were saved,
+// otherwise the first suggested title.
+func titled(groups []grouping.Group, saved []store.Group) []export.Titled {
+	result := make([]export.Titled, 0, len(groups))
+
+	for i, group := range groups {
+		t := export.Titled{Group: group}
+
+		switch {
+		case saved != nil:
+			t.Title = saved[i].Title
+		case len(group.Titles) > 0:
+			t.Title = group.Titles[0]
+		}
+
+		result = append(result, t)
+	}
+
+	return result
+}
+
+// storedTitled returns the stored groups along with the title chosen for each.
+func storedTitled(groups []store.Group) []export.Titled {
+	result := make([]export.Titled, 0, len(groups))
+
+	for _, group := range groups {
+		result = append(result, export.Titled{Group: group.Group, Title: group.Title})
+	}
+
+	return result
+}
+
+// writeXMP writes the title, trip type and place of each group into the XMP of its photos, printing the changes to
+// stderr instead when xmpDryRun is set, so that they aren't mixed into the groups written to stdout.
+func writeXMP(groups []export.Titled) {
+	xmpOptions := export.XMPOptions{
+		DryRun: xmpDryRun,
+		Embed:  embedXMP,
+	}
+
+	if xmpDryRun {
+		xmpOptions.Diff = os.Stderr
+	}
+
+	stats := export.WriteXMP(groups, xmpOptions)
+
+	log.WithFields(
+		log.Fields{
+			"changed":   stats.Changed,
+			"unchanged": stats.Unchanged,
+			"skipped":   stats.Skipped,
+			"failed":    stats.Failed,
+			"dryRun":    xmpDryRun,
+		},
+	).Info("xmp written")
+}
+
+// exportGroups writes the groups to the file at path using write, which is only created once they've been written.
+func exportGroups(path string, groups []export.Titled, write func(io.Writer, []export.Titled) error) {
+	var b bytes.Buffer
+
+	if err := write(&b, groups); err != nil {
+		log.WithError(err).WithField("path", path).Error("exporting groups")
+		return
+	}
+
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		log.WithError(err).WithField("path", path).Error("exporting groups")
+		return
+	}
+
+	log.WithFields(log.Fields{"path": path, "groups": len(groups)}).Info("groups exported")
+}
@@ -2,46 +2,158 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
+	"time"
 
-	"github.com/JackFazackerley/photo-grouping/internal/categoriser"
-	"github.com/JackFazackerley/photo-grouping/internal/consumer"
-	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/export"
+	"github.com/JackFazackerley/photo-grouping/internal/gpx"
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	"github.com/JackFazackerley/photo-grouping/internal/progress"
+	"github.com/JackFazackerley/photo-grouping/internal/store"
+	"github.com/JackFazackerley/photo-grouping/internal/tracing"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 	"googlemaps.github.io/maps"
 )
 
 var (
-	csvPath string
-	apiKey  string
+	csvPath        string
+	takeoutPath    string
+	applePath      string
+	photosPath     string
+	xmpPrecedence  string
+	apiKey         string
+	configPath     string
+	metricsSummary bool
+	showProgress   bool
+	dbPath         string
+	gpxPaths       []string
+	xmpWrite       bool
+	xmpDryRun      bool
+	embedXMP       bool
+	geoJSONPath    string
+	kmlPath        string
+	icsPath        string
+	output         string
+
+	options      = grouping.DefaultOptions()
+	traceOptions tracing.Options
+	gpxOptions   = gpx.DefaultOptions
 )
 
 func init() {
 	flag.StringVar(&csvPath, "csvPath", "", "path to csv")
-	flag.StringVar(&apiKey, "apiKey", "", "apiKey required for Google's Reverse Geocoding API")
+	flag.StringVar(&takeoutPath, "takeout", "", "path to a Google Takeout export of Google Photos, read instead of a csv")
+	flag.StringVar(&applePath, "applePhotos", "", "path to an Apple Photos library exported with osxphotos query --json, read instead of a csv")
+	flag.StringVar(&photosPath, "photos", "", "path to a directory of photos, read instead of a csv")
+	flag.StringVar(&xmpPrecedence, "xmpPrecedence", "sidecar", "whether a photo's XMP sidecar or embedded XMP is used when both set a field, either sidecar or embedded")
+	flag.BoolVar(&metricsSummary, "metrics", false, "print a summary of the pipeline metrics once complete")
+	flag.StringVar(&dbPath, "db", "", "path to a SQLite database to store photos and groups in, so that runs are incremental")
+	flag.StringSliceVar(&gpxPaths, "gpx", nil, "paths to GPX track logs which locate rows with only a timestamp, and photos without a location, can be repeated")
+	flag.DurationVar(&gpxOptions.Offset, "clockOffset", gpxOptions.Offset, "added to each photo's timestamp to match the GPX track, i.e. 5m for a camera 5 minutes slow")
+	flag.DurationVar(&gpxOptions.MaxGap, "maxGap", gpxOptions.MaxGap, "furthest in time a photo can be from a GPX track point to be located from it")
+	flag.BoolVar(&xmpWrite, "writeXMP", false, "write each group's title, trip type and place into the XMP sidecars of its photos")
+	flag.BoolVar(&xmpDryRun, "xmpDryRun", false, "print the changes --writeXMP would make as a diff to stderr, without writing them")
+	flag.BoolVar(&embedXMP, "embedXMP", false, "with --writeXMP, update the XMP embedded in JPEGs rather than their sidecars, rewriting the files")
+	flag.StringVar(&geoJSONPath, "geojson", "", "path to write the groups to as GeoJSON")
+	flag.StringVar(&kmlPath, "kml", "", "path to write the groups to as KML")
+	flag.StringVar(&icsPath, "ics", "", "path to write the groups to as iCalendar events")
+	flag.StringVar(&output, "output", outputLines, "how the groups are printed, either lines of suggested titles, markdown or table for people to read, or geojson, kml or ics")
+	flag.BoolVar(&showProgress, "progress", true, "report progress while geocoding, as a progress bar on a terminal or as log lines otherwise")
+	addPipelineFlags(flag.CommandLine)
 }
 
-func main() {
-	flag.Parse()
+// addPipelineFlags registers the flags shared by every command which runs the pipeline.
+func addPipelineFlags(flags *flag.FlagSet) {
+	flags.StringVar(&apiKey, "apiKey", "", "apiKey required for Google's Reverse Geocoding API")
+	flags.StringVar(&configPath, "config", "", "path to a JSON file with trip thresholds and custom trip types")
+	flags.BoolVar(&options.Explain, "explain", false, "print why each location was given its trip type and titles")
+	flags.Float64Var(&options.ReuseRadius, "reuseRadius", options.ReuseRadius, "distance in metres a photo can be from an already geocoded photo to reuse its result")
+	flags.Float64Var(&options.Cluster.Radius, "clusterRadius", options.Cluster.Radius, "distance in metres between photos without a locality to be clustered")
+	flags.DurationVar(&options.Cluster.Window, "clusterWindow", options.Cluster.Window, "time between photos without a locality to be clustered")
+	flags.IntVar(&options.Cluster.MinPhotos, "clusterMinPhotos", options.Cluster.MinPhotos, "number of photos without a locality needed to form a cluster")
+	flags.Float64Var(&options.Attach.Distance, "attachDistance", options.Attach.Distance, "distance in metres an ungeocoded photo can be from a group to be attached to it")
+	flags.DurationVar(&options.Attach.Window, "attachWindow", options.Attach.Window, "time an ungeocoded photo can be from a group to be attached to it")
+	flags.StringVar(&traceOptions.Endpoint, "traceEndpoint", "", "host:port of an OTLP/HTTP collector to export traces to")
+	flags.BoolVar(&traceOptions.Insecure, "traceInsecure", false, "connect to the trace endpoint without TLS")
+	flags.StringVar(&traceOptions.File, "traceFile", "", "path to a file to write traces to as JSON")
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	wg := &sync.WaitGroup{}
+// setupTracing exports traces if either a trace endpoint or file was given. The returned function flushes any
+// remaining spans.
+func setupTracing() func() {
+	if traceOptions.Endpoint == "" && traceOptions.File == "" {
+		return func() {}
+	}
 
-	reader, err := consumer.NewReader(csvPath)
+	shutdown, err := tracing.Setup(context.Background(), traceOptions)
 	if err != nil {
-		log.WithError(err).Fatal("creating new reader")
+		log.WithError(err).Fatal("setting up tracing")
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		if err := shutdown(ctx); err != nil {
+			log.WithError(err).Error("shutting down tracing")
+		}
 	}
-	defer reader.Close()
+}
 
-	photosChan := reader.ReadCSV(ctx)
+// loadConfig replaces options.Config with the config file, if one was given.
+func loadConfig() {
+	if configPath == "" {
+		return
+	}
 
-	consumer, err := consumer.NewConsumer(apiKey, maps.WithRateLimit(50))
+	config, err := grouping.LoadConfig(configPath)
 	if err != nil {
-		log.WithError(err).Fatal("creating consumer")
+		log.WithError(err).Fatal("loading config")
+	}
+	options.Config = config
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "watch":
+			watchDir(os.Args[2:])
+			return
+		case "groups":
+			listGroups(os.Args[2:])
+			return
+		case "title":
+			setTitle(os.Args[2:])
+			return
+		case "report":
+			writeReport(os.Args[2:])
+			return
+		}
+	}
+
+	flag.Parse()
+	checkOutput(output)
+	loadConfig()
+
+	shutdownTracing := setupTracing()
+	defer shutdownTracing()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	source, total, closeSource := openSource()
+	defer closeSource()
+
+	client, err := maps.NewClient(maps.WithAPIKey(apiKey), maps.WithRateLimit(50))
+	if err != nil {
+		log.WithError(err).Fatal("creating maps client")
 	}
 
 	go func() {
@@ -53,21 +165,127 @@ func main() {
 		cancel()
 	}()
 
-	photoHeap := heap.New()
+	var (
+		photoStore *store.Store
+		stored     []grouping.Photo
+	)
+
+	if dbPath != "" {
+		photoStore = openStore(dbPath)
+		defer photoStore.Close()
+
+		stored, err = photoStore.Photos(ctx)
+		if err != nil {
+			log.WithError(err).Fatal("loading stored photos")
+		}
+
+		source = store.Merge(stored, source)
+	}
+
+	runProgress := &grouping.Progress{}
+	reporterCtx, stopReporter := context.WithCancel(ctx)
+	reporterDone := make(chan struct{})
+
+	if showProgress {
+		reporter := progress.New(runProgress, total+int64(len(stored)), os.Stderr, progress.DefaultOptions)
+
+		go func() {
+			defer close(reporterDone)
+			reporter.Run(reporterCtx)
+		}()
+	} else {
+		close(reporterDone)
+	}
+
+	result, err := grouping.New(client, options).RunWithProgress(ctx, source, runProgress)
+	if err != nil {
+		log.WithError(err).Error("grouping photos")
+	}
+
+	var saved []store.Group
 
-	for i := 0; i < 5; i++ {
-		wg.Add(1)
-		go consumer.Run(ctx, photoHeap, photosChan, wg)
+	if photoStore != nil && err == nil {
+		saved, err = photoStore.Save(ctx, result)
+		if err != nil {
+			log.WithError(err).Error("saving groups")
+		}
 	}
 
-	wg.Wait()
+	stopReporter()
+	<-reporterDone
+
+	log.WithFields(
+		log.Fields{
+			"requests":  result.Stats.Requests,
+			"coalesced": result.Stats.Coalesced,
+			"reused":    result.Stats.Reused,
+			"geocoded":  result.Stats.Geocoded,
+			"failed":    result.Stats.Failed,
+			"stored":    result.Stats.Stored,
+		},
+	).Info("geocoding complete")
+
+	groups := titled(result.Groups, saved)
+
+	// the explanations are printed whatever the output, as they're asked for explicitly, followed by the groups in any
+	// other format.
+	if options.Explain {
+		for _, group := range result.Groups {
+			fmt.Println(group.Explanation)
+		}
+	} else if output == outputLines {
+		for i, group := range result.Groups {
+			entry := log.NewEntry(log.StandardLogger())
+			titles := group.Titles
+
+			// the group's ID is logged so that its title can be changed with the title command.
+			if saved != nil {
+				entry = entry.WithField("group", saved[i].ID)
 
-	locations := categoriser.Group(photoHeap)
+				if saved[i].Overridden {
+					titles = []string{saved[i].Title}
+				}
+			}
+
+			for _, title := range titles {
+				entry.Println(title)
+			}
+		}
+	}
+
+	if output != outputLines {
+		printGroups(output, groups)
+	}
+
+	if len(result.Unplaced) > 0 {
+		log.WithField("photos", len(result.Unplaced)).Println("Unplaced")
+
+		if options.Explain {
+			for _, photo := range result.Unplaced {
+				fmt.Printf("  %s (%f, %f) %s\n", photo.Timestamp.Format(time.RFC3339), photo.Latitude, photo.Longitude, photo.Status)
+			}
+		}
+	}
+
+	if xmpWrite || xmpDryRun {
+		writeXMP(groups)
+	}
+
+	if geoJSONPath != "" {
+		exportGroups(geoJSONPath, groups, export.WriteGeoJSON)
+	}
+
+	if kmlPath != "" {
+		exportGroups(kmlPath, groups, export.WriteKML)
+	}
+
+	if icsPath != "" {
+		exportGroups(icsPath, groups, outputFormats["ics"])
+	}
 
-	for _, location := range locations {
-		suggestions := location.GenerateTitles()
-		for _, suggestion := range suggestions {
-			log.Println(suggestion)
+	if metricsSummary {
+		if err := metrics.WriteSummary(os.Stdout); err != nil {
+			log.WithError(err).Error("writing metrics summary")
 		}
 	}
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/report"
+	"github.com/JackFazackerley/photo-grouping/internal/store"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+)
+
+// writeReport renders the stored groups matching the query flags as an HTML report.
+func writeReport(args []string) {
+	var (
+		path  string
+		out   string
+		query store.Query
+	)
+
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	flags.StringVar(&path, "db", "", "path to the SQLite database")
+	flags.StringVar(&out, "out", "report.html", "path to write the report to")
+	flags.IntVar(&query.Year, "year", 0, "only report groups covering part of the year")
+	flags.StringVar(&query.Country, "country", "", "only report groups with a photo taken in the country")
+	flags.StringVar(&query.Name, "name", "", "only report groups with the name")
+	flags.StringVar(&query.TripType, "tripType", "", "only report groups with the trip type")
+
+	_ = flags.Parse(args)
+
+	if path == "" {
+		log.Fatal("--db is required")
+	}
+
+	photoStore := openStore(path)
+	defer photoStore.Close()
+
+	groups, err := photoStore.Groups(context.Background(), query)
+	if err != nil {
+		log.WithError(err).Fatal("querying groups")
+	}
+
+	var b bytes.Buffer
+
+	if err := report.Write(&b, storedTitled(groups), time.Now()); err != nil {
+		log.WithError(err).Fatal("rendering report")
+	}
+
+	if err := os.WriteFile(out, b.Bytes(), 0o644); err != nil {
+		log.WithError(err).Fatal("writing report")
+	}
+
+	log.WithFields(log.Fields{"path": out, "groups": len(groups)}).Info("report written")
+}
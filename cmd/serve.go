@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/server"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"googlemaps.github.io/maps"
+)
+
+// serve runs the HTTP API until the process is interrupted, then stops accepting requests and waits up to
+// shutdownTimeout for in-flight requests to finish. Running jobs are cancelled once the HTTP server has shut down.
+func serve(args []string) {
+	var (
+		addr            string
+		shutdownTimeout time.Duration
+		jobWorkers      int
+		queueSize       int
+		jobRetention    time.Duration
+		serverOptions   = server.DefaultOptions
+	)
+
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.StringVar(&addr, "addr", ":8080", "address to listen on")
+	flags.Int64Var(&serverOptions.MaxBodyBytes, "maxBodyBytes", serverOptions.MaxBodyBytes, "largest request body accepted, in bytes")
+	flags.DurationVar(&serverOptions.RequestTimeout, "requestTimeout", serverOptions.RequestTimeout, "how long a single grouping request can run for")
+	flags.IntVar(&jobWorkers, "jobWorkers", 2, "number of jobs run at once")
+	flags.IntVar(&queueSize, "queueSize", 100, "number of jobs which can wait for a worker before submissions are rejected")
+	flags.DurationVar(&jobRetention, "jobRetention", time.Hour, "how long a finished job's result is kept")
+	flags.DurationVar(&shutdownTimeout, "shutdownTimeout", time.Second*30, "how long to wait for in-flight requests when shutting down")
+	addPipelineFlags(flags)
+
+	_ = flags.Parse(args)
+	loadConfig()
+
+	shutdownTracing := setupTracing()
+	defer shutdownTracing()
+
+	client, err := maps.NewClient(maps.WithAPIKey(apiKey), maps.WithRateLimit(50))
+	if err != nil {
+		log.WithError(err).Fatal("creating maps client")
+	}
+
+	pipeline := grouping.New(client, options)
+	queue := server.NewQueue(pipeline, queueSize, jobRetention)
+
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < jobWorkers; i++ {
+		wg.Add(1)
+		go queue.Run(jobCtx, wg)
+	}
+
+	wg.Add(1)
+	go queue.RunExpiry(jobCtx, wg)
+
+	s := server.New(pipeline, queue, serverOptions)
+	httpServer := &http.Server{
+		Addr:    addr,
+		Handler: s,
+	}
+
+	shutdown := make(chan struct{})
+
+	go func() {
+		defer close(shutdown)
+
+		c := make(chan os.Signal, 1)
+
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+		<-c
+		s.SetReady(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.WithError(err).Error("shutting down server")
+		}
+	}()
+
+	log.WithField("addr", addr).Info("serving")
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.WithError(err).Fatal("serving")
+	}
+
+	<-shutdown
+
+	cancelJobs()
+	wg.Wait()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/JackFazackerley/photo-grouping/internal/consumer"
+	"github.com/JackFazackerley/photo-grouping/internal/gpx"
+	"github.com/JackFazackerley/photo-grouping/internal/xmp"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	log "github.com/sirupsen/logrus"
+)
+
+// openSource returns the Source chosen by the input flags; --photos, --takeout, --applePhotos, otherwise --csvPath.
+// Photos without a location from any of them are located from the --gpx track logs, if any are given.
+// total is the number of rows in a CSV which will be grouped, so that progress can be reported against it, and 0 for
+// other inputs or when it can't be counted.
+// closeSource closes the input once the pipeline has finished with it.
+func openSource() (source grouping.Source, total int64, closeSource func()) {
+	locator := openLocator()
+
+	if photosPath != "" {
+		precedence, err := xmp.ParsePrecedence(xmpPrecedence)
+		if err != nil {
+			log.WithError(err).Fatal("parsing xmpPrecedence")
+		}
+
+		if locator != nil {
+			return grouping.FilesWithLocator(photosPath, grouping.XMPPrecedence(precedence), locator), 0, func() {}
+		}
+
+		return grouping.Files(photosPath, grouping.XMPPrecedence(precedence)), 0, func() {}
+	}
+
+	if takeoutPath != "" {
+		if locator != nil {
+			return grouping.TakeoutWithLocator(takeoutPath, locator), 0, func() {}
+		}
+
+		return grouping.Takeout(takeoutPath), 0, func() {}
+	}
+
+	path := csvPath
+	if applePath != "" {
+		path = applePath
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.WithError(err).Fatal("opening input")
+	}
+
+	if applePath != "" {
+		if locator != nil {
+			return grouping.ApplePhotosWithLocator(file, locator), 0, func() { file.Close() }
+		}
+
+		return grouping.ApplePhotos(file), 0, func() { file.Close() }
+	}
+
+	if showProgress {
+		total = countRows(file, locator)
+	}
+
+	source = grouping.CSV(file)
+	if locator != nil {
+		source = grouping.CSVWithLocator(file, locator)
+	}
+
+	return source, total, func() { file.Close() }
+}
+
+// openLocator returns a Locator for the --gpx track logs, or nil if none were given.
+func openLocator() grouping.Locator {
+	if len(gpxPaths) == 0 {
+		return nil
+	}
+
+	track, err := gpx.Load(gpxPaths...)
+	if err != nil {
+		log.WithError(err).Fatal("loading gpx")
+	}
+
+	return gpx.NewLocator(track, gpxOptions)
+}
+
+// countRows counts the rows in the file which will be grouped so that an ETA can be given, after which the file is
+// rewound to be read by the pipeline. Files which can't be rewound, such as pipes, aren't counted, and 0 is returned.
+func countRows(file *os.File, locator consumer.Locator) int64 {
+	if _, err := file.Seek(0, io.SeekCurrent); err != nil {
+		log.WithError(err).Debug("csv can't be rewound, progress is reported without a total")
+		return 0
+	}
+
+	total, err := consumer.CountRows(file, locator)
+	if err != nil {
+		log.WithError(err).Error("counting rows")
+		total = 0
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.WithError(err).Fatal("rewinding csv")
+	}
+
+	return total
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/store"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+)
+
+// openStore opens the store at path, exiting if it can't be opened.
+func openStore(path string) *store.Store {
+	photoStore, err := store.Open(path)
+	if err != nil {
+		log.WithError(err).Fatal("opening store")
+	}
+
+	return photoStore
+}
+
+// listGroups prints the stored groups matching the query flags, one per line.
+func listGroups(args []string) {
+	var (
+		path   string
+		format string
+		query  store.Query
+	)
+
+	flags := flag.NewFlagSet("groups", flag.ExitOnError)
+	flags.StringVar(&path, "db", "", "path to the SQLite database")
+	flags.StringVar(&format, "output", outputLines, "how the groups are printed, either lines of ID, dates, name, trip type and title, markdown or table for people to read, or geojson, kml or ics")
+	flags.IntVar(&query.Year, "year", 0, "only list groups covering part of the year")
+	flags.StringVar(&query.Country, "country", "", "only list groups with a photo taken in the country")
+	flags.StringVar(&query.Name, "name", "", "only list groups with the name")
+	flags.StringVar(&query.TripType, "tripType", "", "only list groups with the trip type")
+
+	_ = flags.Parse(args)
+
+	if path == "" {
+		log.Fatal("--db is required")
+	}
+
+	checkOutput(format)
+
+	photoStore := openStore(path)
+	defer photoStore.Close()
+
+	groups, err := photoStore.Groups(context.Background(), query)
+	if err != nil {
+		log.WithError(err).Fatal("querying groups")
+	}
+
+	if format != outputLines {
+		printGroups(format, storedTitled(groups))
+		return
+	}
+
+	for _, group := range groups {
+		fmt.Printf(
+			"%d\t%s\t%s\t%s\t%s\t%s\n", group.ID, group.Start.Format(time.RFC3339), group.End.Format(time.RFC3339),
+			group.Name, group.TripType, group.Title,
+		)
+	}
+}
+
+// setTitle sets, or resets, the title of a stored group.
+func setTitle(args []string) {
+	var (
+		path  string
+		id    int64
+		title string
+		reset bool
+	)
+
+	flags := flag.NewFlagSet("title", flag.ExitOnError)
+	flags.StringVar(&path, "db", "", "path to the SQLite database")
+	flags.Int64Var(&id, "id", 0, "ID of the group, as listed by the groups command")
+	flags.StringVar(&title, "title", "", "title to give the group, which is kept when photos are regrouped")
+	flags.BoolVar(&reset, "reset", false, "go back to the group's suggested title")
+
+	_ = flags.Parse(args)
+
+	if path == "" {
+		log.Fatal("--db is required")
+	}
+
+	if title == "" && !reset {
+		log.Fatal("either --title or --reset is required")
+	}
+
+	photoStore := openStore(path)
+	defer photoStore.Close()
+
+	var err error
+	if reset {
+		err = photoStore.ResetTitle(context.Background(), id)
+	} else {
+		err = photoStore.SetTitle(context.Background(), id, title)
+	}
+
+	if err != nil {
+		log.WithError(err).WithField("group", id).Fatal("setting title")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/watch"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"googlemaps.github.io/maps"
+)
+
+// watchDir ingests every CSV added to a directory until the process is interrupted, printing the groups that change
+// to stdout, or sending them to a webhook.
+func watchDir(args []string) {
+	var (
+		dir            string
+		path           string
+		webhook        string
+		webhookTimeout time.Duration
+		watchOptions   = watch.DefaultOptions
+	)
+
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.StringVar(&dir, "dir", "", "directory to watch for new CSVs")
+	flags.StringVar(&path, "db", "", "path to the SQLite database the groups and progress are saved to, defaults to .photo-grouping.db in the watched directory")
+	flags.StringVar(&webhook, "webhook", "", "URL to POST changed groups to as JSON, instead of printing them")
+	flags.DurationVar(&webhookTimeout, "webhookTimeout", time.Second*10, "how long to wait for the webhook to respond")
+	flags.StringVar(&watchOptions.Pattern, "pattern", watchOptions.Pattern, "pattern of the file names to ingest, each is read as a CSV")
+	flags.DurationVar(&watchOptions.Settle, "settle", watchOptions.Settle, "how long a file must go unchanged before it's ingested")
+	flags.DurationVar(&options.Stream.Lateness, "lateness", options.Stream.Lateness, "how far behind the newest photo a photo can arrive and still be grouped in order")
+	addPipelineFlags(flags)
+
+	_ = flags.Parse(args)
+	loadConfig()
+
+	if dir == "" {
+		log.Fatal("--dir is required")
+	}
+
+	if path == "" {
+		path = filepath.Join(dir, ".photo-grouping.db")
+	}
+
+	shutdownTracing := setupTracing()
+	defer shutdownTracing()
+
+	client, err := maps.NewClient(maps.WithAPIKey(apiKey), maps.WithRateLimit(50))
+	if err != nil {
+		log.WithError(err).Fatal("creating maps client")
+	}
+
+	var notifier watch.Notifier = watch.NewWriter(os.Stdout)
+	if webhook != "" {
+		notifier = watch.NewWebhook(webhook, webhookTimeout)
+	}
+
+	photoStore := openStore(path)
+	defer photoStore.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	watcher, err := watch.New(ctx, grouping.New(client, options), dir, photoStore, path, notifier, watchOptions)
+	if err != nil {
+		log.WithError(err).Fatal("creating watcher")
+	}
+
+	go func() {
+		c := make(chan os.Signal, 1)
+
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+		<-c
+		cancel()
+	}()
+
+	log.WithField("dir", dir).Info("watching")
+
+	if err := watcher.Run(ctx); err != nil {
+		log.WithError(err).Fatal("watching")
+	}
+}
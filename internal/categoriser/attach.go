@@ -0,0 +1,129 @@
+package categoriser
+
+import (
+	"sort"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/geo"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+)
+
+const (
+	// Unplaced is the name of the Location returned by Attach for photos which couldn't be attached to any group.
+	Unplaced = "Unplaced"
+)
+
+var (
+	// DefaultAttachOptions are the AttachOptions used when no other configuration is provided.
+	DefaultAttachOptions = AttachOptions{
+		Distance: 20000,
+		Window:   time.Hour * 3,
+	}
+)
+
+// AttachOptions configures Attach. An unresolved photo can only be attached to a group when it was taken within
+// Window and Distance, in metres, of one of the photos within the group.
+type AttachOptions struct {
+	Distance float64
+	Window   time.Duration
+}
+
+// Attach is used to add photos which couldn't be geocoded to the group of the nearest in time resolved photo.
+// The nearest resolved photo must be within AttachOptions.Window and AttachOptions.Distance of the unresolved photo.
+// As a resolved photo belongs to a Location for each of its addresses, the unresolved photo is attached to every
+// Location that shares the nearest resolved photo, extending startTime or endTime when needed.
+//
+// Photos which can't be attached to any Location are returned within a Location named Unplaced, if every photo was
+// attached then nil is returned.
+func Attach(locations map[string]*Location, photos []heap.Photo, options AttachOptions) *Location {
+	unplaced := make([]heap.Photo, 0)
+
+	for _, photo := range photos {
+		nearest := make([]*Location, 0)
+		var nearestGap time.Duration
+
+		for _, location := range locations {
+			gap, ok := location.nearestGap(photo, options)
+			if !ok {
+				continue
+			}
+
+			if len(nearest) == 0 || gap < nearestGap {
+				nearest = []*Location{location}
+				nearestGap = gap
+			} else if gap == nearestGap {
+				nearest = append(nearest, location)
+			}
+		}
+
+		if len(nearest) == 0 {
+			unplaced = append(unplaced, photo)
+			continue
+		}
+
+		for _, location := range nearest {
+			location.attach(photo)
+		}
+	}
+
+	if len(unplaced) == 0 {
+		return nil
+	}
+
+	sortPhotos(unplaced)
+
+	return NewLocation(Unplaced, unplaced)
+}
+
+// nearestGap returns the smallest time between photo and any photo within the Location that is within the
+// AttachOptions. If none of the photos are within the AttachOptions, false is returned.
+func (l *Location) nearestGap(photo heap.Photo, options AttachOptions) (time.Duration, bool) {
+	var nearest time.Duration
+	found := false
+
+	for _, p := range l.photos {
+		gap := photo.Timestamp.Sub(p.Timestamp)
+		if gap < 0 {
+			gap = -gap
+		}
+
+		if gap > options.Window {
+			continue
+		}
+
+		if geo.Distance(photo.Latitude, photo.Longitude, p.Latitude, p.Longitude) > options.Distance {
+			continue
+		}
+
+		if !found || gap < nearest {
+			nearest = gap
+			found = true
+		}
+	}
+
+	return nearest, found
+}
+
+// attach adds the photo to the Location, keeping the photos in order and extending startTime or endTime if the photo
+// was taken outside of them.
+func (l *Location) attach(photo heap.Photo) {
+	l.photos = append(l.photos, photo)
+	sortPhotos(l.photos)
+
+	if photo.Timestamp.Before(l.startTime) {
+		l.startTime = photo.Timestamp
+	}
+
+	if photo.Timestamp.After(l.endTime) {
+		l.endTime = photo.Timestamp
+	}
+}
+
+// sortPhotos orders photos by timestamp.
+func sortPhotos(photos []heap.Photo) {
+	sort.SliceStable(
+		photos, func(i, j int) bool {
+			return photos[i].Timestamp.Before(photos[j].Timestamp)
+		},
+	)
+}
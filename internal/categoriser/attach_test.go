@@ -0,0 +1,134 @@
+package categoriser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttach(t *testing.T) {
+	london := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 10, 0, 0, 0, time.UTC),
+		Latitude:  51.5072,
+		Longitude: -0.1276,
+		Addresses: map[string]struct{}{
+			"London":         {},
+			"United Kingdom": {},
+		},
+		Status: heap.GeocodeResolved,
+	}
+	paris := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 16, 0, 0, 0, time.UTC),
+		Latitude:  48.8566,
+		Longitude: 2.3522,
+		Addresses: map[string]struct{}{
+			"Paris": {},
+		},
+		Status: heap.GeocodeResolved,
+	}
+	thames := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 11, 0, 0, 0, time.UTC),
+		Latitude:  51.5055,
+		Longitude: -0.0754,
+		Status:    heap.GeocodeFailed,
+	}
+	beforeLondon := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 9, 0, 0, 0, time.UTC),
+		Latitude:  51.5055,
+		Longitude: -0.0754,
+		Status:    heap.GeocodeUnresolved,
+	}
+	channel := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 13, 0, 0, 0, time.UTC),
+		Latitude:  50.5,
+		Longitude: 0.5,
+		Status:    heap.GeocodeUnresolved,
+	}
+	nextDayThames := heap.Photo{
+		Timestamp: time.Date(2022, 03, 29, 11, 0, 0, 0, time.UTC),
+		Latitude:  51.5055,
+		Longitude: -0.0754,
+		Status:    heap.GeocodeUnresolved,
+	}
+
+	tests := []struct {
+		name             string
+		photos           []heap.Photo
+		expected         map[string]*Location
+		expectedUnplaced *Location
+	}{
+		{
+			name:   "attaches to every location sharing the nearest photo",
+			photos: []heap.Photo{thames, beforeLondon},
+			expected: map[string]*Location{
+				"London": {
+					startTime: beforeLondon.Timestamp,
+					endTime:   thames.Timestamp,
+					location:  "London",
+					photos:    []heap.Photo{beforeLondon, london, thames},
+				},
+				"United Kingdom": {
+					startTime: beforeLondon.Timestamp,
+					endTime:   thames.Timestamp,
+					location:  "United Kingdom",
+					photos:    []heap.Photo{beforeLondon, london, thames},
+				},
+				"Paris": {
+					startTime: paris.Timestamp,
+					endTime:   paris.Timestamp,
+					location:  "Paris",
+					photos:    []heap.Photo{paris},
+				},
+			},
+			expectedUnplaced: nil,
+		},
+		{
+			name:   "reports photos too far away or too long after as unplaced",
+			photos: []heap.Photo{nextDayThames, channel},
+			expected: map[string]*Location{
+				"London": {
+					startTime: london.Timestamp,
+					endTime:   london.Timestamp,
+					location:  "London",
+					photos:    []heap.Photo{london},
+				},
+				"United Kingdom": {
+					startTime: london.Timestamp,
+					endTime:   london.Timestamp,
+					location:  "United Kingdom",
+					photos:    []heap.Photo{london},
+				},
+				"Paris": {
+					startTime: paris.Timestamp,
+					endTime:   paris.Timestamp,
+					location:  "Paris",
+					photos:    []heap.Photo{paris},
+				},
+			},
+			expectedUnplaced: &Location{
+				startTime: channel.Timestamp,
+				endTime:   nextDayThames.Timestamp,
+				location:  Unplaced,
+				photos:    []heap.Photo{channel, nextDayThames},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				photoHeap := heap.New()
+				photoHeap.Push(london)
+				photoHeap.Push(paris)
+
+				locations := Group(photoHeap)
+
+				got := Attach(locations, tt.photos, DefaultAttachOptions)
+
+				assert.Equal(t, tt.expectedUnplaced, got)
+				assert.Equal(t, tt.expected, locations)
+			},
+		)
+	}
+}
@@ -13,37 +13,58 @@ var (
 )
 
 // Location defines a location. startTime used to told when the first photo taken at the location and endTime
-// being the last photo taken at the location
+// being the last photo taken at the location. photos holds every photo which contributed to the Location and ignored
+// holds the photos taken at the location too long after endTime to be part of it.
 type Location struct {
 	startTime time.Time
 	endTime   time.Time
 	location  string
+	photos    []heap.Photo
+	ignored   []heap.Photo
 }
 
-// GenerateTitles is used to determine what type of trip (day,weekend,week,holiday)
-// the Location was. Once determined it will call suggestions to generate a list of story titles and return the result.
+// Name returns the name of the location, i.e. London.
+func (l Location) Name() string {
+	return l.location
+}
+
+// StartTime returns the timestamp of the first photo taken at the Location.
+func (l Location) StartTime() time.Time {
+	return l.startTime
+}
+
+// EndTime returns the timestamp of the last photo taken at the Location.
+func (l Location) EndTime() time.Time {
+	return l.endTime
+}
+
+// Photos returns the photos which contributed to the Location, ordered by timestamp.
+func (l Location) Photos() []heap.Photo {
+	return l.photos
+}
+
+// GenerateTitles is used to determine what type of trip (day,weekend,week,holiday, etc.) the Location was using
+// the DefaultClassifier. Once determined it will call suggestions to generate a list of story titles and return the
+// result.
 func (l Location) GenerateTitles() []string {
-	var phrases []phrase
-
-	startTimeWeekday := l.startTime.Weekday()
-	endTimeWeekday := l.endTime.Weekday()
-
-	tripDiff := l.endTime.Sub(l.startTime)
-	if tripDiff < oneDay {
-		phrases = dayPhrases
-	} else if tripDiff > oneDay && tripDiff <= fourDays {
-		if startTimeWeekday >= time.Friday && endTimeWeekday <= time.Monday {
-			phrases = weekendPhrases
-		} else {
-			phrases = weekPhrases
-		}
-	} else {
-		phrases = holidayPhrases
-	}
+	return DefaultClassifier.GenerateTitles(l)
+}
 
-	phrases = append(phrases, anyPhrases...)
+// NewLocation creates a Location named name from photos which have already been grouped elsewhere, such as a
+// cluster.Cluster. photos must be ordered by timestamp and contain at least one photo.
+func NewLocation(name string, photos []heap.Photo) *Location {
+	return NewLocationBetween(name, photos, photos[0].Timestamp, photos[len(photos)-1].Timestamp)
+}
 
-	return l.suggestions(phrases)
+// NewLocationBetween creates a Location named name from start to end, such as a trip described outside this package.
+// photos may be empty.
+func NewLocationBetween(name string, photos []heap.Photo, start, end time.Time) *Location {
+	return &Location{
+		startTime: start,
+		endTime:   end,
+		location:  name,
+		photos:    photos,
+	}
 }
 
 // suggestions generates titles based on the phrase(s) provided.
@@ -80,6 +101,7 @@ func Group(photoHeap *heap.Heap) map[string]*Location {
 				startTime: photo.Timestamp,
 				endTime:   photo.Timestamp,
 				location:  k,
+				photos:    []heap.Photo{photo},
 			}
 
 			if lastLocation, ok := locations[k]; !ok {
@@ -87,6 +109,9 @@ func Group(photoHeap *heap.Heap) map[string]*Location {
 			} else {
 				if photo.Timestamp.Sub(lastLocation.endTime) <= oneDay {
 					lastLocation.endTime = photo.Timestamp
+					lastLocation.photos = append(lastLocation.photos, photo)
+				} else {
+					lastLocation.ignored = append(lastLocation.ignored, photo)
 				}
 			}
 		}
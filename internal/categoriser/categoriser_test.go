@@ -79,6 +79,28 @@ func TestLocation_GenerateTitles(t *testing.T) {
 	}
 }
 
+func TestNewLocation(t *testing.T) {
+	photos := []heap.Photo{
+		{
+			Timestamp: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+		},
+		{
+			Timestamp: time.Date(2022, 03, 28, 12, 10, 10, 0, time.UTC),
+		},
+	}
+
+	expected := &Location{
+		startTime: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+		endTime:   time.Date(2022, 03, 28, 12, 10, 10, 0, time.UTC),
+		location:  "Lake District National Park",
+		photos:    photos,
+	}
+
+	got := NewLocation("Lake District National Park", photos)
+
+	assert.Equal(t, expected, got)
+}
+
 func TestGroup(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -152,6 +174,111 @@ func TestGroup(t *testing.T) {
 
 				got := Group(photoHeap)
 
+				// the photos each location keeps for its explanation are checked by TestGroup_Photos.
+				for _, location := range got {
+					location.photos, location.ignored = nil, nil
+				}
+
+				assert.Equal(t, tt.expected, got)
+			},
+		)
+	}
+}
+
+func TestGroup_Photos(t *testing.T) {
+	london := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+		Addresses: map[string]struct{}{
+			"London": {},
+		},
+	}
+	laterLondon := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 12, 10, 10, 0, time.UTC),
+		Addresses: map[string]struct{}{
+			"London": {},
+		},
+	}
+	nextWeekLondon := heap.Photo{
+		Timestamp: time.Date(2022, 04, 04, 12, 10, 10, 0, time.UTC),
+		Addresses: map[string]struct{}{
+			"London": {},
+		},
+	}
+	unitedKingdom := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+		Addresses: map[string]struct{}{
+			"London":         {},
+			"United Kingdom": {},
+		},
+	}
+	laterUnitedKingdom := heap.Photo{
+		Timestamp: time.Date(2022, 03, 28, 12, 10, 10, 0, time.UTC),
+		Addresses: map[string]struct{}{
+			"London":         {},
+			"United Kingdom": {},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		photos   []heap.Photo
+		expected map[string]*Location
+	}{
+		{
+			name:   "keeps the photos of each location",
+			photos: []heap.Photo{london, laterLondon},
+			expected: map[string]*Location{
+				"London": {
+					startTime: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+					endTime:   time.Date(2022, 03, 28, 12, 10, 10, 0, time.UTC),
+					location:  "London",
+					photos:    []heap.Photo{london, laterLondon},
+				},
+			},
+		},
+		{
+			name:   "keeps the photos of separate locations",
+			photos: []heap.Photo{unitedKingdom, laterUnitedKingdom},
+			expected: map[string]*Location{
+				"London": {
+					startTime: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+					endTime:   time.Date(2022, 03, 28, 12, 10, 10, 0, time.UTC),
+					location:  "London",
+					photos:    []heap.Photo{unitedKingdom, laterUnitedKingdom},
+				},
+				"United Kingdom": {
+					startTime: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+					endTime:   time.Date(2022, 03, 28, 12, 10, 10, 0, time.UTC),
+					location:  "United Kingdom",
+					photos:    []heap.Photo{unitedKingdom, laterUnitedKingdom},
+				},
+			},
+		},
+		{
+			name:   "ignores photos more than a day after the previous photo",
+			photos: []heap.Photo{london, laterLondon, nextWeekLondon},
+			expected: map[string]*Location{
+				"London": {
+					startTime: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+					endTime:   time.Date(2022, 03, 28, 12, 10, 10, 0, time.UTC),
+					location:  "London",
+					photos:    []heap.Photo{london, laterLondon},
+					ignored:   []heap.Photo{nextWeekLondon},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				photoHeap := heap.New()
+
+				for _, photo := range tt.photos {
+					photoHeap.Push(photo)
+				}
+
+				got := Group(photoHeap)
+
 				assert.Equal(t, tt.expected, got)
 			},
 		)
@@ -0,0 +1,196 @@
+package categoriser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// dateLayout is the layout of holidays within the configuration file.
+	dateLayout = "2006-01-02"
+)
+
+var (
+	// weekdays maps the lower case name of a weekday to the time.Weekday.
+	weekdays = map[string]time.Weekday{
+		"sunday":    time.Sunday,
+		"monday":    time.Monday,
+		"tuesday":   time.Tuesday,
+		"wednesday": time.Wednesday,
+		"thursday":  time.Thursday,
+		"friday":    time.Friday,
+		"saturday":  time.Saturday,
+	}
+)
+
+// Config holds the thresholds, bank holidays and custom trip types read from a configuration file.
+type Config struct {
+	Thresholds Thresholds
+	Holidays   []time.Time
+	TripTypes  []TripType
+}
+
+// fileConfig is the JSON representation of Config. Durations are written as strings that can be parsed by
+// time.ParseDuration, weekdays are written by name and holidays are written as YYYY-MM-DD, i.e.
+//
+//	{
+//	  "thresholds": {"dayTrip": "24h", "shortBreak": "48h", "week": "96h"},
+//	  "holidays": ["2022-12-26", "2022-12-27"],
+//	  "tripTypes": [
+//	    {"name": "ski trip", "minDuration": "120h", "startDays": ["Saturday"], "phrases": ["Skiing in"]}
+//	  ]
+//	}
+type fileConfig struct {
+	Thresholds struct {
+		DayTrip    string `json:"dayTrip"`
+		ShortBreak string `json:"shortBreak"`
+		Week       string `json:"week"`
+	} `json:"thresholds"`
+	Holidays  []string       `json:"holidays"`
+	TripTypes []fileTripType `json:"tripTypes"`
+}
+
+// fileTripType is the JSON representation of a custom TripType.
+type fileTripType struct {
+	Name        string   `json:"name"`
+	MinDuration string   `json:"minDuration"`
+	MaxDuration string   `json:"maxDuration"`
+	StartDays   []string `json:"startDays"`
+	EndDays     []string `json:"endDays"`
+	Phrases     []string `json:"phrases"`
+}
+
+// LoadConfig is used to read a JSON configuration file from the given path. Any threshold that isn't set falls back
+// to the matching DefaultThresholds value.
+//
+// If the file can't be read, or any of the values are invalid, an error is returned.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading config: %w", err)
+	}
+
+	var fc fileConfig
+	if err := json.Unmarshal(b, &fc); err != nil {
+		return Config{}, fmt.Errorf("decoding config: %w", err)
+	}
+
+	config := Config{
+		Thresholds: DefaultThresholds,
+	}
+
+	thresholds := []struct {
+		value  string
+		target *time.Duration
+	}{
+		{fc.Thresholds.DayTrip, &config.Thresholds.DayTrip},
+		{fc.Thresholds.ShortBreak, &config.Thresholds.ShortBreak},
+		{fc.Thresholds.Week, &config.Thresholds.Week},
+	}
+
+	for _, threshold := range thresholds {
+		if threshold.value == "" {
+			continue
+		}
+
+		if *threshold.target, err = time.ParseDuration(threshold.value); err != nil {
+			return Config{}, fmt.Errorf("parsing threshold: %w", err)
+		}
+	}
+
+	if err := config.Thresholds.Validate(); err != nil {
+		return Config{}, fmt.Errorf("validating thresholds: %w", err)
+	}
+
+	for _, holiday := range fc.Holidays {
+		day, err := time.Parse(dateLayout, holiday)
+		if err != nil {
+			return Config{}, fmt.Errorf("parsing holiday: %w", err)
+		}
+
+		config.Holidays = append(config.Holidays, day)
+	}
+
+	for _, ft := range fc.TripTypes {
+		tripType, err := ft.tripType()
+		if err != nil {
+			return Config{}, fmt.Errorf("trip type %q: %w", ft.Name, err)
+		}
+
+		config.TripTypes = append(config.TripTypes, tripType)
+	}
+
+	return config, nil
+}
+
+// tripType converts the fileTripType into a TripType, each of the fields that are set are combined into a single
+// Predicate.
+func (f fileTripType) tripType() (TripType, error) {
+	if f.Name == "" {
+		return TripType{}, errors.New("missing name")
+	}
+
+	if len(f.Phrases) == 0 {
+		return TripType{}, errors.New("missing phrases")
+	}
+
+	var min, max time.Duration
+	var err error
+
+	if f.MinDuration != "" {
+		if min, err = time.ParseDuration(f.MinDuration); err != nil {
+			return TripType{}, fmt.Errorf("parsing minDuration: %w", err)
+		}
+	}
+
+	if f.MaxDuration != "" {
+		if max, err = time.ParseDuration(f.MaxDuration); err != nil {
+			return TripType{}, fmt.Errorf("parsing maxDuration: %w", err)
+		}
+	}
+
+	predicates := []Predicate{
+		DurationBetween(min, max),
+	}
+
+	if len(f.StartDays) > 0 {
+		days, err := parseWeekdays(f.StartDays)
+		if err != nil {
+			return TripType{}, fmt.Errorf("parsing startDays: %w", err)
+		}
+		predicates = append(predicates, StartsOn(days...))
+	}
+
+	if len(f.EndDays) > 0 {
+		days, err := parseWeekdays(f.EndDays)
+		if err != nil {
+			return TripType{}, fmt.Errorf("parsing endDays: %w", err)
+		}
+		predicates = append(predicates, EndsOn(days...))
+	}
+
+	return TripType{
+		Name:    f.Name,
+		Match:   All(predicates...),
+		Phrases: f.Phrases,
+	}, nil
+}
+
+// parseWeekdays converts weekday names, ignoring case, into time.Weekday(s).
+func parseWeekdays(names []string) ([]time.Weekday, error) {
+	days := make([]time.Weekday, 0, len(names))
+
+	for _, name := range names {
+		day, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", name)
+		}
+		days = append(days, day)
+	}
+
+	return days, nil
+}
@@ -0,0 +1,113 @@
+package categoriser
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name               string
+		fileContents       string
+		expectedThresholds Thresholds
+		expectedHolidays   []time.Time
+		expectedTripTypes  []string
+		expectedErr        string
+	}{
+		{
+			name:               "loads empty config with default thresholds",
+			fileContents:       `{}`,
+			expectedThresholds: DefaultThresholds,
+		},
+		{
+			name:         "loads thresholds and trip types",
+			fileContents: `{"thresholds":{"dayTrip":"12h","week":"72h"},"tripTypes":[{"name":"ski trip","minDuration":"120h","startDays":["saturday"],"endDays":["Saturday"],"phrases":["Skiing in"]}]}`,
+			expectedThresholds: Thresholds{
+				DayTrip:    time.Hour * 12,
+				ShortBreak: oneDay * 2,
+				Week:       time.Hour * 72,
+			},
+			expectedTripTypes: []string{"ski trip"},
+		},
+		{
+			name:               "loads holidays",
+			fileContents:       `{"holidays":["2022-12-26","2022-12-27"]}`,
+			expectedThresholds: DefaultThresholds,
+			expectedHolidays: []time.Time{
+				time.Date(2022, 12, 26, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, 12, 27, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:         "errors parsing holiday",
+			fileContents: `{"holidays":["26/12/2022"]}`,
+			expectedErr:  "parsing holiday",
+		},
+		{
+			name:         "errors decoding config",
+			fileContents: `not json`,
+			expectedErr:  "decoding config",
+		},
+		{
+			name:         "errors parsing threshold",
+			fileContents: `{"thresholds":{"dayTrip":"a day"}}`,
+			expectedErr:  "parsing threshold",
+		},
+		{
+			name:         "errors validating thresholds",
+			fileContents: `{"thresholds":{"dayTrip":"100h"}}`,
+			expectedErr:  "validating thresholds",
+		},
+		{
+			name:         "errors on missing phrases",
+			fileContents: `{"tripTypes":[{"name":"ski trip"}]}`,
+			expectedErr:  "missing phrases",
+		},
+		{
+			name:         "errors on unknown weekday",
+			fileContents: `{"tripTypes":[{"name":"ski trip","startDays":["someday"],"phrases":["Skiing in"]}]}`,
+			expectedErr:  "unknown weekday",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				f, err := os.CreateTemp(".", "*.json")
+				if err != nil {
+					t.Fatalf("creating temp file: %s", err)
+				}
+
+				defer func() {
+					_ = os.Remove(f.Name())
+				}()
+
+				_, _ = f.WriteString(tt.fileContents)
+				_ = f.Close()
+
+				got, err := LoadConfig(f.Name())
+
+				if tt.expectedErr != "" {
+					assert.Contains(t, err.Error(), tt.expectedErr)
+					return
+				}
+
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedThresholds, got.Thresholds)
+				assert.Equal(t, tt.expectedHolidays, got.Holidays)
+
+				names := make([]string, 0)
+				for _, tripType := range got.TripTypes {
+					names = append(names, tripType.Name)
+				}
+
+				if tt.expectedTripTypes == nil {
+					tt.expectedTripTypes = []string{}
+				}
+				assert.Equal(t, tt.expectedTripTypes, names)
+			},
+		)
+	}
+}
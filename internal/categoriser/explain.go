@@ -0,0 +1,167 @@
+package categoriser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+)
+
+// Explanation describes why a Location was given its TripType and titles.
+type Explanation struct {
+	Location string
+	// Photos are the photos which contributed to the Location, Ignored are the photos taken at the same location
+	// but too long after the Location ended to be part of it.
+	Photos  []heap.Photo
+	Ignored []heap.Photo
+	// Components are the address component types (locality, country, etc.) the Location name came from,
+	// Addresses are every other address the contributing photos resolved to.
+	Components []string
+	Addresses  []string
+	Duration   time.Duration
+	Weekdays   []time.Weekday
+	// Rules holds each TripType that was evaluated, in order, up to and including the one that matched.
+	Rules    []RuleResult
+	TripType string
+	Chosen   []string
+	Rejected []string
+}
+
+// RuleResult holds the outcome of evaluating a single TripType against a Location.
+type RuleResult struct {
+	TripType string
+	Matched  bool
+}
+
+// Explain classifies the Location with Classifier.Classify and generates its titles the same way as
+// Classifier.GenerateTitles, but records each step along the way. Phrases belonging to trip types which didn't match
+// are returned as rejected.
+func (c *Classifier) Explain(l Location) Explanation {
+	explanation := Explanation{
+		Location: l.location,
+		Photos:   l.photos,
+		Ignored:  l.ignored,
+		Duration: l.endTime.Sub(l.startTime),
+	}
+
+	for _, day := range l.days() {
+		explanation.Weekdays = append(explanation.Weekdays, day.Weekday())
+	}
+
+	components := make(map[string]struct{})
+	addresses := make(map[string]struct{})
+
+	for _, photo := range l.photos {
+		for _, componentType := range photo.AddressTypes[l.location] {
+			components[componentType] = struct{}{}
+		}
+
+		for address := range photo.Addresses {
+			if address != l.location {
+				addresses[address] = struct{}{}
+			}
+		}
+	}
+
+	explanation.Components = sortedKeys(components)
+	explanation.Addresses = sortedKeys(addresses)
+
+	// the trip type is the one Classify chooses, every trip type before it was evaluated and didn't match.
+	matched, ok := c.classify(l)
+
+	for i, tripType := range c.tripTypes {
+		if i <= matched {
+			explanation.Rules = append(
+				explanation.Rules, RuleResult{
+					TripType: tripType.Name,
+					Matched:  i == matched,
+				},
+			)
+		}
+
+		if i == matched {
+			continue
+		}
+
+		for _, p := range tripType.phrases() {
+			explanation.Rejected = append(explanation.Rejected, p.generate(l))
+		}
+	}
+
+	var chosen TripType
+
+	if ok {
+		chosen = c.tripTypes[matched]
+		explanation.TripType = chosen.Name
+	}
+
+	explanation.Chosen = titles(l, chosen, ok)
+
+	return explanation
+}
+
+// String formats the Explanation so that it can be printed to a terminal.
+func (e Explanation) String() string {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "%s\n", e.Location)
+	fmt.Fprintf(b, "  photos: %d\n", len(e.Photos))
+	for _, photo := range e.Photos {
+		fmt.Fprintf(b, "    %s (%f, %f)\n", photo.Timestamp.Format(time.RFC3339), photo.Latitude, photo.Longitude)
+	}
+
+	if len(e.Ignored) > 0 {
+		fmt.Fprintf(b, "  ignored photos, taken more than a day after the previous photo: %d\n", len(e.Ignored))
+		for _, photo := range e.Ignored {
+			fmt.Fprintf(b, "    %s (%f, %f)\n", photo.Timestamp.Format(time.RFC3339), photo.Latitude, photo.Longitude)
+		}
+	}
+
+	fmt.Fprintf(b, "  duration: %s\n", e.Duration)
+
+	weekdays := make([]string, 0, len(e.Weekdays))
+	for _, day := range e.Weekdays {
+		weekdays = append(weekdays, day.String())
+	}
+	fmt.Fprintf(b, "  weekdays: %s\n", strings.Join(weekdays, ", "))
+
+	fmt.Fprintf(b, "  address components: %s\n", strings.Join(e.Components, ", "))
+	fmt.Fprintf(b, "  other addresses: %s\n", strings.Join(e.Addresses, ", "))
+
+	fmt.Fprintf(b, "  rules:\n")
+	for _, rule := range e.Rules {
+		result := "no match"
+		if rule.Matched {
+			result = "matched"
+		}
+		fmt.Fprintf(b, "    %s: %s\n", rule.TripType, result)
+	}
+
+	fmt.Fprintf(b, "  trip type: %s\n", e.TripType)
+
+	fmt.Fprintf(b, "  chosen titles:\n")
+	for _, title := range e.Chosen {
+		fmt.Fprintf(b, "    %s\n", title)
+	}
+
+	fmt.Fprintf(b, "  rejected titles:\n")
+	for _, title := range e.Rejected {
+		fmt.Fprintf(b, "    %s\n", title)
+	}
+
+	return b.String()
+}
+
+// sortedKeys returns the keys of the hashmap in alphabetical order, so that explanations are consistent between runs.
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
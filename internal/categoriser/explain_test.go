@@ -0,0 +1,176 @@
+package categoriser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifier_Explain(t *testing.T) {
+	saturday := heap.Photo{
+		Timestamp: time.Date(2022, 04, 02, 10, 10, 10, 0, time.UTC),
+		Latitude:  51.5072,
+		Longitude: 0.1276,
+		Addresses: map[string]struct{}{
+			"London":         {},
+			"United Kingdom": {},
+		},
+		AddressTypes: map[string][]string{
+			"London":         {"locality", "political"},
+			"United Kingdom": {"country", "political"},
+		},
+	}
+	sunday := heap.Photo{
+		Timestamp: time.Date(2022, 04, 03, 14, 10, 10, 0, time.UTC),
+		Latitude:  51.5072,
+		Longitude: 0.1276,
+		Addresses: map[string]struct{}{
+			"London": {},
+		},
+		AddressTypes: map[string][]string{
+			"London": {"locality", "political"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		location Location
+		expected Explanation
+	}{
+		{
+			name: "explains weekend",
+			location: Location{
+				startTime: saturday.Timestamp,
+				endTime:   sunday.Timestamp,
+				location:  "London",
+				photos:    []heap.Photo{saturday, sunday},
+			},
+			expected: Explanation{
+				Location:   "London",
+				Photos:     []heap.Photo{saturday, sunday},
+				Components: []string{"locality", "political"},
+				Addresses:  []string{"United Kingdom"},
+				Duration:   time.Hour * 28,
+				Weekdays:   []time.Weekday{time.Saturday, time.Sunday},
+				Rules: []RuleResult{
+					{TripType: "day", Matched: false},
+					{TripType: "long weekend", Matched: false},
+					{TripType: "weekend", Matched: true},
+				},
+				TripType: "weekend",
+				Chosen: []string{
+					"A weekend getaway to London",
+					"A weekend in London",
+					"London in April",
+					"Visiting London in April",
+				},
+				Rejected: []string{
+					"A day out in London",
+					"A trip to London",
+					"A long weekend in London",
+					"A long weekend away to London",
+					"A short break to London",
+					"A night away in London",
+					"A trip away to London",
+					"Holiday to London",
+				},
+			},
+		},
+		{
+			name: "explains day trip with ignored photos",
+			location: Location{
+				startTime: saturday.Timestamp,
+				endTime:   saturday.Timestamp,
+				location:  "United Kingdom",
+				photos:    []heap.Photo{saturday},
+				ignored:   []heap.Photo{sunday},
+			},
+			expected: Explanation{
+				Location:   "United Kingdom",
+				Photos:     []heap.Photo{saturday},
+				Ignored:    []heap.Photo{sunday},
+				Components: []string{"country", "political"},
+				Addresses:  []string{"London"},
+				Duration:   0,
+				Weekdays:   []time.Weekday{time.Saturday},
+				Rules: []RuleResult{
+					{TripType: "day", Matched: true},
+				},
+				TripType: "day",
+				Chosen: []string{
+					"A day out in United Kingdom",
+					"A trip to United Kingdom",
+					"United Kingdom in April",
+					"Visiting United Kingdom in April",
+				},
+				Rejected: []string{
+					"A long weekend in United Kingdom",
+					"A long weekend away to United Kingdom",
+					"A weekend getaway to United Kingdom",
+					"A weekend in United Kingdom",
+					"A short break to United Kingdom",
+					"A night away in United Kingdom",
+					"A trip away to United Kingdom",
+					"Holiday to United Kingdom",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := DefaultClassifier.Explain(tt.location)
+
+				assert.Equal(t, tt.expected, got)
+
+				// classifying the location agrees with its explanation
+				tripType, _ := DefaultClassifier.Classify(tt.location)
+				assert.Equal(t, tt.expected.TripType, tripType.Name)
+				assert.Equal(t, tt.expected.Chosen, DefaultClassifier.GenerateTitles(tt.location))
+			},
+		)
+	}
+}
+
+func TestExplanation_String(t *testing.T) {
+	explanation := Explanation{
+		Location: "London",
+		Photos: []heap.Photo{
+			{
+				Timestamp: time.Date(2022, 04, 02, 10, 10, 10, 0, time.UTC),
+				Latitude:  51.5072,
+				Longitude: 0.1276,
+			},
+		},
+		Components: []string{"locality"},
+		Addresses:  []string{"United Kingdom"},
+		Duration:   0,
+		Weekdays:   []time.Weekday{time.Saturday},
+		Rules: []RuleResult{
+			{TripType: "day", Matched: true},
+		},
+		TripType: "day",
+		Chosen:   []string{"A day out in London"},
+		Rejected: []string{"Holiday to London"},
+	}
+
+	expected := `London
+  photos: 1
+    2022-04-02T10:10:10Z (51.507200, 0.127600)
+  duration: 0s
+  weekdays: Saturday
+  address components: locality
+  other addresses: United Kingdom
+  rules:
+    day: matched
+  trip type: day
+  chosen titles:
+    A day out in London
+  rejected titles:
+    Holiday to London
+`
+
+	assert.Equal(t, expected, explanation.String())
+}
@@ -12,24 +12,6 @@ var (
 			delimiter: "in",
 		},
 	}
-
-	weekendPhrases = []phrase{
-		locationPhrase("A weekend getaway to"),
-		locationPhrase("A weekend in"),
-	}
-
-	weekPhrases = []phrase{
-		locationPhrase("A trip away to"),
-	}
-
-	dayPhrases = []phrase{
-		locationPhrase("A day out in"),
-		locationPhrase("A trip to"),
-	}
-
-	holidayPhrases = []phrase{
-		locationPhrase("Holiday to"),
-	}
 )
 
 // phrase is used as a generic interface that can be used for multiple types of phrases.
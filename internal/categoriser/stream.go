@@ -0,0 +1,304 @@
+package categoriser
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+)
+
+var (
+	// DefaultStreamOptions are the StreamOptions used when no other configuration is provided. Gap matches Group.
+	DefaultStreamOptions = StreamOptions{
+		Gap:      oneDay,
+		Lateness: time.Hour,
+	}
+)
+
+// StreamOptions configures a Grouper. Gap is the longest time between two photos in the same group, as with Group.
+// Lateness is how far behind the newest photo a photo can arrive and still be grouped in timestamp order, as
+// photos are geocoded concurrently they don't arrive in order.
+type StreamOptions struct {
+	Gap      time.Duration
+	Lateness time.Duration
+}
+
+// Grouper groups photos incrementally as they are geocoded, rather than once every photo has been geocoded like
+// Group. Grouper satisfies consumer.Sink, so that a Consumer can push photos directly to it.
+//
+// Photos are held until they fall behind the watermark, the newest timestamp seen less Lateness, and are then added to
+// an open group in timestamp order. Once the watermark has passed an open group's endTime by more than Gap, no photo
+// can extend it, so it is closed and passed to emit. Photos older than the watermark are still added to an open group,
+// but a group can't be reopened once it has been emitted. A late photo for it starts a late group instead, which other
+// late photos from the same visit join, and which is closed once the watermark has moved Lateness past the last photo
+// added to it.
+//
+// Unlike Group, which ignores a photo taken too long after a location's group, a Grouper starts a new group for the
+// same location, so a location visited twice is emitted twice. Photos without any addresses are ignored.
+type Grouper struct {
+	options StreamOptions
+	emit    func(*Location)
+
+	mu        sync.Mutex
+	pending   *heap.Heap
+	open      map[string]*Location
+	late      map[string]*lateLocation
+	newest    time.Time
+	watermark time.Time
+}
+
+// lateLocation is a group of photos which arrived after the group they would have been in was emitted. It's kept open
+// until the watermark passes closes, so that other late photos from the same visit can join it.
+type lateLocation struct {
+	location *Location
+	closes   time.Time
+}
+
+// NewGrouper creates a Grouper which passes each group to emit once it has closed. emit is called while the Grouper
+// is locked, so must not call the Grouper.
+func NewGrouper(options StreamOptions, emit func(*Location)) *Grouper {
+	return &Grouper{
+		options: options,
+		emit:    emit,
+		pending: heap.New(),
+		open:    make(map[string]*Location),
+		late:    make(map[string]*lateLocation),
+	}
+}
+
+// Push adds a photo to the Grouper, any groups which can no longer change as a result are emitted. Push can be
+// safely called concurrently.
+func (g *Grouper) Push(photo heap.Photo) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(photo.Addresses) == 0 {
+		return
+	}
+
+	if !g.watermark.IsZero() && photo.Timestamp.Before(g.watermark) {
+		g.add(photo, true)
+	} else {
+		g.pending.Push(photo)
+
+		if photo.Timestamp.After(g.newest) {
+			g.newest = photo.Timestamp
+			g.watermark = g.newest.Add(-g.options.Lateness)
+		}
+	}
+
+	g.advance()
+}
+
+// Flush adds every held photo and emits every open group, i.e. once there are no more photos to come. The Grouper can
+// still be used after Flush, such as when the next day's photos are uploaded.
+func (g *Grouper) Flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for {
+		photo, err := g.pending.Pop()
+		if err != nil {
+			break
+		}
+
+		g.add(photo, false)
+	}
+
+	closed := make([]*Location, 0, len(g.open)+len(g.late))
+	for name, location := range g.open {
+		closed = append(closed, location)
+		delete(g.open, name)
+	}
+
+	for name, late := range g.late {
+		closed = append(closed, late.location)
+		delete(g.late, name)
+	}
+
+	g.emitAll(closed)
+}
+
+// Open returns a copy of each group which is still open, including late groups, ordered by startTime then name.
+// Photos held behind the watermark aren't included.
+func (g *Grouper) Open() []Location {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	locations := make([]*Location, 0, len(g.open)+len(g.late))
+	for _, location := range g.open {
+		locations = append(locations, location)
+	}
+
+	for _, late := range g.late {
+		locations = append(locations, late.location)
+	}
+
+	open := make([]Location, 0, len(locations))
+	for _, location := range locations {
+		copied := *location
+		copied.photos = append([]heap.Photo(nil), location.photos...)
+		open = append(open, copied)
+	}
+
+	sort.Slice(
+		open, func(i, j int) bool {
+			if !open[i].startTime.Equal(open[j].startTime) {
+				return open[i].startTime.Before(open[j].startTime)
+			}
+			return open[i].location < open[j].location
+		},
+	)
+
+	return open
+}
+
+// Pending returns the photos held until they fall behind the watermark, ordered by timestamp.
+func (g *Grouper) Pending() []heap.Photo {
+	return g.pending.Photos()
+}
+
+// PendingLen returns the number of photos held until they fall behind the watermark.
+func (g *Grouper) PendingLen() int {
+	return g.pending.Len()
+}
+
+// Watermark returns the timestamp before which photos are no longer held, the zero time.Time is returned if no
+// photos have been pushed.
+func (g *Grouper) Watermark() time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.watermark
+}
+
+// advance adds every held photo which has fallen behind the watermark, then closes any groups which can no longer
+// be extended, and any late groups the watermark has moved far enough past. g.mu must be held.
+func (g *Grouper) advance() {
+	for {
+		photo, err := g.pending.Peek()
+		if err != nil || photo.Timestamp.After(g.watermark) {
+			break
+		}
+
+		_, _ = g.pending.Pop()
+		g.add(photo, false)
+	}
+
+	closed := make([]*Location, 0)
+	for name, location := range g.open {
+		if g.watermark.Sub(location.endTime) > g.options.Gap {
+			closed = append(closed, location)
+			delete(g.open, name)
+		}
+	}
+
+	for name, late := range g.late {
+		if g.watermark.After(late.closes) {
+			closed = append(closed, late.location)
+			delete(g.late, name)
+		}
+	}
+
+	g.emitAll(closed)
+}
+
+// add adds a photo to the open group of each of its addresses, closing a group first if the photo is too long after
+// it. A photo too long before the open group, or a late photo, one which arrived behind the watermark, too far behind
+// it to start a group which wouldn't be closed straight away, is from a visit which has already been emitted, so it's
+// added to a late group instead. g.mu must be held.
+func (g *Grouper) add(photo heap.Photo, late bool) {
+	closed := make([]*Location, 0)
+
+	for name := range photo.Addresses {
+		location, ok := g.open[name]
+
+		if ok && location.fits(photo, g.options.Gap) {
+			location.insert(photo)
+			continue
+		}
+
+		if (ok && photo.Timestamp.Before(location.startTime)) || (late && g.watermark.Sub(photo.Timestamp) > g.options.Gap) {
+			closed = append(closed, g.addLate(name, photo)...)
+			continue
+		}
+
+		if ok {
+			closed = append(closed, location)
+		}
+
+		g.open[name] = NewLocation(name, []heap.Photo{photo})
+	}
+
+	g.emitAll(closed)
+}
+
+// addLate adds a late photo to the late group of the location, keeping the group open until the watermark has moved
+// Lateness past where it is now. If the photo is too far from the late group to join it, the late group is replaced
+// by a new one, and the replaced group is returned to be closed. g.mu must be held.
+func (g *Grouper) addLate(name string, photo heap.Photo) []*Location {
+	closes := g.watermark.Add(g.options.Lateness)
+
+	late, ok := g.late[name]
+	if ok && late.location.fits(photo, g.options.Gap) {
+		late.location.insert(photo)
+		late.closes = closes
+
+		return nil
+	}
+
+	g.late[name] = &lateLocation{
+		location: NewLocation(name, []heap.Photo{photo}),
+		closes:   closes,
+	}
+
+	if ok {
+		return []*Location{late.location}
+	}
+
+	return nil
+}
+
+// fits returns whether the photo was taken within gap of the Location, so that it belongs in it.
+func (l *Location) fits(photo heap.Photo, gap time.Duration) bool {
+	return l.startTime.Sub(photo.Timestamp) <= gap && photo.Timestamp.Sub(l.endTime) <= gap
+}
+
+// insert adds a photo to the Location keeping photos ordered by timestamp, and extends startTime or endTime if the
+// photo is outside of them.
+func (l *Location) insert(photo heap.Photo) {
+	i := sort.Search(
+		len(l.photos), func(i int) bool {
+			return l.photos[i].Timestamp.After(photo.Timestamp)
+		},
+	)
+
+	l.photos = append(l.photos, heap.Photo{})
+	copy(l.photos[i+1:], l.photos[i:])
+	l.photos[i] = photo
+
+	if photo.Timestamp.Before(l.startTime) {
+		l.startTime = photo.Timestamp
+	}
+
+	if photo.Timestamp.After(l.endTime) {
+		l.endTime = photo.Timestamp
+	}
+}
+
+// emitAll passes each location to emit, ordered by startTime then name so that the order is stable.
+func (g *Grouper) emitAll(locations []*Location) {
+	sort.Slice(
+		locations, func(i, j int) bool {
+			if !locations[i].startTime.Equal(locations[j].startTime) {
+				return locations[i].startTime.Before(locations[j].startTime)
+			}
+			return locations[i].location < locations[j].location
+		},
+	)
+
+	for _, location := range locations {
+		g.emit(location)
+	}
+}
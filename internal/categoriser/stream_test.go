@@ -0,0 +1,273 @@
+package categoriser
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamPhoto returns a photo taken at name, the given number of hours after 2022-01-03 00:00 UTC.
+func streamPhoto(name string, hours float64) heap.Photo {
+	return heap.Photo{
+		Timestamp: time.Date(2022, 01, 03, 0, 0, 0, 0, time.UTC).Add(time.Duration(hours * float64(time.Hour))),
+		Addresses: map[string]struct{}{
+			name: {},
+		},
+	}
+}
+
+// emitted is a summary of a Location passed to emit.
+type emitted struct {
+	name   string
+	start  time.Time
+	end    time.Time
+	photos int
+}
+
+func summarise(l *Location) emitted {
+	return emitted{
+		name:   l.Name(),
+		start:  l.StartTime(),
+		end:    l.EndTime(),
+		photos: len(l.Photos()),
+	}
+}
+
+func TestGrouper(t *testing.T) {
+	tests := []struct {
+		name                string
+		photos              []heap.Photo
+		expectedBeforeFlush []emitted
+		expectedAfterFlush  []emitted
+	}{
+		{
+			name: "emits group once watermark passes it",
+			photos: []heap.Photo{
+				streamPhoto("London", 10),
+				streamPhoto("London", 12),
+				streamPhoto("Paris", 58),
+			},
+			expectedBeforeFlush: []emitted{
+				{name: "London", start: streamPhoto("", 10).Timestamp, end: streamPhoto("", 12).Timestamp, photos: 2},
+			},
+			expectedAfterFlush: []emitted{
+				{name: "Paris", start: streamPhoto("", 58).Timestamp, end: streamPhoto("", 58).Timestamp, photos: 1},
+			},
+		},
+		{
+			name: "orders photos arriving within lateness",
+			photos: []heap.Photo{
+				streamPhoto("London", 10.5),
+				streamPhoto("London", 10),
+				streamPhoto("London", 11),
+			},
+			expectedBeforeFlush: []emitted{},
+			expectedAfterFlush: []emitted{
+				{name: "London", start: streamPhoto("", 10).Timestamp, end: streamPhoto("", 11).Timestamp, photos: 3},
+			},
+		},
+		{
+			name: "adds late photo to open group",
+			photos: []heap.Photo{
+				streamPhoto("London", 10),
+				streamPhoto("London", 20),
+				streamPhoto("London", 5),
+			},
+			expectedBeforeFlush: []emitted{},
+			expectedAfterFlush: []emitted{
+				{name: "London", start: streamPhoto("", 5).Timestamp, end: streamPhoto("", 20).Timestamp, photos: 3},
+			},
+		},
+		{
+			name: "emits location visited twice as two groups",
+			photos: []heap.Photo{
+				streamPhoto("London", 10),
+				streamPhoto("London", 60),
+			},
+			expectedBeforeFlush: []emitted{
+				{name: "London", start: streamPhoto("", 10).Timestamp, end: streamPhoto("", 10).Timestamp, photos: 1},
+			},
+			expectedAfterFlush: []emitted{
+				{name: "London", start: streamPhoto("", 60).Timestamp, end: streamPhoto("", 60).Timestamp, photos: 1},
+			},
+		},
+		{
+			name: "emits late photo from closed group in a group of its own",
+			photos: []heap.Photo{
+				streamPhoto("London", 10),
+				streamPhoto("London", 60),
+				streamPhoto("London", 11),
+			},
+			expectedBeforeFlush: []emitted{
+				{name: "London", start: streamPhoto("", 10).Timestamp, end: streamPhoto("", 10).Timestamp, photos: 1},
+			},
+			expectedAfterFlush: []emitted{
+				{name: "London", start: streamPhoto("", 11).Timestamp, end: streamPhoto("", 11).Timestamp, photos: 1},
+				{name: "London", start: streamPhoto("", 60).Timestamp, end: streamPhoto("", 60).Timestamp, photos: 1},
+			},
+		},
+		{
+			name: "groups late photos from the same visit together",
+			photos: []heap.Photo{
+				streamPhoto("London", 10),
+				streamPhoto("London", 60),
+				streamPhoto("London", 13),
+				streamPhoto("London", 11),
+				streamPhoto("London", 12),
+			},
+			expectedBeforeFlush: []emitted{
+				{name: "London", start: streamPhoto("", 10).Timestamp, end: streamPhoto("", 10).Timestamp, photos: 1},
+			},
+			expectedAfterFlush: []emitted{
+				{name: "London", start: streamPhoto("", 11).Timestamp, end: streamPhoto("", 13).Timestamp, photos: 3},
+				{name: "London", start: streamPhoto("", 60).Timestamp, end: streamPhoto("", 60).Timestamp, photos: 1},
+			},
+		},
+		{
+			name: "emits late group once watermark moves past lateness",
+			photos: []heap.Photo{
+				streamPhoto("London", 10),
+				streamPhoto("London", 60),
+				streamPhoto("London", 11),
+				streamPhoto("London", 12),
+				streamPhoto("Paris", 62),
+			},
+			expectedBeforeFlush: []emitted{
+				{name: "London", start: streamPhoto("", 10).Timestamp, end: streamPhoto("", 10).Timestamp, photos: 1},
+				{name: "London", start: streamPhoto("", 11).Timestamp, end: streamPhoto("", 12).Timestamp, photos: 2},
+			},
+			expectedAfterFlush: []emitted{
+				{name: "London", start: streamPhoto("", 60).Timestamp, end: streamPhoto("", 60).Timestamp, photos: 1},
+				{name: "Paris", start: streamPhoto("", 62).Timestamp, end: streamPhoto("", 62).Timestamp, photos: 1},
+			},
+		},
+		{
+			name: "starts another late group for a late photo from a different visit",
+			photos: []heap.Photo{
+				streamPhoto("London", 10),
+				streamPhoto("London", 100),
+				streamPhoto("London", 11),
+				streamPhoto("London", 50),
+			},
+			expectedBeforeFlush: []emitted{
+				{name: "London", start: streamPhoto("", 10).Timestamp, end: streamPhoto("", 10).Timestamp, photos: 1},
+				{name: "London", start: streamPhoto("", 11).Timestamp, end: streamPhoto("", 11).Timestamp, photos: 1},
+			},
+			expectedAfterFlush: []emitted{
+				{name: "London", start: streamPhoto("", 50).Timestamp, end: streamPhoto("", 50).Timestamp, photos: 1},
+				{name: "London", start: streamPhoto("", 100).Timestamp, end: streamPhoto("", 100).Timestamp, photos: 1},
+			},
+		},
+		{
+			name: "ignores photos without addresses",
+			photos: []heap.Photo{
+				{
+					Timestamp: time.Date(2022, 01, 03, 10, 0, 0, 0, time.UTC),
+					Status:    heap.GeocodeUnresolved,
+				},
+			},
+			expectedBeforeFlush: []emitted{},
+			expectedAfterFlush:  []emitted{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := make([]emitted, 0)
+
+				g := NewGrouper(
+					DefaultStreamOptions, func(l *Location) {
+						got = append(got, summarise(l))
+					},
+				)
+
+				for _, photo := range tt.photos {
+					g.Push(photo)
+				}
+
+				assert.Equal(t, tt.expectedBeforeFlush, got)
+
+				got = got[:0]
+				g.Flush()
+
+				assert.Equal(t, tt.expectedAfterFlush, got)
+				assert.Empty(t, g.Open())
+			},
+		)
+	}
+}
+
+func TestGrouper_Open(t *testing.T) {
+	g := NewGrouper(DefaultStreamOptions, func(l *Location) {})
+
+	g.Push(streamPhoto("London", 10))
+	g.Push(streamPhoto("Paris", 12))
+
+	// London is behind the watermark, Paris is still held
+	open := g.Open()
+	assert.Len(t, open, 1)
+	assert.Equal(t, "London", open[0].Name())
+	assert.Equal(t, streamPhoto("", 11).Timestamp, g.Watermark())
+	assert.Equal(t, []heap.Photo{streamPhoto("Paris", 12)}, g.Pending())
+
+	// photos uploaded later update the open group
+	g.Push(streamPhoto("London", 14))
+	g.Push(streamPhoto("London", 20))
+
+	open = g.Open()
+	assert.Len(t, open, 2)
+	assert.Equal(t, "London", open[0].Name())
+	assert.Len(t, open[0].Photos(), 2)
+	assert.Equal(t, "Paris", open[1].Name())
+
+	// modifying the copy doesn't change the Grouper
+	open[0].photos[0] = heap.Photo{}
+	assert.Equal(t, streamPhoto("London", 10), g.Open()[0].Photos()[0])
+}
+
+func TestGrouper_OpenLate(t *testing.T) {
+	g := NewGrouper(DefaultStreamOptions, func(l *Location) {})
+
+	g.Push(streamPhoto("London", 10))
+	g.Push(streamPhoto("London", 60))
+	g.Push(streamPhoto("London", 11))
+	g.Push(streamPhoto("London", 12))
+
+	// the late group is open for more late photos, the photo at 60 is still held
+	open := g.Open()
+	assert.Len(t, open, 1)
+	assert.Equal(t, streamPhoto("", 11).Timestamp, open[0].StartTime())
+	assert.Len(t, open[0].Photos(), 2)
+}
+
+func TestGrouper_PushConcurrently(t *testing.T) {
+	got := make([]emitted, 0)
+
+	g := NewGrouper(
+		DefaultStreamOptions, func(l *Location) {
+			got = append(got, summarise(l))
+		},
+	)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			g.Push(streamPhoto("London", float64(i)/10))
+		}(i)
+	}
+
+	wg.Wait()
+	g.Flush()
+
+	assert.Equal(
+		t, []emitted{
+			{name: "London", start: streamPhoto("", 0).Timestamp, end: streamPhoto("", 0.9).Timestamp, photos: 10},
+		}, got,
+	)
+}
@@ -0,0 +1,209 @@
+package categoriser
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// DefaultThresholds are the durations used to separate the built-in trip types when no configuration is provided.
+	DefaultThresholds = Thresholds{
+		DayTrip:    oneDay,
+		ShortBreak: oneDay * 2,
+		Week:       fourDays,
+	}
+
+	// DefaultClassifier is the Classifier used by Location.GenerateTitles, it only contains the built-in trip types
+	// created from DefaultThresholds.
+	DefaultClassifier = NewClassifier(Config{Thresholds: DefaultThresholds})
+
+	// ErrInvalidThresholds is returned by Thresholds.Validate when the thresholds are not in ascending order.
+	ErrInvalidThresholds = errors.New("thresholds must be positive and ascending")
+)
+
+// Thresholds holds the durations used by the built-in trip types.
+// DayTrip is the upper bound of a day trip, ShortBreak is the upper bound of an overnight trip that isn't a weekend,
+// and Week is the upper bound of a week trip. Anything that is at least Week is considered a holiday.
+type Thresholds struct {
+	DayTrip    time.Duration
+	ShortBreak time.Duration
+	Week       time.Duration
+}
+
+// Validate is used to make sure each threshold is positive and larger than the previous one,
+// otherwise some of the built-in trip types could never be matched.
+func (t Thresholds) Validate() error {
+	if t.DayTrip <= 0 || t.ShortBreak <= t.DayTrip || t.Week <= t.ShortBreak {
+		return ErrInvalidThresholds
+	}
+
+	return nil
+}
+
+// Predicate is used to decide whether a Location belongs to a TripType.
+type Predicate func(l Location) bool
+
+// DurationBetween matches a Location whose duration is at least min and less than max.
+// A max of zero means there is no upper bound.
+func DurationBetween(min, max time.Duration) Predicate {
+	return func(l Location) bool {
+		duration := l.endTime.Sub(l.startTime)
+		return duration >= min && (max == 0 || duration < max)
+	}
+}
+
+// StartsOn matches a Location where the first photo was taken on one of the given days.
+func StartsOn(days ...time.Weekday) Predicate {
+	return func(l Location) bool {
+		return containsWeekday(days, l.startTime.Weekday())
+	}
+}
+
+// EndsOn matches a Location where the last photo was taken on one of the given days.
+func EndsOn(days ...time.Weekday) Predicate {
+	return func(l Location) bool {
+		return containsWeekday(days, l.endTime.Weekday())
+	}
+}
+
+// All matches a Location only when every one of the given predicates match.
+func All(predicates ...Predicate) Predicate {
+	return func(l Location) bool {
+		for _, predicate := range predicates {
+			if !predicate(l) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// TripType defines a kind of trip (day, weekend, holiday, etc). Match is used to determine if a Location is the
+// TripType, and Phrases are prepended to the Location when generating titles, i.e. "A day out in" becomes
+// "A day out in London".
+type TripType struct {
+	Name    string
+	Match   Predicate
+	Phrases []string
+}
+
+// phrases converts TripType.Phrases into locationPhrase(s) so that they can be used to generate titles.
+func (t TripType) phrases() []phrase {
+	phrases := make([]phrase, 0, len(t.Phrases))
+
+	for _, p := range t.Phrases {
+		phrases = append(phrases, locationPhrase(p))
+	}
+
+	return phrases
+}
+
+// DefaultTripTypes returns the built-in trip types in the order they should be evaluated.
+// The order matters, as a long weekend is also a weekend, and a weekend could also be a short break.
+// holidays are used to extend weekends, see Weekend.
+func DefaultTripTypes(thresholds Thresholds, holidays ...time.Time) []TripType {
+	return []TripType{
+		{
+			Name:    "day",
+			Match:   DurationBetween(0, thresholds.DayTrip),
+			Phrases: []string{"A day out in", "A trip to"},
+		},
+		{
+			Name:    "long weekend",
+			Match:   LongWeekend(holidays...),
+			Phrases: []string{"A long weekend in", "A long weekend away to"},
+		},
+		{
+			Name:    "weekend",
+			Match:   Weekend(holidays...),
+			Phrases: []string{"A weekend getaway to", "A weekend in"},
+		},
+		{
+			Name:    "short break",
+			Match:   DurationBetween(thresholds.DayTrip, thresholds.ShortBreak),
+			Phrases: []string{"A short break to", "A night away in"},
+		},
+		{
+			Name:    "week",
+			Match:   DurationBetween(thresholds.ShortBreak, thresholds.Week),
+			Phrases: []string{"A trip away to"},
+		},
+		{
+			Name:    "holiday",
+			Match:   DurationBetween(thresholds.Week, 0),
+			Phrases: []string{"Holiday to"},
+		},
+	}
+}
+
+// Classifier holds an ordered list of TripType(s) which are evaluated against a Location, the first TripType to
+// match is the one used.
+type Classifier struct {
+	tripTypes []TripType
+}
+
+// NewClassifier creates a Classifier from the built-in trip types using the thresholds and holidays from the Config.
+// Custom trip types are evaluated before the built-in ones, so that they can take precedence.
+func NewClassifier(config Config) *Classifier {
+	tripTypes := make([]TripType, 0, len(config.TripTypes)+6)
+	tripTypes = append(tripTypes, config.TripTypes...)
+	tripTypes = append(tripTypes, DefaultTripTypes(config.Thresholds, config.Holidays...)...)
+
+	return &Classifier{
+		tripTypes: tripTypes,
+	}
+}
+
+// Classify returns the first TripType which matches the Location. If nothing matches false is returned.
+func (c *Classifier) Classify(l Location) (TripType, bool) {
+	i, ok := c.classify(l)
+	if !ok {
+		return TripType{}, false
+	}
+
+	return c.tripTypes[i], true
+}
+
+// classify returns the index of the first TripType which matches the Location. If nothing matches false is returned,
+// along with the number of trip types, so that every trip type is before it.
+func (c *Classifier) classify(l Location) (int, bool) {
+	for i, tripType := range c.tripTypes {
+		if tripType.Match != nil && tripType.Match(l) {
+			return i, true
+		}
+	}
+
+	return len(c.tripTypes), false
+}
+
+// GenerateTitles is used to determine what TripType the Location is. Once determined, the TripType phrases and
+// the phrases shared by every trip are used to generate a list of story titles.
+func (c *Classifier) GenerateTitles(l Location) []string {
+	tripType, ok := c.Classify(l)
+
+	return titles(l, tripType, ok)
+}
+
+// titles generates the story titles of the Location from the phrases of its TripType, if it has one, and the phrases
+// shared by every trip.
+func titles(l Location, tripType TripType, ok bool) []string {
+	var phrases []phrase
+
+	if ok {
+		phrases = tripType.phrases()
+	}
+
+	phrases = append(phrases, anyPhrases...)
+
+	return l.suggestions(phrases)
+}
+
+// containsWeekday reports whether day is within days.
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package categoriser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		custom     []TripType
+		startTime  time.Time
+		endTime    time.Time
+		expected   string
+	}{
+		{
+			name:       "classifies day trip",
+			thresholds: DefaultThresholds,
+			startTime:  time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+			endTime:    time.Date(2022, 03, 28, 14, 10, 10, 0, time.UTC),
+			expected:   "day",
+		},
+		{
+			name:       "classifies long weekend",
+			thresholds: DefaultThresholds,
+			startTime:  time.Date(2022, 04, 01, 10, 10, 10, 0, time.UTC),
+			endTime:    time.Date(2022, 04, 04, 14, 10, 10, 0, time.UTC),
+			expected:   "long weekend",
+		},
+		{
+			name:       "classifies weekend",
+			thresholds: DefaultThresholds,
+			startTime:  time.Date(2022, 04, 02, 10, 10, 10, 0, time.UTC),
+			endTime:    time.Date(2022, 04, 03, 14, 10, 10, 0, time.UTC),
+			expected:   "weekend",
+		},
+		{
+			name:       "classifies friday to saturday as weekend",
+			thresholds: DefaultThresholds,
+			startTime:  time.Date(2022, 04, 01, 10, 10, 10, 0, time.UTC),
+			endTime:    time.Date(2022, 04, 02, 14, 10, 10, 0, time.UTC),
+			expected:   "weekend",
+		},
+		{
+			name:       "classifies sunday to monday as weekend",
+			thresholds: DefaultThresholds,
+			startTime:  time.Date(2022, 04, 03, 10, 10, 10, 0, time.UTC),
+			endTime:    time.Date(2022, 04, 04, 14, 10, 10, 0, time.UTC),
+			expected:   "weekend",
+		},
+		{
+			name:       "classifies short break",
+			thresholds: DefaultThresholds,
+			startTime:  time.Date(2022, 03, 29, 10, 10, 10, 0, time.UTC),
+			endTime:    time.Date(2022, 03, 30, 14, 10, 10, 0, time.UTC),
+			expected:   "short break",
+		},
+		{
+			name:       "classifies week trip",
+			thresholds: DefaultThresholds,
+			startTime:  time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+			endTime:    time.Date(2022, 03, 30, 14, 10, 10, 0, time.UTC),
+			expected:   "week",
+		},
+		{
+			name:       "classifies holiday",
+			thresholds: DefaultThresholds,
+			startTime:  time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+			endTime:    time.Date(2022, 04, 05, 14, 10, 10, 0, time.UTC),
+			expected:   "holiday",
+		},
+		{
+			name: "uses custom thresholds",
+			thresholds: Thresholds{
+				DayTrip:    time.Hour * 12,
+				ShortBreak: time.Hour * 24,
+				Week:       time.Hour * 48,
+			},
+			startTime: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 03, 30, 14, 10, 10, 0, time.UTC),
+			expected:  "holiday",
+		},
+		{
+			name:       "custom trip type takes precedence",
+			thresholds: DefaultThresholds,
+			custom: []TripType{
+				{
+					Name:    "ski trip",
+					Match:   All(DurationBetween(fourDays, 0), StartsOn(time.Saturday), EndsOn(time.Saturday)),
+					Phrases: []string{"Skiing in"},
+				},
+			},
+			startTime: time.Date(2022, 04, 02, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 04, 9, 14, 10, 10, 0, time.UTC),
+			expected:  "ski trip",
+		},
+		{
+			name:       "falls through unmatched custom trip type",
+			thresholds: DefaultThresholds,
+			custom: []TripType{
+				{
+					Name:    "ski trip",
+					Match:   All(DurationBetween(fourDays, 0), StartsOn(time.Saturday), EndsOn(time.Saturday)),
+					Phrases: []string{"Skiing in"},
+				},
+			},
+			startTime: time.Date(2022, 03, 28, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 04, 05, 14, 10, 10, 0, time.UTC),
+			expected:  "holiday",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				l := Location{
+					startTime: tt.startTime,
+					endTime:   tt.endTime,
+					location:  "London",
+				}
+
+				got, ok := NewClassifier(Config{Thresholds: tt.thresholds, TripTypes: tt.custom}).Classify(l)
+
+				assert.True(t, ok)
+				assert.Equal(t, tt.expected, got.Name)
+			},
+		)
+	}
+}
+
+func TestClassifier_GenerateTitles(t *testing.T) {
+	tests := []struct {
+		name      string
+		custom    []TripType
+		startTime time.Time
+		endTime   time.Time
+		expected  []string
+	}{
+		{
+			name:      "generates long weekend phrases",
+			startTime: time.Date(2022, 04, 01, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 04, 04, 14, 10, 10, 0, time.UTC),
+			expected: []string{
+				"A long weekend in London",
+				"A long weekend away to London",
+				"London in April",
+				"Visiting London in April",
+			},
+		},
+		{
+			name:      "generates short break phrases",
+			startTime: time.Date(2022, 03, 29, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 03, 30, 14, 10, 10, 0, time.UTC),
+			expected: []string{
+				"A short break to London",
+				"A night away in London",
+				"London in March",
+				"Visiting London in March",
+			},
+		},
+		{
+			name: "generates custom phrases",
+			custom: []TripType{
+				{
+					Name:    "work",
+					Match:   StartsOn(time.Tuesday),
+					Phrases: []string{"Working in"},
+				},
+			},
+			startTime: time.Date(2022, 03, 29, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 03, 30, 14, 10, 10, 0, time.UTC),
+			expected: []string{
+				"Working in London",
+				"London in March",
+				"Visiting London in March",
+			},
+		},
+		{
+			name: "generates shared phrases when nothing matches",
+			custom: []TripType{
+				{
+					Name:    "never",
+					Match:   StartsOn(),
+					Phrases: []string{"Never in"},
+				},
+			},
+			startTime: time.Date(2022, 03, 29, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 03, 30, 14, 10, 10, 0, time.UTC),
+			expected: []string{
+				"A short break to London",
+				"A night away in London",
+				"London in March",
+				"Visiting London in March",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				l := Location{
+					startTime: tt.startTime,
+					endTime:   tt.endTime,
+					location:  "London",
+				}
+
+				got := NewClassifier(Config{Thresholds: DefaultThresholds, TripTypes: tt.custom}).GenerateTitles(l)
+
+				assert.Equal(t, tt.expected, got)
+			},
+		)
+	}
+}
+
+func TestThresholds_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		thresholds  Thresholds
+		expectedErr error
+	}{
+		{
+			name:        "default thresholds are valid",
+			thresholds:  DefaultThresholds,
+			expectedErr: nil,
+		},
+		{
+			name: "errors on zero day trip",
+			thresholds: Thresholds{
+				ShortBreak: oneDay,
+				Week:       fourDays,
+			},
+			expectedErr: ErrInvalidThresholds,
+		},
+		{
+			name: "errors on descending thresholds",
+			thresholds: Thresholds{
+				DayTrip:    fourDays,
+				ShortBreak: oneDay * 2,
+				Week:       oneDay,
+			},
+			expectedErr: ErrInvalidThresholds,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := tt.thresholds.Validate()
+				assert.Equal(t, tt.expectedErr, err)
+			},
+		)
+	}
+}
@@ -0,0 +1,105 @@
+package categoriser
+
+import (
+	"time"
+)
+
+// dateSet is a set of calendar dates, the time of day is ignored.
+type dateSet map[date]struct{}
+
+// date is a calendar day which can be used as a key on a hashmap.
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func newDate(t time.Time) date {
+	year, month, day := t.Date()
+	return date{year: year, month: month, day: day}
+}
+
+func newDateSet(dates []time.Time) dateSet {
+	set := make(dateSet, len(dates))
+	for _, d := range dates {
+		set[newDate(d)] = struct{}{}
+	}
+	return set
+}
+
+func (d dateSet) contains(t time.Time) bool {
+	_, ok := d[newDate(t)]
+	return ok
+}
+
+// days returns every calendar day covered by the Location, from the day of startTime to the day of endTime.
+// endTime is converted into the time.Location of startTime so that both are compared on the same calendar.
+func (l Location) days() []time.Time {
+	start := l.startTime
+	end := l.endTime.In(start.Location())
+
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, start.Location())
+
+	days := make([]time.Time, 0)
+	for !day.After(last) {
+		days = append(days, day)
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return days
+}
+
+// Weekend matches a Location where every calendar day covered falls within a single Friday to Monday window,
+// and at least one of those days is a Saturday or Sunday.
+//
+// As the calendar days covered are contiguous, there is no need to check the order of the weekdays, a Location
+// can only reach a second weekend by covering a Tuesday, Wednesday or Thursday. Any day within holidays (i.e. bank
+// holidays) is treated as part of the window, extending it, so that Thursday to Sunday over Easter is still a weekend.
+func Weekend(holidays ...time.Time) Predicate {
+	bankHolidays := newDateSet(holidays)
+
+	return func(l Location) bool {
+		coversWeekend := false
+
+		for _, day := range l.days() {
+			switch day.Weekday() {
+			case time.Saturday, time.Sunday:
+				coversWeekend = true
+			case time.Friday, time.Monday:
+			default:
+				if !bankHolidays.contains(day) {
+					return false
+				}
+			}
+		}
+
+		return coversWeekend
+	}
+}
+
+// LongWeekend matches a Weekend which either covers four or more calendar days (Friday to Monday), or covers one of
+// the holidays, i.e. Saturday to a bank holiday Monday.
+func LongWeekend(holidays ...time.Time) Predicate {
+	bankHolidays := newDateSet(holidays)
+	weekend := Weekend(holidays...)
+
+	return func(l Location) bool {
+		if !weekend(l) {
+			return false
+		}
+
+		days := l.days()
+		if len(days) >= 4 {
+			return true
+		}
+
+		for _, day := range days {
+			if bankHolidays.contains(day) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
@@ -0,0 +1,179 @@
+package categoriser
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWeekend_Weekdays covers every start and end weekday combination within a single week, starting from
+// Sunday 3rd April 2022.
+func TestWeekend_Weekdays(t *testing.T) {
+	sunday := time.Date(2022, 04, 03, 10, 10, 10, 0, time.UTC)
+
+	tests := []struct {
+		start       time.Weekday
+		end         time.Weekday
+		weekend     bool
+		longWeekend bool
+	}{
+		{time.Sunday, time.Sunday, true, false},
+		{time.Sunday, time.Monday, true, false},
+		{time.Sunday, time.Tuesday, false, false},
+		{time.Sunday, time.Wednesday, false, false},
+		{time.Sunday, time.Thursday, false, false},
+		{time.Sunday, time.Friday, false, false},
+		{time.Sunday, time.Saturday, false, false},
+		{time.Monday, time.Monday, false, false},
+		{time.Monday, time.Tuesday, false, false},
+		{time.Monday, time.Wednesday, false, false},
+		{time.Monday, time.Thursday, false, false},
+		{time.Monday, time.Friday, false, false},
+		{time.Monday, time.Saturday, false, false},
+		{time.Monday, time.Sunday, false, false},
+		{time.Tuesday, time.Tuesday, false, false},
+		{time.Tuesday, time.Wednesday, false, false},
+		{time.Tuesday, time.Thursday, false, false},
+		{time.Tuesday, time.Friday, false, false},
+		{time.Tuesday, time.Saturday, false, false},
+		{time.Tuesday, time.Sunday, false, false},
+		{time.Tuesday, time.Monday, false, false},
+		{time.Wednesday, time.Wednesday, false, false},
+		{time.Wednesday, time.Thursday, false, false},
+		{time.Wednesday, time.Friday, false, false},
+		{time.Wednesday, time.Saturday, false, false},
+		{time.Wednesday, time.Sunday, false, false},
+		{time.Wednesday, time.Monday, false, false},
+		{time.Wednesday, time.Tuesday, false, false},
+		{time.Thursday, time.Thursday, false, false},
+		{time.Thursday, time.Friday, false, false},
+		{time.Thursday, time.Saturday, false, false},
+		{time.Thursday, time.Sunday, false, false},
+		{time.Thursday, time.Monday, false, false},
+		{time.Thursday, time.Tuesday, false, false},
+		{time.Thursday, time.Wednesday, false, false},
+		{time.Friday, time.Friday, false, false},
+		{time.Friday, time.Saturday, true, false},
+		{time.Friday, time.Sunday, true, false},
+		{time.Friday, time.Monday, true, true},
+		{time.Friday, time.Tuesday, false, false},
+		{time.Friday, time.Wednesday, false, false},
+		{time.Friday, time.Thursday, false, false},
+		{time.Saturday, time.Saturday, true, false},
+		{time.Saturday, time.Sunday, true, false},
+		{time.Saturday, time.Monday, true, false},
+		{time.Saturday, time.Tuesday, false, false},
+		{time.Saturday, time.Wednesday, false, false},
+		{time.Saturday, time.Thursday, false, false},
+		{time.Saturday, time.Friday, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(
+			fmt.Sprintf("%s to %s", tt.start, tt.end), func(t *testing.T) {
+				startTime := sunday.AddDate(0, 0, int(tt.start))
+				endTime := startTime.AddDate(0, 0, (int(tt.end)-int(tt.start)+7)%7).Add(time.Hour * 4)
+
+				l := Location{
+					startTime: startTime,
+					endTime:   endTime,
+					location:  "London",
+				}
+
+				assert.Equal(t, tt.weekend, Weekend()(l))
+				assert.Equal(t, tt.longWeekend, LongWeekend()(l))
+			},
+		)
+	}
+}
+
+func TestWeekend_Holidays(t *testing.T) {
+	tests := []struct {
+		name        string
+		startTime   time.Time
+		endTime     time.Time
+		holidays    []time.Time
+		weekend     bool
+		longWeekend bool
+	}{
+		{
+			name:        "saturday to sunday without holidays",
+			startTime:   time.Date(2022, 04, 16, 10, 10, 10, 0, time.UTC),
+			endTime:     time.Date(2022, 04, 17, 14, 10, 10, 0, time.UTC),
+			weekend:     true,
+			longWeekend: false,
+		},
+		{
+			name:      "saturday to bank holiday monday",
+			startTime: time.Date(2022, 04, 16, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 04, 18, 14, 10, 10, 0, time.UTC),
+			holidays: []time.Time{
+				time.Date(2022, 04, 15, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, 04, 18, 0, 0, 0, 0, time.UTC),
+			},
+			weekend:     true,
+			longWeekend: true,
+		},
+		{
+			name:        "thursday to sunday without holidays",
+			startTime:   time.Date(2022, 04, 14, 10, 10, 10, 0, time.UTC),
+			endTime:     time.Date(2022, 04, 17, 14, 10, 10, 0, time.UTC),
+			weekend:     false,
+			longWeekend: false,
+		},
+		{
+			name:      "bank holiday thursday to sunday",
+			startTime: time.Date(2022, 04, 14, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 04, 17, 14, 10, 10, 0, time.UTC),
+			holidays: []time.Time{
+				time.Date(2022, 04, 14, 0, 0, 0, 0, time.UTC),
+			},
+			weekend:     true,
+			longWeekend: true,
+		},
+		{
+			name:      "friday to bank holiday tuesday",
+			startTime: time.Date(2022, 12, 23, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 12, 27, 14, 10, 10, 0, time.UTC),
+			holidays: []time.Time{
+				time.Date(2022, 12, 26, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, 12, 27, 0, 0, 0, 0, time.UTC),
+			},
+			weekend:     true,
+			longWeekend: true,
+		},
+		{
+			name:      "holiday doesn't join two weekends",
+			startTime: time.Date(2022, 12, 23, 10, 10, 10, 0, time.UTC),
+			endTime:   time.Date(2022, 12, 31, 14, 10, 10, 0, time.UTC),
+			holidays: []time.Time{
+				time.Date(2022, 12, 26, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, 12, 27, 0, 0, 0, 0, time.UTC),
+			},
+			weekend:     false,
+			longWeekend: false,
+		},
+		{
+			name:        "end time in another timezone uses the start time calendar",
+			startTime:   time.Date(2022, 04, 16, 10, 10, 10, 0, time.UTC),
+			endTime:     time.Date(2022, 04, 18, 1, 10, 10, 0, time.FixedZone("UTC+2", 2*60*60)),
+			weekend:     true,
+			longWeekend: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				l := Location{
+					startTime: tt.startTime,
+					endTime:   tt.endTime,
+					location:  "London",
+				}
+
+				assert.Equal(t, tt.weekend, Weekend(tt.holidays...)(l))
+				assert.Equal(t, tt.longWeekend, LongWeekend(tt.holidays...)(l))
+			},
+		)
+	}
+}
@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/geo"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+)
+
+const (
+	// unvisited and noise are the labels given to photos which haven't been looked at yet,
+	// or don't belong to any cluster. Any label above noise is the cluster the photo belongs to.
+	unvisited = -1
+	noise     = 0
+)
+
+var (
+	// DefaultOptions are the Options used when no other configuration is provided. Photos within 500 metres and
+	// 6 hours of each other are neighbours, and it takes 3 photos to form a cluster.
+	DefaultOptions = Options{
+		Radius:    500,
+		Window:    time.Hour * 6,
+		MinPhotos: 3,
+	}
+)
+
+// Options configures DBSCAN. Radius is the distance in metres and Window the time either side of a photo in which
+// other photos are considered neighbours. MinPhotos is the number of photos, including itself, a photo needs within
+// its neighbourhood to start or grow a cluster.
+type Options struct {
+	Radius    float64
+	Window    time.Duration
+	MinPhotos int
+}
+
+// Cluster is a group of photos which were taken close together in both space and time.
+// Name is empty until the Cluster has been labelled.
+type Cluster struct {
+	Name   string
+	Photos []heap.Photo
+}
+
+// Centroid returns the mean latitude and longitude of the photos within the Cluster.
+func (c Cluster) Centroid() (float64, float64) {
+	var latitude, longitude float64
+
+	for _, photo := range c.Photos {
+		latitude += photo.Latitude
+		longitude += photo.Longitude
+	}
+
+	n := float64(len(c.Photos))
+
+	return latitude / n, longitude / n
+}
+
+// Coordinates returns the centroid of the Cluster formatted as "latitude, longitude", for when there is no better
+// name available.
+func (c Cluster) Coordinates() string {
+	latitude, longitude := c.Centroid()
+	return fmt.Sprintf("%.4f, %.4f", latitude, longitude)
+}
+
+// Labeler is used to find the best available name for a point, see consumer.Consumer.Label.
+type Labeler interface {
+	Label(ctx context.Context, latitude, longitude float64) (string, error)
+}
+
+// Unresolved returns the photos which geocoding couldn't find any address for, or failed to geocode.
+func Unresolved(photos []heap.Photo) []heap.Photo {
+	unresolved := make([]heap.Photo, 0)
+
+	for _, photo := range photos {
+		if photo.Status != heap.GeocodeResolved {
+			unresolved = append(unresolved, photo)
+		}
+	}
+
+	return unresolved
+}
+
+// DBSCAN groups photos into clusters using a spatio-temporal variant of DBSCAN, where two photos are only neighbours
+// when they are within both Options.Radius and Options.Window of each other. Photos which don't belong to any cluster
+// are returned as noise.
+//
+// Photos are sorted by timestamp first, so that finding the neighbours of a photo only needs to look at the photos
+// either side of it until they fall outside Options.Window.
+func DBSCAN(photos []heap.Photo, options Options) ([]Cluster, []heap.Photo) {
+	sorted := make([]heap.Photo, len(photos))
+	copy(sorted, photos)
+
+	sort.SliceStable(
+		sorted, func(i, j int) bool {
+			return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+		},
+	)
+
+	labels := make([]int, len(sorted))
+	for i := range labels {
+		labels[i] = unvisited
+	}
+
+	clusterID := noise
+
+	for i := range sorted {
+		if labels[i] != unvisited {
+			continue
+		}
+
+		neighbours := regionQuery(sorted, i, options)
+		if len(neighbours)+1 < options.MinPhotos {
+			labels[i] = noise
+			continue
+		}
+
+		clusterID++
+		labels[i] = clusterID
+
+		for len(neighbours) > 0 {
+			j := neighbours[0]
+			neighbours = neighbours[1:]
+
+			if labels[j] == noise {
+				labels[j] = clusterID
+			}
+
+			if labels[j] != unvisited {
+				continue
+			}
+
+			labels[j] = clusterID
+
+			expanded := regionQuery(sorted, j, options)
+			if len(expanded)+1 >= options.MinPhotos {
+				neighbours = append(neighbours, expanded...)
+			}
+		}
+	}
+
+	clusters := make([]Cluster, clusterID)
+	noisePhotos := make([]heap.Photo, 0)
+
+	for i, label := range labels {
+		if label == noise {
+			noisePhotos = append(noisePhotos, sorted[i])
+			continue
+		}
+
+		clusters[label-1].Photos = append(clusters[label-1].Photos, sorted[i])
+	}
+
+	return clusters, noisePhotos
+}
+
+// regionQuery returns the index of every photo, other than i, within Options.Radius and Options.Window of the photo
+// at i. photos must be sorted by timestamp.
+func regionQuery(photos []heap.Photo, i int, options Options) []int {
+	neighbours := make([]int, 0)
+
+	for j := i - 1; j >= 0 && photos[i].Timestamp.Sub(photos[j].Timestamp) <= options.Window; j-- {
+		if withinRadius(photos[i], photos[j], options.Radius) {
+			neighbours = append(neighbours, j)
+		}
+	}
+
+	for j := i + 1; j < len(photos) && photos[j].Timestamp.Sub(photos[i].Timestamp) <= options.Window; j++ {
+		if withinRadius(photos[i], photos[j], options.Radius) {
+			neighbours = append(neighbours, j)
+		}
+	}
+
+	return neighbours
+}
+
+func withinRadius(a, b heap.Photo, radius float64) bool {
+	return geo.Distance(a.Latitude, a.Longitude, b.Latitude, b.Longitude) <= radius
+}
+
+// Label is used to name each Cluster using the Labeler at the centroid of the Cluster. If the Labeler has no name for
+// the centroid, i.e. the photos were taken at sea, the coordinates of the centroid are used instead.
+//
+// Each Cluster is labelled independently, so a Cluster the Labeler returns an error for is named by its coordinates,
+// and the rest are still labelled. The errors for every Cluster are returned together.
+func Label(ctx context.Context, clusters []Cluster, labeler Labeler) error {
+	var errs labelErrors
+
+	for i := range clusters {
+		latitude, longitude := clusters[i].Centroid()
+
+		name, err := labeler.Label(ctx, latitude, longitude)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("labelling cluster %s: %w", clusters[i].Coordinates(), err))
+		}
+
+		if name == "" {
+			name = clusters[i].Coordinates()
+		}
+
+		clusters[i].Name = name
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// labelErrors is the errors from labelling several clusters, as one error.
+type labelErrors []error
+
+func (e labelErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether any of the errors is target, so that errors.Is can be used on labelErrors.
+func (e labelErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,221 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockLabeler struct {
+	label string
+	err   error
+}
+
+func (m mockLabeler) Label(ctx context.Context, latitude, longitude float64) (string, error) {
+	return m.label, m.err
+}
+
+func photoAt(hour int, latitude, longitude float64) heap.Photo {
+	return heap.Photo{
+		Timestamp: time.Date(2022, 04, 02, hour, 0, 0, 0, time.UTC),
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+}
+
+func TestDBSCAN(t *testing.T) {
+	hikeStart := photoAt(9, 54.4609, -3.0886)
+	hikeMiddle := photoAt(10, 54.4620, -3.0880)
+	hikeEnd := photoAt(11, 54.4630, -3.0870)
+	sailStart := photoAt(15, 50.7000, -1.3000)
+	sailEnd := photoAt(16, 50.7010, -1.3010)
+	sailLater := photoAt(17, 50.7015, -1.3005)
+	nextDayHike := heap.Photo{
+		Timestamp: time.Date(2022, 04, 03, 10, 0, 0, 0, time.UTC),
+		Latitude:  54.4620,
+		Longitude: -3.0880,
+	}
+
+	tests := []struct {
+		name          string
+		photos        []heap.Photo
+		options       Options
+		expected      []Cluster
+		expectedNoise []heap.Photo
+	}{
+		{
+			name:    "clusters photos close in space and time",
+			photos:  []heap.Photo{hikeEnd, sailStart, hikeStart, sailLater, hikeMiddle, sailEnd},
+			options: DefaultOptions,
+			expected: []Cluster{
+				{Photos: []heap.Photo{hikeStart, hikeMiddle, hikeEnd}},
+				{Photos: []heap.Photo{sailStart, sailEnd, sailLater}},
+			},
+			expectedNoise: []heap.Photo{},
+		},
+		{
+			name:          "photos at the same place on another day are noise",
+			photos:        []heap.Photo{hikeStart, hikeMiddle, nextDayHike},
+			options:       DefaultOptions,
+			expected:      []Cluster{},
+			expectedNoise: []heap.Photo{hikeStart, hikeMiddle, nextDayHike},
+		},
+		{
+			name:   "border photos join clusters",
+			photos: []heap.Photo{hikeStart, hikeMiddle, hikeEnd},
+			options: Options{
+				Radius:    150,
+				Window:    time.Hour,
+				MinPhotos: 3,
+			},
+			expected: []Cluster{
+				{Photos: []heap.Photo{hikeStart, hikeMiddle, hikeEnd}},
+			},
+			expectedNoise: []heap.Photo{},
+		},
+		{
+			name:          "no photos",
+			photos:        []heap.Photo{},
+			options:       DefaultOptions,
+			expected:      []Cluster{},
+			expectedNoise: []heap.Photo{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, gotNoise := DBSCAN(tt.photos, tt.options)
+
+				assert.Equal(t, tt.expected, got)
+				assert.Equal(t, tt.expectedNoise, gotNoise)
+			},
+		)
+	}
+}
+
+func TestUnresolved(t *testing.T) {
+	resolved := heap.Photo{
+		Timestamp: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+		Addresses: map[string]struct{}{
+			"London": {},
+		},
+		Status: heap.GeocodeResolved,
+	}
+	unresolved := heap.Photo{
+		Timestamp: time.Date(2022, 04, 02, 11, 0, 0, 0, time.UTC),
+		Status:    heap.GeocodeUnresolved,
+	}
+	failed := heap.Photo{
+		Timestamp: time.Date(2022, 04, 02, 12, 0, 0, 0, time.UTC),
+		Status:    heap.GeocodeFailed,
+	}
+
+	got := Unresolved([]heap.Photo{resolved, unresolved, failed})
+
+	assert.Equal(t, []heap.Photo{unresolved, failed}, got)
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		name        string
+		labeler     Labeler
+		expected    []Cluster
+		expectedErr string
+	}{
+		{
+			name:    "labels clusters",
+			labeler: mockLabeler{label: "Lake District National Park"},
+			expected: []Cluster{
+				{
+					Name:   "Lake District National Park",
+					Photos: []heap.Photo{photoAt(9, 54.4600, -3.0800), photoAt(10, 54.4620, -3.0820)},
+				},
+			},
+		},
+		{
+			name:    "labels clusters with coordinates when there is no name",
+			labeler: mockLabeler{},
+			expected: []Cluster{
+				{
+					Name:   "54.4610, -3.0810",
+					Photos: []heap.Photo{photoAt(9, 54.4600, -3.0800), photoAt(10, 54.4620, -3.0820)},
+				},
+			},
+		},
+		{
+			name:    "errors labelling clusters",
+			labeler: mockLabeler{err: errors.New("labeler error")},
+			expected: []Cluster{
+				{
+					Name:   "54.4610, -3.0810",
+					Photos: []heap.Photo{photoAt(9, 54.4600, -3.0800), photoAt(10, 54.4620, -3.0820)},
+				},
+			},
+			expectedErr: "labelling cluster",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				clusters := []Cluster{
+					{
+						Photos: []heap.Photo{photoAt(9, 54.4600, -3.0800), photoAt(10, 54.4620, -3.0820)},
+					},
+				}
+
+				err := Label(context.Background(), clusters, tt.labeler)
+
+				if tt.expectedErr != "" {
+					assert.Contains(t, err.Error(), tt.expectedErr)
+				} else {
+					assert.NoError(t, err)
+				}
+
+				assert.Equal(t, tt.expected, clusters)
+			},
+		)
+	}
+}
+
+// latitudeLabeler labels every latitude in labels, and returns an error for any other.
+type latitudeLabeler map[float64]string
+
+func (l latitudeLabeler) Label(ctx context.Context, latitude, longitude float64) (string, error) {
+	label, ok := l[latitude]
+	if !ok {
+		return "", fmt.Errorf("no label for %.4f", latitude)
+	}
+
+	return label, nil
+}
+
+func TestLabel_Independently(t *testing.T) {
+	clusters := []Cluster{
+		{Photos: []heap.Photo{photoAt(9, 10, 10)}},
+		{Photos: []heap.Photo{photoAt(10, 20, 20)}},
+		{Photos: []heap.Photo{photoAt(11, 30, 30)}},
+	}
+
+	err := Label(context.Background(), clusters, latitudeLabeler{20: "Somewhere"})
+
+	// the clusters after a failure are still labelled, and every failure is returned
+	assert.Equal(t, "10.0000, 10.0000", clusters[0].Name)
+	assert.Equal(t, "Somewhere", clusters[1].Name)
+	assert.Equal(t, "30.0000, 30.0000", clusters[2].Name)
+	assert.EqualError(
+		t, err,
+		"labelling cluster 10.0000, 10.0000: no label for 10.0000; labelling cluster 30.0000, 30.0000: no label for 30.0000",
+	)
+}
+
+func TestLabel_IsCancelled(t *testing.T) {
+	clusters := []Cluster{{Photos: []heap.Photo{photoAt(9, 10, 10)}}}
+
+	err := Label(context.Background(), clusters, mockLabeler{err: context.Canceled})
+	assert.ErrorIs(t, err, context.Canceled)
+}
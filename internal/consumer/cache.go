@@ -0,0 +1,154 @@
+package consumer
+
+import (
+	"sync"
+
+	"github.com/JackFazackerley/photo-grouping/internal/geo"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+)
+
+// lookupOutcome describes how geocodeCache.lookup found a geocodeResult.
+type lookupOutcome int
+
+const (
+	// fetched means a new request was made.
+	fetched lookupOutcome = iota
+	// coalesced means the result of an in-flight request for a point within the radius was shared.
+	coalesced
+	// reused means the result of a previous request for a point within the radius was reused.
+	reused
+)
+
+// String returns the name of the outcome, as used by metrics.CacheLookups.
+func (o lookupOutcome) String() string {
+	switch o {
+	case coalesced:
+		return "coalesced"
+	case reused:
+		return "reused"
+	default:
+		return "fetched"
+	}
+}
+
+// geocodeResult holds the parts of a heap.Photo which are set from geocoding, so that they can be shared between
+// photos taken close to each other. As the hashmaps are shared they must not be modified once cached.
+type geocodeResult struct {
+	addresses    map[string]struct{}
+	addressTypes map[string][]string
+	status       heap.GeocodeStatus
+}
+
+// cachedPoint is a point which has already been geocoded.
+type cachedPoint struct {
+	latitude  float64
+	longitude float64
+	result    geocodeResult
+}
+
+// call is an in-flight request, other lookups within radius wait on it rather than making their own.
+type call struct {
+	cachedPoint
+	wg  sync.WaitGroup
+	err error
+}
+
+// geocodeCache is used to avoid geocoding points within radius metres of each other more than once.
+//
+// Resolved points are stored by the geo.Cell they are in, so that only the cells nearby a point need to be searched.
+// Requests are coalesced per geo.Cell, the first lookup in a cell makes the request and any other lookup within
+// radius of it, in the same or a nearby cell, waits for it to finish and shares the result.
+type geocodeCache struct {
+	radius   float64
+	grid     geo.Grid
+	mu       *sync.Mutex
+	points   map[geo.Cell][]cachedPoint
+	inflight map[geo.Cell]*call
+}
+
+func newGeocodeCache(radius float64) *geocodeCache {
+	return &geocodeCache{
+		radius:   radius,
+		grid:     geo.NewGrid(radius),
+		mu:       &sync.Mutex{},
+		points:   make(map[geo.Cell][]cachedPoint),
+		inflight: make(map[geo.Cell]*call),
+	}
+}
+
+// lookup returns the geocodeResult for the point. A previous result within radius is used if there is one, otherwise
+// an in-flight request within radius is waited on, otherwise fetch is called. Only successful results are cached.
+func (g *geocodeCache) lookup(latitude, longitude float64, fetch func() (geocodeResult, error)) (geocodeResult, lookupOutcome, error) {
+	cell := g.grid.Cell(latitude, longitude)
+
+	g.mu.Lock()
+
+	if result, ok := g.nearest(latitude, longitude); ok {
+		g.mu.Unlock()
+		return result, reused, nil
+	}
+
+	for _, nearby := range g.grid.Nearby(latitude, longitude) {
+		if c, ok := g.inflight[nearby]; ok && g.within(c.latitude, c.longitude, latitude, longitude) {
+			g.mu.Unlock()
+			c.wg.Wait()
+			return c.result, coalesced, c.err
+		}
+	}
+
+	c := &call{
+		cachedPoint: cachedPoint{
+			latitude:  latitude,
+			longitude: longitude,
+		},
+	}
+	c.wg.Add(1)
+
+	// another request in the same cell may already be in-flight for a point outside radius, in which case this
+	// request isn't registered so that it doesn't replace it.
+	registered := false
+	if _, ok := g.inflight[cell]; !ok {
+		g.inflight[cell] = c
+		registered = true
+	}
+
+	g.mu.Unlock()
+
+	c.result, c.err = fetch()
+
+	g.mu.Lock()
+	if registered {
+		delete(g.inflight, cell)
+	}
+	if c.err == nil {
+		g.points[cell] = append(g.points[cell], c.cachedPoint)
+	}
+	g.mu.Unlock()
+
+	c.wg.Done()
+
+	return c.result, fetched, c.err
+}
+
+// nearest returns the result of the closest cached point within radius. g.mu must be held.
+func (g *geocodeCache) nearest(latitude, longitude float64) (geocodeResult, bool) {
+	var result geocodeResult
+	closest := -1.0
+
+	for _, cell := range g.grid.Nearby(latitude, longitude) {
+		for _, point := range g.points[cell] {
+			distance := geo.Distance(latitude, longitude, point.latitude, point.longitude)
+			if distance <= g.radius && (closest < 0 || distance < closest) {
+				result = point.result
+				closest = distance
+			}
+		}
+	}
+
+	return result, closest >= 0
+}
+
+// within reports whether the two points are within radius of each other.
+func (g *geocodeCache) within(lat1, lng1, lat2, lng2 float64) bool {
+	return geo.Distance(lat1, lng1, lat2, lng2) <= g.radius
+}
@@ -0,0 +1,189 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/stretchr/testify/assert"
+	"googlemaps.github.io/maps"
+)
+
+// countingGeocodingClient counts the number of calls to ReverseGeocode, and blocks each call until release is closed.
+type countingGeocodingClient struct {
+	mockGeocodingClient
+	calls   *int64
+	release chan struct{}
+}
+
+func (m countingGeocodingClient) ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	atomic.AddInt64(m.calls, 1)
+	<-m.release
+	return m.result, m.err
+}
+
+func TestGeocodeCache_lookup(t *testing.T) {
+	london := geocodeResult{
+		addresses: map[string]struct{}{
+			"London": {},
+		},
+		status: heap.GeocodeResolved,
+	}
+	paris := geocodeResult{
+		addresses: map[string]struct{}{
+			"Paris": {},
+		},
+		status: heap.GeocodeResolved,
+	}
+
+	tests := []struct {
+		name            string
+		latitude        float64
+		longitude       float64
+		fetchResult     geocodeResult
+		fetchErr        error
+		expected        geocodeResult
+		expectedOutcome lookupOutcome
+		expectedErr     error
+	}{
+		{
+			name:            "reuses result within radius",
+			latitude:        51.5080,
+			longitude:       -0.1280,
+			fetchResult:     paris,
+			expected:        london,
+			expectedOutcome: reused,
+		},
+		{
+			name:            "fetches result outside radius",
+			latitude:        48.8566,
+			longitude:       2.3522,
+			fetchResult:     paris,
+			expected:        paris,
+			expectedOutcome: fetched,
+		},
+		{
+			name:            "returns fetch error",
+			latitude:        48.8566,
+			longitude:       2.3522,
+			fetchErr:        errors.New("client error"),
+			expected:        geocodeResult{},
+			expectedOutcome: fetched,
+			expectedErr:     errors.New("client error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				cache := newGeocodeCache(200)
+
+				_, _, _ = cache.lookup(
+					51.5072, -0.1276, func() (geocodeResult, error) {
+						return london, nil
+					},
+				)
+
+				got, outcome, err := cache.lookup(
+					tt.latitude, tt.longitude, func() (geocodeResult, error) {
+						return tt.fetchResult, tt.fetchErr
+					},
+				)
+
+				assert.Equal(t, tt.expectedErr, err)
+				assert.Equal(t, tt.expectedOutcome, outcome)
+				assert.Equal(t, tt.expected, got)
+			},
+		)
+	}
+}
+
+func TestGeocodeCache_lookupDoesNotCacheErrors(t *testing.T) {
+	cache := newGeocodeCache(200)
+
+	_, _, err := cache.lookup(
+		51.5072, -0.1276, func() (geocodeResult, error) {
+			return geocodeResult{}, errors.New("client error")
+		},
+	)
+	assert.Error(t, err)
+
+	_, outcome, err := cache.lookup(
+		51.5072, -0.1276, func() (geocodeResult, error) {
+			return geocodeResult{status: heap.GeocodeUnresolved}, nil
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, fetched, outcome)
+}
+
+func TestConsumer_RunCoalescesRequests(t *testing.T) {
+	calls := int64(0)
+	release := make(chan struct{})
+
+	c := &Consumer{
+		client: countingGeocodingClient{
+			mockGeocodingClient: mockGeocodingClient{
+				result: []maps.GeocodingResult{
+					{
+						AddressComponents: []maps.AddressComponent{
+							{
+								LongName: "London",
+								Types:    []string{"locality"},
+							},
+						},
+					},
+				},
+			},
+			calls:   &calls,
+			release: release,
+		},
+		cache: newGeocodeCache(200),
+		stats: &Stats{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	photosChan := make(chan heap.Photo, 5)
+	for i := 0; i < 5; i++ {
+		photosChan <- heap.Photo{
+			Timestamp: time.Date(2022, 01, 03, 10, i, 0, 0, time.UTC),
+			Latitude:  51.5072 + float64(i)*0.0001,
+			Longitude: -0.1276,
+		}
+	}
+	close(photosChan)
+
+	photoHeap := heap.New()
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go c.Run(ctx, photoHeap, photosChan, wg)
+	}
+
+	// give every worker a chance to pick up a photo before the first request returns
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+
+	wg.Wait()
+
+	stats := c.Stats()
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	assert.Equal(t, int64(1), stats.Requests)
+	assert.Equal(t, int64(4), stats.Coalesced+stats.Reused)
+	assert.Equal(t, int64(5), stats.Geocoded)
+	assert.Equal(t, int64(0), stats.Failed)
+
+	for i := 0; i < 5; i++ {
+		photo, err := photoHeap.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, heap.GeocodeResolved, photo.Status)
+		assert.Equal(t, map[string]struct{}{"London": {}}, photo.Addresses)
+	}
+}
@@ -3,10 +3,17 @@ package consumer
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	"github.com/JackFazackerley/photo-grouping/internal/tracing"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"googlemaps.github.io/maps"
 )
 
@@ -24,21 +31,62 @@ var (
 	}
 )
 
-// geocodeClient is used so that unit tests can be easily written for the consumer
-type geocodeClient interface {
+const (
+	// maxRetries is the number of times a request which failed with a transient error is retried, see retryable.
+	maxRetries = 2
+	// defaultRetryBackoff is how long the first retry waits, each retry after it waits twice as long as the one before.
+	defaultRetryBackoff = time.Millisecond * 500
+)
+
+// GeocodeClient is used so that unit tests can be easily written for the consumer, and so that other geocoding
+// providers can be used in place of the maps.Client.
+type GeocodeClient interface {
 	ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error)
 }
 
+// Sink receives each photo once it has been geocoded. *heap.Heap is used to group every photo once geocoding is
+// complete, and categoriser.Grouper to group photos as they are geocoded.
+type Sink interface {
+	Push(photo heap.Photo)
+}
+
 // Consumer is used to hold the maps.Client so that concurrently running Consumer.
 // Run methods don't need the client passed in each time.
+//
+// cache is shared between each Consumer.Run so that photos taken close to each other are only geocoded once,
+// if cache is nil every photo is geocoded.
+//
+// Requests which fail with a transient error, such as a timeout or the API's rate limit, are retried maxRetries times,
+// waiting retryBackoff before the first retry.
 type Consumer struct {
-	client geocodeClient
+	client       GeocodeClient
+	cache        *geocodeCache
+	stats        *Stats
+	retryBackoff time.Duration
+}
+
+// Stats holds the number of geocoding requests made, and the number saved, by a Consumer.
+// Coalesced is the number of photos which shared an in-flight request and Reused is the number of photos which
+// reused the result of a previous request.
+//
+// Geocoded is the number of photos which have been geocoded, whether or not an address was found, and Failed is the
+// number of photos where the request failed. Stored is the number of photos which were already geocoded, such as
+// those loaded from a store, and are included in Geocoded.
+type Stats struct {
+	Requests  int64
+	Coalesced int64
+	Reused    int64
+	Geocoded  int64
+	Failed    int64
+	Stored    int64
 }
 
 // NewConsumer is used to create the maps.Client and return an instance of Consumer.
 // An API key is required in order to connect to the API.
+// Photos within reuseRadius metres of an already geocoded photo reuse its result, a reuseRadius of zero disables
+// this and geocodes every photo.
 // Options is a variadic argument allowing this package to be used with other maps.ClientOption(s).
-func NewConsumer(APIKey string, options ...maps.ClientOption) (*Consumer, error) {
+func NewConsumer(APIKey string, reuseRadius float64, options ...maps.ClientOption) (*Consumer, error) {
 	options = append(options, maps.WithAPIKey(APIKey))
 
 	client, err := maps.NewClient(options...)
@@ -46,14 +94,45 @@ func NewConsumer(APIKey string, options ...maps.ClientOption) (*Consumer, error)
 		return nil, fmt.Errorf("creating new maps client: %w", err)
 	}
 
-	return &Consumer{
-		client: client,
-	}, nil
+	return NewConsumerWithClient(client, reuseRadius), nil
+}
+
+// NewConsumerWithClient returns an instance of Consumer which uses the given GeocodeClient rather than creating a
+// maps.Client. See NewConsumer for reuseRadius.
+func NewConsumerWithClient(client GeocodeClient, reuseRadius float64) *Consumer {
+	consumer := &Consumer{
+		client:       client,
+		stats:        &Stats{},
+		retryBackoff: defaultRetryBackoff,
+	}
+
+	if reuseRadius > 0 {
+		consumer.cache = newGeocodeCache(reuseRadius)
+	}
+
+	return consumer
+}
+
+// Stats returns a snapshot of the geocoding requests made so far. Stats can be safely called while Consumer.Run is
+// running.
+func (c *Consumer) Stats() Stats {
+	if c.stats == nil {
+		return Stats{}
+	}
+
+	return Stats{
+		Requests:  atomic.LoadInt64(&c.stats.Requests),
+		Coalesced: atomic.LoadInt64(&c.stats.Coalesced),
+		Reused:    atomic.LoadInt64(&c.stats.Reused),
+		Geocoded:  atomic.LoadInt64(&c.stats.Geocoded),
+		Failed:    atomic.LoadInt64(&c.stats.Failed),
+		Stored:    atomic.LoadInt64(&c.stats.Stored),
+	}
 }
 
-// Run is used to consume parsed heap.Photo(s) from the channel and add to the heap.Heap.
+// Run is used to consume parsed heap.Photo(s) from the channel, geocode them, and push them to the Sink.
 // If the context or channel is closed this method will return early.
-func (c *Consumer) Run(ctx context.Context, photoHeap *heap.Heap, photos <-chan heap.Photo, wg *sync.WaitGroup) {
+func (c *Consumer) Run(ctx context.Context, sink Sink, photos <-chan heap.Photo, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for {
@@ -65,54 +144,241 @@ func (c *Consumer) Run(ctx context.Context, photoHeap *heap.Heap, photos <-chan
 				return
 			}
 
-			if err := c.getGeocoding(ctx, photoHeap, photo); err != nil {
+			if err := c.getGeocoding(ctx, sink, photo); err != nil {
 				log.WithError(err).Error("getting location")
 			}
 		}
 	}
 }
 
-// getGeocoding is used to communicate with Google's ReverseGeocode API,
+// getGeocoding is used to find the addresses of the photo, either from geocodeCache or by calling reverseGeocode.
+//
+// Once each heap.Photo's addresses have been stored it will then be pushed to the Sink.
+// Photos without any addresses, such as those taken in a national park or at sea, are still pushed with the
+// heap.GeocodeUnresolved status so that they can be clustered instead, see cluster.DBSCAN.
+//
+// if the request to the API fails, the photo is pushed with the heap.GeocodeFailed status and an error is returned.
+//
+// Photos which have already been geocoded, with either the heap.GeocodeResolved or heap.GeocodeUnresolved status, are
+// pushed as they are.
+func (c *Consumer) getGeocoding(ctx context.Context, sink Sink, photo heap.Photo) error {
+	if photo.Status == heap.GeocodeResolved || photo.Status == heap.GeocodeUnresolved {
+		c.stored()
+		sink.Push(photo)
+
+		return nil
+	}
+
+	ctx, span := tracing.Tracer().Start(
+		ctx, "getGeocoding", trace.WithAttributes(
+			tracing.Timestamp.String(photo.Timestamp.Format(time.RFC3339)),
+			tracing.Latitude.Float64(photo.Latitude),
+			tracing.Longitude.Float64(photo.Longitude),
+			tracing.Provider.String(providerName(c.client)),
+		),
+	)
+	defer span.End()
+
+	photo.Spans = tracing.AddSpan(photo.Spans, span.SpanContext())
+
+	// retries is only counted by the request this photo made, a photo which coalesced or reused a result made none.
+	var retries int
+
+	fetch := func() (geocodeResult, error) {
+		result, n, err := c.reverseGeocode(ctx, photo.Latitude, photo.Longitude)
+		retries = n
+
+		return result, err
+	}
+
+	var result geocodeResult
+	var err error
+
+	if c.cache == nil {
+		c.count(fetched)
+		span.SetAttributes(tracing.Outcome.String(fetched.String()))
+		result, err = fetch()
+	} else {
+		cell := c.cache.grid.Cell(photo.Latitude, photo.Longitude)
+		span.SetAttributes(tracing.Cell.String(fmt.Sprintf("%d,%d", cell.Row, cell.Column)))
+
+		var outcome lookupOutcome
+		result, outcome, err = c.cache.lookup(photo.Latitude, photo.Longitude, fetch)
+		c.count(outcome)
+		span.SetAttributes(tracing.Outcome.String(outcome.String()))
+	}
+
+	c.done(err)
+	span.SetAttributes(tracing.Retries.Int(retries))
+
+	if err != nil {
+		photo.Status = heap.GeocodeFailed
+		sink.Push(photo)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "getting location")
+		span.SetAttributes(tracing.Status.String(photo.Status.String()))
+
+		return fmt.Errorf("getting location: %w", err)
+	}
+
+	photo.Addresses = result.addresses
+	photo.AddressTypes = result.addressTypes
+	photo.Status = result.status
+
+	span.SetAttributes(tracing.Status.String(photo.Status.String()))
+
+	sink.Push(photo)
+
+	return nil
+}
+
+// reverseGeocode is used to communicate with Google's ReverseGeocode API,
 // the Latitude and Longitude are used to return the approximate location of the photo.
 // The results from the API are then processed and duplicated results are guaranteed to not occur on the heap.
 // Photo from the use of a hashmap.
-//
-// Once each heap.Photo's addresses have been stored it will then be pushed onto the heap.Heap and sorted.
-//
-// if the request to the API fails, an error is returned.
-func (c *Consumer) getGeocoding(ctx context.Context, photoHeap *heap.Heap, photo heap.Photo) error {
-	results, err := c.client.ReverseGeocode(
+// The number of times the request was retried is also returned.
+func (c *Consumer) reverseGeocode(ctx context.Context, latitude, longitude float64) (geocodeResult, int, error) {
+	results, retries, err := c.request(
 		ctx, &maps.GeocodingRequest{
 			LatLng: &maps.LatLng{
-				Lat: photo.Latitude,
-				Lng: photo.Longitude,
+				Lat: latitude,
+				Lng: longitude,
 			},
 			ResultType: []string{
 				"locality",
 			},
-		},
+		}, metrics.RequestLocality,
 	)
 	if err != nil {
-		return fmt.Errorf("getting location: %w", err)
+		return geocodeResult{}, retries, err
+	}
+
+	result := geocodeResult{
+		status: heap.GeocodeUnresolved,
 	}
 
 	if len(results) > 0 {
-		photo.Addresses = make(map[string]struct{})
+		result.addresses = make(map[string]struct{})
+		result.addressTypes = make(map[string][]string)
 
-		for _, result := range results {
-			for _, address := range result.AddressComponents {
+		for _, r := range results {
+			for _, address := range r.AddressComponents {
 				if acceptedTypes(address.Types) {
-					if _, ok := photo.Addresses[address.LongName]; !ok {
-						photo.Addresses[address.LongName] = struct{}{}
+					if _, ok := result.addresses[address.LongName]; !ok {
+						result.addresses[address.LongName] = struct{}{}
+						result.addressTypes[address.LongName] = address.Types
 					}
 				}
 			}
 		}
 
-		photoHeap.Push(photo)
+		if len(result.addresses) > 0 {
+			result.status = heap.GeocodeResolved
+		}
 	}
 
-	return nil
+	return result, retries, nil
+}
+
+// count increments the Stats field matching the outcome.
+func (c *Consumer) count(outcome lookupOutcome) {
+	metrics.CacheLookups.WithLabelValues(outcome.String()).Inc()
+
+	if c.stats == nil {
+		return
+	}
+
+	switch outcome {
+	case fetched:
+		atomic.AddInt64(&c.stats.Requests, 1)
+	case coalesced:
+		atomic.AddInt64(&c.stats.Coalesced, 1)
+	case reused:
+		atomic.AddInt64(&c.stats.Reused, 1)
+	}
+}
+
+// providerName returns the name of the geocoding provider used by client, for tracing.
+func providerName(client GeocodeClient) string {
+	if _, ok := client.(*maps.Client); ok {
+		return "google"
+	}
+
+	return fmt.Sprintf("%T", client)
+}
+
+// request makes a request to the GeocodeClient, recording the latency and any error of each attempt against kind. A
+// request which fails with a transient error is retried, and the number of retries is returned along with the result.
+func (c *Consumer) request(
+	ctx context.Context, r *maps.GeocodingRequest, kind string,
+) ([]maps.GeocodingResult, int, error) {
+	backoff := c.retryBackoff
+
+	for retries := 0; ; retries++ {
+		start := time.Now()
+		results, err := c.client.ReverseGeocode(ctx, r)
+		metrics.GeocodeDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+
+		if err == nil {
+			return results, retries, nil
+		}
+
+		metrics.GeocodeErrors.WithLabelValues(metrics.ErrorClass(err)).Inc()
+
+		if retries == maxRetries || !retryable(err) {
+			return nil, retries, err
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, retries, err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+}
+
+// retryable reports whether a request which failed with err may succeed if it's made again, i.e. it timed out, the
+// connection failed, or the API's rate limit was reached. Reaching the daily limit isn't retried, as it won't reset
+// for hours.
+func retryable(err error) bool {
+	switch metrics.ErrorClass(err) {
+	case "timeout", "network":
+		return true
+	case "quota":
+		return !strings.Contains(err.Error(), "OVER_DAILY_LIMIT")
+	default:
+		return false
+	}
+}
+
+// done increments Stats.Failed if err is not nil, otherwise Stats.Geocoded.
+func (c *Consumer) done(err error) {
+	if c.stats == nil {
+		return
+	}
+
+	if err != nil {
+		atomic.AddInt64(&c.stats.Failed, 1)
+		return
+	}
+
+	atomic.AddInt64(&c.stats.Geocoded, 1)
+}
+
+// stored increments Stats.Stored and Stats.Geocoded.
+func (c *Consumer) stored() {
+	if c.stats == nil {
+		return
+	}
+
+	atomic.AddInt64(&c.stats.Stored, 1)
+	atomic.AddInt64(&c.stats.Geocoded, 1)
 }
 
 // acceptedTypes is used to determine if any of the address types from locationTypes are present within
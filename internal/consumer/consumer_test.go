@@ -3,12 +3,19 @@ package consumer
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/tracing"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	"googlemaps.github.io/maps"
 )
 
@@ -25,6 +32,24 @@ func (m mockGeocodingClient) ReverseGeocode(ctx context.Context, r *maps.Geocodi
 	return m.result, m.err
 }
 
+// flakyGeocodingClient fails the first failures requests with err, then returns result.
+type flakyGeocodingClient struct {
+	result   []maps.GeocodingResult
+	err      error
+	failures int
+	requests *int
+}
+
+func (f flakyGeocodingClient) ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	*f.requests++
+
+	if *f.requests <= f.failures {
+		return nil, f.err
+	}
+
+	return f.result, nil
+}
+
 func TestNew(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -46,7 +71,7 @@ func TestNew(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				_, err := NewConsumer(tt.apiKey)
+				_, err := NewConsumer(tt.apiKey, 0)
 
 				if tt.expectedErr == "" {
 					assert.NoError(t, err)
@@ -62,7 +87,7 @@ func TestConsumer_Run(t *testing.T) {
 	tests := []struct {
 		name              string
 		photo             heap.Photo
-		client            geocodeClient
+		client            GeocodeClient
 		earlyChannelClose bool
 		expected          heap.Photo
 	}{
@@ -120,10 +145,17 @@ func TestConsumer_Run(t *testing.T) {
 					"England":        {},
 					"United Kingdom": {},
 				},
+				AddressTypes: map[string][]string{
+					"London":         {"locality"},
+					"Greater London": {"administrative_area_level_2"},
+					"England":        {"administrative_area_level_1"},
+					"United Kingdom": {"country"},
+				},
+				Status: heap.GeocodeResolved,
 			},
 		},
 		{
-			name: "nothing added to the heap on error",
+			name: "adds failed photo to the heap on error",
 			photo: heap.Photo{
 				Timestamp: time.Date(2022, 01, 03, 10, 11, 12, 0, time.UTC),
 				Latitude:  51.5072,
@@ -132,10 +164,15 @@ func TestConsumer_Run(t *testing.T) {
 			client: mockGeocodingClient{
 				err: errors.New("client error"),
 			},
-			expected: heap.Photo{},
+			expected: heap.Photo{
+				Timestamp: time.Date(2022, 01, 03, 10, 11, 12, 0, time.UTC),
+				Latitude:  51.5072,
+				Longitude: 0.1276,
+				Status:    heap.GeocodeFailed,
+			},
 		},
 		{
-			name: "exits on channel close",
+			name: "adds photo without results to the heap and exits on channel close",
 			photo: heap.Photo{
 				Timestamp: time.Date(2022, 01, 03, 10, 11, 12, 0, time.UTC),
 				Latitude:  51.5072,
@@ -143,7 +180,42 @@ func TestConsumer_Run(t *testing.T) {
 			},
 			client:            mockGeocodingClient{},
 			earlyChannelClose: true,
-			expected:          heap.Photo{},
+			expected: heap.Photo{
+				Timestamp: time.Date(2022, 01, 03, 10, 11, 12, 0, time.UTC),
+				Latitude:  51.5072,
+				Longitude: 0.1276,
+				Status:    heap.GeocodeUnresolved,
+			},
+		},
+		{
+			name: "adds already geocoded photo to the heap without geocoding it",
+			photo: heap.Photo{
+				Timestamp: time.Date(2022, 01, 03, 10, 11, 12, 0, time.UTC),
+				Latitude:  51.5072,
+				Longitude: 0.1276,
+				Addresses: map[string]struct{}{
+					"London": {},
+				},
+				AddressTypes: map[string][]string{
+					"London": {"locality"},
+				},
+				Status: heap.GeocodeResolved,
+			},
+			client: mockGeocodingClient{
+				err: errors.New("client error"),
+			},
+			expected: heap.Photo{
+				Timestamp: time.Date(2022, 01, 03, 10, 11, 12, 0, time.UTC),
+				Latitude:  51.5072,
+				Longitude: 0.1276,
+				Addresses: map[string]struct{}{
+					"London": {},
+				},
+				AddressTypes: map[string][]string{
+					"London": {"locality"},
+				},
+				Status: heap.GeocodeResolved,
+			},
 		},
 		{
 			name: "deduplicates on known addresses",
@@ -206,6 +278,13 @@ func TestConsumer_Run(t *testing.T) {
 					"England":        {},
 					"United Kingdom": {},
 				},
+				AddressTypes: map[string][]string{
+					"London":         {"locality"},
+					"Greater London": {"administrative_area_level_2"},
+					"England":        {"administrative_area_level_1"},
+					"United Kingdom": {"country"},
+				},
+				Status: heap.GeocodeResolved,
 			},
 		},
 		{
@@ -245,6 +324,10 @@ func TestConsumer_Run(t *testing.T) {
 				Addresses: map[string]struct{}{
 					"London": {},
 				},
+				AddressTypes: map[string][]string{
+					"London": {"locality"},
+				},
+				Status: heap.GeocodeResolved,
 			},
 		},
 	}
@@ -279,3 +362,144 @@ func TestConsumer_Run(t *testing.T) {
 		)
 	}
 }
+
+// recordSpans installs a global TracerProvider which records every span until the test finishes, when a noop
+// TracerProvider replaces it. The previous provider can't be restored, as before any is set it's the global delegate,
+// which ignores being set to itself.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	otel.SetTracerProvider(provider)
+
+	t.Cleanup(
+		func() {
+			otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		},
+	)
+
+	return recorder
+}
+
+// spanAttributes returns the attributes of span keyed by name.
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestConsumer_getGeocodingSpan(t *testing.T) {
+	tests := []struct {
+		name            string
+		client          GeocodeClient
+		reuseRadius     float64
+		expectedStatus  string
+		expectedCell    bool
+		expectedError   bool
+		expectedRetries int64
+	}{
+		{
+			name: "records resolved photo",
+			client: mockGeocodingClient{
+				result: []maps.GeocodingResult{
+					{
+						AddressComponents: []maps.AddressComponent{
+							{
+								LongName: "London",
+								Types:    []string{"locality"},
+							},
+						},
+					},
+				},
+			},
+			reuseRadius:    200,
+			expectedStatus: "resolved",
+			expectedCell:   true,
+		},
+		{
+			name: "records failed photo",
+			client: mockGeocodingClient{
+				err: errors.New("client error"),
+			},
+			expectedStatus: "failed",
+			expectedError:  true,
+		},
+		{
+			name: "records retries of a request over the rate limit",
+			client: flakyGeocodingClient{
+				result: []maps.GeocodingResult{
+					{
+						AddressComponents: []maps.AddressComponent{
+							{
+								LongName: "London",
+								Types:    []string{"locality"},
+							},
+						},
+					},
+				},
+				err:      errors.New("maps: OVER_QUERY_LIMIT - "),
+				failures: 2,
+				requests: new(int),
+			},
+			expectedStatus:  "resolved",
+			expectedRetries: 2,
+		},
+		{
+			name: "records failed photo once retries run out",
+			client: flakyGeocodingClient{
+				err:      errors.New("maps: OVER_QUERY_LIMIT - "),
+				failures: maxRetries + 1,
+				requests: new(int),
+			},
+			expectedStatus:  "failed",
+			expectedError:   true,
+			expectedRetries: maxRetries,
+		},
+		{
+			name: "doesn't retry a request over the daily limit",
+			client: flakyGeocodingClient{
+				err:      errors.New("maps: OVER_DAILY_LIMIT - "),
+				failures: 1,
+				requests: new(int),
+			},
+			expectedStatus: "failed",
+			expectedError:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				recorder := recordSpans(t)
+
+				c := NewConsumerWithClient(tt.client, tt.reuseRadius)
+				c.retryBackoff = time.Millisecond
+
+				_ = c.getGeocoding(
+					context.Background(), heap.New(), heap.Photo{
+						Timestamp: time.Date(2022, 01, 03, 10, 0, 0, 0, time.UTC),
+						Latitude:  51.5072,
+						Longitude: -0.1276,
+					},
+				)
+
+				spans := recorder.Ended()
+				assert.Len(t, spans, 1)
+				assert.Equal(t, "getGeocoding", spans[0].Name())
+
+				attributes := spanAttributes(spans[0])
+				assert.Equal(t, tt.expectedStatus, attributes[tracing.Status].AsString())
+				assert.Equal(t, "fetched", attributes[tracing.Outcome].AsString())
+				assert.Equal(t, fmt.Sprintf("%T", tt.client), attributes[tracing.Provider].AsString())
+				assert.Equal(t, tt.expectedRetries, attributes[tracing.Retries].AsInt64())
+				assert.Equal(t, 51.5072, attributes[tracing.Latitude].AsFloat64())
+
+				_, ok := attributes[tracing.Cell]
+				assert.Equal(t, tt.expectedCell, ok)
+
+				assert.Equal(t, tt.expectedError, len(spans[0].Events()) > 0)
+			},
+		)
+	}
+}
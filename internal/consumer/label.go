@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/JackFazackerley/photo-grouping/internal/geo"
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	"googlemaps.github.io/maps"
+)
+
+const (
+	// nearbyDistance is how far, in metres, a point can be from the centre of a town before it is labelled
+	// "near" the town rather than in it.
+	nearbyDistance = 5000
+)
+
+var (
+	// labelTypes are the address component types used to label a point, in order of preference. Parks and natural
+	// features are preferred, as a photo taken on a hike is better described by the park than the nearest town.
+	labelTypes = []string{
+		"park",
+		"natural_feature",
+		"tourist_attraction",
+		"locality",
+		"postal_town",
+		"administrative_area_level_3",
+		"administrative_area_level_2",
+		"administrative_area_level_1",
+		"country",
+	}
+
+	// townTypes are the label types which can be prefixed with "near" when the point is far from the town.
+	townTypes = []string{
+		"locality",
+		"postal_town",
+	}
+)
+
+// Label is used to find the best available name for a point which couldn't be geocoded to a locality, such as a
+// national park or the sea. Unlike getGeocoding, the request isn't limited to localities.
+//
+// If the best name is a town whose centre is further than nearbyDistance from the point, the name is returned as
+// "near X". If no name could be found an empty string is returned.
+func (c *Consumer) Label(ctx context.Context, latitude, longitude float64) (string, error) {
+	results, _, err := c.request(
+		ctx, &maps.GeocodingRequest{
+			LatLng: &maps.LatLng{
+				Lat: latitude,
+				Lng: longitude,
+			},
+		}, metrics.RequestLabel,
+	)
+	if err != nil {
+		return "", fmt.Errorf("getting label: %w", err)
+	}
+
+	for _, labelType := range labelTypes {
+		for _, result := range results {
+			for _, address := range result.AddressComponents {
+				if !containsType(address.Types, labelType) {
+					continue
+				}
+
+				if containsType(townTypes, labelType) && containsType(result.Types, labelType) {
+					distance := geo.Distance(
+						latitude, longitude, result.Geometry.Location.Lat, result.Geometry.Location.Lng,
+					)
+					if distance > nearbyDistance {
+						return fmt.Sprintf("near %s", address.LongName), nil
+					}
+				}
+
+				return address.LongName, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// containsType reports whether locationType is within locationTypes.
+func containsType(locationTypes []string, locationType string) bool {
+	for _, t := range locationTypes {
+		if t == locationType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"googlemaps.github.io/maps"
+)
+
+func TestConsumer_Label(t *testing.T) {
+	tests := []struct {
+		name        string
+		latitude    float64
+		longitude   float64
+		client      GeocodeClient
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:      "prefers park over locality",
+			latitude:  54.4609,
+			longitude: -3.0886,
+			client: mockGeocodingClient{
+				result: []maps.GeocodingResult{
+					{
+						AddressComponents: []maps.AddressComponent{
+							{
+								LongName: "Ambleside",
+								Types:    []string{"locality"},
+							},
+							{
+								LongName: "Lake District National Park",
+								Types:    []string{"park"},
+							},
+						},
+					},
+				},
+			},
+			expected: "Lake District National Park",
+		},
+		{
+			name:      "labels near a distant town",
+			latitude:  54.4609,
+			longitude: -3.0886,
+			client: mockGeocodingClient{
+				result: []maps.GeocodingResult{
+					{
+						AddressComponents: []maps.AddressComponent{
+							{
+								LongName: "Keswick",
+								Types:    []string{"locality"},
+							},
+						},
+						Geometry: maps.AddressGeometry{
+							Location: maps.LatLng{Lat: 54.6013, Lng: -3.1347},
+						},
+						Types: []string{"locality", "political"},
+					},
+				},
+			},
+			expected: "near Keswick",
+		},
+		{
+			name:      "labels within a close town",
+			latitude:  54.6013,
+			longitude: -3.1347,
+			client: mockGeocodingClient{
+				result: []maps.GeocodingResult{
+					{
+						AddressComponents: []maps.AddressComponent{
+							{
+								LongName: "Keswick",
+								Types:    []string{"locality"},
+							},
+						},
+						Geometry: maps.AddressGeometry{
+							Location: maps.LatLng{Lat: 54.6013, Lng: -3.1347},
+						},
+						Types: []string{"locality", "political"},
+					},
+				},
+			},
+			expected: "Keswick",
+		},
+		{
+			name:      "falls back to administrative area",
+			latitude:  54.4609,
+			longitude: -3.0886,
+			client: mockGeocodingClient{
+				result: []maps.GeocodingResult{
+					{
+						AddressComponents: []maps.AddressComponent{
+							{
+								LongName: "Cumbria",
+								Types:    []string{"administrative_area_level_2"},
+							},
+							{
+								LongName: "United Kingdom",
+								Types:    []string{"country"},
+							},
+						},
+					},
+				},
+			},
+			expected: "Cumbria",
+		},
+		{
+			name:      "returns empty label without results",
+			latitude:  0,
+			longitude: 0,
+			client:    mockGeocodingClient{},
+			expected:  "",
+		},
+		{
+			name:      "errors getting label",
+			latitude:  0,
+			longitude: 0,
+			client: mockGeocodingClient{
+				err: errors.New("client error"),
+			},
+			expectedErr: "getting label",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				c := &Consumer{
+					client: tt.client,
+				}
+
+				got, err := c.Label(context.Background(), tt.latitude, tt.longitude)
+
+				if tt.expectedErr != "" {
+					assert.Contains(t, err.Error(), tt.expectedErr)
+				} else {
+					assert.NoError(t, err)
+				}
+
+				assert.Equal(t, tt.expected, got)
+			},
+		)
+	}
+}
@@ -8,15 +8,27 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	"github.com/JackFazackerley/photo-grouping/internal/tracing"
 	"github.com/araddon/dateparse"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Locator finds where a photo was taken from its timestamp, such as from a GPS track log, for rows which only have a
+// timestamp.
+type Locator interface {
+	Locate(timestamp time.Time) (latitude, longitude float64, ok bool)
+}
+
 // Reader is used to read a file, parse and output rows to a channel.
+// If locator is nil, rows without a latitude and longitude are rejected.
 type Reader struct {
-	file io.ReadCloser
+	file    io.ReadCloser
+	locator Locator
 }
 
 // NewReader is used to open a file from the given filePath and will return a new instance of Reader.
@@ -31,6 +43,55 @@ func NewReader(filePath string) (*Reader, error) {
 	}, nil
 }
 
+// NewReaderFrom returns a new instance of Reader which reads from r rather than opening a file. If r is an
+// io.ReadCloser, Reader.Close will close it.
+func NewReaderFrom(r io.Reader) *Reader {
+	file, ok := r.(io.ReadCloser)
+	if !ok {
+		file = io.NopCloser(r)
+	}
+
+	return &Reader{
+		file: file,
+	}
+}
+
+// NewReaderWithLocator returns a new instance of Reader which reads from r, see NewReaderFrom. Rows with only a timestamp
+// are located with locator, and rejected if it can't locate them.
+func NewReaderWithLocator(r io.Reader, locator Locator) *Reader {
+	reader := NewReaderFrom(r)
+	reader.locator = locator
+
+	return reader
+}
+
+// CountRows returns the number of rows in a CSV which ReadCSV would accept, so that progress can be reported against
+// a total before the rows are read. Rows with only a timestamp are counted if locator can locate them, locator may be
+// nil.
+func CountRows(r io.Reader, locator Locator) (int64, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var rows int64
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return rows, fmt.Errorf("counting rows: %w", err)
+		}
+
+		if _, _, _, reason, _ := parse(row, locator); reason == "" {
+			rows++
+		}
+	}
+}
+
 // ReadCSV is used to read the contents of Reader.file and return a channel.
 // Rows are parses one at a time and a heap.Photo is created, then pushed onto the channel.
 //
@@ -42,6 +103,8 @@ func NewReader(filePath string) (*Reader, error) {
 // has been cancelled, if it hasn't the line will be read, otherwise the go routine exits
 func (r *Reader) ReadCSV(ctx context.Context) <-chan heap.Photo {
 	reader := csv.NewReader(r.file)
+	// rows are checked by parseRow, as rows with only a timestamp can be mixed with rows with a location.
+	reader.FieldsPerRecord = -1
 	photoChan := make(chan heap.Photo)
 
 	go func(ctx context.Context) {
@@ -49,6 +112,8 @@ func (r *Reader) ReadCSV(ctx context.Context) <-chan heap.Photo {
 			close(photoChan)
 		}()
 
+		line := 0
+
 		for {
 			select {
 			case <-ctx.Done():
@@ -59,31 +124,14 @@ func (r *Reader) ReadCSV(ctx context.Context) <-chan heap.Photo {
 					return
 				}
 
-				if len(row) == 3 {
-					timestamp, err := dateparse.ParseAny(row[0])
-					if err != nil {
-						logrus.WithError(err).Error("parsing timestamp")
-						continue
-					}
-
-					latitude, err := strconv.ParseFloat(row[1], 64)
-					if err != nil {
-						logrus.WithError(err).Error("parsing latitude")
-						continue
-					}
-
-					longitude, err := strconv.ParseFloat(row[2], 64)
-					if err != nil {
-						logrus.WithError(err).Error("parsing longitude")
-						continue
-					}
-
-					photoChan <- heap.Photo{
-						Timestamp: timestamp,
-						Longitude: longitude,
-						Latitude:  latitude,
-					}
+				line++
+
+				photo, ok := parseRow(ctx, line, row, r.locator)
+				if !ok {
+					continue
 				}
+
+				photoChan <- photo
 			}
 		}
 	}(ctx)
@@ -91,6 +139,85 @@ func (r *Reader) ReadCSV(ctx context.Context) <-chan heap.Photo {
 	return photoChan
 }
 
+// parseRow is used to parse a single row into a heap.Photo, recording a ReadCSV span and metrics for the row.
+// If the row can't be parsed, the reason is logged and false is returned. A row with only a timestamp is located
+// with locator, if it isn't nil.
+func parseRow(ctx context.Context, line int, row []string, locator Locator) (heap.Photo, bool) {
+	_, span := tracing.Tracer().Start(ctx, "ReadCSV", trace.WithAttributes(tracing.Row.Int(line)))
+	defer span.End()
+
+	metrics.RowsRead.Inc()
+
+	timestamp, latitude, longitude, reason, err := parse(row, locator)
+	if reason == "" {
+		return located(span, timestamp, latitude, longitude), true
+	}
+
+	metrics.RowsRejected.WithLabelValues(reason).Inc()
+	span.SetAttributes(tracing.Rejected.String(reason))
+
+	if err != nil {
+		span.RecordError(err)
+		logrus.WithError(err).Errorf("parsing %s", reason)
+	}
+
+	if reason == metrics.ReasonUnlocated {
+		logrus.WithField("timestamp", timestamp.Format(time.RFC3339)).Warn("no position for photo")
+	}
+
+	return heap.Photo{}, false
+}
+
+// parse returns the timestamp and position of a row. If the row can't be parsed, the reason it was rejected is
+// returned, one of the metrics.Reason constants, along with the error if there was one.
+func parse(row []string, locator Locator) (time.Time, float64, float64, string, error) {
+	if len(row) != 3 && (len(row) != 1 || locator == nil) {
+		return time.Time{}, 0, 0, metrics.ReasonMalformed, nil
+	}
+
+	timestamp, err := dateparse.ParseAny(row[0])
+	if err != nil {
+		return time.Time{}, 0, 0, metrics.ReasonTimestamp, err
+	}
+
+	if len(row) == 1 {
+		latitude, longitude, ok := locator.Locate(timestamp)
+		if !ok {
+			return timestamp, 0, 0, metrics.ReasonUnlocated, nil
+		}
+
+		return timestamp, latitude, longitude, "", nil
+	}
+
+	latitude, err := strconv.ParseFloat(row[1], 64)
+	if err != nil {
+		return time.Time{}, 0, 0, metrics.ReasonLatitude, err
+	}
+
+	longitude, err := strconv.ParseFloat(row[2], 64)
+	if err != nil {
+		return time.Time{}, 0, 0, metrics.ReasonLongitude, err
+	}
+
+	return timestamp, latitude, longitude, "", nil
+}
+
+// located returns the heap.Photo for a parsed row, recording it on the span.
+func located(span trace.Span, timestamp time.Time, latitude, longitude float64) heap.Photo {
+	span.SetAttributes(
+		tracing.Timestamp.String(timestamp.Format(time.RFC3339)),
+		tracing.Latitude.Float64(latitude),
+		tracing.Longitude.Float64(longitude),
+	)
+
+	return heap.Photo{
+		Timestamp: timestamp,
+		Longitude: longitude,
+		Latitude:  latitude,
+		Spans:     tracing.AddSpan(nil, span.SpanContext()),
+	}
+}
+
 // Close wraps the Reader.file Close, so that the file may be safely closed.
 func (r *Reader) Close() error {
 	return r.file.Close()
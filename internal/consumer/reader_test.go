@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/tracing"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -69,6 +70,34 @@ func TestNewReader(t *testing.T) {
 	}
 }
 
+func TestNewReaderFrom(t *testing.T) {
+	tests := []struct {
+		name     string
+		reader   io.Reader
+		expected io.ReadCloser
+	}{
+		{
+			name:     "keeps read closer",
+			reader:   &os.File{},
+			expected: &os.File{},
+		},
+		{
+			name:     "wraps reader",
+			reader:   strings.NewReader(""),
+			expected: io.NopCloser(strings.NewReader("")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := NewReaderFrom(tt.reader)
+
+				assert.IsType(t, tt.expected, got.file)
+			},
+		)
+	}
+}
+
 func TestReader_ReadCSV(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -146,3 +175,135 @@ func TestReader_ReadCSV(t *testing.T) {
 		)
 	}
 }
+
+// mockLocator locates every photo taken on 2020-03-30 at the same position.
+type mockLocator struct{}
+
+func (m mockLocator) Locate(timestamp time.Time) (float64, float64, bool) {
+	if timestamp.YearDay() != time.Date(2020, 03, 30, 0, 0, 0, 0, time.UTC).YearDay() {
+		return 0, 0, false
+	}
+
+	return 40.728808, -73.996106, true
+}
+
+func TestReader_ReadCSVWithLocator(t *testing.T) {
+	tests := []struct {
+		name     string
+		locator  Locator
+		expected []heap.Photo
+	}{
+		{
+			name:    "locates rows with only a timestamp",
+			locator: mockLocator{},
+			expected: []heap.Photo{
+				{
+					Timestamp: time.Date(2020, 03, 30, 14, 12, 19, 0, time.UTC),
+					Latitude:  40.728808,
+					Longitude: -73.996106,
+				},
+				{
+					Timestamp: time.Date(2020, 03, 30, 14, 20, 10, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+				},
+			},
+		},
+		{
+			name:    "rejects rows with only a timestamp without a locator",
+			locator: nil,
+			expected: []heap.Photo{
+				{
+					Timestamp: time.Date(2020, 03, 30, 14, 20, 10, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				r := NewReaderWithLocator(
+					strings.NewReader("2020-03-30 14:12:19\n2020-03-30 14:20:10,51.5072,-0.1276\n2020-03-31 09:00:00"),
+					tt.locator,
+				)
+
+				got := make([]heap.Photo, 0)
+
+				for photo := range r.ReadCSV(context.Background()) {
+					got = append(got, photo)
+				}
+
+				assert.Equal(t, tt.expected, got)
+			},
+		)
+	}
+}
+
+func TestReader_ReadCSVSpans(t *testing.T) {
+	recorder := recordSpans(t)
+
+	r := NewReaderFrom(strings.NewReader("2020-03-30 14:12:19,40.728808,-73.996106\nnot_a_time,40.728808,-73.996106"))
+
+	for range r.ReadCSV(context.Background()) {
+	}
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+
+	first := spanAttributes(spans[0])
+	assert.Equal(t, "ReadCSV", spans[0].Name())
+	assert.Equal(t, int64(1), first[tracing.Row].AsInt64())
+	assert.Equal(t, "2020-03-30T14:12:19Z", first[tracing.Timestamp].AsString())
+
+	second := spanAttributes(spans[1])
+	assert.Equal(t, int64(2), second[tracing.Row].AsInt64())
+	assert.Equal(t, "timestamp", second[tracing.Rejected].AsString())
+}
+
+func TestCountRows(t *testing.T) {
+	tests := []struct {
+		name         string
+		fileContents string
+		locator      Locator
+		expected     int64
+	}{
+		{
+			name:         "counts rows",
+			fileContents: "2020-03-30 14:12:19,40.728808,-73.996106\n2020-03-30 14:20:10,40.728656,-73.998790\n",
+			expected:     2,
+		},
+		{
+			name:         "counts rows without trailing newline",
+			fileContents: "2020-03-30 14:12:19,40.728808,-73.996106\n2020-03-30 14:20:10,40.728656,-73.998790",
+			expected:     2,
+		},
+		{
+			name:         "skips rows which are rejected",
+			fileContents: "2020-03-30 14:12:19,40.728808\nnot_a_time,40.728808,-73.996106\n2020-03-30 14:12:19,40.728808,-73.996106\n",
+			expected:     1,
+		},
+		{
+			name:         "counts rows located by the locator",
+			fileContents: "2020-03-30 14:12:19\n2020-03-31 14:12:19\n",
+			locator:      mockLocator{},
+			expected:     1,
+		},
+		{
+			name:         "counts empty file",
+			fileContents: "",
+			expected:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := CountRows(strings.NewReader(tt.fileContents), tt.locator)
+
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got)
+			},
+		)
+	}
+}
@@ -0,0 +1,177 @@
+// Package exif reads the capture time and location of a photo from its EXIF, without any external tools. The format
+// of a file is detected from its contents rather than its extension; JPEG, PNG, HEIF, TIFF and the RAW formats built
+// on TIFF (DNG, CR2, NEF, ARW, ORF, RW2), as well as Canon's CR3 and Fujifilm's RAF, are supported.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	rafSignature = []byte("FUJIFILMCCD-RAW")
+)
+
+// Metadata is what's known about a photo from its EXIF. Taken is zero if the capture time isn't known, and Located is
+// false if the location isn't.
+type Metadata struct {
+	Taken     time.Time
+	Latitude  float64
+	Longitude float64
+	Located   bool
+}
+
+// Read reads the EXIF of a file of size bytes, returning false if the file has no EXIF, or isn't a supported format.
+func Read(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	header := make([]byte, 16)
+
+	n, err := r.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Metadata{}, false, fmt.Errorf("reading header: %w", err)
+	}
+
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0xff, 0xd8}):
+		return readJPEG(r, size)
+	case bytes.HasPrefix(header, pngSignature):
+		return readPNG(r, size)
+	case bytes.HasPrefix(header, rafSignature):
+		return readRAF(r, size)
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		if string(header[8:12]) == "crx " {
+			return readCR3(r, size)
+		}
+
+		return readHEIF(r, size)
+	}
+
+	if _, _, err := parseTIFF(r); err == nil {
+		metadata, err := readTIFF(r)
+		return metadata, err == nil, err
+	}
+
+	return Metadata{}, false, nil
+}
+
+// readTIFF reads the EXIF of a TIFF, which starts at the start of r, following IFD0 to its Exif and GPS IFDs.
+func readTIFF(r io.ReaderAt) (Metadata, error) {
+	t, offset, err := parseTIFF(r)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	ifd0, err := t.ifd(offset)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	exifIFD, err := t.pointer(ifd0, tagExifIFD)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("reading exif ifd: %w", err)
+	}
+
+	gpsIFD, err := t.pointer(ifd0, tagGPSIFD)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("reading gps ifd: %w", err)
+	}
+
+	metadata := Metadata{}
+
+	if metadata.Taken, err = t.taken(exifIFD); err != nil {
+		return Metadata{}, err
+	}
+
+	if metadata.Latitude, metadata.Longitude, metadata.Located, err = t.location(gpsIFD); err != nil {
+		return Metadata{}, err
+	}
+
+	return metadata, nil
+}
+
+// readJPEG reads the EXIF of a JPEG, which is a TIFF in an APP1 segment starting with "Exif\0\0". Only the segments
+// before the image data are read.
+func readJPEG(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	marker := make([]byte, 4)
+	identifier := make([]byte, 6)
+
+	for offset := int64(2); offset+4 <= size; {
+		if _, err := r.ReadAt(marker, offset); err != nil {
+			return Metadata{}, false, fmt.Errorf("reading jpeg: %w", err)
+		}
+
+		if marker[0] != 0xff {
+			return Metadata{}, false, errors.New("reading jpeg: invalid marker")
+		}
+
+		// the start of scan, or end of image, marker means there are no more metadata segments.
+		if marker[1] == 0xda || marker[1] == 0xd9 {
+			break
+		}
+
+		length := int64(binary.BigEndian.Uint16(marker[2:]))
+
+		if marker[1] == 0xe1 && length > 8 {
+			if _, err := r.ReadAt(identifier, offset+4); err != nil {
+				return Metadata{}, false, fmt.Errorf("reading jpeg: %w", err)
+			}
+
+			if string(identifier) == "Exif\x00\x00" {
+				metadata, err := readTIFF(io.NewSectionReader(r, offset+10, length-8))
+				return metadata, err == nil, err
+			}
+		}
+
+		offset += 2 + length
+	}
+
+	return Metadata{}, false, nil
+}
+
+// readPNG reads the EXIF of a PNG, which is a TIFF in the eXIf chunk.
+func readPNG(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	header := make([]byte, 8)
+
+	for offset := int64(len(pngSignature)); offset+8 <= size; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return Metadata{}, false, fmt.Errorf("reading png: %w", err)
+		}
+
+		length := int64(binary.BigEndian.Uint32(header))
+
+		switch string(header[4:]) {
+		case "eXIf":
+			metadata, err := readTIFF(io.NewSectionReader(r, offset+8, length))
+			return metadata, err == nil, err
+		case "IEND":
+			return Metadata{}, false, nil
+		}
+
+		// each chunk is its length and type, its data, then a CRC.
+		offset += 12 + length
+	}
+
+	return Metadata{}, false, nil
+}
+
+// readRAF reads the EXIF of a Fujifilm RAF, which is in the JPEG preview embedded in it. The header holds the offset
+// and length of the preview.
+func readRAF(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 84); err != nil {
+		return Metadata{}, false, fmt.Errorf("reading raf header: %w", err)
+	}
+
+	offset, length := int64(binary.BigEndian.Uint32(header)), int64(binary.BigEndian.Uint32(header[4:]))
+	if offset+length > size {
+		return Metadata{}, false, errors.New("reading raf header: invalid preview offset")
+	}
+
+	return readJPEG(io.NewSectionReader(r, offset, length), length)
+}
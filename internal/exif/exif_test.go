@@ -0,0 +1,320 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEntry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+func asciiEntry(tag uint16, value string) testEntry {
+	return testEntry{tag: tag, kind: typeASCII, count: uint32(len(value) + 1), value: append([]byte(value), 0)}
+}
+
+func rationalEntry(order binary.ByteOrder, tag uint16, values ...uint32) testEntry {
+	value := make([]byte, 4*len(values))
+	for i, v := range values {
+		order.PutUint32(value[i*4:], v)
+	}
+
+	return testEntry{tag: tag, kind: typeRational, count: uint32(len(values) / 2), value: value}
+}
+
+// buildIFDs returns a TIFF with ifd0, followed by each of pointed, followed by their values. pointers are the tags added
+// to ifd0 which point to each of pointed.
+func buildIFDs(order binary.ByteOrder, ifd0 []testEntry, pointers []uint16, pointed ...[]testEntry) []byte {
+	ifd0 = append([]testEntry(nil), ifd0...)
+	first := len(ifd0)
+
+	for _, tag := range pointers {
+		ifd0 = append(ifd0, testEntry{tag: tag, kind: typeLong, count: 1, value: make([]byte, 4)})
+	}
+
+	ifds := append([][]testEntry{ifd0}, pointed...)
+
+	offsets := make([]uint32, len(ifds))
+	dataOffset := uint32(8)
+
+	for i, entries := range ifds {
+		offsets[i] = dataOffset
+		dataOffset += uint32(2 + 12*len(entries) + 4)
+	}
+
+	for i := range pointers {
+		order.PutUint32(ifd0[first+i].value, offsets[i+1])
+	}
+
+	file := &bytes.Buffer{}
+	data := &bytes.Buffer{}
+
+	if order == binary.LittleEndian {
+		file.WriteString("II")
+	} else {
+		file.WriteString("MM")
+	}
+
+	_ = binary.Write(file, order, uint16(magicTIFF))
+	_ = binary.Write(file, order, uint32(8))
+
+	for _, entries := range ifds {
+		_ = binary.Write(file, order, uint16(len(entries)))
+
+		for _, e := range entries {
+			_ = binary.Write(file, order, e.tag)
+			_ = binary.Write(file, order, e.kind)
+			_ = binary.Write(file, order, e.count)
+
+			if len(e.value) <= 4 {
+				file.Write(append(e.value, make([]byte, 4-len(e.value))...))
+				continue
+			}
+
+			_ = binary.Write(file, order, dataOffset+uint32(data.Len()))
+			data.Write(e.value)
+		}
+
+		_ = binary.Write(file, order, uint32(0))
+	}
+
+	return append(file.Bytes(), data.Bytes()...)
+}
+
+func exifEntries() []testEntry {
+	return []testEntry{
+		asciiEntry(tagDateTimeOriginal, "2022:04:02 10:52:59"),
+		asciiEntry(tagOffsetTimeOriginal, "+01:00"),
+		asciiEntry(tagSubSecTimeOriginal, "12"),
+	}
+}
+
+func gpsEntries(order binary.ByteOrder) []testEntry {
+	return []testEntry{
+		asciiEntry(tagGPSLatitudeRef, "N"),
+		rationalEntry(order, tagGPSLatitude, 51, 1, 30, 1, 2592, 100),
+		asciiEntry(tagGPSLongitudeRef, "W"),
+		rationalEntry(order, tagGPSLongitude, 0, 1, 7, 1, 3936, 100),
+	}
+}
+
+func buildTIFF(order binary.ByteOrder) []byte {
+	return buildIFDs(order, nil, []uint16{tagExifIFD, tagGPSIFD}, exifEntries(), gpsEntries(order))
+}
+
+func buildBox(kind string, contents ...[]byte) []byte {
+	data := bytes.Join(contents, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)+8))
+	copy(header[4:], kind)
+
+	return append(header, data...)
+}
+
+func buildJPEG(tiff []byte) []byte {
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(app1)+2))
+
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x04, 0x00, 0x00, 0xff, 0xe1}
+	jpeg = append(jpeg, length...)
+	jpeg = append(jpeg, app1...)
+
+	return append(jpeg, 0xff, 0xda, 0x00, 0x02, 0xff, 0xd9)
+}
+
+// buildHEIF returns a HEIF with the EXIF as item 2, after the meta box.
+func buildHEIF(tiff []byte) []byte {
+	ftyp := buildBox("ftyp", []byte("heic"), make([]byte, 4), []byte("mif1heic"))
+
+	infe := func(id uint16, kind string) []byte {
+		contents := []byte{2, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint16(contents[4:], id)
+
+		return buildBox("infe", contents, []byte(kind), []byte{0})
+	}
+	iinf := buildBox("iinf", []byte{0, 0, 0, 0, 0, 2}, infe(1, "hvc1"), infe(2, "Exif"))
+
+	item := append([]byte{0, 0, 0, 6}, []byte("Exif\x00\x00")...)
+	item = append(item, tiff...)
+
+	// version 1, 4 byte offsets and lengths, no base offsets and 2 items.
+	iloc := func(exifOffset uint32) []byte {
+		contents := []byte{1, 0, 0, 0, 0x44, 0x00, 0, 2}
+		for id, location := range [][2]uint32{{0, 0}, {exifOffset, uint32(len(item))}} {
+			extent := make([]byte, 16)
+			binary.BigEndian.PutUint16(extent, uint16(id+1))
+			binary.BigEndian.PutUint16(extent[6:], 1)
+			binary.BigEndian.PutUint32(extent[8:], location[0])
+			binary.BigEndian.PutUint32(extent[12:], location[1])
+			contents = append(contents, extent...)
+		}
+
+		return buildBox("iloc", contents)
+	}
+
+	meta := func(exifOffset uint32) []byte {
+		return buildBox("meta", make([]byte, 4), buildBox("hdlr", make([]byte, 24)), iinf, iloc(exifOffset))
+	}
+
+	exifOffset := uint32(len(ftyp) + len(meta(0)) + 8)
+
+	return bytes.Join([][]byte{ftyp, meta(exifOffset), buildBox("mdat", item)}, nil)
+}
+
+func buildCR3(order binary.ByteOrder) []byte {
+	ftyp := buildBox("ftyp", []byte("crx "), make([]byte, 4), []byte("crx isom"))
+	canon := buildBox(
+		"uuid", canonUUID,
+		buildBox("CNCV", []byte("CanonCR3_001/00.09.00/00.00.00")),
+		buildBox("CMT1", buildIFDs(order, []testEntry{asciiEntry(0x010f, "Canon")}, nil)),
+		buildBox("CMT2", buildIFDs(order, exifEntries(), nil)),
+		buildBox("CMT4", buildIFDs(order, gpsEntries(order), nil)),
+	)
+
+	return bytes.Join([][]byte{ftyp, buildBox("moov", buildBox("mvhd", make([]byte, 100)), canon)}, nil)
+}
+
+func TestRead(t *testing.T) {
+	taken := time.Date(2022, 04, 02, 10, 52, 59, 120000000, time.FixedZone("", 60*60))
+	located := Metadata{Taken: taken, Latitude: 51.5072, Longitude: -0.1276, Located: true}
+
+	png := append([]byte(nil), pngSignature...)
+	png = append(png, buildChunk("IHDR", make([]byte, 13))...)
+	png = append(png, buildChunk("eXIf", buildTIFF(binary.BigEndian))...)
+	png = append(png, buildChunk("IEND", nil)...)
+
+	jpeg := buildJPEG(buildTIFF(binary.LittleEndian))
+	raf := append([]byte("FUJIFILMCCD-RAW 0201FF383501"), make([]byte, 100-28)...)
+	binary.BigEndian.PutUint32(raf[84:], 100)
+	binary.BigEndian.PutUint32(raf[88:], uint32(len(jpeg)))
+	raf = append(raf, jpeg...)
+
+	tests := []struct {
+		name     string
+		file     []byte
+		expected Metadata
+		ok       bool
+		err      string
+	}{
+		{
+			name:     "reads little-endian tiff",
+			file:     buildTIFF(binary.LittleEndian),
+			expected: located,
+			ok:       true,
+		},
+		{
+			name:     "reads big-endian tiff",
+			file:     buildTIFF(binary.BigEndian),
+			expected: located,
+			ok:       true,
+		},
+		{
+			name:     "reads jpeg",
+			file:     jpeg,
+			expected: located,
+			ok:       true,
+		},
+		{
+			name:     "reads png",
+			file:     png,
+			expected: located,
+			ok:       true,
+		},
+		{
+			name:     "reads heif",
+			file:     buildHEIF(buildTIFF(binary.BigEndian)),
+			expected: located,
+			ok:       true,
+		},
+		{
+			name:     "reads cr3",
+			file:     buildCR3(binary.LittleEndian),
+			expected: located,
+			ok:       true,
+		},
+		{
+			name:     "reads raf",
+			file:     raf,
+			expected: located,
+			ok:       true,
+		},
+		{
+			name: "reads time without offset as utc",
+			file: buildIFDs(
+				binary.LittleEndian, nil, []uint16{tagExifIFD}, []testEntry{asciiEntry(tagDateTimeOriginal, "2022:04:02 10:52:59")},
+			),
+			expected: Metadata{Taken: time.Date(2022, 04, 02, 10, 52, 59, 0, time.UTC)},
+			ok:       true,
+		},
+		{
+			name: "ignores unset time",
+			file: buildIFDs(
+				binary.LittleEndian, nil, []uint16{tagExifIFD}, []testEntry{asciiEntry(tagDateTimeOriginal, "0000:00:00 00:00:00")},
+			),
+			expected: Metadata{},
+			ok:       true,
+		},
+		{
+			name:     "jpeg without exif",
+			file:     []byte{0xff, 0xd8, 0xff, 0xda, 0x00, 0x02, 0xff, 0xd9},
+			expected: Metadata{},
+			ok:       false,
+		},
+		{
+			name:     "unknown format",
+			file:     []byte("not a photo"),
+			expected: Metadata{},
+			ok:       false,
+		},
+		{
+			name: "rejects invalid coordinate",
+			file: buildIFDs(
+				binary.LittleEndian, nil, []uint16{tagGPSIFD}, []testEntry{
+					rationalEntry(binary.LittleEndian, tagGPSLatitude, 51, 1, 30, 0, 0, 1),
+					rationalEntry(binary.LittleEndian, tagGPSLongitude, 0, 1, 0, 1, 0, 1),
+				},
+			),
+			err: "reading gps coordinate: rational with a denominator of 0",
+		},
+		{
+			name: "rejects truncated tiff",
+			file: buildTIFF(binary.LittleEndian)[:40],
+			err:  "reading exif ifd: reading ifd: EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, ok, err := Read(bytes.NewReader(tt.file), int64(len(tt.file)))
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+					return
+				}
+
+				assert.NoError(t, err)
+				assert.Equal(t, tt.ok, ok)
+				assert.True(t, tt.expected.Taken.Equal(got.Taken), got.Taken)
+				assert.Equal(t, tt.expected.Taken.Format(time.RFC3339Nano), got.Taken.Format(time.RFC3339Nano))
+				assert.InDelta(t, tt.expected.Latitude, got.Latitude, 1e-9)
+				assert.InDelta(t, tt.expected.Longitude, got.Longitude, 1e-9)
+				assert.Equal(t, tt.expected.Located, got.Located)
+			},
+		)
+	}
+}
+
+func buildChunk(kind string, data []byte) []byte {
+	chunk := make([]byte, 8)
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], kind)
+
+	return append(append(chunk, data...), 0, 0, 0, 0)
+}
@@ -0,0 +1,280 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/JackFazackerley/photo-grouping/internal/isobmff"
+)
+
+// canonUUID is the user type of the box in a CR3's moov box which holds its EXIF.
+var canonUUID = []byte{0x85, 0xc0, 0xb6, 0x87, 0x82, 0x0f, 0x11, 0xe0, 0x81, 0x11, 0xf4, 0xce, 0x46, 0x2b, 0x6a, 0x48}
+
+// readHEIF reads the EXIF of a HEIF file, such as a HEIC from an iPhone. The EXIF is an item of type Exif, which is
+// found in the iinf box of the meta box, and located in the file by the iloc box.
+func readHEIF(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	top, err := isobmff.Boxes(r, 0, size)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	meta, ok := isobmff.Find(top, "meta")
+	if !ok {
+		return Metadata{}, false, nil
+	}
+
+	// meta is a full box, so its children follow its version and flags.
+	children, err := isobmff.Boxes(r, meta.Offset+4, meta.Offset+meta.Size)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	iinf, iinfOK := isobmff.Find(children, "iinf")
+	iloc, ilocOK := isobmff.Find(children, "iloc")
+	if !iinfOK || !ilocOK {
+		return Metadata{}, false, nil
+	}
+
+	id, ok, err := exifItem(r, iinf)
+	if err != nil || !ok {
+		return Metadata{}, false, err
+	}
+
+	offset, length, ok, err := itemLocation(r, iloc, id)
+	if err != nil || !ok {
+		return Metadata{}, false, err
+	}
+
+	// the item starts with the offset from the end of the offset itself to the TIFF header, skipping "Exif\0\0".
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header, int64(offset)); err != nil {
+		return Metadata{}, false, fmt.Errorf("reading exif item: %w", err)
+	}
+
+	start := offset + 4 + uint64(binary.BigEndian.Uint32(header))
+	if start >= offset+length || offset+length > uint64(size) {
+		return Metadata{}, false, errors.New("reading exif item: invalid offset")
+	}
+
+	metadata, err := readTIFF(io.NewSectionReader(r, int64(start), int64(offset+length-start)))
+
+	return metadata, err == nil, err
+}
+
+// exifItem returns the ID of the item of type Exif in the iinf box.
+func exifItem(r io.ReaderAt, iinf isobmff.Box) (uint64, bool, error) {
+	data, err := iinf.Bytes(r)
+	if err != nil {
+		return 0, false, err
+	}
+
+	c := isobmff.NewCursor(data)
+
+	countSize := 2
+	if c.Uint(1) > 0 {
+		countSize = 4
+	}
+	c.Uint(3)
+	c.Uint(countSize)
+
+	if c.Err() != nil {
+		return 0, false, fmt.Errorf("reading iinf box: %w", c.Err())
+	}
+
+	entries, err := isobmff.Boxes(bytes.NewReader(data), int64(c.Pos()), int64(len(data)))
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, infe := range entries {
+		if infe.Type != "infe" {
+			continue
+		}
+
+		c := isobmff.NewCursor(data[infe.Offset : infe.Offset+infe.Size])
+
+		// item types are only in versions 2 and later.
+		version := c.Uint(1)
+		if version < 2 {
+			continue
+		}
+		c.Uint(3)
+
+		idSize := 2
+		if version > 2 {
+			idSize = 4
+		}
+
+		id := c.Uint(idSize)
+		c.Uint(2)
+		kind := c.Uint(4)
+
+		if c.Err() != nil {
+			return 0, false, fmt.Errorf("reading infe box: %w", c.Err())
+		}
+
+		if kind == uint64(binary.BigEndian.Uint32([]byte("Exif"))) {
+			return id, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// itemLocation returns the offset in the file and length of the item with id, from the iloc box. Only the first extent
+// of the item is used, as the EXIF is never split.
+func itemLocation(r io.ReaderAt, iloc isobmff.Box, id uint64) (uint64, uint64, bool, error) {
+	data, err := iloc.Bytes(r)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	c := isobmff.NewCursor(data)
+
+	version := c.Uint(1)
+	c.Uint(3)
+
+	sizes := c.Uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0xf)
+
+	sizes = c.Uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), 0
+	if version == 1 || version == 2 {
+		indexSize = int(sizes & 0xf)
+	}
+
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+
+	count := c.Uint(idSize)
+
+	for i := uint64(0); i < count && c.Err() == nil; i++ {
+		itemID := c.Uint(idSize)
+
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			method = c.Uint(2) & 0xf
+		}
+
+		c.Uint(2)
+		baseOffset := c.Uint(baseOffsetSize)
+		extents := c.Uint(2)
+
+		var offset, length uint64
+
+		for j := uint64(0); j < extents && c.Err() == nil; j++ {
+			c.Uint(indexSize)
+			extentOffset, extentLength := c.Uint(offsetSize), c.Uint(lengthSize)
+
+			if j == 0 {
+				offset, length = baseOffset+extentOffset, extentLength
+			}
+		}
+
+		if c.Err() == nil && itemID == id && extents > 0 {
+			// items can also be stored within the idat box, which isn't used for EXIF.
+			if method != 0 {
+				return 0, 0, false, fmt.Errorf("reading iloc box: unsupported construction method %d", method)
+			}
+
+			return offset, length, true, nil
+		}
+	}
+
+	if c.Err() != nil {
+		return 0, 0, false, fmt.Errorf("reading iloc box: %w", c.Err())
+	}
+
+	return 0, 0, false, nil
+}
+
+// readCR3 reads the EXIF of a Canon CR3. Rather than a single TIFF, a CR3 has a TIFF for each IFD in a uuid box within
+// its moov box; CMT2 holds the Exif IFD and CMT4 holds the GPS IFD.
+func readCR3(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	top, err := isobmff.Boxes(r, 0, size)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	moov, ok := isobmff.Find(top, "moov")
+	if !ok {
+		return Metadata{}, false, nil
+	}
+
+	children, err := isobmff.Boxes(r, moov.Offset, moov.Offset+moov.Size)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	for _, b := range children {
+		if b.Type != "uuid" || b.Size < 16 {
+			continue
+		}
+
+		userType := make([]byte, 16)
+		if _, err := r.ReadAt(userType, b.Offset); err != nil {
+			return Metadata{}, false, fmt.Errorf("reading uuid box: %w", err)
+		}
+
+		if !bytes.Equal(userType, canonUUID) {
+			continue
+		}
+
+		cmt, err := isobmff.Boxes(r, b.Offset+16, b.Offset+b.Size)
+		if err != nil {
+			return Metadata{}, false, err
+		}
+
+		return readCMT(r, cmt)
+	}
+
+	return Metadata{}, false, nil
+}
+
+func readCMT(r io.ReaderAt, cmt []isobmff.Box) (Metadata, bool, error) {
+	metadata := Metadata{}
+	found := false
+
+	if b, ok := isobmff.Find(cmt, "CMT2"); ok {
+		t, offset, err := parseTIFF(b.Section(r))
+		if err != nil {
+			return Metadata{}, false, fmt.Errorf("reading CMT2: %w", err)
+		}
+
+		exifIFD, err := t.ifd(offset)
+		if err != nil {
+			return Metadata{}, false, fmt.Errorf("reading CMT2: %w", err)
+		}
+
+		if metadata.Taken, err = t.taken(exifIFD); err != nil {
+			return Metadata{}, false, err
+		}
+
+		found = true
+	}
+
+	if b, ok := isobmff.Find(cmt, "CMT4"); ok {
+		t, offset, err := parseTIFF(b.Section(r))
+		if err != nil {
+			return Metadata{}, false, fmt.Errorf("reading CMT4: %w", err)
+		}
+
+		gpsIFD, err := t.ifd(offset)
+		if err != nil {
+			return Metadata{}, false, fmt.Errorf("reading CMT4: %w", err)
+		}
+
+		if metadata.Latitude, metadata.Longitude, metadata.Located, err = t.location(gpsIFD); err != nil {
+			return Metadata{}, false, err
+		}
+
+		found = true
+	}
+
+	return metadata, found, nil
+}
@@ -0,0 +1,295 @@
+package exif
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// The tags which are read, the date tags are in the Exif IFD and the location tags are in the GPS IFD.
+const (
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagSubSecTimeOriginal = 0x9291
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+)
+
+// The magic numbers which follow the byte order of a TIFF header. Olympus and Panasonic RAW files are TIFFs with their
+// own magic numbers.
+const (
+	magicTIFF      = 42
+	magicOlympus   = 0x4f52
+	magicOlympusS  = 0x5352
+	magicPanasonic = 0x55
+)
+
+// The TIFF field types which are read.
+const (
+	typeASCII    = 2
+	typeLong     = 4
+	typeRational = 5
+	typeIFD      = 13
+)
+
+// maxValueSize is the largest value which is read, the values read are never more than a few bytes.
+const maxValueSize = 64 * 1024
+
+var (
+	errInvalidTIFF = errors.New("invalid tiff header")
+
+	// typeSizes is the size in bytes of each TIFF field type.
+	typeSizes = map[uint16]int64{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4}
+)
+
+// entry is a single field in an IFD. value holds the field's value if it fits in 4 bytes, otherwise the offset to it.
+type entry struct {
+	kind  uint16
+	count uint32
+	value [4]byte
+}
+
+// ifd maps the tags in an IFD to their entries, a nil ifd has no entries.
+type ifd map[uint16]entry
+
+// tiff reads the IFDs of a TIFF structure, which holds the EXIF of every supported format. Offsets are from the start of
+// r, which is the TIFF header.
+type tiff struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// parseTIFF reads the TIFF header at the start of r, returning the offset to IFD0.
+func parseTIFF(r io.ReaderAt) (*tiff, uint32, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, 0, fmt.Errorf("reading tiff header: %w", err)
+	}
+
+	t := &tiff{
+		r: r,
+	}
+
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, errInvalidTIFF
+	}
+
+	switch t.order.Uint16(header[2:]) {
+	case magicTIFF, magicOlympus, magicOlympusS, magicPanasonic:
+	default:
+		return nil, 0, errInvalidTIFF
+	}
+
+	return t, t.order.Uint32(header[4:]), nil
+}
+
+// ifd reads the IFD at offset.
+func (t *tiff) ifd(offset uint32) (ifd, error) {
+	count := make([]byte, 2)
+	if _, err := t.r.ReadAt(count, int64(offset)); err != nil {
+		return nil, fmt.Errorf("reading ifd: %w", err)
+	}
+
+	data := make([]byte, int(t.order.Uint16(count))*12)
+	if _, err := t.r.ReadAt(data, int64(offset)+2); err != nil {
+		return nil, fmt.Errorf("reading ifd: %w", err)
+	}
+
+	entries := make(ifd, len(data)/12)
+
+	for raw := data; len(raw) >= 12; raw = raw[12:] {
+		e := entry{
+			kind:  t.order.Uint16(raw[2:]),
+			count: t.order.Uint32(raw[4:]),
+		}
+		copy(e.value[:], raw[8:12])
+
+		entries[t.order.Uint16(raw)] = e
+	}
+
+	return entries, nil
+}
+
+// pointer reads the IFD pointed to by tag in entries, such as the Exif IFD from IFD0. If entries doesn't have tag, nil
+// is returned.
+func (t *tiff) pointer(entries ifd, tag uint16) (ifd, error) {
+	e, ok := entries[tag]
+	if !ok {
+		return nil, nil
+	}
+
+	if e.kind != typeLong && e.kind != typeIFD {
+		return nil, fmt.Errorf("invalid type %d for ifd pointer", e.kind)
+	}
+
+	return t.ifd(t.order.Uint32(e.value[:]))
+}
+
+func (t *tiff) bytes(e entry) ([]byte, error) {
+	size, ok := typeSizes[e.kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown tiff type %d", e.kind)
+	}
+
+	size *= int64(e.count)
+	if size <= 4 {
+		return e.value[:size], nil
+	}
+
+	if size > maxValueSize {
+		return nil, errors.New("tiff value too large")
+	}
+
+	value := make([]byte, size)
+	if _, err := t.r.ReadAt(value, int64(t.order.Uint32(e.value[:]))); err != nil {
+		return nil, fmt.Errorf("reading tiff value: %w", err)
+	}
+
+	return value, nil
+}
+
+func (t *tiff) ascii(e entry) (string, error) {
+	if e.kind != typeASCII {
+		return "", fmt.Errorf("invalid type %d for ascii", e.kind)
+	}
+
+	value, err := t.bytes(e)
+	if err != nil {
+		return "", err
+	}
+
+	if i := strings.IndexByte(string(value), 0); i >= 0 {
+		value = value[:i]
+	}
+
+	return strings.TrimSpace(string(value)), nil
+}
+
+func (t *tiff) rationals(e entry) ([]float64, error) {
+	if e.kind != typeRational {
+		return nil, fmt.Errorf("invalid type %d for rational", e.kind)
+	}
+
+	value, err := t.bytes(e)
+	if err != nil {
+		return nil, err
+	}
+
+	rationals := make([]float64, 0, e.count)
+
+	for ; len(value) >= 8; value = value[8:] {
+		denominator := t.order.Uint32(value[4:])
+		if denominator == 0 {
+			return nil, errors.New("rational with a denominator of 0")
+		}
+
+		rationals = append(rationals, float64(t.order.Uint32(value))/float64(denominator))
+	}
+
+	return rationals, nil
+}
+
+// taken returns the time in DateTimeOriginal of the Exif IFD, with its subseconds and offset if they're set. A time
+// without an offset is treated as UTC, and a zero time is returned if it isn't set, which cameras with an unset clock
+// write as zeros or spaces.
+func (t *tiff) taken(exifIFD ifd) (time.Time, error) {
+	e, ok := exifIFD[tagDateTimeOriginal]
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	value, err := t.ascii(e)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading DateTimeOriginal: %w", err)
+	}
+
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, nil
+	}
+
+	layout := "2006:01:02 15:04:05"
+
+	if e, ok := exifIFD[tagSubSecTimeOriginal]; ok {
+		if subSec, err := t.ascii(e); err == nil && subSec != "" {
+			value += "." + subSec
+		}
+	}
+
+	if e, ok := exifIFD[tagOffsetTimeOriginal]; ok {
+		if offset, err := t.ascii(e); err == nil && offset != "" {
+			value += offset
+			layout += "-07:00"
+		}
+	}
+
+	taken, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing DateTimeOriginal: %w", err)
+	}
+
+	return taken, nil
+}
+
+// location returns the latitude and longitude in the GPS IFD, returning false if either isn't set.
+func (t *tiff) location(gpsIFD ifd) (float64, float64, bool, error) {
+	latitude, ok, err := t.coordinate(gpsIFD, tagGPSLatitude, tagGPSLatitudeRef, "S")
+	if err != nil || !ok {
+		return 0, 0, false, err
+	}
+
+	longitude, ok, err := t.coordinate(gpsIFD, tagGPSLongitude, tagGPSLongitudeRef, "W")
+	if err != nil || !ok {
+		return 0, 0, false, err
+	}
+
+	if math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return 0, 0, false, fmt.Errorf("location %f, %f out of range", latitude, longitude)
+	}
+
+	return latitude, longitude, true, nil
+}
+
+// coordinate reads a GPS coordinate, which is the degrees, minutes and seconds in tag, and its direction in refTag.
+func (t *tiff) coordinate(gpsIFD ifd, tag, refTag uint16, negative string) (float64, bool, error) {
+	e, ok := gpsIFD[tag]
+	if !ok {
+		return 0, false, nil
+	}
+
+	values, err := t.rationals(e)
+	if err != nil {
+		return 0, false, fmt.Errorf("reading gps coordinate: %w", err)
+	}
+
+	if len(values) != 3 {
+		return 0, false, fmt.Errorf("reading gps coordinate: expected 3 values, got %d", len(values))
+	}
+
+	coordinate := values[0] + values[1]/60 + values[2]/3600
+
+	if e, ok := gpsIFD[refTag]; ok {
+		ref, err := t.ascii(e)
+		if err != nil {
+			return 0, false, fmt.Errorf("reading gps coordinate ref: %w", err)
+		}
+
+		if ref == negative {
+			coordinate = -coordinate
+		}
+	}
+
+	return coordinate, true, nil
+}
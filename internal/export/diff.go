@@ -0,0 +1,199 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// edit is a single line of a diff, kind is one of ' ', '-' or '+'.
+type edit struct {
+	kind byte
+	line string
+}
+
+// Diff writes the change from before to after to w as a unified diff, with path used as the name of both files. An
+// empty before is shown as /dev/null, as the file is being created. Nothing is written if they're the same.
+func Diff(w io.Writer, path, before, after string) error {
+	if before == after {
+		return nil
+	}
+
+	edits := diffLines(splitLines(before), splitLines(after))
+
+	from := path
+	if before == "" {
+		from = "/dev/null"
+	}
+
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", from, path); err != nil {
+		return err
+	}
+
+	for _, hunk := range hunks(edits) {
+		if err := writeHunk(w, edits[hunk[0]:hunk[1]], hunk[2], hunk[3]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffLines returns the edits which turn a into b, found from their longest common subsequence.
+func diffLines(a, b []string) []edit {
+	// lengths[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{kind: ' ', line: a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lengths[i+1][j] >= lengths[i][j+1]):
+			edits = append(edits, edit{kind: '-', line: a[i]})
+			i++
+		default:
+			edits = append(edits, edit{kind: '+', line: b[j]})
+			j++
+		}
+	}
+
+	return edits
+}
+
+// hunks groups the changes in edits, along with the lines around them, returning the start and end of each hunk in
+// edits and the line each starts at in the files before and after.
+func hunks(edits []edit) [][4]int {
+	var (
+		result      [][4]int
+		before      = 1
+		after       = 1
+		start       = -1
+		startBefore int
+		startAfter  int
+		lastChange  = -1
+		linesBefore = make([]int, len(edits))
+		linesAfter  = make([]int, len(edits))
+	)
+
+	for i, e := range edits {
+		linesBefore[i], linesAfter[i] = before, after
+
+		if e.kind != '+' {
+			before++
+		}
+		if e.kind != '-' {
+			after++
+		}
+	}
+
+	for i, e := range edits {
+		if e.kind == ' ' {
+			continue
+		}
+
+		if start >= 0 && i-lastChange > 2*diffContext {
+			result = append(result, [4]int{start, min(lastChange+diffContext+1, len(edits)), startBefore, startAfter})
+			start = -1
+		}
+
+		if start < 0 {
+			start = max(i-diffContext, 0)
+			startBefore, startAfter = linesBefore[start], linesAfter[start]
+		}
+
+		lastChange = i
+	}
+
+	if start >= 0 {
+		result = append(result, [4]int{start, min(lastChange+diffContext+1, len(edits)), startBefore, startAfter})
+	}
+
+	return result
+}
+
+func writeHunk(w io.Writer, edits []edit, before, after int) error {
+	var countBefore, countAfter int
+
+	for _, e := range edits {
+		if e.kind != '+' {
+			countBefore++
+		}
+		if e.kind != '-' {
+			countAfter++
+		}
+	}
+
+	// an empty range starts at the line before it.
+	if countBefore == 0 {
+		before--
+	}
+	if countAfter == 0 {
+		after--
+	}
+
+	if _, err := fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", before, countBefore, after, countAfter); err != nil {
+		return err
+	}
+
+	for _, e := range edits {
+		line := e.line
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n\\ No newline at end of file\n"
+		}
+
+		if _, err := fmt.Fprintf(w, "%c%s", e.kind, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,65 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	lines := func(from, to int) string {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			b.WriteString(strings.Repeat("x", i) + "\n")
+		}
+
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		expected string
+	}{
+		{
+			name:     "same",
+			before:   "a\nb\n",
+			after:    "a\nb\n",
+			expected: "",
+		},
+		{
+			name:     "creates file",
+			before:   "",
+			after:    "a\nb\n",
+			expected: "--- /dev/null\n+++ f.xmp\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:   "changes line",
+			before: "a\nb\nc\n",
+			after:  "a\nB\nc",
+			expected: "--- f.xmp\n+++ f.xmp\n@@ -1,3 +1,3 @@\n a\n-b\n-c\n+B\n+c\n" +
+				"\\ No newline at end of file\n",
+		},
+		{
+			name:   "separate hunks",
+			before: lines(1, 20),
+			after:  "y\n" + lines(1, 19) + "y\n" + lines(20, 20),
+			expected: "--- f.xmp\n+++ f.xmp\n" +
+				"@@ -1,3 +1,4 @@\n+y\n x\n xx\n xxx\n" +
+				"@@ -17,4 +18,5 @@\n " + strings.Repeat("x", 17) + "\n " + strings.Repeat("x", 18) + "\n " +
+				strings.Repeat("x", 19) + "\n+y\n " + strings.Repeat("x", 20) + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var b strings.Builder
+
+				assert.NoError(t, Diff(&b, "f.xmp", tt.before, tt.after))
+				assert.Equal(t, tt.expected, b.String())
+			},
+		)
+	}
+}
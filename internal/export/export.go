@@ -0,0 +1,193 @@
+// Package export writes the groups found by a run out of photo-grouping, into the photos' own metadata or into files
+// other tools can read.
+package export
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+)
+
+// Titled is a Group along with the title chosen for it, which is the title it was given in the store if it's been
+// overridden, otherwise its first suggested title.
+type Titled struct {
+	grouping.Group
+	Title string
+}
+
+// place is where a group was, from the broadest to the narrowest part of its address. Location is the place within
+// the city, such as a neighbourhood or a park.
+type place struct {
+	Location string
+	City     string
+	State    string
+	Country  string
+}
+
+// placeTypes maps the address types returned by the geocoder to the part of a place they fill, in order of preference.
+var placeTypes = []struct {
+	addressType string
+	field       func(*place) *string
+}{
+	{"sublocality", func(p *place) *string { return &p.Location }},
+	{"neighborhood", func(p *place) *string { return &p.Location }},
+	{"natural_feature", func(p *place) *string { return &p.Location }},
+	{"park", func(p *place) *string { return &p.Location }},
+	{"locality", func(p *place) *string { return &p.City }},
+	{"postal_town", func(p *place) *string { return &p.City }},
+	{"administrative_area_level_1", func(p *place) *string { return &p.State }},
+	{"country", func(p *place) *string { return &p.Country }},
+}
+
+// placeOf returns the place the photos were taken. Each part is the name given to the most photos, so that a few
+// photos taken over a border don't change the place of the whole group. Ties go to the first name alphabetically.
+func placeOf(photos []grouping.Photo) place {
+	var p place
+
+	for _, placeType := range placeTypes {
+		field := placeType.field(&p)
+		if *field != "" {
+			continue
+		}
+
+		counts := make(map[string]int)
+
+		for _, photo := range photos {
+			for name, types := range photo.AddressTypes {
+				for _, addressType := range types {
+					if addressType == placeType.addressType {
+						counts[name]++
+					}
+				}
+			}
+		}
+
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+
+		sort.Slice(
+			names, func(i, j int) bool {
+				if counts[names[i]] != counts[names[j]] {
+					return counts[names[i]] > counts[names[j]]
+				}
+
+				return names[i] < names[j]
+			},
+		)
+
+		if len(names) > 0 {
+			*field = names[0]
+		}
+	}
+
+	return p
+}
+
+// Bounds is the box bounding a set of photos.
+type Bounds struct {
+	North float64
+	South float64
+	East  float64
+	West  float64
+}
+
+// BoundsOf returns the box bounding the photos, which mustn't be empty. Groups are never wide enough to cross the
+// antimeridian, so the box is simply between the photos' smallest and largest coordinates.
+func BoundsOf(photos []grouping.Photo) Bounds {
+	b := Bounds{
+		North: photos[0].Latitude,
+		South: photos[0].Latitude,
+		East:  photos[0].Longitude,
+		West:  photos[0].Longitude,
+	}
+
+	for _, photo := range photos[1:] {
+		b.North = math.Max(b.North, photo.Latitude)
+		b.South = math.Min(b.South, photo.Latitude)
+		b.East = math.Max(b.East, photo.Longitude)
+		b.West = math.Min(b.West, photo.Longitude)
+	}
+
+	return b
+}
+
+// Centroid returns the mean latitude and longitude of the photos, which mustn't be empty.
+func Centroid(photos []grouping.Photo) (float64, float64) {
+	var latitude, longitude float64
+
+	for _, photo := range photos {
+		latitude += photo.Latitude
+		longitude += photo.Longitude
+	}
+
+	return latitude / float64(len(photos)), longitude / float64(len(photos))
+}
+
+// Distinct returns every photo in the groups once, in the order they're first seen. A photo is in several groups when
+// they overlap, such as a city and its country. Photos are told apart by their ID, or by their time and place when
+// they don't have one, as photos read from a CSV don't.
+func Distinct(groups []Titled) []grouping.Photo {
+	type key struct {
+		id        string
+		timestamp int64
+		latitude  float64
+		longitude float64
+	}
+
+	seen := make(map[key]struct{})
+	photos := make([]grouping.Photo, 0)
+
+	for _, group := range groups {
+		for _, photo := range group.Photos {
+			k := key{id: photo.ID}
+			if k.id == "" {
+				k = key{timestamp: photo.Timestamp.UnixNano(), latitude: photo.Latitude, longitude: photo.Longitude}
+			}
+
+			if _, ok := seen[k]; ok {
+				continue
+			}
+
+			seen[k] = struct{}{}
+			photos = append(photos, photo)
+		}
+	}
+
+	return photos
+}
+
+// writeFile writes data to path with the given permissions. The data is written to a temporary file first, which then
+// replaces path, so that path is left as it was if writing fails part way through.
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing file: %w", err)
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing file: %w", err)
+	}
+
+	return nil
+}
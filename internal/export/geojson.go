@@ -0,0 +1,142 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The features written for each group, see WriteGeoJSON.
+const (
+	featurePhoto    = "photo"
+	featureBounds   = "bounds"
+	featureCentroid = "centroid"
+)
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string            `json:"type"`
+	Geometry   geometry          `json:"geometry"`
+	Properties featureProperties `json:"properties"`
+}
+
+type geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// featureProperties are the properties of every feature. The group's properties are set on each of its features, so
+// that features can be filtered by group, and the photo's properties are only set on photo features.
+type featureProperties struct {
+	Feature  string    `json:"feature"`
+	Group    int       `json:"group"`
+	Title    string    `json:"title"`
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"`
+	TripType string    `json:"tripType"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Photos   int       `json:"photos"`
+
+	ID        string     `json:"id,omitempty"`
+	Video     bool       `json:"video,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// WriteGeoJSON writes the groups to w as a GeoJSON FeatureCollection. Each group has a Point feature for each of its
+// photos, a Polygon feature for the box bounding its photos, and a Point feature at the centre of its photos. A
+// feature's "feature" property is either "photo", "bounds" or "centroid", and its "group" property is the index of its
+// group, so that the features of a group can be found.
+func WriteGeoJSON(w io.Writer, groups []Titled) error {
+	collection := featureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]feature, 0),
+	}
+
+	for i, group := range groups {
+		if len(group.Photos) == 0 {
+			continue
+		}
+
+		properties := featureProperties{
+			Group:    i,
+			Title:    group.Title,
+			Name:     group.Name,
+			Kind:     string(group.Kind),
+			TripType: group.TripType,
+			Start:    group.Start,
+			End:      group.End,
+			Photos:   len(group.Photos),
+		}
+
+		for _, photo := range group.Photos {
+			timestamp := photo.Timestamp
+
+			photoProperties := properties
+			photoProperties.Feature = featurePhoto
+			photoProperties.ID = photo.ID
+			photoProperties.Video = photo.Video
+			photoProperties.Timestamp = &timestamp
+
+			collection.Features = append(
+				collection.Features, feature{
+					Type:       "Feature",
+					Geometry:   geometry{Type: "Point", Coordinates: position(photo.Latitude, photo.Longitude)},
+					Properties: photoProperties,
+				},
+			)
+		}
+
+		b := BoundsOf(group.Photos)
+
+		properties.Feature = featureBounds
+		collection.Features = append(
+			collection.Features, feature{
+				Type: "Feature",
+				Geometry: geometry{
+					Type: "Polygon",
+					Coordinates: [][][]float64{
+						{
+							position(b.South, b.West),
+							position(b.South, b.East),
+							position(b.North, b.East),
+							position(b.North, b.West),
+							position(b.South, b.West),
+						},
+					},
+				},
+				Properties: properties,
+			},
+		)
+
+		latitude, longitude := Centroid(group.Photos)
+
+		properties.Feature = featureCentroid
+		collection.Features = append(
+			collection.Features, feature{
+				Type:       "Feature",
+				Geometry:   geometry{Type: "Point", Coordinates: position(latitude, longitude)},
+				Properties: properties,
+			},
+		)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(collection); err != nil {
+		return fmt.Errorf("encoding geojson: %w", err)
+	}
+
+	return nil
+}
+
+// position returns a GeoJSON position, which is longitude first.
+func position(latitude, longitude float64) []float64 {
+	return []float64{longitude, latitude}
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+// mapGroups returns a group of photos taken around Rome, and a group with a single photo taken in Naples.
+func mapGroups() []Titled {
+	start := time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC)
+
+	return []Titled{
+		{
+			Group: grouping.Group{
+				Name:     "Rome",
+				Kind:     grouping.KindLocation,
+				TripType: "weekend",
+				Start:    start,
+				End:      start.Add(time.Hour * 26),
+				Photos: []grouping.Photo{
+					{ID: "/photos/IMG_0002.jpg", Timestamp: start.Add(time.Hour * 26), Latitude: 41.9, Longitude: 12.5},
+					{ID: "/photos/IMG_0001.jpg", Timestamp: start, Latitude: 41.8, Longitude: 12.4},
+					{ID: "/photos/VID_0003.mp4", Timestamp: start.Add(time.Hour), Latitude: 42, Longitude: 12.6, Video: true},
+				},
+			},
+			Title: "A weekend in Rome",
+		},
+		{
+			Group: grouping.Group{
+				Name:     "Naples",
+				Kind:     grouping.KindLocation,
+				TripType: "day",
+				Start:    start.Add(time.Hour * 48),
+				End:      start.Add(time.Hour * 48),
+				Photos:   []grouping.Photo{{Timestamp: start.Add(time.Hour * 48), Latitude: 40.85, Longitude: 14.27}},
+			},
+			Title: "A day out in Naples",
+		},
+	}
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	var b bytes.Buffer
+
+	assert.NoError(t, WriteGeoJSON(&b, mapGroups()))
+
+	var got struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &got))
+	assert.Equal(t, "FeatureCollection", got.Type)
+	assert.Len(t, got.Features, 8)
+
+	photo := got.Features[2]
+	assert.Equal(t, "Point", photo.Geometry.Type)
+	assert.JSONEq(t, `[12.6, 42]`, string(photo.Geometry.Coordinates))
+	assert.Equal(t, "photo", photo.Properties["feature"])
+	assert.Equal(t, "/photos/VID_0003.mp4", photo.Properties["id"])
+	assert.Equal(t, true, photo.Properties["video"])
+	assert.Equal(t, "2022-04-02T11:00:00Z", photo.Properties["timestamp"])
+
+	bounds := got.Features[3]
+	assert.Equal(t, "Polygon", bounds.Geometry.Type)
+	assert.JSONEq(
+		t, `[[[12.4, 41.8], [12.6, 41.8], [12.6, 42], [12.4, 42], [12.4, 41.8]]]`, string(bounds.Geometry.Coordinates),
+	)
+	assert.Equal(t, "bounds", bounds.Properties["feature"])
+	assert.Equal(t, "A weekend in Rome", bounds.Properties["title"])
+	assert.Equal(t, "weekend", bounds.Properties["tripType"])
+	assert.Equal(t, "2022-04-02T10:00:00Z", bounds.Properties["start"])
+	assert.Equal(t, "2022-04-03T12:00:00Z", bounds.Properties["end"])
+	assert.Equal(t, float64(3), bounds.Properties["photos"])
+	assert.NotContains(t, bounds.Properties, "timestamp")
+
+	centroid := got.Features[4]
+	assert.Equal(t, "centroid", centroid.Properties["feature"])
+
+	var position []float64
+	assert.NoError(t, json.Unmarshal(centroid.Geometry.Coordinates, &position))
+	assert.InDeltaSlice(t, []float64{12.5, 41.9}, position, 1e-9)
+
+	assert.Equal(t, float64(1), got.Features[7].Properties["group"])
+	assert.Equal(t, "A day out in Naples", got.Features[7].Properties["title"])
+}
@@ -0,0 +1,145 @@
+package export
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The formats of the dates and times in an iCalendar file. Times in UTC end with Z, other times are the local time
+// where the photos were taken.
+const (
+	icsDate      = "20060102"
+	icsLocalTime = "20060102T150405"
+	icsUTCTime   = "20060102T150405Z"
+)
+
+// icsLineLength is the most octets a line of an iCalendar file can have, longer lines are folded.
+const icsLineLength = 75
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// WriteICS writes the groups to w as iCalendar events, stamped with when they were exported. A group that starts and
+// ends on the same day is an event lasting from its first to its last photo, otherwise it's an all-day event covering
+// each day of the trip. Times are in the timezone the photos were taken in, as they're recorded by the photos, or in
+// UTC when the photos don't record their offset.
+//
+// Each event's summary is the group's title and its location is where the group was, from the place within the city
+// to the country. Each event's UID is made from the group's kind, name and the day it starts, so that exporting the
+// groups again updates the events already in a calendar, even after more photos have been added to the end of a trip.
+// Groups aren't given an identity which survives regrouping, so a photo added before a trip's first day gives its
+// event a new UID, leaving the old event in the calendar. Groups with the same kind and name starting on the same day
+// are told apart by the order they started in.
+func WriteICS(w io.Writer, groups []Titled, stamp time.Time) error {
+	b := bufio.NewWriter(w)
+
+	writeLine(b, "BEGIN:VCALENDAR")
+	writeLine(b, "VERSION:2.0")
+	writeLine(b, "PRODID:-//photo-grouping//photo-grouping//EN")
+	writeLine(b, "CALSCALE:GREGORIAN")
+
+	// seen counts the groups given each UID, so that groups which would share one are given their own.
+	seen := make(map[string]int)
+
+	for _, group := range groups {
+		if len(group.Photos) == 0 {
+			continue
+		}
+
+		uid := eventUID(group)
+
+		seen[uid]++
+		if n := seen[uid]; n > 1 {
+			uid += "-" + strconv.Itoa(n)
+		}
+
+		// the end of the group is in the same timezone as its start, so that an event doesn't end before it starts.
+		start, end := group.Start, group.End.In(group.Start.Location())
+
+		writeLine(b, "BEGIN:VEVENT")
+		writeLine(b, "UID:"+uid+"@photo-grouping")
+		writeLine(b, "DTSTAMP:"+stamp.UTC().Format(icsUTCTime))
+
+		if start.YearDay() == end.YearDay() && start.Year() == end.Year() {
+			writeLine(b, "DTSTART:"+icsTime(start))
+			writeLine(b, "DTEND:"+icsTime(end))
+		} else {
+			// an all-day event ends on the day after it finishes.
+			writeLine(b, "DTSTART;VALUE=DATE:"+start.Format(icsDate))
+			writeLine(b, "DTEND;VALUE=DATE:"+end.AddDate(0, 0, 1).Format(icsDate))
+		}
+
+		writeLine(b, "SUMMARY:"+icsEscaper.Replace(group.Title))
+
+		if location := placeName(group); location != "" {
+			writeLine(b, "LOCATION:"+icsEscaper.Replace(location))
+		}
+
+		latitude, longitude := Centroid(group.Photos)
+		writeLine(
+			b, "GEO:"+strconv.FormatFloat(latitude, 'f', 6, 64)+";"+strconv.FormatFloat(longitude, 'f', 6, 64),
+		)
+
+		description := fmt.Sprintf(
+			"%s trip, %d photos\nSuggested titles: %s", group.TripType, len(group.Photos), strings.Join(group.Titles, "; "),
+		)
+		writeLine(b, "DESCRIPTION:"+icsEscaper.Replace(description))
+
+		if group.TripType != "" {
+			writeLine(b, "CATEGORIES:"+icsEscaper.Replace(group.TripType))
+		}
+
+		writeLine(b, "END:VEVENT")
+	}
+
+	writeLine(b, "END:VCALENDAR")
+
+	if err := b.Flush(); err != nil {
+		return fmt.Errorf("writing ics: %w", err)
+	}
+
+	return nil
+}
+
+// eventUID returns the hash of the group's kind, name and the day it starts, which identifies its event.
+func eventUID(group Titled) string {
+	hash := sha1.Sum([]byte(string(group.Kind) + "\x00" + group.Name + "\x00" + group.Start.Format(icsDate)))
+
+	return hex.EncodeToString(hash[:10])
+}
+
+// icsTime returns the value of a DTSTART or DTEND property for t. Times whose offset isn't known, which are read in
+// UTC, are written as UTC, any other time as the local time it was taken at, even where that's UTC+0.
+func icsTime(t time.Time) string {
+	if t.Location() == time.UTC {
+		return t.Format(icsUTCTime)
+	}
+
+	return t.Format(icsLocalTime)
+}
+
+// writeLine writes a content line, folding it so that no line is longer than icsLineLength octets. Lines are only
+// folded between characters, so that multi-byte characters aren't split.
+func writeLine(w *bufio.Writer, line string) {
+	length := 0
+
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+
+		if length+size > icsLineLength {
+			_, _ = w.WriteString("\r\n ")
+			length = 1
+		}
+
+		_, _ = w.WriteRune(r)
+		length += size
+	}
+
+	_, _ = w.WriteString("\r\n")
+}
@@ -0,0 +1,143 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteICS(t *testing.T) {
+	groups := mapGroups()
+	groups[0].Titles = []string{"A weekend in Rome", "Rome, Italy"}
+	groups[0].Photos[0].AddressTypes = map[string][]string{
+		"Rome":  {"locality", "political"},
+		"Lazio": {"administrative_area_level_1", "political"},
+		"Italy": {"country", "political"},
+	}
+
+	offset := time.FixedZone("", 2*60*60)
+	groups = append(
+		groups, Titled{
+			Group: grouping.Group{
+				Name:     "Sorrento",
+				Kind:     grouping.KindCluster,
+				TripType: "day",
+				Start:    time.Date(2022, 04, 05, 9, 0, 0, 0, offset),
+				End:      time.Date(2022, 04, 05, 15, 30, 0, 0, time.UTC),
+				Photos:   []grouping.Photo{{Latitude: 40.626, Longitude: 14.376}},
+			},
+			Title: "A day out in Sorrento, with a title long enough that it has to be folded",
+		},
+	)
+
+	var b bytes.Buffer
+
+	stamp := time.Date(2022, 05, 01, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, WriteICS(&b, groups, stamp))
+
+	got := b.String()
+	assert.True(t, strings.HasPrefix(got, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(got, "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	assert.Equal(t, 3, strings.Count(got, "BEGIN:VEVENT\r\n"))
+
+	events := strings.Split(got, "BEGIN:VEVENT\r\n")[1:]
+
+	// a trip over several days is an all-day event.
+	assert.Contains(t, events[0], "DTSTAMP:20220501T120000Z\r\n")
+	assert.Contains(t, events[0], "DTSTART;VALUE=DATE:20220402\r\nDTEND;VALUE=DATE:20220404\r\n")
+	assert.Contains(t, events[0], "SUMMARY:A weekend in Rome\r\n")
+	assert.Contains(t, events[0], "LOCATION:Rome\\, Lazio\\, Italy\r\n")
+	assert.Contains(t, events[0], "GEO:41.900000;12.500000\r\n")
+	assert.Contains(t, events[0], "DESCRIPTION:weekend trip\\, 3 photos\\nSuggested titles: A weekend in Rome\\; \r\n Rome\\, Italy\r\n")
+	assert.Contains(t, events[0], "CATEGORIES:weekend\r\n")
+
+	// a trip within a day is a timed event, in UTC when that's all that's known.
+	assert.Contains(t, events[1], "DTSTART:20220404T100000Z\r\nDTEND:20220404T100000Z\r\n")
+	assert.Contains(t, events[1], "LOCATION:Naples\r\n")
+
+	// otherwise in the local time the photos were taken.
+	assert.Contains(t, events[2], "DTSTART:20220405T090000\r\nDTEND:20220405T173000\r\n")
+	assert.Contains(t, events[2], "SUMMARY:A day out in Sorrento\\, with a title long enough that it has to be \r\n folded\r\n")
+
+	for _, line := range strings.Split(got, "\r\n") {
+		assert.LessOrEqual(t, len(line), icsLineLength)
+	}
+
+	// exporting again, after the trip has grown, keeps the same UIDs.
+	groups[0].End = groups[0].End.Add(time.Hour * 24)
+
+	var again bytes.Buffer
+	assert.NoError(t, WriteICS(&again, groups, stamp.Add(time.Hour)))
+
+	uids := func(s string) []string {
+		var result []string
+		for _, line := range strings.Split(s, "\r\n") {
+			if strings.HasPrefix(line, "UID:") {
+				result = append(result, line)
+			}
+		}
+
+		return result
+	}
+
+	assert.Len(t, uids(got), 3)
+	assert.Equal(t, uids(got), uids(again.String()))
+	assert.NotEqual(t, uids(got)[0], uids(got)[1])
+
+	// a second visit on the same day has its own UID.
+	revisit := groups[1]
+	revisit.Start = revisit.Start.Add(time.Hour * 6)
+	revisit.End = revisit.Start
+
+	var revisited bytes.Buffer
+	assert.NoError(t, WriteICS(&revisited, append(groups, revisit), stamp))
+
+	assert.Len(t, uids(revisited.String()), 4)
+	assert.Equal(t, uids(got), uids(revisited.String())[:3])
+	assert.Equal(t, strings.TrimSuffix(uids(got)[1], "@photo-grouping")+"-2@photo-grouping", uids(revisited.String())[3])
+}
+
+func TestWriteICS_KnownOffset(t *testing.T) {
+	tests := []struct {
+		name     string
+		location *time.Location
+		expected string
+	}{
+		{
+			name:     "unknown offset is written as UTC",
+			location: time.UTC,
+			expected: "DTSTART:20220115T100000Z\r\nDTEND:20220115T160000Z\r\n",
+		},
+		{
+			name:     "known offset of zero is written as local time",
+			location: time.FixedZone("", 0),
+			expected: "DTSTART:20220115T100000\r\nDTEND:20220115T160000\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				groups := []Titled{
+					{
+						Group: grouping.Group{
+							Name:   "London",
+							Kind:   grouping.KindLocation,
+							Start:  time.Date(2022, 01, 15, 10, 0, 0, 0, tt.location),
+							End:    time.Date(2022, 01, 15, 16, 0, 0, 0, tt.location),
+							Photos: []grouping.Photo{{Latitude: 51.5072, Longitude: -0.1276}},
+						},
+						Title: "A day out in London",
+					},
+				}
+
+				var b bytes.Buffer
+				assert.NoError(t, WriteICS(&b, groups, time.Date(2022, 05, 01, 12, 0, 0, 0, time.UTC)))
+				assert.Contains(t, b.String(), tt.expected)
+			},
+		)
+	}
+}
@@ -0,0 +1,142 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+)
+
+const namespaceKML = "http://www.opengis.net/kml/2.2"
+
+type kml struct {
+	XMLName  xml.Name    `xml:"kml"`
+	XMLNS    string      `xml:"xmlns,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name    string      `xml:"name"`
+	Folders []kmlFolder `xml:"Folder"`
+}
+
+type kmlFolder struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description"`
+	TimeSpan    kmlTimeSpan    `xml:"TimeSpan"`
+	Placemarks  []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlTimeSpan struct {
+	Begin string `xml:"begin"`
+	End   string `xml:"end"`
+}
+
+type kmlPlacemark struct {
+	Name       string         `xml:"name"`
+	TimeStamp  *kmlTimeStamp  `xml:"TimeStamp,omitempty"`
+	Point      *kmlPoint      `xml:"Point,omitempty"`
+	LineString *kmlLineString `xml:"LineString,omitempty"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+// WriteKML writes the groups to w as a KML document, with a folder for each group. Each folder has a placemark for
+// each of the group's photos, and a path through the photos in the order they were taken, so that the trip can be
+// followed on a map. Folders and placemarks are given the time they cover, so that they can be played through in Google
+// Earth.
+func WriteKML(w io.Writer, groups []Titled) error {
+	document := kml{
+		XMLNS:    namespaceKML,
+		Document: kmlDocument{Name: "photo-grouping"},
+	}
+
+	for _, group := range groups {
+		if len(group.Photos) == 0 {
+			continue
+		}
+
+		photos := make([]grouping.Photo, len(group.Photos))
+		copy(photos, group.Photos)
+		sort.SliceStable(photos, func(i, j int) bool { return photos[i].Timestamp.Before(photos[j].Timestamp) })
+
+		folder := kmlFolder{
+			Name: group.Title,
+			Description: fmt.Sprintf(
+				"%s, %s trip from %s to %s, %d photos", group.Name, group.TripType,
+				group.Start.Format(time.RFC3339), group.End.Format(time.RFC3339), len(photos),
+			),
+			TimeSpan: kmlTimeSpan{Begin: group.Start.Format(time.RFC3339), End: group.End.Format(time.RFC3339)},
+		}
+
+		if len(photos) > 1 {
+			path := make([]string, 0, len(photos))
+			for _, photo := range photos {
+				path = append(path, coordinates(photo))
+			}
+
+			folder.Placemarks = append(
+				folder.Placemarks, kmlPlacemark{
+					Name:       "Path",
+					LineString: &kmlLineString{Tessellate: 1, Coordinates: strings.Join(path, " ")},
+				},
+			)
+		}
+
+		for _, photo := range photos {
+			name := filepath.Base(photo.ID)
+			if photo.ID == "" {
+				name = photo.Timestamp.Format(time.RFC3339)
+			}
+
+			folder.Placemarks = append(
+				folder.Placemarks, kmlPlacemark{
+					Name:      name,
+					TimeStamp: &kmlTimeStamp{When: photo.Timestamp.Format(time.RFC3339)},
+					Point:     &kmlPoint{Coordinates: coordinates(photo)},
+				},
+			)
+		}
+
+		document.Document.Folders = append(document.Document.Folders, folder)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("writing kml: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("encoding kml: %w", err)
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("writing kml: %w", err)
+	}
+
+	return nil
+}
+
+// coordinates returns the KML coordinates of the photo, which are longitude first.
+func coordinates(photo grouping.Photo) string {
+	return strconv.FormatFloat(photo.Longitude, 'f', -1, 64) + "," + strconv.FormatFloat(photo.Latitude, 'f', -1, 64)
+}
@@ -0,0 +1,41 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteKML(t *testing.T) {
+	var b bytes.Buffer
+
+	assert.NoError(t, WriteKML(&b, mapGroups()))
+
+	var got kml
+	assert.NoError(t, xml.Unmarshal(b.Bytes(), &got))
+
+	assert.Equal(t, namespaceKML, got.XMLName.Space)
+	assert.Len(t, got.Document.Folders, 2)
+
+	rome := got.Document.Folders[0]
+	assert.Equal(t, "A weekend in Rome", rome.Name)
+	assert.Equal(t, "Rome, weekend trip from 2022-04-02T10:00:00Z to 2022-04-03T12:00:00Z, 3 photos", rome.Description)
+	assert.Equal(t, kmlTimeSpan{Begin: "2022-04-02T10:00:00Z", End: "2022-04-03T12:00:00Z"}, rome.TimeSpan)
+	assert.Len(t, rome.Placemarks, 4)
+
+	// the path and photos are in the order they were taken.
+	assert.Equal(t, "Path", rome.Placemarks[0].Name)
+	assert.Equal(t, "12.4,41.8 12.6,42 12.5,41.9", rome.Placemarks[0].LineString.Coordinates)
+	assert.Equal(t, "IMG_0001.jpg", rome.Placemarks[1].Name)
+	assert.Equal(t, "2022-04-02T10:00:00Z", rome.Placemarks[1].TimeStamp.When)
+	assert.Equal(t, "12.4,41.8", rome.Placemarks[1].Point.Coordinates)
+	assert.Equal(t, "VID_0003.mp4", rome.Placemarks[2].Name)
+
+	// a single photo has no path, and is named after when it was taken as it has no ID.
+	naples := got.Document.Folders[1]
+	assert.Len(t, naples.Placemarks, 1)
+	assert.Equal(t, "2022-04-04T10:00:00Z", naples.Placemarks[0].Name)
+	assert.Nil(t, naples.Placemarks[0].LineString)
+}
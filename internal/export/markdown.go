@@ -0,0 +1,78 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// markdownEscaper escapes the characters which would otherwise format a title in Markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "#", `\#`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "|", `\|`,
+)
+
+// WriteMarkdown writes the groups to w as a Markdown document, with a section for each group in the order they started.
+// Each section is headed by the group's title, and lists its dates, how long it lasted, where it was and how far its
+// photos were spread, the number of photos and the trip type, followed by the other titles suggested for it.
+func WriteMarkdown(w io.Writer, groups []Titled) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "# Photo groups\n\n%s in %d groups.\n", photoCount(groups), len(groups))
+
+	for _, group := range chronological(groups) {
+		summary := Summarise(group)
+
+		fmt.Fprintf(b, "\n## %s\n\n", markdownEscaper.Replace(group.Title))
+		fmt.Fprintf(b, "- **Dates:** %s\n", DateRange(group))
+		fmt.Fprintf(b, "- **Duration:** %s\n", summary.Length())
+		fmt.Fprintf(b, "- **Place:** %s, across %s\n", markdownEscaper.Replace(summary.Place), summary.Extent())
+		fmt.Fprintf(b, "- **Photos:** %s\n", summary.Count())
+
+		if group.TripType != "" {
+			fmt.Fprintf(b, "- **Trip type:** %s\n", markdownEscaper.Replace(group.TripType))
+		}
+
+		others := make([]string, 0, len(group.Titles))
+		for _, title := range group.Titles {
+			if title != group.Title {
+				others = append(others, markdownEscaper.Replace(title))
+			}
+		}
+
+		if len(others) > 0 {
+			fmt.Fprintf(b, "- **Also suggested:** %s\n", strings.Join(others, "; "))
+		}
+	}
+
+	if err := b.Flush(); err != nil {
+		return fmt.Errorf("writing markdown: %w", err)
+	}
+
+	return nil
+}
+
+// chronological returns the groups in the order they started.
+func chronological(groups []Titled) []Titled {
+	sorted := make([]Titled, len(groups))
+	copy(sorted, groups)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	return sorted
+}
+
+// photoCount returns the number of photos and videos in the groups, counting each once even if it's in several.
+func photoCount(groups []Titled) string {
+	var summary Summary
+
+	for _, photo := range Distinct(groups) {
+		if photo.Video {
+			summary.Videos++
+		} else {
+			summary.Photos++
+		}
+	}
+
+	return summary.Count()
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	groups := mapGroups()
+	// groups are written in the order they started, whatever order they're given in.
+	groups[0], groups[1] = groups[1], groups[0]
+	groups[1].Title = "Roman *holiday*"
+	groups[1].Titles = []string{"A weekend in Rome", "Rome, Italy"}
+
+	var b strings.Builder
+
+	assert.NoError(t, WriteMarkdown(&b, groups))
+	assert.Equal(
+		t, `# Photo groups
+
+3 photos, 1 video in 2 groups.
+
+## Roman \*holiday\*
+
+- **Dates:** 2 Apr 2022 – 3 Apr 2022
+- **Duration:** 2 days
+- **Place:** Rome, across 27.7 km
+- **Photos:** 2 photos, 1 video
+- **Trip type:** weekend
+- **Also suggested:** A weekend in Rome; Rome, Italy
+
+## A day out in Naples
+
+- **Dates:** 4 Apr 2022
+- **Duration:** 0 minutes
+- **Place:** Naples, across 0 m
+- **Photos:** 1 photo
+- **Trip type:** day
+`, b.String(),
+	)
+}
+
+func TestWriteMarkdown_Overlapping(t *testing.T) {
+	groups := mapGroups()
+
+	// the country holds the photos of both cities, and the Naples photo, which has no ID, is told apart by its time
+	// and place.
+	italy := Titled{Group: groups[0].Group, Title: "A trip to Italy"}
+	italy.Name = "Italy"
+	italy.Photos = append(append([]grouping.Photo{}, groups[0].Photos...), groups[1].Photos...)
+	groups = append(groups, italy)
+
+	var b strings.Builder
+
+	assert.NoError(t, WriteMarkdown(&b, groups))
+	assert.Contains(t, b.String(), "3 photos, 1 video in 3 groups.\n")
+}
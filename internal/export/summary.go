@@ -0,0 +1,128 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/geo"
+)
+
+// Summary describes a group for people to read. Days is the number of calendar days the group covers, in the timezone
+// it started in, and Breadth is the distance in metres across the box bounding its photos.
+type Summary struct {
+	Photos   int
+	Videos   int
+	Days     int
+	Duration time.Duration
+	Breadth  float64
+	Place    string
+}
+
+// Summarise returns the Summary of the group.
+func Summarise(group Titled) Summary {
+	summary := Summary{
+		Duration: group.End.Sub(group.Start),
+		Place:    placeName(group),
+	}
+
+	for _, photo := range group.Photos {
+		if photo.Video {
+			summary.Videos++
+		} else {
+			summary.Photos++
+		}
+	}
+
+	start := group.Start
+	end := group.End.In(start.Location())
+	startDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	endDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	summary.Days = int(endDay.Sub(startDay).Hours()/24) + 1
+
+	if len(group.Photos) > 0 {
+		b := BoundsOf(group.Photos)
+		summary.Breadth = geo.Distance(b.South, b.West, b.North, b.East)
+	}
+
+	return summary
+}
+
+// Count returns the number of photos and videos, such as "12 photos, 1 video".
+func (s Summary) Count() string {
+	count := plural(s.Photos, "photo")
+	if s.Videos > 0 {
+		count += ", " + plural(s.Videos, "video")
+	}
+
+	return count
+}
+
+// Length returns how long the group lasted, in days if it covers more than one, otherwise in hours and minutes.
+func (s Summary) Length() string {
+	if s.Days > 1 {
+		return plural(s.Days, "day")
+	}
+
+	hours, minutes := int(s.Duration.Hours()), int(s.Duration.Minutes())%60
+
+	switch {
+	case hours == 0:
+		return plural(minutes, "minute")
+	case minutes == 0:
+		return plural(hours, "hour")
+	default:
+		return fmt.Sprintf("%s %s", plural(hours, "hour"), plural(minutes, "minute"))
+	}
+}
+
+// Extent returns how far the photos were spread across, in metres under a kilometre, otherwise in kilometres.
+func (s Summary) Extent() string {
+	switch {
+	case s.Breadth < 1000:
+		return fmt.Sprintf("%.0f m", s.Breadth)
+	case s.Breadth < 100000:
+		return fmt.Sprintf("%.1f km", s.Breadth/1000)
+	default:
+		return fmt.Sprintf("%.0f km", s.Breadth/1000)
+	}
+}
+
+// DateRange returns the dates of the group, such as "2 Apr 2022" or "2 Apr 2022 – 4 Apr 2022".
+func DateRange(group Titled) string {
+	start := group.Start.Format("2 Jan 2006")
+	end := group.End.In(group.Start.Location()).Format("2 Jan 2006")
+
+	if start == end {
+		return start
+	}
+
+	return start + " – " + end
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// placeName returns the parts of the group's place, from the narrowest to the broadest, or its name if it has no
+// place, such as a cluster.
+func placeName(group Titled) string {
+	p := placeOf(group.Photos)
+	parts := make([]string, 0, 4)
+
+	for _, part := range []string{p.Location, p.City, p.State, p.Country} {
+		if part != "" && (len(parts) == 0 || parts[len(parts)-1] != part) {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return group.Name
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarise(t *testing.T) {
+	groups := mapGroups()
+
+	rome := Summarise(groups[0])
+	assert.Equal(t, 2, rome.Photos)
+	assert.Equal(t, 1, rome.Videos)
+	assert.Equal(t, 2, rome.Days)
+	assert.Equal(t, "2 photos, 1 video", rome.Count())
+	assert.Equal(t, "2 days", rome.Length())
+	assert.Equal(t, "27.7 km", rome.Extent())
+	assert.Equal(t, "Rome", rome.Place)
+	assert.Equal(t, "2 Apr 2022 – 3 Apr 2022", DateRange(groups[0]))
+
+	naples := Summarise(groups[1])
+	assert.Equal(t, "1 photo", naples.Count())
+	assert.Equal(t, "0 minutes", naples.Length())
+	assert.Equal(t, "0 m", naples.Extent())
+	assert.Equal(t, "4 Apr 2022", DateRange(groups[1]))
+}
+
+func TestSummary_Length(t *testing.T) {
+	tests := []struct {
+		name     string
+		group    grouping.Group
+		expected string
+	}{
+		{
+			name: "minutes",
+			group: grouping.Group{
+				Start: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(2022, 04, 02, 10, 45, 0, 0, time.UTC),
+			},
+			expected: "45 minutes",
+		},
+		{
+			name: "hours",
+			group: grouping.Group{
+				Start: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(2022, 04, 02, 11, 0, 0, 0, time.UTC),
+			},
+			expected: "1 hour",
+		},
+		{
+			name: "hours and minutes",
+			group: grouping.Group{
+				Start: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(2022, 04, 02, 15, 30, 0, 0, time.UTC),
+			},
+			expected: "5 hours 30 minutes",
+		},
+		{
+			// the days are counted in the timezone the group started in.
+			name: "days",
+			group: grouping.Group{
+				Start: time.Date(2022, 04, 02, 23, 0, 0, 0, time.FixedZone("", 60*60)),
+				End:   time.Date(2022, 04, 02, 23, 30, 0, 0, time.UTC),
+			},
+			expected: "2 days",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, Summarise(Titled{Group: tt.group}).Length())
+			},
+		)
+	}
+}
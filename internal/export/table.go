@@ -0,0 +1,30 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteTable writes the groups to w as a table aligned with spaces, with a row for each group in the order they
+// started, for reading in a terminal.
+func WriteTable(w io.Writer, groups []Titled) error {
+	t := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(t, "DATES\tDURATION\tPHOTOS\tSPREAD\tTRIP TYPE\tTITLE")
+
+	for _, group := range chronological(groups) {
+		summary := Summarise(group)
+
+		fmt.Fprintf(
+			t, "%s\t%s\t%d\t%s\t%s\t%s\n", DateRange(group), summary.Length(), len(group.Photos), summary.Extent(),
+			group.TripType, group.Title,
+		)
+	}
+
+	if err := t.Flush(); err != nil {
+		return fmt.Errorf("writing table: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTable(t *testing.T) {
+	reversed := mapGroups()
+	reversed[0], reversed[1] = reversed[1], reversed[0]
+
+	tests := []struct {
+		name     string
+		groups   []Titled
+		expected string
+	}{
+		{
+			name:   "writes a row for each group",
+			groups: mapGroups(),
+			expected: `DATES                    DURATION   PHOTOS  SPREAD   TRIP TYPE  TITLE
+2 Apr 2022 – 3 Apr 2022  2 days     3       27.7 km  weekend    A weekend in Rome
+4 Apr 2022               0 minutes  1       0 m      day        A day out in Naples
+`,
+		},
+		{
+			name:   "writes groups in the order they started",
+			groups: reversed,
+			expected: `DATES                    DURATION   PHOTOS  SPREAD   TRIP TYPE  TITLE
+2 Apr 2022 – 3 Apr 2022  2 days     3       27.7 km  weekend    A weekend in Rome
+4 Apr 2022               0 minutes  1       0 m      day        A day out in Naples
+`,
+		},
+		{
+			name:     "writes the header without groups",
+			groups:   nil,
+			expected: "DATES  DURATION  PHOTOS  SPREAD  TRIP TYPE  TITLE\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var b strings.Builder
+
+				assert.NoError(t, WriteTable(&b, tt.groups))
+				assert.Equal(t, tt.expected, b.String())
+			},
+		)
+	}
+}
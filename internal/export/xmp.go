@@ -0,0 +1,233 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/JackFazackerley/photo-grouping/internal/xmp"
+	log "github.com/sirupsen/logrus"
+)
+
+// XMPOptions configures WriteXMP. When DryRun is set nothing is written, and the change each photo would have is
+// written to Diff as a unified diff, if it's set. When Embed is set the XMP embedded in JPEGs is updated rather than
+// their sidecars; the other photos always have a sidecar written, so that their original bytes are never touched.
+type XMPOptions struct {
+	DryRun bool
+	Embed  bool
+	Diff   io.Writer
+}
+
+// XMPStats counts the photos written by WriteXMP. Changed is the number whose XMP was, or in a dry run would have
+// been, changed, Unchanged the number which already had the tags, Skipped the number which aren't files, such as the
+// photos read from a CSV, and Failed the number which couldn't be read or written.
+type XMPStats struct {
+	Changed   int
+	Unchanged int
+	Skipped   int
+	Failed    int
+}
+
+// WriteXMP writes each group's title, trip type and place into the XMP of its photos. A photo is in a group for each
+// part of its address, such as its city and its country, so each photo is written once with the tags of every group
+// it's in, see photoTags. The titles and trip types are added to the photo's keywords, replacing those written by a
+// previous WriteXMP, and the place replaces its location, city, state and country. A photo's existing sidecar is
+// updated if it has one, otherwise one is created called after the whole file name, such as IMG_0001.CR3.xmp. Failures
+// are logged, and don't stop the remaining photos being written.
+func WriteXMP(groups []Titled, options XMPOptions) XMPStats {
+	var stats XMPStats
+
+	ids, tags := photoTags(groups)
+
+	for _, id := range ids {
+		info, err := os.Stat(id)
+		if id == "" || err != nil || !info.Mode().IsRegular() {
+			stats.Skipped++
+			continue
+		}
+
+		write := writeSidecar
+		if options.Embed && isJPEG(id) {
+			write = writeEmbedded
+		}
+
+		changed, err := write(id, tags[id], options)
+		switch {
+		case err != nil:
+			log.WithError(err).WithField("photo", id).Error("writing xmp")
+			stats.Failed++
+		case changed:
+			stats.Changed++
+		default:
+			stats.Unchanged++
+		}
+	}
+
+	return stats
+}
+
+// photoTags returns the IDs of the groups' photos, each only once, along with the tags written into each. A photo's
+// keywords are the titles and trip types of every group it's in, from the narrowest group, the one with the fewest
+// photos, to the broadest, and each part of its place is taken from the narrowest group which sets it.
+func photoTags(groups []Titled) ([]string, map[string]xmp.Tags) {
+	sorted := append([]Titled(nil), groups...)
+	sort.SliceStable(
+		sorted, func(i, j int) bool {
+			return len(sorted[i].Photos) < len(sorted[j].Photos)
+		},
+	)
+
+	ids := make([]string, 0)
+	tags := make(map[string]xmp.Tags)
+
+	for _, group := range sorted {
+		added := groupTags(group)
+
+		for _, photo := range group.Photos {
+			existing, ok := tags[photo.ID]
+			if !ok {
+				ids = append(ids, photo.ID)
+			}
+
+			tags[photo.ID] = mergeTags(existing, added)
+		}
+	}
+
+	return ids, tags
+}
+
+// groupTags returns the tags written into the XMP of the group's photos.
+func groupTags(group Titled) xmp.Tags {
+	p := placeOf(group.Photos)
+
+	return xmp.Tags{
+		Keywords: []string{group.Title, group.TripType},
+		Location: p.Location,
+		City:     p.City,
+		State:    p.State,
+		Country:  p.Country,
+	}
+}
+
+// mergeTags returns tags with the keywords of added appended to its own, and any part of the place it doesn't set
+// taken from added.
+func mergeTags(tags, added xmp.Tags) xmp.Tags {
+	tags.Keywords = append(append([]string(nil), tags.Keywords...), added.Keywords...)
+
+	if tags.Location == "" {
+		tags.Location = added.Location
+	}
+
+	if tags.City == "" {
+		tags.City = added.City
+	}
+
+	if tags.State == "" {
+		tags.State = added.State
+	}
+
+	if tags.Country == "" {
+		tags.Country = added.Country
+	}
+
+	return tags
+}
+
+func isJPEG(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".jpg" || extension == ".jpeg"
+}
+
+// writeSidecar updates the sidecar of the photo at path, and reports whether it changed.
+func writeSidecar(path string, tags xmp.Tags, options XMPOptions) (bool, error) {
+	sidecarPath := path + ".xmp"
+
+	var packet []byte
+
+	for _, candidate := range xmp.SidecarPaths(path) {
+		data, err := os.ReadFile(candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("reading sidecar: %w", err)
+		}
+
+		sidecarPath, packet = candidate, data
+
+		break
+	}
+
+	updated, err := xmp.Update(packet, tags)
+	if err != nil {
+		return false, fmt.Errorf("updating sidecar %s: %w", sidecarPath, err)
+	}
+
+	if bytes.Equal(packet, updated) {
+		return false, nil
+	}
+
+	if options.DryRun {
+		return true, writeDiff(options.Diff, sidecarPath, packet, updated)
+	}
+
+	return true, writeFile(sidecarPath, updated, 0o644)
+}
+
+// writeEmbedded updates the XMP embedded in the JPEG at path, and reports whether it changed. Only the XMP segment of
+// the file is replaced.
+func writeEmbedded(path string, tags xmp.Tags, options XMPOptions) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, fmt.Errorf("reading jpeg: %w", err)
+	}
+
+	jpeg, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("reading jpeg: %w", err)
+	}
+
+	packet, err := xmp.EmbeddedJPEG(jpeg)
+	if err != nil {
+		return false, fmt.Errorf("reading jpeg: %w", err)
+	}
+
+	updated, err := xmp.Update(packet, tags)
+	if err != nil {
+		return false, fmt.Errorf("updating embedded xmp: %w", err)
+	}
+
+	if bytes.Equal(packet, updated) {
+		return false, nil
+	}
+
+	if options.DryRun {
+		return true, writeDiff(options.Diff, path, packet, updated)
+	}
+
+	embedded, err := xmp.EmbedJPEG(jpeg, updated)
+	if err != nil {
+		return false, err
+	}
+
+	return true, writeFile(path, embedded, info.Mode().Perm())
+}
+
+// writeDiff writes the change from before to after to w, if it's set.
+func writeDiff(w io.Writer, path string, before, after []byte) error {
+	if w == nil {
+		return nil
+	}
+
+	if err := Diff(w, path, string(before), string(after)); err != nil {
+		return fmt.Errorf("writing diff: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JackFazackerley/photo-grouping/internal/xmp"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+var testJPEG = []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x04, 0x00, 0x00, 0xff, 0xda, 0x00, 0x02, 0xff, 0xd9}
+
+const existingSidecar = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:crs="http://ns.adobe.com/camera-raw-settings/1.0/" crs:Exposure2012="+0.35"/>
+ </rdf:RDF>
+</x:xmpmeta>
+`
+
+func testGroups(root string) []Titled {
+	addresses := map[string][]string{
+		"Westminster":    {"sublocality", "political"},
+		"London":         {"locality", "political"},
+		"England":        {"administrative_area_level_1", "political"},
+		"United Kingdom": {"country", "political"},
+	}
+
+	return []Titled{
+		{
+			Group: grouping.Group{
+				Name:     "London",
+				TripType: "day",
+				Photos: []grouping.Photo{
+					{ID: filepath.Join(root, "IMG_0001.jpg"), AddressTypes: addresses},
+					{ID: filepath.Join(root, "DSC_0002.NEF"), AddressTypes: addresses},
+					{ID: "row-3"},
+				},
+			},
+			Title: "A day out in London",
+		},
+	}
+}
+
+func setupPhotos(t *testing.T) string {
+	root := t.TempDir()
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "IMG_0001.jpg"), testJPEG, 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "DSC_0002.NEF"), []byte("raw"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "DSC_0002.xmp"), []byte(existingSidecar), 0o600))
+
+	return root
+}
+
+func readXMP(t *testing.T, path string) xmp.Metadata {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	metadata, err := xmp.Parse(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	return metadata
+}
+
+func TestWriteXMP_DryRun(t *testing.T) {
+	root := setupPhotos(t)
+
+	var diff strings.Builder
+
+	stats := WriteXMP(testGroups(root), XMPOptions{DryRun: true, Diff: &diff})
+	assert.Equal(t, XMPStats{Changed: 2, Skipped: 1}, stats)
+
+	assert.NoFileExists(t, filepath.Join(root, "IMG_0001.jpg.xmp"))
+	sidecar, err := os.ReadFile(filepath.Join(root, "DSC_0002.xmp"))
+	assert.NoError(t, err)
+	assert.Equal(t, existingSidecar, string(sidecar))
+
+	assert.Contains(t, diff.String(), "--- /dev/null\n+++ "+filepath.Join(root, "IMG_0001.jpg.xmp")+"\n")
+	assert.Contains(t, diff.String(), "+     <rdf:li>A day out in London</rdf:li>\n")
+	assert.Contains(t, diff.String(), `+   photoshop:City="London"`)
+}
+
+func TestWriteXMP(t *testing.T) {
+	root := setupPhotos(t)
+	groups := testGroups(root)
+
+	stats := WriteXMP(groups, XMPOptions{})
+	assert.Equal(t, XMPStats{Changed: 2, Skipped: 1}, stats)
+
+	jpeg, err := os.ReadFile(filepath.Join(root, "IMG_0001.jpg"))
+	assert.NoError(t, err)
+	assert.Equal(t, testJPEG, jpeg)
+
+	metadata := readXMP(t, filepath.Join(root, "IMG_0001.jpg.xmp"))
+	assert.Equal(t, []string{"A day out in London", "day"}, metadata.Keywords)
+
+	// the existing sidecar is updated, rather than a new one created.
+	assert.NoFileExists(t, filepath.Join(root, "DSC_0002.NEF.xmp"))
+	sidecar, err := os.ReadFile(filepath.Join(root, "DSC_0002.xmp"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(sidecar), `crs:Exposure2012="+0.35"`)
+	assert.Contains(t, string(sidecar), `Iptc4xmpCore:Location="Westminster"`)
+	assert.Contains(t, string(sidecar), `photoshop:State="England"`)
+	assert.Contains(t, string(sidecar), `photoshop:Country="United Kingdom"`)
+
+	stats = WriteXMP(groups, XMPOptions{})
+	assert.Equal(t, XMPStats{Unchanged: 2, Skipped: 1}, stats)
+}
+
+func TestWriteXMP_Overlapping(t *testing.T) {
+	root := setupPhotos(t)
+	groups := testGroups(root)
+
+	// the photos are also in a broader group for the country, along with photos which aren't files, whose most common
+	// city is Edinburgh.
+	edinburgh := map[string][]string{"Edinburgh": {"locality", "political"}}
+	groups = append(
+		groups, Titled{
+			Group: grouping.Group{
+				Name:     "United Kingdom",
+				TripType: "weekend",
+				Photos: []grouping.Photo{
+					groups[0].Photos[0],
+					groups[0].Photos[1],
+					groups[0].Photos[2],
+					{ID: "row-4", AddressTypes: edinburgh},
+					{ID: "row-5", AddressTypes: edinburgh},
+					{ID: "row-6", AddressTypes: edinburgh},
+				},
+			},
+			Title: "A weekend in the United Kingdom",
+		},
+	)
+
+	var diff strings.Builder
+
+	stats := WriteXMP(groups, XMPOptions{DryRun: true, Diff: &diff})
+	assert.Equal(t, XMPStats{Changed: 2, Skipped: 4}, stats)
+	assert.Equal(t, 2, strings.Count(diff.String(), "\n+++ "))
+
+	stats = WriteXMP(groups, XMPOptions{})
+	assert.Equal(t, XMPStats{Changed: 2, Skipped: 4}, stats)
+
+	metadata := readXMP(t, filepath.Join(root, "IMG_0001.jpg.xmp"))
+	assert.Equal(
+		t, []string{"A day out in London", "day", "A weekend in the United Kingdom", "weekend"}, metadata.Keywords,
+	)
+
+	// the city is taken from the narrowest group, rather than the country's most common city.
+	sidecar, err := os.ReadFile(filepath.Join(root, "IMG_0001.jpg.xmp"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(sidecar), `photoshop:City="London"`)
+
+	stats = WriteXMP(groups, XMPOptions{})
+	assert.Equal(t, XMPStats{Unchanged: 2, Skipped: 4}, stats)
+}
+
+func TestWriteXMP_Embed(t *testing.T) {
+	root := setupPhotos(t)
+	groups := testGroups(root)
+
+	stats := WriteXMP(groups, XMPOptions{Embed: true})
+	assert.Equal(t, XMPStats{Changed: 2, Skipped: 1}, stats)
+
+	// only the JPEG has its XMP embedded, the RAW file still has a sidecar.
+	assert.NoFileExists(t, filepath.Join(root, "IMG_0001.jpg.xmp"))
+
+	jpeg, err := os.ReadFile(filepath.Join(root, "IMG_0001.jpg"))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasSuffix(jpeg, testJPEG[8:]))
+
+	packet, err := xmp.EmbeddedJPEG(jpeg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(packet), `photoshop:City="London"`)
+
+	raw, err := os.ReadFile(filepath.Join(root, "DSC_0002.NEF"))
+	assert.NoError(t, err)
+	assert.Equal(t, "raw", string(raw))
+
+	stats = WriteXMP(groups, XMPOptions{Embed: true})
+	assert.Equal(t, XMPStats{Unchanged: 2, Skipped: 1}, stats)
+}
+
+func TestPlaceOf(t *testing.T) {
+	photos := []grouping.Photo{
+		{AddressTypes: map[string][]string{"Paris": {"locality"}, "France": {"country"}}},
+		{AddressTypes: map[string][]string{"Paris": {"locality"}, "France": {"country"}}},
+		{AddressTypes: map[string][]string{"Geneva": {"locality"}, "Switzerland": {"country"}}},
+		{AddressTypes: map[string][]string{"Montmartre": {"neighborhood"}}},
+		{},
+	}
+
+	assert.Equal(t, place{Location: "Montmartre", City: "Paris", Country: "France"}, placeOf(photos))
+}
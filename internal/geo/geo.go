@@ -0,0 +1,80 @@
+package geo
+
+import (
+	"math"
+)
+
+const (
+	// earthRadius is the mean radius of the earth in metres.
+	earthRadius = 6371008.8
+
+	// metresPerDegree is the approximate number of metres in a degree of latitude.
+	metresPerDegree = 111320
+
+	// maxLatitude caps the latitude used to widen Grid.Nearby, so that the number of cells stays bounded near the poles.
+	maxLatitude = 89
+)
+
+// Distance returns the great-circle distance in metres between two points using the haversine formula.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1 := radians(lat1)
+	phi2 := radians(lat2)
+	deltaPhi := radians(lat2 - lat1)
+	deltaLambda := radians(lng2 - lng1)
+
+	a := math.Sin(deltaPhi/2)*math.Sin(deltaPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(deltaLambda/2)*math.Sin(deltaLambda/2)
+
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Cell is a single square within a Grid, identified by its row (latitude) and column (longitude).
+type Cell struct {
+	Row    int64
+	Column int64
+}
+
+// Grid splits the earth into cells of Size by Size degrees, where Size is the number of degrees of latitude that
+// covers the given number of metres. As a degree of longitude gets shorter towards the poles, cells are narrower
+// the further they are from the equator.
+type Grid struct {
+	Size float64
+}
+
+// NewGrid creates a Grid where each cell is metres tall.
+func NewGrid(metres float64) Grid {
+	return Grid{
+		Size: metres / metresPerDegree,
+	}
+}
+
+// Cell returns the Cell the point is within.
+func (g Grid) Cell(lat, lng float64) Cell {
+	return Cell{
+		Row:    int64(math.Floor(lat / g.Size)),
+		Column: int64(math.Floor(lng / g.Size)),
+	}
+}
+
+// Nearby returns every Cell that could contain a point within one cell height, in metres, of the given point.
+// Towards the poles more columns are returned, as each cell covers fewer metres of longitude.
+func (g Grid) Nearby(lat, lng float64) []Cell {
+	centre := g.Cell(lat, lng)
+
+	columns := int64(1)
+	if c := math.Cos(radians(math.Min(math.Abs(lat), maxLatitude))); c > 0 {
+		columns = int64(math.Ceil(1 / c))
+	}
+
+	cells := make([]Cell, 0, 3*(2*columns+1))
+	for row := centre.Row - 1; row <= centre.Row+1; row++ {
+		for column := centre.Column - columns; column <= centre.Column+columns; column++ {
+			cells = append(cells, Cell{Row: row, Column: column})
+		}
+	}
+
+	return cells
+}
@@ -0,0 +1,136 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat1     float64
+		lng1     float64
+		lat2     float64
+		lng2     float64
+		expected float64
+	}{
+		{
+			name:     "same point",
+			lat1:     51.5072,
+			lng1:     -0.1276,
+			lat2:     51.5072,
+			lng2:     -0.1276,
+			expected: 0,
+		},
+		{
+			name:     "london to paris",
+			lat1:     51.5072,
+			lng1:     -0.1276,
+			lat2:     48.8566,
+			lng2:     2.3522,
+			expected: 343900,
+		},
+		{
+			name:     "across the antimeridian",
+			lat1:     0,
+			lng1:     179.5,
+			lat2:     0,
+			lng2:     -179.5,
+			expected: 111195,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := Distance(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+				assert.InDelta(t, tt.expected, got, 500)
+			},
+		)
+	}
+}
+
+func TestGrid_Cell(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat      float64
+		lng      float64
+		expected Cell
+	}{
+		{
+			name:     "positive coordinates",
+			lat:      51.5072,
+			lng:      0.1276,
+			expected: Cell{Row: 57337, Column: 142},
+		},
+		{
+			name:     "negative coordinates round down",
+			lat:      -0.0001,
+			lng:      -0.1276,
+			expected: Cell{Row: -1, Column: -143},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := NewGrid(100).Cell(tt.lat, tt.lng)
+				assert.Equal(t, tt.expected, got)
+			},
+		)
+	}
+}
+
+func TestGrid_Nearby(t *testing.T) {
+	tests := []struct {
+		name          string
+		lat           float64
+		lng           float64
+		expectedCells int
+	}{
+		{
+			name:          "equator has three columns",
+			lat:           0,
+			lng:           0,
+			expectedCells: 9,
+		},
+		{
+			name:          "higher latitudes have more columns",
+			lat:           70,
+			lng:           0,
+			expectedCells: 21,
+		},
+		{
+			name:          "poles are capped",
+			lat:           -90,
+			lng:           0,
+			expectedCells: 351,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				grid := NewGrid(100)
+				got := grid.Nearby(tt.lat, tt.lng)
+
+				assert.Len(t, got, tt.expectedCells)
+				assert.Contains(t, got, grid.Cell(tt.lat, tt.lng))
+			},
+		)
+	}
+}
+
+func TestGrid_NearbyContainsPointsWithinACell(t *testing.T) {
+	grid := NewGrid(100)
+
+	for _, lat := range []float64{0, 45, 60, 80, -75} {
+		for _, bearing := range []struct{ lat, lng float64 }{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {0.7, 0.7}, {-0.7, 0.7}} {
+			// move 99 metres from the point along the bearing
+			dLat := bearing.lat * 99 / metresPerDegree
+			dLng := bearing.lng * 99 / (metresPerDegree * math.Cos(radians(lat)))
+
+			assert.LessOrEqual(t, Distance(lat, 10, lat+dLat, 10+dLng), 100.0)
+			assert.Contains(t, grid.Nearby(lat, 10), grid.Cell(lat+dLat, 10+dLng))
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package gpx geotags photos taken by cameras without GPS, using track logs recorded at the same time by another
+// device. The position of a photo is interpolated from the track points recorded either side of it.
+package gpx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNoPoints is returned by Parse when a GPX file has no track points with a time.
+	ErrNoPoints = errors.New("no track points with a time")
+
+	// DefaultOptions are the Options used when no other configuration is provided.
+	DefaultOptions = Options{
+		MaxGap: time.Minute * 5,
+	}
+)
+
+// Point is a single position recorded in a Track.
+type Point struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+}
+
+// Track is a series of Points ordered by time.
+type Track struct {
+	points []Point
+}
+
+// Points returns a copy of the Points in the Track, ordered by time.
+func (t Track) Points() []Point {
+	return append([]Point(nil), t.points...)
+}
+
+// gpxFile holds the parts of a GPX 1.0 or 1.1 file which are needed to build a Track.
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type gpxPoint struct {
+	Latitude  float64 `xml:"lat,attr"`
+	Longitude float64 `xml:"lon,attr"`
+	Time      string  `xml:"time"`
+}
+
+// Parse reads every track point from a GPX file. Points without a time can't be correlated with a photo, so are
+// skipped, and if no points have a time ErrNoPoints is returned.
+func Parse(r io.Reader) (Track, error) {
+	file := gpxFile{}
+
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return Track{}, fmt.Errorf("decoding gpx: %w", err)
+	}
+
+	points := make([]Point, 0)
+
+	for _, track := range file.Tracks {
+		for _, segment := range track.Segments {
+			for _, point := range segment.Points {
+				if point.Time == "" {
+					continue
+				}
+
+				timestamp, err := parseTime(point.Time)
+				if err != nil {
+					return Track{}, err
+				}
+
+				points = append(
+					points, Point{
+						Time:      timestamp,
+						Latitude:  point.Latitude,
+						Longitude: point.Longitude,
+					},
+				)
+			}
+		}
+	}
+
+	if len(points) == 0 {
+		return Track{}, ErrNoPoints
+	}
+
+	return newTrack(points), nil
+}
+
+// Load parses each of the GPX files at paths and merges them into a single Track.
+func Load(paths ...string) (Track, error) {
+	tracks := make([]Track, 0, len(paths))
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return Track{}, fmt.Errorf("opening gpx: %w", err)
+		}
+
+		track, err := Parse(file)
+		file.Close()
+
+		if err != nil {
+			return Track{}, fmt.Errorf("%s: %w", path, err)
+		}
+
+		tracks = append(tracks, track)
+	}
+
+	return Merge(tracks...), nil
+}
+
+// Merge combines tracks into a single Track, such as the logs of several days, or of several devices.
+func Merge(tracks ...Track) Track {
+	points := make([]Point, 0)
+
+	for _, track := range tracks {
+		points = append(points, track.points...)
+	}
+
+	return newTrack(points)
+}
+
+func newTrack(points []Point) Track {
+	sort.SliceStable(
+		points, func(i, j int) bool {
+			return points[i].Time.Before(points[j].Time)
+		},
+	)
+
+	return Track{points: points}
+}
+
+// parseTime parses a GPX time, which is an xsd:dateTime. Times without a zone are treated as UTC.
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if timestamp, err := time.Parse(layout, value); err == nil {
+			return timestamp, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("parsing time %q", value)
+}
+
+// Options configures a Locator.
+//
+// Offset is added to a photo's timestamp to find the time on the track, it corrects a camera clock which is wrong, or
+// set to a different timezone to the one its timestamps are read in. A camera running 5 minutes slow has an Offset of
+// 5 minutes.
+//
+// MaxGap is how far apart the points either side of a photo can be for its position to be interpolated between them.
+// Otherwise, the photo is placed at the nearest point, as long as it's within MaxGap, so photos taken while the GPS had
+// no signal, or before the track started, aren't placed far from where they were taken.
+type Options struct {
+	Offset time.Duration
+	MaxGap time.Duration
+}
+
+// Locator finds where photos were taken from their timestamps using a Track.
+type Locator struct {
+	track   Track
+	options Options
+}
+
+// NewLocator returns a Locator for the Track.
+func NewLocator(track Track, options Options) *Locator {
+	return &Locator{
+		track:   track,
+		options: options,
+	}
+}
+
+// Locate returns the latitude and longitude at timestamp, corrected by Options.Offset. If the track has no point
+// within Options.MaxGap of timestamp, false is returned.
+func (l *Locator) Locate(timestamp time.Time) (float64, float64, bool) {
+	points := l.track.points
+	at := timestamp.Add(l.options.Offset)
+
+	// i is the first point after at, so points[i-1] is the last point at or before it.
+	i := sort.Search(
+		len(points), func(i int) bool {
+			return points[i].Time.After(at)
+		},
+	)
+
+	var before, after *Point
+	if i > 0 {
+		before = &points[i-1]
+	}
+	if i < len(points) {
+		after = &points[i]
+	}
+
+	switch {
+	case before != nil && before.Time.Equal(at):
+		return before.Latitude, before.Longitude, true
+	case before != nil && after != nil && after.Time.Sub(before.Time) <= l.options.MaxGap:
+		return interpolate(*before, *after, at)
+	}
+
+	nearest := before
+	if nearest == nil || (after != nil && after.Time.Sub(at) < at.Sub(before.Time)) {
+		nearest = after
+	}
+
+	if nearest == nil || absDuration(nearest.Time.Sub(at)) > l.options.MaxGap {
+		return 0, 0, false
+	}
+
+	return nearest.Latitude, nearest.Longitude, true
+}
+
+// interpolate returns the position at, linearly between before and after. Longitudes are interpolated the short way
+// around, so that a track crossing the antimeridian doesn't pass through the other side of the world.
+func interpolate(before, after Point, at time.Time) (float64, float64, bool) {
+	fraction := float64(at.Sub(before.Time)) / float64(after.Time.Sub(before.Time))
+
+	longitudeDelta := after.Longitude - before.Longitude
+	if longitudeDelta > 180 {
+		longitudeDelta -= 360
+	} else if longitudeDelta < -180 {
+		longitudeDelta += 360
+	}
+
+	longitude := before.Longitude + longitudeDelta*fraction
+	if longitude > 180 {
+		longitude -= 360
+	} else if longitude < -180 {
+		longitude += 360
+	}
+
+	return before.Latitude + (after.Latitude-before.Latitude)*fraction, longitude, true
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
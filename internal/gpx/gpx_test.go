@@ -0,0 +1,208 @@
+package gpx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <trkseg>
+      <trkpt lat="51.5000" lon="-0.1000"><time>2022-04-02T10:02:00Z</time></trkpt>
+      <trkpt lat="51.5100" lon="-0.1200"><time>2022-04-02T10:00:00Z</time></trkpt>
+      <trkpt lat="51.6000" lon="-0.2000"></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="51.7000" lon="-0.3000"><time>2022-04-02T11:00:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func testPoint(minutes float64, latitude, longitude float64) Point {
+	return Point{
+		Time:      time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC).Add(time.Duration(minutes * float64(time.Minute))),
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contents    string
+		expected    []Point
+		expectedErr string
+	}{
+		{
+			name:     "parses track points with a time in order",
+			contents: testGPX,
+			expected: []Point{
+				testPoint(0, 51.51, -0.12),
+				testPoint(2, 51.5, -0.1),
+				testPoint(60, 51.7, -0.3),
+			},
+		},
+		{
+			name:        "returns error without any timed points",
+			contents:    `<gpx><trk><trkseg><trkpt lat="1" lon="1"></trkpt></trkseg></trk></gpx>`,
+			expectedErr: "no track points with a time",
+		},
+		{
+			name:        "returns error for invalid time",
+			contents:    `<gpx><trk><trkseg><trkpt lat="1" lon="1"><time>yesterday</time></trkpt></trkseg></trk></gpx>`,
+			expectedErr: `parsing time "yesterday"`,
+		},
+		{
+			name:        "returns error for invalid xml",
+			contents:    `<gpx><trk>`,
+			expectedErr: "decoding gpx: XML syntax error on line 1: unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := Parse(strings.NewReader(tt.contents))
+				if tt.expectedErr != "" {
+					assert.EqualError(t, err, tt.expectedErr)
+					return
+				}
+
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, got.Points())
+			},
+		)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	first := filepath.Join(dir, "first.gpx")
+	second := filepath.Join(dir, "second.gpx")
+
+	assert.NoError(t, os.WriteFile(first, []byte(testGPX), 0o600))
+	assert.NoError(
+		t, os.WriteFile(
+			second,
+			[]byte(`<gpx><trk><trkseg><trkpt lat="52" lon="1"><time>2022-04-02T10:30:00</time></trkpt></trkseg></trk></gpx>`),
+			0o600,
+		),
+	)
+
+	track, err := Load(first, second)
+	assert.NoError(t, err)
+	assert.Equal(
+		t, []Point{
+			testPoint(0, 51.51, -0.12),
+			testPoint(2, 51.5, -0.1),
+			testPoint(30, 52, 1),
+			testPoint(60, 51.7, -0.3),
+		}, track.Points(),
+	)
+
+	_, err = Load(filepath.Join(dir, "missing.gpx"))
+	assert.Error(t, err)
+}
+
+func TestLocator_Locate(t *testing.T) {
+	track := Merge(
+		Track{
+			points: []Point{
+				testPoint(0, 50, 10),
+				testPoint(4, 52, 14),
+				testPoint(60, 60, 20),
+				testPoint(62, 0, 179),
+				testPoint(64, 0, -179),
+			},
+		},
+	)
+
+	tests := []struct {
+		name              string
+		minutes           float64
+		options           Options
+		expectedLatitude  float64
+		expectedLongitude float64
+		expectedOK        bool
+	}{
+		{
+			name:              "returns point at the same time",
+			minutes:           4,
+			options:           DefaultOptions,
+			expectedLatitude:  52,
+			expectedLongitude: 14,
+			expectedOK:        true,
+		},
+		{
+			name:              "interpolates between points",
+			minutes:           1,
+			options:           DefaultOptions,
+			expectedLatitude:  50.5,
+			expectedLongitude: 11,
+			expectedOK:        true,
+		},
+		{
+			name:              "applies clock offset",
+			minutes:           -59,
+			options:           Options{Offset: time.Hour, MaxGap: time.Minute * 5},
+			expectedLatitude:  50.5,
+			expectedLongitude: 11,
+			expectedOK:        true,
+		},
+		{
+			name:              "interpolates across the antimeridian",
+			minutes:           63,
+			options:           DefaultOptions,
+			expectedLatitude:  0,
+			expectedLongitude: 180,
+			expectedOK:        true,
+		},
+		{
+			name:              "uses nearest point when points either side are too far apart",
+			minutes:           6,
+			options:           DefaultOptions,
+			expectedLatitude:  52,
+			expectedLongitude: 14,
+			expectedOK:        true,
+		},
+		{
+			name:              "uses first point before the track starts",
+			minutes:           -3,
+			options:           DefaultOptions,
+			expectedLatitude:  50,
+			expectedLongitude: 10,
+			expectedOK:        true,
+		},
+		{
+			name:       "doesn't locate photo too far from any point",
+			minutes:    30,
+			options:    DefaultOptions,
+			expectedOK: false,
+		},
+		{
+			name:       "doesn't locate photo too long after the track ends",
+			minutes:    70,
+			options:    DefaultOptions,
+			expectedOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				timestamp := testPoint(tt.minutes, 0, 0).Time
+
+				latitude, longitude, ok := NewLocator(track, tt.options).Locate(timestamp)
+
+				assert.Equal(t, tt.expectedOK, ok)
+				assert.InDelta(t, tt.expectedLatitude, latitude, 0.0001)
+				assert.InDelta(t, tt.expectedLongitude, longitude, 0.0001)
+			},
+		)
+	}
+}
@@ -3,6 +3,7 @@ package heap
 import (
 	"container/heap"
 	"errors"
+	"sort"
 	"sync"
 )
 
@@ -40,9 +41,11 @@ func (h *Heap) Push(photo Photo) {
 // If the length is 0 we return ErrEmptyHeap.
 //
 // As all items that are pushed onto the queue are strongly typed, type casting from v to Photo is guaranteed to work.
+// Pop modifies the heap, so it takes the write lock, allowing it to be safely called while photos are still being
+// pushed.
 func (h *Heap) Pop() (Photo, error) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
 	if h.photoHeap.Len() == 0 {
 		return Photo{}, ErrEmptyHeap
@@ -53,3 +56,41 @@ func (h *Heap) Pop() (Photo, error) {
 
 	return photo, nil
 }
+
+// Peek returns the first item on the heap without removing it. If the length is 0 we return ErrEmptyHeap.
+func (h *Heap) Peek() (Photo, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.photoHeap.Len() == 0 {
+		return Photo{}, ErrEmptyHeap
+	}
+
+	return (*h.photoHeap)[0], nil
+}
+
+// Len returns the number of photos on the heap.
+func (h *Heap) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.photoHeap.Len()
+}
+
+// Photos returns a copy of every Photo on the heap, ordered by timestamp, without removing them.
+// This allows other stages to look at the photos before they are popped.
+func (h *Heap) Photos() []Photo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	photos := make([]Photo, len(*h.photoHeap))
+	copy(photos, *h.photoHeap)
+
+	sort.SliceStable(
+		photos, func(i, j int) bool {
+			return photos[i].Timestamp.Before(photos[j].Timestamp)
+		},
+	)
+
+	return photos
+}
@@ -154,3 +154,129 @@ func TestHeap_Pop(t *testing.T) {
 		)
 	}
 }
+
+func TestHeap_Photos(t *testing.T) {
+	first := Photo{
+		Timestamp: time.Date(2022, 01, 02, 10, 11, 12, 0, time.UTC),
+		Latitude:  -45,
+		Longitude: 10,
+	}
+	second := Photo{
+		Timestamp: time.Date(2022, 01, 03, 10, 11, 12, 0, time.UTC),
+		Latitude:  -45,
+		Longitude: 10,
+	}
+	third := Photo{
+		Timestamp: time.Date(2022, 01, 04, 10, 11, 12, 0, time.UTC),
+		Latitude:  -45,
+		Longitude: 10,
+	}
+
+	tests := []struct {
+		name     string
+		photos   []Photo
+		expected []Photo
+	}{
+		{
+			name:     "returns photos in order",
+			photos:   []Photo{third, first, second},
+			expected: []Photo{first, second, third},
+		},
+		{
+			name:     "returns empty slice on empty heap",
+			photos:   []Photo{},
+			expected: []Photo{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				h := New()
+
+				for _, photo := range tt.photos {
+					h.Push(photo)
+				}
+
+				got := h.Photos()
+				assert.Equal(t, tt.expected, got)
+				assert.Equal(t, len(tt.photos), h.photoHeap.Len())
+			},
+		)
+	}
+}
+
+func TestHeap_Peek(t *testing.T) {
+	first := Photo{
+		Timestamp: time.Date(2022, 01, 02, 10, 11, 12, 0, time.UTC),
+	}
+	second := Photo{
+		Timestamp: time.Date(2022, 01, 03, 10, 11, 12, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name        string
+		photos      []Photo
+		expected    Photo
+		expectedErr error
+	}{
+		{
+			name:     "returns first photo without removing it",
+			photos:   []Photo{second, first},
+			expected: first,
+		},
+		{
+			name:        "errors on empty heap",
+			photos:      []Photo{},
+			expected:    Photo{},
+			expectedErr: ErrEmptyHeap,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				h := New()
+
+				for _, photo := range tt.photos {
+					h.Push(photo)
+				}
+
+				got, err := h.Peek()
+				assert.Equal(t, tt.expectedErr, err)
+				assert.Equal(t, tt.expected, got)
+				assert.Equal(t, len(tt.photos), h.Len())
+			},
+		)
+	}
+}
+
+func TestHeap_PopWhilePushing(t *testing.T) {
+	h := New()
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			h.Push(Photo{Timestamp: time.Date(2022, 01, 02, 10, i, 0, 0, time.UTC)})
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			_, _ = h.Pop()
+		}()
+	}
+
+	wg.Wait()
+
+	popped := 0
+	for {
+		if _, err := h.Pop(); err != nil {
+			break
+		}
+		popped++
+	}
+
+	assert.Equal(t, 0, h.Len())
+	assert.LessOrEqual(t, popped, 10)
+}
@@ -2,17 +2,63 @@ package heap
 
 import (
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
+// GeocodeStatus describes the outcome of geocoding a Photo.
+type GeocodeStatus int
+
+const (
+	// GeocodePending is the status of a Photo which hasn't been geocoded yet.
+	GeocodePending GeocodeStatus = iota
+	// GeocodeResolved is the status of a Photo which has at least one address.
+	GeocodeResolved
+	// GeocodeUnresolved is the status of a Photo which was geocoded successfully, but had no usable addresses,
+	// i.e. a photo taken at sea.
+	GeocodeUnresolved
+	// GeocodeFailed is the status of a Photo where the request to geocode it failed.
+	GeocodeFailed
+)
+
+func (s GeocodeStatus) String() string {
+	switch s {
+	case GeocodeResolved:
+		return "resolved"
+	case GeocodeUnresolved:
+		return "unresolved"
+	case GeocodeFailed:
+		return "failed"
+	default:
+		return "pending"
+	}
+}
+
 // Photo holds the attributes to a photo's geological location, timestamp, and the addresses of the photo.
 //
 // An empty struct is used as the value on the hashmap, this is because an empty struct allocates no memory.
 // The use of the hashmap is to remove any duplicate addresses
+//
+// AddressTypes holds the address component types (locality, country, etc.) of each address, so that it's possible
+// to explain where a group's name came from. Status holds the outcome of geocoding the photo.
+//
+// ID identifies the photo in the source it was read from, such as the path to the photo's file, or its ID in a photo
+// library. Photos read from a CSV don't have an ID. Keywords holds any keywords the photo was already tagged with, such
+// as in its XMP. Video is true if the photo is a video, which are grouped alongside photos.
+//
+// Spans holds the spans which have handled the photo, such as ReadCSV and getGeocoding, so that the span which groups
+// it can link to them. It's empty unless tracing is set up.
 type Photo struct {
-	Timestamp time.Time
-	Latitude  float64
-	Longitude float64
-	Addresses map[string]struct{}
+	ID           string
+	Keywords     []string
+	Video        bool
+	Timestamp    time.Time
+	Latitude     float64
+	Longitude    float64
+	Addresses    map[string]struct{}
+	AddressTypes map[string][]string
+	Status       GeocodeStatus
+	Spans        []trace.SpanContext
 }
 
 // An PhotoHeap is a min-heap of photos. PhotoHeap implements sort.Interface so that the heap can be ordered,
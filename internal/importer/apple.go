@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/consumer"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+)
+
+// Apple reads photos from an Apple Photos library exported as JSON by osxphotos, with `osxphotos query --json`.
+// Apple Photos doesn't export the metadata of a library itself, and osxphotos reads it from the library's database.
+// If locator is nil, photos without a location are skipped.
+type Apple struct {
+	r       io.Reader
+	locator consumer.Locator
+}
+
+// NewApple returns an Apple which reads the export from r.
+func NewApple(r io.Reader) *Apple {
+	return &Apple{
+		r: r,
+	}
+}
+
+// NewAppleWithLocator returns an Apple which reads the export from r, see NewApple. Photos without a location are
+// located with locator, and skipped if it can't locate them.
+func NewAppleWithLocator(r io.Reader, locator consumer.Locator) *Apple {
+	apple := NewApple(r)
+	apple.locator = locator
+
+	return apple
+}
+
+// applePhoto holds the parts of a photo in an osxphotos export which are needed to build a heap.Photo. date is the
+// time the photo was taken, in the timezone it was taken in.
+type applePhoto struct {
+	UUID      string   `json:"uuid"`
+	Date      string   `json:"date"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// Read decodes the export one photo at a time and returns a channel of the photos it holds. Each photo's ID is its
+// UUID in the library, and its timestamp keeps the timezone it was taken in.
+//
+// Read honours context.Context in the same way as consumer.Reader.ReadCSV.
+func (a *Apple) Read(ctx context.Context) <-chan heap.Photo {
+	photoChan := make(chan heap.Photo)
+
+	go func() {
+		defer close(photoChan)
+
+		decoder := json.NewDecoder(a.r)
+
+		token, err := decoder.Token()
+		if err != nil {
+			reject("", fmt.Errorf("decoding export: %w", err))
+			return
+		}
+
+		if token != json.Delim('[') {
+			reject("", errors.New("decoding export: expected an array of photos"))
+			return
+		}
+
+		for decoder.More() {
+			if ctx.Err() != nil {
+				return
+			}
+
+			photo := applePhoto{}
+			if err := decoder.Decode(&photo); err != nil {
+				// the rest of the export can't be decoded once its syntax is invalid.
+				reject("", fmt.Errorf("decoding export: %w", err))
+				return
+			}
+
+			r, err := photo.record()
+			if err != nil {
+				reject(photo.UUID, err)
+				continue
+			}
+
+			if !send(ctx, photoChan, r, a.locator) {
+				return
+			}
+		}
+	}()
+
+	return photoChan
+}
+
+func (p applePhoto) record() (record, error) {
+	timestamp, err := time.Parse(time.RFC3339Nano, p.Date)
+	if err != nil {
+		return record{}, fmt.Errorf("parsing date: %w", err)
+	}
+
+	r := record{
+		photo: heap.Photo{
+			ID:        p.UUID,
+			Timestamp: timestamp,
+		},
+	}
+
+	if p.Latitude != nil && p.Longitude != nil {
+		r.photo.Latitude = *p.Latitude
+		r.photo.Longitude = *p.Longitude
+		r.located = true
+	}
+
+	return r, nil
+}
@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApple_Read(t *testing.T) {
+	tests := []struct {
+		name     string
+		export   string
+		expected []heap.Photo
+	}{
+		{
+			name: "reads photos with a location",
+			export: `[
+  {"uuid": "A1B2", "original_filename": "IMG_0001.HEIC", "date": "2022-04-02T10:52:59.123+01:00", "latitude": 51.5072, "longitude": -0.1276},
+  {"uuid": "C3D4", "original_filename": "IMG_0002.HEIC", "date": "2022-04-02T11:00:00+01:00", "latitude": null, "longitude": null},
+  {"uuid": "E5F6", "original_filename": "IMG_0003.HEIC", "date": "not a date", "latitude": 1, "longitude": 1},
+  {"uuid": "G7H8", "original_filename": "IMG_0004.HEIC", "date": "2022-04-03T09:00:00-05:00", "latitude": 40.7128, "longitude": -74.006}
+]`,
+			expected: []heap.Photo{
+				{
+					ID:        "A1B2",
+					Timestamp: time.Date(2022, 04, 02, 10, 52, 59, 123000000, time.FixedZone("", 60*60)),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+				},
+				{
+					ID:        "G7H8",
+					Timestamp: time.Date(2022, 04, 03, 9, 0, 0, 0, time.FixedZone("", -5*60*60)),
+					Latitude:  40.7128,
+					Longitude: -74.006,
+				},
+			},
+		},
+		{
+			name:     "stops at invalid syntax",
+			export:   `[{"uuid": "A1B2", "date": "2022-04-02T10:52:59Z", "latitude": 1, "longitude": 1}, {"uuid":`,
+			expected: []heap.Photo{{ID: "A1B2", Timestamp: time.Date(2022, 04, 02, 10, 52, 59, 0, time.UTC), Latitude: 1, Longitude: 1}},
+		},
+		{
+			name:     "rejects export which isn't an array",
+			export:   `{"uuid": "A1B2"}`,
+			expected: []heap.Photo{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := make([]heap.Photo, 0)
+				for photo := range NewApple(strings.NewReader(tt.export)).Read(context.Background()) {
+					got = append(got, photo)
+				}
+
+				assert.Equal(t, len(tt.expected), len(got))
+
+				for i := range tt.expected {
+					assert.Equal(t, tt.expected[i].ID, got[i].ID)
+					assert.True(t, tt.expected[i].Timestamp.Equal(got[i].Timestamp), got[i].Timestamp)
+					assert.Equal(t, tt.expected[i].Timestamp.Format(time.RFC3339Nano), got[i].Timestamp.Format(time.RFC3339Nano))
+					assert.Equal(t, tt.expected[i].Latitude, got[i].Latitude)
+					assert.Equal(t, tt.expected[i].Longitude, got[i].Longitude)
+				}
+			},
+		)
+	}
+}
+
+// mockLocator locates every photo taken on 2022-04-02 at the same position.
+type mockLocator struct{}
+
+func (m mockLocator) Locate(timestamp time.Time) (float64, float64, bool) {
+	if timestamp.UTC().YearDay() != time.Date(2022, 04, 02, 0, 0, 0, 0, time.UTC).YearDay() {
+		return 0, 0, false
+	}
+
+	return 40.728808, -73.996106, true
+}
+
+func TestApple_ReadWithLocator(t *testing.T) {
+	export := `[
+  {"uuid": "A1B2", "date": "2022-04-02T10:52:59+01:00", "latitude": 51.5072, "longitude": -0.1276},
+  {"uuid": "C3D4", "date": "2022-04-02T11:00:00+01:00", "latitude": null, "longitude": null},
+  {"uuid": "E5F6", "date": "2022-04-05T11:00:00+01:00", "latitude": null, "longitude": null}
+]`
+
+	got := make([]heap.Photo, 0)
+	for photo := range NewAppleWithLocator(strings.NewReader(export), mockLocator{}).Read(context.Background()) {
+		got = append(got, photo)
+	}
+
+	assert.Len(t, got, 2)
+
+	// a photo with a location keeps it
+	assert.Equal(t, "A1B2", got[0].ID)
+	assert.Equal(t, 51.5072, got[0].Latitude)
+	assert.Equal(t, -0.1276, got[0].Longitude)
+
+	// a photo without a location is located, and one which can't be located is skipped
+	assert.Equal(t, "C3D4", got[1].ID)
+	assert.Equal(t, 40.728808, got[1].Latitude)
+	assert.Equal(t, -73.996106, got[1].Longitude)
+}
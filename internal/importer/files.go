@@ -0,0 +1,248 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JackFazackerley/photo-grouping/internal/consumer"
+	"github.com/JackFazackerley/photo-grouping/internal/exif"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/video"
+	"github.com/JackFazackerley/photo-grouping/internal/xmp"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// errNoCaptureTime is the reason a photo is rejected when none of its metadata has the time it was taken.
+	errNoCaptureTime = errors.New("no capture time")
+
+	// imageExtensions are the extensions of the files read as photos, any other file which isn't a video is skipped.
+	imageExtensions = map[string]struct{}{
+		".jpg":  {},
+		".jpeg": {},
+		".png":  {},
+		".tif":  {},
+		".tiff": {},
+		".heic": {},
+		".heif": {},
+		".dng":  {},
+		".cr2":  {},
+		".cr3":  {},
+		".nef":  {},
+		".arw":  {},
+		".orf":  {},
+		".raf":  {},
+		".rw2":  {},
+	}
+
+	// videoExtensions are the extensions of the files read as videos.
+	videoExtensions = map[string]struct{}{
+		".mp4": {},
+		".m4v": {},
+		".mov": {},
+		".3gp": {},
+	}
+
+	// DefaultFilesOptions are the FilesOptions used when no other configuration is provided.
+	DefaultFilesOptions = FilesOptions{
+		Precedence: xmp.PreferSidecar,
+	}
+)
+
+// FilesOptions configures Files.
+//
+// Precedence decides whether a photo's sidecar or embedded metadata is used when both set the same field. Locator
+// locates photos without a location from their timestamps, if it isn't nil, otherwise they're skipped.
+type FilesOptions struct {
+	Precedence xmp.Precedence
+	Locator    consumer.Locator
+}
+
+// Files reads photos from a directory of image files, using the metadata embedded in each file along with any XMP
+// sidecar kept next to it. A file's embedded metadata is its XMP, falling back to its EXIF for the fields its XMP
+// doesn't set, so that photos straight from a camera or phone, which only have EXIF, can be read. Videos are read in the
+// same way, from their MP4 or QuickTime metadata rather than EXIF.
+type Files struct {
+	root    string
+	options FilesOptions
+}
+
+// NewFiles returns a Files which reads every photo in the directory root, and its subdirectories.
+func NewFiles(root string, options FilesOptions) *Files {
+	return &Files{
+		root:    root,
+		options: options,
+	}
+}
+
+// Read walks the directory and returns a channel of the photos and videos in it. Each photo's ID is the path to its
+// file.
+//
+// Read honours context.Context in the same way as consumer.Reader.ReadCSV.
+func (f *Files) Read(ctx context.Context) <-chan heap.Photo {
+	photoChan := make(chan heap.Photo)
+
+	go func() {
+		defer close(photoChan)
+
+		err := filepath.WalkDir(
+			f.root, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				extension := strings.ToLower(filepath.Ext(path))
+				_, image := imageExtensions[extension]
+				_, video := videoExtensions[extension]
+
+				if entry.IsDir() || (!image && !video) {
+					return nil
+				}
+
+				r, err := f.readPhoto(path, video)
+				if err != nil {
+					reject(path, err)
+					return nil
+				}
+
+				if send(ctx, photoChan, r, f.options.Locator) {
+					return nil
+				}
+
+				return ctx.Err()
+			},
+		)
+		if err != nil && ctx.Err() == nil {
+			reject(f.root, err)
+		}
+	}()
+
+	return photoChan
+}
+
+// readPhoto reads the metadata of the photo, or video, at path, combining its embedded metadata with its sidecar, if it
+// has one.
+func (f *Files) readPhoto(path string, video bool) (record, error) {
+	embedded, err := readEmbedded(path, video)
+	if err != nil {
+		return record{}, err
+	}
+
+	sidecar, err := readSidecar(path)
+	if err != nil {
+		return record{}, err
+	}
+
+	metadata := f.options.Precedence.Combine(embedded, sidecar)
+	if metadata.Taken.IsZero() {
+		return record{}, errNoCaptureTime
+	}
+
+	return record{
+		photo: heap.Photo{
+			ID:        path,
+			Timestamp: metadata.Taken,
+			Latitude:  metadata.Latitude,
+			Longitude: metadata.Longitude,
+			Keywords:  metadata.Keywords,
+			Video:     video,
+		},
+		located: metadata.Located,
+	}, nil
+}
+
+// readEmbedded reads the metadata embedded in the file at path, which is its XMP, falling back to its EXIF, or its MP4
+// metadata if it's a video. Metadata which can't be read is logged and ignored rather than rejecting the photo, as its
+// sidecar may have everything that's needed.
+func readEmbedded(path string, isVideo bool) (xmp.Metadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return xmp.Metadata{}, fmt.Errorf("opening photo: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return xmp.Metadata{}, fmt.Errorf("opening photo: %w", err)
+	}
+
+	fallback, err := readFallback(file, info.Size(), isVideo)
+	if err != nil {
+		log.WithError(err).WithField("photo", path).Warn("reading embedded metadata")
+	}
+
+	extract := xmp.Extract
+	if isVideo {
+		extract = xmp.ExtractISOBMFF
+	}
+
+	metadata, _, err := extract(file, info.Size())
+	if err != nil {
+		log.WithError(err).WithField("photo", path).Warn("reading embedded xmp")
+	}
+
+	return xmp.Merge(metadata, fallback), nil
+}
+
+// readFallback reads the metadata which is used for the fields a file's XMP doesn't set, which is EXIF for a photo and
+// the MP4 metadata for a video.
+func readFallback(file *os.File, size int64, isVideo bool) (xmp.Metadata, error) {
+	if isVideo {
+		metadata, _, err := video.Read(file, size)
+		if err != nil {
+			return xmp.Metadata{}, fmt.Errorf("reading video metadata: %w", err)
+		}
+
+		return xmp.Metadata{
+			Taken:     metadata.Taken,
+			Latitude:  metadata.Latitude,
+			Longitude: metadata.Longitude,
+			Located:   metadata.Located,
+		}, nil
+	}
+
+	metadata, _, err := exif.Read(file, size)
+	if err != nil {
+		return xmp.Metadata{}, fmt.Errorf("reading exif: %w", err)
+	}
+
+	return xmp.Metadata{
+		Taken:     metadata.Taken,
+		Latitude:  metadata.Latitude,
+		Longitude: metadata.Longitude,
+		Located:   metadata.Located,
+	}, nil
+}
+
+// readSidecar reads the XMP sidecar of the photo at path, if it has one, see xmp.SidecarPaths.
+func readSidecar(path string) (xmp.Metadata, error) {
+	for _, sidecarPath := range xmp.SidecarPaths(path) {
+		file, err := os.Open(sidecarPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return xmp.Metadata{}, fmt.Errorf("opening sidecar: %w", err)
+		}
+
+		metadata, err := xmp.Parse(file)
+		file.Close()
+
+		if err != nil {
+			return xmp.Metadata{}, fmt.Errorf("reading sidecar %s: %w", sidecarPath, err)
+		}
+
+		return metadata, nil
+	}
+
+	return xmp.Metadata{}, nil
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/xmp"
+	"github.com/stretchr/testify/assert"
+)
+
+func xmpPacket(properties string) string {
+	return `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/" ` + properties + `/>
+</rdf:RDF></x:xmpmeta>`
+}
+
+func TestFiles_Read(t *testing.T) {
+	root := t.TempDir()
+
+	// a JPEG with embedded XMP, whose location was corrected in Lightroom.
+	writeSidecar(
+		t, filepath.Join(root, "2022", "IMG_0001.jpg"),
+		"\xff\xd8\xff\xe1"+xmpPacket(`exif:DateTimeOriginal="2022-04-02T10:52:59Z" exif:GPSLatitude="1,0N" exif:GPSLongitude="1,0E"`),
+	)
+	writeSidecar(
+		t, filepath.Join(root, "2022", "IMG_0001.xmp"),
+		xmpPacket(`exif:GPSLatitude="51,30.432N" exif:GPSLongitude="0,7.656W"`),
+	)
+	// a RAW file with only a darktable sidecar.
+	writeSidecar(t, filepath.Join(root, "2022", "DSC_0002.NEF"), "raw")
+	writeSidecar(
+		t, filepath.Join(root, "2022", "DSC_0002.NEF.xmp"),
+		xmpPacket(`exif:DateTimeOriginal="2022-04-02T11:00:00Z" exif:GPSLatitude="51,30.432N" exif:GPSLongitude="0,7.656W"`),
+	)
+	// a video with only a sidecar.
+	writeSidecar(t, filepath.Join(root, "2022", "VID_0005.mp4"), "\x00\x00\x00\x08ftyp")
+	writeSidecar(
+		t, filepath.Join(root, "2022", "VID_0005.mp4.xmp"),
+		xmpPacket(`exif:DateTimeOriginal="2022-04-02T13:00:00Z" exif:GPSLatitude="51,30.432N" exif:GPSLongitude="0,7.656W"`),
+	)
+	// a JPEG whose EXIF can't be read, which is read from its sidecar.
+	writeSidecar(t, filepath.Join(root, "2022", "IMG_0006.jpg"), "\xff\xd8\xff\xe1\x00\x10Exif\x00\x00XXXXXXXX\xff\xd9")
+	writeSidecar(
+		t, filepath.Join(root, "2022", "IMG_0006.xmp"),
+		xmpPacket(`exif:DateTimeOriginal="2022-04-02T14:00:00Z" exif:GPSLatitude="51,30.432N" exif:GPSLongitude="0,7.656W"`),
+	)
+	// no location, so it's skipped.
+	writeSidecar(t, filepath.Join(root, "2022", "IMG_0003.jpg"), xmpPacket(`exif:DateTimeOriginal="2022-04-02T12:00:00Z"`))
+	// no capture time, so it's rejected.
+	writeSidecar(t, filepath.Join(root, "2022", "IMG_0004.jpg"), "no metadata")
+	writeSidecar(t, filepath.Join(root, "notes.txt"), xmpPacket(`exif:DateTimeOriginal="2022-04-02T12:00:00Z"`))
+
+	tests := []struct {
+		name       string
+		precedence xmp.Precedence
+		expected   []heap.Photo
+	}{
+		{
+			name:       "prefers sidecar",
+			precedence: xmp.PreferSidecar,
+			expected: []heap.Photo{
+				{
+					ID:        filepath.Join(root, "2022", "DSC_0002.NEF"),
+					Timestamp: time.Date(2022, 04, 02, 11, 0, 0, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+				},
+				{
+					ID:        filepath.Join(root, "2022", "IMG_0001.jpg"),
+					Timestamp: time.Date(2022, 04, 02, 10, 52, 59, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+				},
+				{
+					ID:        filepath.Join(root, "2022", "IMG_0006.jpg"),
+					Timestamp: time.Date(2022, 04, 02, 14, 0, 0, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+				},
+				{
+					ID:        filepath.Join(root, "2022", "VID_0005.mp4"),
+					Timestamp: time.Date(2022, 04, 02, 13, 0, 0, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+					Video:     true,
+				},
+			},
+		},
+		{
+			name:       "prefers embedded",
+			precedence: xmp.PreferEmbedded,
+			expected: []heap.Photo{
+				{
+					ID:        filepath.Join(root, "2022", "DSC_0002.NEF"),
+					Timestamp: time.Date(2022, 04, 02, 11, 0, 0, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+				},
+				{
+					ID:        filepath.Join(root, "2022", "IMG_0001.jpg"),
+					Timestamp: time.Date(2022, 04, 02, 10, 52, 59, 0, time.UTC),
+					Latitude:  1,
+					Longitude: 1,
+				},
+				{
+					ID:        filepath.Join(root, "2022", "IMG_0006.jpg"),
+					Timestamp: time.Date(2022, 04, 02, 14, 0, 0, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+				},
+				{
+					ID:        filepath.Join(root, "2022", "VID_0005.mp4"),
+					Timestamp: time.Date(2022, 04, 02, 13, 0, 0, 0, time.UTC),
+					Latitude:  51.5072,
+					Longitude: -0.1276,
+					Video:     true,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got := make([]heap.Photo, 0)
+				for photo := range NewFiles(root, FilesOptions{Precedence: tt.precedence}).Read(context.Background()) {
+					got = append(got, photo)
+				}
+
+				assert.Equal(t, len(tt.expected), len(got))
+
+				for i := range tt.expected {
+					assert.Equal(t, tt.expected[i].ID, got[i].ID)
+					assert.True(t, tt.expected[i].Timestamp.Equal(got[i].Timestamp), got[i].Timestamp)
+					assert.InDelta(t, tt.expected[i].Latitude, got[i].Latitude, 1e-9)
+					assert.InDelta(t, tt.expected[i].Longitude, got[i].Longitude, 1e-9)
+					assert.Equal(t, tt.expected[i].Video, got[i].Video)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,53 @@
+// Package importer reads photos from the exports of photo libraries, rather than from a CSV. Each importer follows
+// the same contract as consumer.Reader.ReadCSV; photos are sent on a channel which is closed once every photo has been
+// read, or once the context is done, so that the rest of the pipeline is unchanged.
+//
+// Photos without a location can't be grouped, so they are located with a consumer.Locator if the importer has one, and
+// otherwise, or if it can't locate them, logged and skipped.
+package importer
+
+import (
+	"context"
+
+	"github.com/JackFazackerley/photo-grouping/internal/consumer"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// record is a single photo read by an importer, along with whether it has a location.
+type record struct {
+	photo   heap.Photo
+	located bool
+}
+
+// send records the record in the rows metrics and sends its photo on photoChan if it has a location, locating it from
+// its timestamp with locator if it doesn't and locator isn't nil. It returns false if ctx is done.
+func send(ctx context.Context, photoChan chan<- heap.Photo, r record, locator consumer.Locator) bool {
+	metrics.RowsRead.Inc()
+
+	if !r.located && locator != nil {
+		r.photo.Latitude, r.photo.Longitude, r.located = locator.Locate(r.photo.Timestamp)
+	}
+
+	if !r.located {
+		metrics.RowsRejected.WithLabelValues(metrics.ReasonUnlocated).Inc()
+		log.WithField("photo", r.photo.ID).Warn("no location for photo")
+
+		return ctx.Err() == nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case photoChan <- r.photo:
+		return true
+	}
+}
+
+// reject records a photo which couldn't be read in the rows metrics.
+func reject(id string, err error) {
+	metrics.RowsRead.Inc()
+	metrics.RowsRejected.WithLabelValues(metrics.ReasonMalformed).Inc()
+	log.WithError(err).WithField("photo", id).Error("reading photo")
+}
@@ -0,0 +1,254 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/consumer"
+	"github.com/JackFazackerley/photo-grouping/internal/exif"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+)
+
+// Takeout reads photos from a Google Takeout export of Google Photos. Takeout writes a JSON sidecar next to each
+// photo, such as IMG_0001.jpg.json, holding when and where the photo was taken. If locator is nil, photos without a
+// location are skipped.
+type Takeout struct {
+	root    string
+	locator consumer.Locator
+}
+
+// NewTakeout returns a Takeout which reads every sidecar in the directory root, and its subdirectories.
+func NewTakeout(root string) *Takeout {
+	return &Takeout{
+		root: root,
+	}
+}
+
+// NewTakeoutWithLocator returns a Takeout which reads the export in root, see NewTakeout. Photos without a location
+// are located with locator, and skipped if it can't locate them.
+func NewTakeoutWithLocator(root string, locator consumer.Locator) *Takeout {
+	takeout := NewTakeout(root)
+	takeout.locator = locator
+
+	return takeout
+}
+
+const (
+	// takeoutSupplemental is appended to the name of a photo's sidecar by newer exports, before ".json". It's cut short
+	// when the sidecar's name is truncated, such as to IMG_0001.jpg.supplemental-me.json.
+	takeoutSupplemental = "supplemental-metadata"
+)
+
+var (
+	// takeoutDuplicate is the number Takeout gives a photo with the same name as another in its directory, which is
+	// moved to the end of its sidecar's name, so IMG_0001(1).jpg has the sidecar IMG_0001.jpg(1).json.
+	takeoutDuplicate = regexp.MustCompile(`\(\d+\)$`)
+)
+
+// takeoutSidecar holds the parts of a Takeout sidecar which are needed to build a heap.Photo.
+type takeoutSidecar struct {
+	Title          string `json:"title"`
+	PhotoTakenTime *struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"photoTakenTime"`
+	GeoData     takeoutGeoData `json:"geoData"`
+	GeoDataExif takeoutGeoData `json:"geoDataExif"`
+}
+
+// takeoutGeoData is a location in a Takeout sidecar, a location of 0, 0 means the photo has no location.
+type takeoutGeoData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (g takeoutGeoData) known() bool {
+	return g.Latitude != 0 || g.Longitude != 0
+}
+
+// Read walks the export and returns a channel of the photos it holds. Each photo's ID is the path to its file. Takeout
+// only records when a photo was taken in UTC, so its timestamp is moved into the offset in the photo's EXIF, and is
+// left in UTC if the photo is missing or its EXIF doesn't record an offset. JSON files which aren't photo sidecars,
+// such as album metadata, are skipped.
+//
+// Read honours context.Context in the same way as consumer.Reader.ReadCSV.
+func (t *Takeout) Read(ctx context.Context) <-chan heap.Photo {
+	photoChan := make(chan heap.Photo)
+
+	go func() {
+		defer close(photoChan)
+
+		err := filepath.WalkDir(
+			t.root, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+					return nil
+				}
+
+				r, ok, err := readTakeoutSidecar(path)
+				if err != nil {
+					reject(path, err)
+					return nil
+				}
+
+				if !ok || send(ctx, photoChan, r, t.locator) {
+					return nil
+				}
+
+				return ctx.Err()
+			},
+		)
+		if err != nil && ctx.Err() == nil {
+			reject(t.root, err)
+		}
+	}()
+
+	return photoChan
+}
+
+// readTakeoutSidecar reads the sidecar at path, returning false if it isn't a photo sidecar. The photo's location is
+// taken from geoData, which includes any location set by the user, falling back to the location in the photo's EXIF.
+func readTakeoutSidecar(path string) (record, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record{}, false, fmt.Errorf("reading sidecar: %w", err)
+	}
+
+	sidecar := takeoutSidecar{}
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return record{}, false, fmt.Errorf("decoding sidecar: %w", err)
+	}
+
+	if sidecar.PhotoTakenTime == nil || sidecar.Title == "" {
+		return record{}, false, nil
+	}
+
+	seconds, err := strconv.ParseInt(sidecar.PhotoTakenTime.Timestamp, 10, 64)
+	if err != nil {
+		return record{}, false, fmt.Errorf("parsing photoTakenTime: %w", err)
+	}
+
+	r := record{
+		photo: heap.Photo{
+			ID:        takeoutMediaPath(path),
+			Timestamp: time.Unix(seconds, 0).UTC(),
+		},
+	}
+
+	if location, ok := takeoutLocation(r.photo.ID); ok {
+		r.photo.Timestamp = r.photo.Timestamp.In(location)
+	}
+
+	for _, geoData := range []takeoutGeoData{sidecar.GeoData, sidecar.GeoDataExif} {
+		if geoData.known() {
+			r.photo.Latitude = geoData.Latitude
+			r.photo.Longitude = geoData.Longitude
+			r.located = true
+
+			break
+		}
+	}
+
+	return r, true, nil
+}
+
+// takeoutMediaPath returns the path to the photo described by the sidecar at path. The sidecar is named after the
+// photo's file rather than its title, which isn't unique, with any duplicate number moved back before the extension.
+// Takeout truncates long sidecar names, cutting into the supplemental-metadata suffix or the photo's own name, so if
+// the photo isn't found the shortest file in the directory whose name starts with what's left is used, as the photo's
+// edited copy, such as IMG_0001-edited.jpg, shares its sidecar. If nothing matches, the untruncated path is returned.
+func takeoutMediaPath(path string) string {
+	dir, name := filepath.Split(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	duplicate := takeoutDuplicate.FindString(name)
+	name = strings.TrimSuffix(name, duplicate)
+
+	if i := strings.LastIndex(name, "."); i >= 0 && i+1 < len(name) && strings.HasPrefix(takeoutSupplemental, name[i+1:]) {
+		name = name[:i]
+	}
+
+	ext := filepath.Ext(name)
+	media := filepath.Join(dir, strings.TrimSuffix(name, ext)+duplicate+ext)
+
+	if _, err := os.Stat(media); err == nil {
+		return media
+	}
+
+	if truncated, ok := takeoutTruncated(dir, name, duplicate); ok {
+		return truncated
+	}
+
+	return media
+}
+
+// takeoutTruncated returns the shortest file in dir, other than a sidecar, whose name starts with prefix once
+// duplicate, which its name must hold before its extension, is removed.
+func takeoutTruncated(dir, prefix, duplicate string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+
+	found := ""
+
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+
+		if entry.IsDir() || strings.EqualFold(ext, ".json") {
+			continue
+		}
+
+		stem := strings.TrimSuffix(name, ext)
+		if !strings.HasSuffix(stem, duplicate) || !strings.HasPrefix(strings.TrimSuffix(stem, duplicate)+ext, prefix) {
+			continue
+		}
+
+		if found == "" || len(name) < len(found) {
+			found = name
+		}
+	}
+
+	if found == "" {
+		return "", false
+	}
+
+	return filepath.Join(dir, found), true
+}
+
+// takeoutLocation returns the timezone the photo at path was taken in, from the offset in its EXIF. It returns false
+// if the photo can't be read, as the sidecar still holds when it was taken.
+func takeoutLocation(path string) (*time.Location, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false
+	}
+
+	metadata, ok, err := exif.Read(file, info.Size())
+	if err != nil || !ok || metadata.Taken.IsZero() {
+		return nil, false
+	}
+
+	return metadata.Taken.Location(), true
+}
@@ -0,0 +1,272 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSidecar(t *testing.T, path, contents string) {
+	t.Helper()
+
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+}
+
+// exifJPEG returns a JPEG whose EXIF holds the DateTimeOriginal taken and OffsetTimeOriginal offset.
+func exifJPEG(taken, offset string) string {
+	values := []string{taken + "\x00", offset + "\x00"}
+
+	// the Exif IFD follows IFD0, which holds a single pointer to it, and its values follow it.
+	tiff := &bytes.Buffer{}
+	tiff.WriteString("II*\x00")
+	_ = binary.Write(tiff, binary.LittleEndian, []uint32{8})
+	_ = binary.Write(tiff, binary.LittleEndian, []uint16{1, 0x8769, 4})
+	_ = binary.Write(tiff, binary.LittleEndian, []uint32{1, 26, 0})
+
+	dataOffset := uint32(26 + 2 + 12*len(values) + 4)
+	_ = binary.Write(tiff, binary.LittleEndian, uint16(len(values)))
+	for i, tag := range []uint16{0x9003, 0x9011} {
+		_ = binary.Write(tiff, binary.LittleEndian, []uint16{tag, 2})
+		_ = binary.Write(tiff, binary.LittleEndian, []uint32{uint32(len(values[i])), dataOffset})
+		dataOffset += uint32(len(values[i]))
+	}
+	_ = binary.Write(tiff, binary.LittleEndian, uint32(0))
+	for _, value := range values {
+		tiff.WriteString(value)
+	}
+
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(tiff.Len()+8))
+
+	return "\xff\xd8\xff\xe1" + string(length) + "Exif\x00\x00" + tiff.String() + "\xff\xd9"
+}
+
+func TestTakeout_Read(t *testing.T) {
+	root := t.TempDir()
+	album := filepath.Join(root, "Takeout", "Google Photos", "Photos from 2022")
+
+	writeSidecar(
+		t, filepath.Join(album, "IMG_0001.jpg.json"), `{
+  "title": "IMG_0001.jpg",
+  "photoTakenTime": {"timestamp": "1648893179", "formatted": "2 Apr 2022, 09:52:59 UTC"},
+  "geoData": {"latitude": 40.627883, "longitude": 14.366858, "altitude": 0},
+  "geoDataExif": {"latitude": 40.6, "longitude": 14.3, "altitude": 0}
+}`,
+	)
+	// the location set by the user is missing, so the EXIF location is used
+	writeSidecar(
+		t, filepath.Join(album, "IMG_0002.jpg.supplemental-metadata.json"), `{
+  "title": "IMG_0002.jpg",
+  "photoTakenTime": {"timestamp": "1648896779"},
+  "geoData": {"latitude": 0.0, "longitude": 0.0},
+  "geoDataExif": {"latitude": 40.6, "longitude": 14.3}
+}`,
+	)
+	writeSidecar(
+		t, filepath.Join(album, "IMG_0003.jpg.json"), `{
+  "title": "IMG_0003.jpg",
+  "photoTakenTime": {"timestamp": "1648900379"},
+  "geoData": {"latitude": 0.0, "longitude": 0.0}
+}`,
+	)
+	// the photo's EXIF records the offset it was taken in
+	writeSidecar(
+		t, filepath.Join(album, "IMG_0005.jpg.json"), `{
+  "title": "IMG_0005.jpg",
+  "photoTakenTime": {"timestamp": "1648900379"},
+  "geoData": {"latitude": 40.6, "longitude": 14.3}
+}`,
+	)
+	writeSidecar(t, filepath.Join(album, "IMG_0005.jpg"), exifJPEG("2022:04:02 13:52:59", "+02:00"))
+	writeSidecar(t, filepath.Join(album, "IMG_0004.jpg.json"), `{"title": "IMG_0004.jpg", "photoTakenTime": {"timestamp": "yesterday"}}`)
+	writeSidecar(t, filepath.Join(album, "metadata.json"), `{"title": "Photos from 2022", "date": {"timestamp": "1648893179"}}`)
+	writeSidecar(t, filepath.Join(album, "broken.json"), `{`)
+	writeSidecar(t, filepath.Join(album, "IMG_0001.jpg"), "not a sidecar")
+
+	got := make([]heap.Photo, 0)
+	for photo := range NewTakeout(root).Read(context.Background()) {
+		got = append(got, photo)
+	}
+
+	assert.Equal(
+		t, []heap.Photo{
+			{
+				ID:        filepath.Join(album, "IMG_0001.jpg"),
+				Timestamp: time.Date(2022, 04, 02, 9, 52, 59, 0, time.UTC),
+				Latitude:  40.627883,
+				Longitude: 14.366858,
+			},
+			{
+				ID:        filepath.Join(album, "IMG_0002.jpg"),
+				Timestamp: time.Date(2022, 04, 02, 10, 52, 59, 0, time.UTC),
+				Latitude:  40.6,
+				Longitude: 14.3,
+			},
+			{
+				ID:        filepath.Join(album, "IMG_0005.jpg"),
+				Timestamp: time.Date(2022, 04, 02, 13, 52, 59, 0, time.FixedZone("", 2*60*60)),
+				Latitude:  40.6,
+				Longitude: 14.3,
+			},
+		}, got,
+	)
+}
+
+func TestTakeout_ReadDuplicates(t *testing.T) {
+	root := t.TempDir()
+
+	// both photos were uploaded as IMG_0001.jpg, so share a title, and the second was renamed by Takeout
+	writeSidecar(
+		t, filepath.Join(root, "IMG_0001.jpg.json"),
+		`{"title": "IMG_0001.jpg", "photoTakenTime": {"timestamp": "1648893179"}, "geoData": {"latitude": 1, "longitude": 1}}`,
+	)
+	writeSidecar(
+		t, filepath.Join(root, "IMG_0001.jpg(1).json"),
+		`{"title": "IMG_0001.jpg", "photoTakenTime": {"timestamp": "1648896779"}, "geoData": {"latitude": 2, "longitude": 2}}`,
+	)
+	writeSidecar(t, filepath.Join(root, "IMG_0001.jpg"), "photo")
+	writeSidecar(t, filepath.Join(root, "IMG_0001(1).jpg"), "photo")
+
+	got := make([]heap.Photo, 0)
+	for photo := range NewTakeout(root).Read(context.Background()) {
+		got = append(got, photo)
+	}
+
+	assert.Equal(
+		t, []heap.Photo{
+			{
+				ID:        filepath.Join(root, "IMG_0001(1).jpg"),
+				Timestamp: time.Date(2022, 04, 02, 10, 52, 59, 0, time.UTC),
+				Latitude:  2,
+				Longitude: 2,
+			},
+			{
+				ID:        filepath.Join(root, "IMG_0001.jpg"),
+				Timestamp: time.Date(2022, 04, 02, 9, 52, 59, 0, time.UTC),
+				Latitude:  1,
+				Longitude: 1,
+			},
+		}, got,
+	)
+}
+
+func Test_takeoutMediaPath(t *testing.T) {
+	root := t.TempDir()
+
+	for _, name := range []string{
+		"IMG_0001.jpg",
+		"IMG_0001(1).jpg",
+		"IMG_0002(2).HEIC",
+		"Screenshot_20220402-105259_Some_Long_App_Name.png",
+		"Screenshot_20220402-105259_Some_Long_App_Name-edited.png",
+		"PXL_20220402_105259123.PORTRAIT.ORIGINAL_SHOT.jpg",
+	} {
+		writeSidecar(t, filepath.Join(root, name), "photo")
+	}
+
+	tests := []struct {
+		name     string
+		sidecar  string
+		expected string
+	}{
+		{
+			name:     "appends json",
+			sidecar:  "IMG_0001.jpg.json",
+			expected: "IMG_0001.jpg",
+		},
+		{
+			name:     "moves a duplicate number before the extension",
+			sidecar:  "IMG_0001.jpg(1).json",
+			expected: "IMG_0001(1).jpg",
+		},
+		{
+			name:     "removes supplemental metadata",
+			sidecar:  "IMG_0002.HEIC.supplemental-metadata(2).json",
+			expected: "IMG_0002(2).HEIC",
+		},
+		{
+			name:     "removes truncated supplemental metadata",
+			sidecar:  "IMG_0001.jpg.supplemental-me.json",
+			expected: "IMG_0001.jpg",
+		},
+		{
+			name:     "matches a truncated name to the original rather than the edited copy",
+			sidecar:  "Screenshot_20220402-105259_Some_Long_App_Name.json",
+			expected: "Screenshot_20220402-105259_Some_Long_App_Name.png",
+		},
+		{
+			name:     "matches a name truncated within its extension",
+			sidecar:  "PXL_20220402_105259123.PORTRAIT.ORIGINAL_SHOT.j.json",
+			expected: "PXL_20220402_105259123.PORTRAIT.ORIGINAL_SHOT.jpg",
+		},
+		{
+			name:     "keeps the name of a missing photo",
+			sidecar:  "IMG_0003.jpg.supplemental-metadata.json",
+			expected: "IMG_0003.jpg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, filepath.Join(root, tt.expected), takeoutMediaPath(filepath.Join(root, tt.sidecar)))
+			},
+		)
+	}
+}
+
+func TestTakeout_ReadWithLocator(t *testing.T) {
+	root := t.TempDir()
+
+	writeSidecar(
+		t, filepath.Join(root, "IMG_0001.jpg.json"),
+		`{"title": "IMG_0001.jpg", "photoTakenTime": {"timestamp": "1648893179"}, "geoData": {"latitude": 0, "longitude": 0}}`,
+	)
+	// taken on 2022-04-05, which mockLocator can't locate
+	writeSidecar(
+		t, filepath.Join(root, "IMG_0002.jpg.json"),
+		`{"title": "IMG_0002.jpg", "photoTakenTime": {"timestamp": "1649152379"}, "geoData": {"latitude": 0, "longitude": 0}}`,
+	)
+
+	got := make([]heap.Photo, 0)
+	for photo := range NewTakeoutWithLocator(root, mockLocator{}).Read(context.Background()) {
+		got = append(got, photo)
+	}
+
+	assert.Equal(
+		t, []heap.Photo{
+			{
+				ID:        filepath.Join(root, "IMG_0001.jpg"),
+				Timestamp: time.Date(2022, 04, 02, 9, 52, 59, 0, time.UTC),
+				Latitude:  40.728808,
+				Longitude: -73.996106,
+			},
+		}, got,
+	)
+}
+
+func TestTakeout_ReadCancelled(t *testing.T) {
+	root := t.TempDir()
+
+	writeSidecar(
+		t, filepath.Join(root, "IMG_0001.jpg.json"),
+		`{"title": "IMG_0001.jpg", "photoTakenTime": {"timestamp": "1648893179"}, "geoData": {"latitude": 1, "longitude": 1}}`,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	got := make([]heap.Photo, 0)
+	for photo := range NewTakeout(root).Read(ctx) {
+		got = append(got, photo)
+	}
+
+	assert.Empty(t, got)
+}
@@ -0,0 +1,150 @@
+// Package isobmff reads the boxes of files in the ISO base media file format, which HEIF, CR3, MP4 and QuickTime
+// files are built on. A file is a tree of boxes, each with a size and a four character type.
+package isobmff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxBoxSize is the largest box which is read into memory by Box.Bytes, only the boxes describing a file's contents
+// are, which are small.
+const maxBoxSize = 4 * 1024 * 1024
+
+// ErrTruncated is the error of a Cursor which reads past the end of its data.
+var ErrTruncated = errors.New("truncated box")
+
+// Box is a single box in a file, Offset and Size are of its contents, after its header.
+type Box struct {
+	Type   string
+	Offset int64
+	Size   int64
+}
+
+// Section returns a reader of the Box's contents.
+func (b Box) Section(r io.ReaderAt) *io.SectionReader {
+	return io.NewSectionReader(r, b.Offset, b.Size)
+}
+
+// Bytes reads the Box's contents, which must be no more than 4MiB.
+func (b Box) Bytes(r io.ReaderAt) ([]byte, error) {
+	if b.Size > maxBoxSize {
+		return nil, fmt.Errorf("reading %s box: too large", b.Type)
+	}
+
+	data := make([]byte, b.Size)
+	if _, err := r.ReadAt(data, b.Offset); err != nil {
+		return nil, fmt.Errorf("reading %s box: %w", b.Type, err)
+	}
+
+	return data, nil
+}
+
+// Boxes reads the headers of the boxes in r from start to end, such as the whole file, or the contents of a Box. Only
+// the headers are read, so a box's children are read by calling Boxes again with its contents.
+func Boxes(r io.ReaderAt, start, end int64) ([]Box, error) {
+	result := make([]Box, 0)
+	header := make([]byte, 16)
+
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, fmt.Errorf("reading box: %w", err)
+		}
+
+		size := uint64(binary.BigEndian.Uint32(header))
+		headerSize := uint64(8)
+
+		switch size {
+		case 0:
+			// the box extends to the end of its parent.
+			size = uint64(end - offset)
+		case 1:
+			if _, err := r.ReadAt(header[8:], offset+8); err != nil {
+				return nil, fmt.Errorf("reading box: %w", err)
+			}
+
+			size = binary.BigEndian.Uint64(header[8:])
+			headerSize = 16
+		}
+
+		if size < headerSize || size > uint64(end-offset) {
+			return nil, fmt.Errorf("reading %s box: invalid size", header[4:8])
+		}
+
+		result = append(
+			result, Box{
+				Type:   string(header[4:8]),
+				Offset: offset + int64(headerSize),
+				Size:   int64(size - headerSize),
+			},
+		)
+
+		offset += int64(size)
+	}
+
+	return result, nil
+}
+
+// Find returns the first Box in boxes of the type kind.
+func Find(boxes []Box, kind string) (Box, bool) {
+	for _, b := range boxes {
+		if b.Type == kind {
+			return b, true
+		}
+	}
+
+	return Box{}, false
+}
+
+// Cursor reads big-endian integers from the contents of a box, in order. After an error, every read returns 0.
+type Cursor struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// NewCursor returns a Cursor which reads from the start of data.
+func NewCursor(data []byte) *Cursor {
+	return &Cursor{
+		data: data,
+	}
+}
+
+// Uint reads an unsigned integer of size bytes, a size of 0 reads nothing.
+func (c *Cursor) Uint(size int) uint64 {
+	value := uint64(0)
+	for _, b := range c.Bytes(size) {
+		value = value<<8 | uint64(b)
+	}
+
+	return value
+}
+
+// Bytes reads the next size bytes.
+func (c *Cursor) Bytes(size int) []byte {
+	if c.err != nil || size == 0 {
+		return nil
+	}
+
+	if size < 0 || c.pos+size > len(c.data) {
+		c.err = ErrTruncated
+		return nil
+	}
+
+	value := c.data[c.pos : c.pos+size]
+	c.pos += size
+
+	return value
+}
+
+// Pos returns the offset of the next read.
+func (c *Cursor) Pos() int {
+	return c.pos
+}
+
+// Err returns ErrTruncated if a read went past the end of the data.
+func (c *Cursor) Err() error {
+	return c.err
+}
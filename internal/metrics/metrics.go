@@ -0,0 +1,129 @@
+// Package metrics holds the Prometheus collectors used to instrument the pipeline. Every collector is registered with
+// Registry, which is exposed on /metrics in server mode and summarised at the end of a CLI run, see WriteSummary.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "photo_grouping"
+
+// The reasons a row is rejected by the reader, see RowsRejected.
+const (
+	ReasonMalformed = "malformed"
+	ReasonTimestamp = "timestamp"
+	ReasonLatitude  = "latitude"
+	ReasonLongitude = "longitude"
+	ReasonUnlocated = "unlocated"
+)
+
+// The kinds of geocoding request made, see GeocodeDuration.
+const (
+	RequestLocality = "locality"
+	RequestLabel    = "label"
+)
+
+var (
+	// Registry holds every collector in this package. A dedicated registry is used, rather than the Prometheus
+	// default, so that only the pipeline's metrics are exposed.
+	Registry = prometheus.NewRegistry()
+
+	factory = promauto.With(Registry)
+
+	// RowsRead is the number of rows read from a CSV, or photos read from an import, including those which are
+	// rejected.
+	RowsRead = factory.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_read_total",
+			Help:      "Number of rows read from a CSV, or photos read from an import.",
+		},
+	)
+
+	// RowsRejected is the number of rows which couldn't be parsed, by reason.
+	RowsRejected = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_rejected_total",
+			Help:      "Number of rows which couldn't be parsed, by reason.",
+		}, []string{"reason"},
+	)
+
+	// GeocodeDuration is the latency of requests to the geocoding provider, by request kind.
+	GeocodeDuration = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "geocode_duration_seconds",
+			Help:      "Latency of requests to the geocoding provider.",
+			Buckets:   []float64{0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		}, []string{"request"},
+	)
+
+	// GeocodeErrors is the number of failed requests to the geocoding provider, by class, see ErrorClass.
+	GeocodeErrors = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "geocode_errors_total",
+			Help:      "Number of failed requests to the geocoding provider, by class.",
+		}, []string{"class"},
+	)
+
+	// CacheLookups is the number of photos geocoded, by whether a request was made, shared with an in-flight request,
+	// or reused from a nearby photo. The cache hit ratio is the share of lookups which weren't fetched.
+	CacheLookups = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Number of geocoding cache lookups, by outcome.",
+		}, []string{"outcome"},
+	)
+
+	// HeapSize is the number of photos currently held on heaps, waiting to be grouped.
+	HeapSize = factory.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "heap_size",
+			Help:      "Number of photos held on heaps waiting to be grouped.",
+		},
+	)
+
+	// GroupsProduced is the number of groups produced, by kind.
+	GroupsProduced = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "groups_produced_total",
+			Help:      "Number of groups produced, by kind.",
+		}, []string{"kind"},
+	)
+)
+
+// ErrorClass returns a low cardinality class for a geocoding error, for use as the GeocodeErrors label.
+func ErrorClass(err error) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case strings.Contains(err.Error(), "OVER_QUERY_LIMIT"), strings.Contains(err.Error(), "OVER_DAILY_LIMIT"):
+		return "quota"
+	case strings.Contains(err.Error(), "REQUEST_DENIED"):
+		return "denied"
+	case strings.Contains(err.Error(), "INVALID_REQUEST"):
+		return "invalid"
+	default:
+		return "other"
+	}
+}
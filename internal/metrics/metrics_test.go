@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "cancelled",
+			err:      fmt.Errorf("getting location: %w", context.Canceled),
+			expected: "cancelled",
+		},
+		{
+			name:     "deadline exceeded",
+			err:      context.DeadlineExceeded,
+			expected: "timeout",
+		},
+		{
+			name:     "network timeout",
+			err:      &net.DNSError{IsTimeout: true},
+			expected: "timeout",
+		},
+		{
+			name:     "network",
+			err:      &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			expected: "network",
+		},
+		{
+			name:     "quota",
+			err:      errors.New("maps: OVER_QUERY_LIMIT - You have exceeded your rate-limit for this API."),
+			expected: "quota",
+		},
+		{
+			name:     "denied",
+			err:      errors.New("maps: REQUEST_DENIED - The provided API key is invalid."),
+			expected: "denied",
+		},
+		{
+			name:     "invalid",
+			err:      errors.New("maps: INVALID_REQUEST - "),
+			expected: "invalid",
+		},
+		{
+			name:     "other",
+			err:      errors.New("client error"),
+			expected: "other",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, ErrorClass(tt.err))
+			},
+		)
+	}
+}
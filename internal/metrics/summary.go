@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// WriteSummary writes a human readable summary of every metric in Registry to w, for the end of a CLI run.
+func WriteSummary(w io.Writer) error {
+	return writeSummary(w, Registry)
+}
+
+// writeSummary writes the summary of the metrics gathered from gatherer to w.
+func writeSummary(w io.Writer, gatherer prometheus.Gatherer) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[strings.TrimPrefix(family.GetName(), namespace+"_")] = family
+	}
+
+	lookups := labelled(byName["cache_lookups_total"], "outcome")
+	hits := lookups["reused"] + lookups["coalesced"]
+	total := hits + lookups["fetched"]
+
+	var hitRatio float64
+	if total > 0 {
+		hitRatio = hits / total * 100
+	}
+
+	requests, latency := histogram(byName["geocode_duration_seconds"])
+
+	lines := []string{
+		"pipeline summary:",
+		fmt.Sprintf("  rows read: %.0f", sum(byName["rows_read_total"])),
+		fmt.Sprintf("  rows rejected: %s", breakdown(byName["rows_rejected_total"], "reason")),
+		fmt.Sprintf("  geocoding requests: %d (mean %s)", requests, latency),
+		fmt.Sprintf("  geocoding errors: %s", breakdown(byName["geocode_errors_total"], "class")),
+		fmt.Sprintf("  cache hit ratio: %.1f%%, lookups: %s", hitRatio, breakdown(byName["cache_lookups_total"], "outcome")),
+		fmt.Sprintf("  groups produced: %s", breakdown(byName["groups_produced_total"], "kind")),
+	}
+
+	_, err = fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// sum returns the total value of every counter or gauge in family.
+func sum(family *dto.MetricFamily) float64 {
+	var total float64
+
+	for _, metric := range family.GetMetric() {
+		total += metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+	}
+
+	return total
+}
+
+// labelled returns the value of every counter in family, keyed by the value of label.
+func labelled(family *dto.MetricFamily, label string) map[string]float64 {
+	values := make(map[string]float64)
+
+	for _, metric := range family.GetMetric() {
+		for _, pair := range metric.GetLabel() {
+			if pair.GetName() == label {
+				values[pair.GetValue()] += metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return values
+}
+
+// breakdown formats the total of family, followed by the value for each label, i.e. "3 (location: 2, cluster: 1)".
+func breakdown(family *dto.MetricFamily, label string) string {
+	values := labelled(family, label)
+	if len(values) == 0 {
+		return "0"
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %.0f", key, values[key]))
+	}
+
+	return fmt.Sprintf("%.0f (%s)", sum(family), strings.Join(parts, ", "))
+}
+
+// histogram returns the number of observations in every histogram in family, and their mean.
+func histogram(family *dto.MetricFamily) (uint64, time.Duration) {
+	var count uint64
+	var total float64
+
+	for _, metric := range family.GetMetric() {
+		count += metric.GetHistogram().GetSampleCount()
+		total += metric.GetHistogram().GetSampleSum()
+	}
+
+	if count == 0 {
+		return 0, 0
+	}
+
+	return count, time.Duration(total / float64(count) * float64(time.Second)).Round(time.Millisecond)
+}
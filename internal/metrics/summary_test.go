@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSummary(t *testing.T) {
+	// a fresh registry is used, rather than Registry, so that the test doesn't depend on what else has been counted.
+	registry := prometheus.NewRegistry()
+	factory := promauto.With(registry)
+
+	rowsRead := factory.NewCounter(prometheus.CounterOpts{Namespace: namespace, Name: "rows_read_total"})
+	rowsRejected := factory.NewCounterVec(
+		prometheus.CounterOpts{Namespace: namespace, Name: "rows_rejected_total"}, []string{"reason"},
+	)
+	geocodeDuration := factory.NewHistogramVec(
+		prometheus.HistogramOpts{Namespace: namespace, Name: "geocode_duration_seconds"}, []string{"request"},
+	)
+	cacheLookups := factory.NewCounterVec(
+		prometheus.CounterOpts{Namespace: namespace, Name: "cache_lookups_total"}, []string{"outcome"},
+	)
+	groupsProduced := factory.NewCounterVec(
+		prometheus.CounterOpts{Namespace: namespace, Name: "groups_produced_total"}, []string{"kind"},
+	)
+
+	rowsRead.Add(4)
+	rowsRejected.WithLabelValues(ReasonTimestamp).Inc()
+	geocodeDuration.WithLabelValues(RequestLocality).Observe(0.1)
+	geocodeDuration.WithLabelValues(RequestLocality).Observe(0.3)
+	cacheLookups.WithLabelValues("fetched").Add(2)
+	cacheLookups.WithLabelValues("reused").Inc()
+	cacheLookups.WithLabelValues("coalesced").Inc()
+	groupsProduced.WithLabelValues("location").Add(2)
+	groupsProduced.WithLabelValues("cluster").Inc()
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, writeSummary(buf, registry))
+
+	assert.Equal(
+		t, `pipeline summary:
+  rows read: 4
+  rows rejected: 1 (timestamp: 1)
+  geocoding requests: 2 (mean 200ms)
+  geocoding errors: 0
+  cache hit ratio: 50.0%, lookups: 4 (coalesced: 1, fetched: 2, reused: 1)
+  groups produced: 3 (cluster: 1, location: 2)
+`, buf.String(),
+	)
+}
@@ -0,0 +1,216 @@
+// Package progress reports how far through a run the pipeline is, as a progress bar when writing to a terminal, or as
+// periodic log lines otherwise.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// barWidth is the number of characters in the progress bar, excluding the brackets.
+	barWidth = 30
+	// smoothing is the weight given to the latest rate when averaging, so that the ETA doesn't jump around.
+	smoothing = 0.3
+)
+
+var (
+	// DefaultOptions are the Options used when no other configuration is provided.
+	DefaultOptions = Options{
+		BarInterval: time.Millisecond * 200,
+		LogInterval: time.Second * 10,
+	}
+)
+
+// Snapshotter provides the progress of a run, *grouping.Progress satisfies it.
+type Snapshotter interface {
+	Snapshot() grouping.ProgressSnapshot
+}
+
+// Options configures a Reporter. BarInterval is how often the progress bar is redrawn, and LogInterval how often a
+// log line is written when not writing to a terminal.
+type Options struct {
+	BarInterval time.Duration
+	LogInterval time.Duration
+}
+
+// Reporter periodically reports a Snapshotter's progress. Total is the number of rows expected, if it is zero no
+// percentage or ETA is shown.
+type Reporter struct {
+	progress Snapshotter
+	total    int64
+	out      io.Writer
+	tty      bool
+	options  Options
+
+	last     grouping.ProgressSnapshot
+	lastAt   time.Time
+	rate     float64
+	measured bool
+}
+
+// New creates a Reporter which writes to out. A progress bar is drawn if out is a terminal, otherwise log lines are
+// written.
+func New(progress Snapshotter, total int64, out io.Writer, options Options) *Reporter {
+	return &Reporter{
+		progress: progress,
+		total:    total,
+		out:      out,
+		tty:      isTerminal(out),
+		options:  options,
+	}
+}
+
+// Run reports progress until ctx is done, then reports the final progress.
+func (r *Reporter) Run(ctx context.Context) {
+	interval := r.options.LogInterval
+	if r.tty {
+		interval = r.options.BarInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	r.lastAt = time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.report(time.Now())
+			if r.tty {
+				fmt.Fprintln(r.out)
+			}
+			return
+		case now := <-ticker.C:
+			r.report(now)
+		}
+	}
+}
+
+// report updates the rate and writes the progress at now.
+func (r *Reporter) report(now time.Time) {
+	snapshot := r.progress.Snapshot()
+	r.update(snapshot, now)
+
+	if r.tty {
+		// \x1b[K clears the rest of the line, in case the previous bar was longer.
+		fmt.Fprintf(r.out, "\r%s\x1b[K", r.bar(snapshot))
+		return
+	}
+
+	fields := log.Fields{
+		"read":     snapshot.Read,
+		"geocoded": snapshot.Geocoded,
+		"failed":   snapshot.Failed,
+		"rate":     fmt.Sprintf("%.1f/s", r.rate),
+	}
+
+	if r.total > 0 {
+		fields["total"] = r.total
+	}
+
+	if eta, ok := r.eta(snapshot); ok {
+		fields["eta"] = eta.String()
+	}
+
+	log.WithFields(fields).Info("progress")
+}
+
+// update recalculates the rate of photos processed per second, smoothed with the previous rate.
+func (r *Reporter) update(snapshot grouping.ProgressSnapshot, now time.Time) {
+	elapsed := now.Sub(r.lastAt).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	processed := float64(done(snapshot) - done(r.last))
+	current := processed / elapsed
+
+	if r.measured {
+		r.rate = smoothing*current + (1-smoothing)*r.rate
+	} else {
+		r.rate = current
+		r.measured = true
+	}
+
+	r.last = snapshot
+	r.lastAt = now
+}
+
+// eta returns how long is left at the current rate, false is returned if it can't be estimated.
+func (r *Reporter) eta(snapshot grouping.ProgressSnapshot) (time.Duration, bool) {
+	if r.total <= 0 || r.rate <= 0 {
+		return 0, false
+	}
+
+	remaining := r.total - done(snapshot)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return time.Duration(float64(remaining) / r.rate * float64(time.Second)).Round(time.Second), true
+}
+
+// bar renders the progress as a single line, i.e.
+//
+//	[=========>                    ] 33% 100/300 read 110 failed 2 12.5/s ETA 16s
+func (r *Reporter) bar(snapshot grouping.ProgressSnapshot) string {
+	parts := make([]string, 0, 8)
+
+	if r.total > 0 {
+		fraction := float64(done(snapshot)) / float64(r.total)
+		if fraction > 1 {
+			fraction = 1
+		}
+
+		filled := int(fraction * barWidth)
+		bar := strings.Repeat("=", filled)
+		if filled < barWidth {
+			bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+		}
+
+		parts = append(parts, fmt.Sprintf("[%s] %3.0f%% %d/%d", bar, fraction*100, done(snapshot), r.total))
+	} else {
+		parts = append(parts, fmt.Sprintf("%d", done(snapshot)))
+	}
+
+	parts = append(
+		parts,
+		fmt.Sprintf("read %d", snapshot.Read),
+		fmt.Sprintf("failed %d", snapshot.Failed),
+		fmt.Sprintf("%.1f/s", r.rate),
+	)
+
+	if eta, ok := r.eta(snapshot); ok {
+		parts = append(parts, fmt.Sprintf("ETA %s", eta))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// done is the number of photos which have finished geocoding, successfully or not.
+func done(snapshot grouping.ProgressSnapshot) int64 {
+	return snapshot.Geocoded + snapshot.Failed
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
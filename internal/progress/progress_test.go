@@ -0,0 +1,144 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSnapshotter struct {
+	geocoded int64
+}
+
+func (m *mockSnapshotter) Snapshot() grouping.ProgressSnapshot {
+	geocoded := atomic.LoadInt64(&m.geocoded)
+
+	return grouping.ProgressSnapshot{
+		Read:     geocoded + 1,
+		Geocoded: geocoded,
+		Failed:   1,
+	}
+}
+
+func TestReporter_bar(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int64
+		rate     float64
+		snapshot grouping.ProgressSnapshot
+		expected string
+	}{
+		{
+			name:  "renders partial progress with eta",
+			total: 300,
+			rate:  10,
+			snapshot: grouping.ProgressSnapshot{
+				Read:     110,
+				Geocoded: 98,
+				Failed:   2,
+			},
+			expected: "[==========>                   ]  33% 100/300 read 110 failed 2 10.0/s ETA 20s",
+		},
+		{
+			name:  "renders complete progress",
+			total: 300,
+			rate:  10,
+			snapshot: grouping.ProgressSnapshot{
+				Read:     300,
+				Geocoded: 300,
+			},
+			expected: "[==============================] 100% 300/300 read 300 failed 0 10.0/s ETA 0s",
+		},
+		{
+			name:  "renders without eta before rate is known",
+			total: 300,
+			snapshot: grouping.ProgressSnapshot{
+				Read: 5,
+			},
+			expected: "[>                             ]   0% 0/300 read 5 failed 0 0.0/s",
+		},
+		{
+			name: "renders without total",
+			rate: 2.5,
+			snapshot: grouping.ProgressSnapshot{
+				Read:     12,
+				Geocoded: 10,
+			},
+			expected: "10 read 12 failed 0 2.5/s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				r := &Reporter{
+					total: tt.total,
+					rate:  tt.rate,
+				}
+
+				assert.Equal(t, tt.expected, r.bar(tt.snapshot))
+			},
+		)
+	}
+}
+
+func TestReporter_update(t *testing.T) {
+	start := time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC)
+
+	r := &Reporter{
+		lastAt: start,
+	}
+
+	r.update(grouping.ProgressSnapshot{Geocoded: 10}, start.Add(time.Second))
+	assert.Equal(t, 10.0, r.rate)
+
+	r.update(grouping.ProgressSnapshot{Geocoded: 30}, start.Add(time.Second*2))
+	assert.InDelta(t, 13.0, r.rate, 0.001)
+
+	// no time has passed, so the rate is left as it was
+	r.update(grouping.ProgressSnapshot{Geocoded: 40}, start.Add(time.Second*2))
+	assert.InDelta(t, 13.0, r.rate, 0.001)
+}
+
+func TestReporter_Run(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	out := log.StandardLogger().Out
+	log.SetOutput(buf)
+	defer log.SetOutput(out)
+
+	snapshotter := &mockSnapshotter{}
+
+	r := New(
+		snapshotter, 10, &bytes.Buffer{}, Options{
+			BarInterval: time.Millisecond,
+			LogInterval: time.Millisecond * 5,
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+
+	for i := 0; i < 9; i++ {
+		atomic.AddInt64(&snapshotter.geocoded, 1)
+		time.Sleep(time.Millisecond * 2)
+	}
+
+	cancel()
+	<-done
+
+	assert.Contains(t, buf.String(), "msg=progress")
+	assert.Contains(t, buf.String(), "failed=1 geocoded=9")
+	assert.Contains(t, buf.String(), "read=10 total=10")
+	assert.Contains(t, buf.String(), "eta=0s")
+}
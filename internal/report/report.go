@@ -0,0 +1,303 @@
+// Package report renders groups as a self-contained HTML page, with a timeline of each year's groups and a map of the
+// photos in each group, so that the grouping of a library can be checked at a glance.
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/export"
+)
+
+var (
+	//go:embed report.html
+	reportHTML string
+	//go:embed world.txt
+	worldTXT string
+
+	reportTemplate = template.Must(template.New("report").Parse(reportHTML))
+)
+
+// colours are given to trip types in the order the trip types are first seen.
+var colours = []string{"#1b9e77", "#d95f02", "#7570b3", "#e7298a", "#66a61e", "#e6ab02", "#a6761d", "#666666"}
+
+const (
+	// minSpan is the fewest degrees of latitude a group's map covers, so that the coastline around a group in a single
+	// place can still be seen.
+	minSpan = 1.0
+	// aspect is the width of every map divided by its height.
+	aspect = 1.5
+	// timelineWidth and laneHeight are the size of a year's timeline, which is a lane tall for each row of groups.
+	timelineWidth = 1000
+	laneHeight    = 14
+)
+
+type page struct {
+	Generated string
+	World     string
+	Groups    int
+	Photos    int
+	TripTypes []tripType
+	Years     []year
+}
+
+type tripType struct {
+	Name   string
+	Colour string
+}
+
+type year struct {
+	Year     int
+	Height   int
+	Months   []month
+	Bars     []bar
+	Overview mapView
+	Groups   []group
+}
+
+type month struct {
+	Name string
+	X    float64
+}
+
+type bar struct {
+	Anchor string
+	Title  string
+	X      float64
+	Y      int
+	Width  float64
+	Colour string
+}
+
+type group struct {
+	Anchor   string
+	Title    string
+	Name     string
+	Kind     string
+	TripType string
+	Colour   string
+	Dates    string
+	Summary  export.Summary
+	Titles   []string
+	Map      mapView
+}
+
+// mapView is the part of the world shown by a map. The world is drawn with longitude along x and latitude up y, scaled
+// along x by Scale, so that distances look the same either way around the map's latitude.
+type mapView struct {
+	ViewBox string
+	Scale   float64
+	Radius  float64
+	Points  []point
+}
+
+type point struct {
+	X      float64
+	Y      float64
+	Colour string
+	Title  string
+}
+
+// Write renders the groups to w as an HTML page, generated at the given time. Groups are shown under the year they
+// started in, in the order they started. Everything the page needs is within it, so it can be opened without a network.
+func Write(w io.Writer, groups []export.Titled, generated time.Time) error {
+	sorted := make([]export.Titled, len(groups))
+	copy(sorted, groups)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	p := page{
+		Generated: generated.Format("2 Jan 2006 15:04 MST"),
+		World:     worldPath(),
+		Groups:    len(sorted),
+		Photos:    len(export.Distinct(sorted)),
+	}
+
+	colourOf := make(map[string]string)
+
+	for _, g := range sorted {
+		if _, ok := colourOf[g.TripType]; !ok {
+			colourOf[g.TripType] = colours[len(colourOf)%len(colours)]
+			p.TripTypes = append(p.TripTypes, tripType{Name: g.TripType, Colour: colourOf[g.TripType]})
+		}
+	}
+
+	for i, g := range sorted {
+		if len(p.Years) == 0 || p.Years[len(p.Years)-1].Year != g.Start.Year() {
+			p.Years = append(p.Years, newYear(g.Start.Year()))
+		}
+
+		y := &p.Years[len(p.Years)-1]
+		anchor := "group-" + strconv.Itoa(i+1)
+		colour := colourOf[g.TripType]
+
+		y.Groups = append(
+			y.Groups, group{
+				Anchor:   anchor,
+				Title:    g.Title,
+				Name:     g.Name,
+				Kind:     string(g.Kind),
+				TripType: g.TripType,
+				Colour:   colour,
+				Dates:    export.DateRange(g),
+				Summary:  export.Summarise(g),
+				Titles:   g.Titles,
+				Map:      groupMap(g, colour),
+			},
+		)
+
+		y.Bars = append(y.Bars, newBar(g, anchor, colour))
+
+		if len(g.Photos) > 0 {
+			latitude, longitude := export.Centroid(g.Photos)
+			y.Overview.Points = append(y.Overview.Points, point{X: longitude, Y: -latitude, Colour: colour, Title: g.Title})
+		}
+	}
+
+	for i := range p.Years {
+		p.Years[i].Height = stack(p.Years[i].Bars)*laneHeight + 20
+	}
+
+	if err := reportTemplate.Execute(w, p); err != nil {
+		return fmt.Errorf("rendering report: %w", err)
+	}
+
+	return nil
+}
+
+func newYear(number int) year {
+	y := year{
+		Year: number,
+		// the overview shows the whole world, apart from Antarctica.
+		Overview: mapView{ViewBox: "-180 -85 360 145", Scale: 1, Radius: 1.5},
+	}
+
+	for m := time.January; m <= time.December; m++ {
+		start := time.Date(number, m, 1, 0, 0, 0, 0, time.UTC)
+		y.Months = append(y.Months, month{Name: m.String()[:3], X: yearFraction(start) * timelineWidth})
+	}
+
+	return y
+}
+
+// newBar returns the bar showing the group on its year's timeline. Groups which go into the next year stop at the end
+// of the year, and every bar is wide enough to be seen.
+func newBar(g export.Titled, anchor, colour string) bar {
+	start := yearFraction(g.Start)
+
+	end := 1.0
+	if g.End.Year() == g.Start.Year() {
+		end = yearFraction(g.End)
+	}
+
+	return bar{
+		Anchor: anchor,
+		Title:  g.Title + " (" + export.DateRange(g) + ")",
+		X:      start * timelineWidth,
+		Width:  math.Max((end-start)*timelineWidth, 3),
+		Colour: colour,
+	}
+}
+
+// stack moves bars which overlap into lanes below each other, returning the number of lanes used.
+func stack(bars []bar) int {
+	var ends []float64
+
+	for i := range bars {
+		lane := 0
+		for lane < len(ends) && ends[lane] > bars[i].X {
+			lane++
+		}
+
+		if lane == len(ends) {
+			ends = append(ends, 0)
+		}
+
+		ends[lane] = bars[i].X + bars[i].Width + 1
+		bars[i].Y = 20 + lane*laneHeight
+	}
+
+	return len(ends)
+}
+
+// yearFraction returns how far through its year t is, from 0 to 1.
+func yearFraction(t time.Time) float64 {
+	start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	end := start.AddDate(1, 0, 0)
+
+	return float64(t.Sub(start)) / float64(end.Sub(start))
+}
+
+// groupMap returns the map of the group's photos, centred on them with a margin around them.
+func groupMap(g export.Titled, colour string) mapView {
+	if len(g.Photos) == 0 {
+		return mapView{}
+	}
+
+	b := export.BoundsOf(g.Photos)
+	scale := math.Max(math.Cos((b.North+b.South)/2*math.Pi/180), 0.1)
+
+	height := math.Max((b.North-b.South)*1.4, minSpan)
+	width := math.Max((b.East-b.West)*scale*1.4, height*aspect)
+	height = width / aspect
+
+	centreX, centreY := (b.East+b.West)/2*scale, -(b.North+b.South)/2
+
+	view := mapView{
+		ViewBox: fmt.Sprintf("%.5f %.5f %.5f %.5f", centreX-width/2, centreY-height/2, width, height),
+		Scale:   scale,
+		Radius:  height / 60,
+	}
+
+	for _, photo := range g.Photos {
+		view.Points = append(
+			view.Points, point{
+				X:      photo.Longitude * scale,
+				Y:      -photo.Latitude,
+				Colour: colour,
+				Title:  photo.Timestamp.Format(time.RFC3339),
+			},
+		)
+	}
+
+	return view
+}
+
+// worldPath returns the SVG path of the world outline. SVG's y axis points down, so each latitude is negated.
+func worldPath() string {
+	var b strings.Builder
+
+	for _, line := range strings.Split(worldTXT, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		_, points, _ := strings.Cut(line, ":")
+
+		for i, p := range strings.Fields(points) {
+			longitude, latitude, _ := strings.Cut(p, ",")
+
+			y, err := strconv.ParseFloat(latitude, 64)
+			if err != nil {
+				panic(fmt.Sprintf("invalid world outline point %q", p))
+			}
+
+			command := "L"
+			if i == 0 {
+				command = "M"
+			}
+
+			b.WriteString(command + longitude + "," + strconv.FormatFloat(-y, 'f', -1, 64))
+		}
+
+		b.WriteString("Z")
+	}
+
+	return b.String()
+}
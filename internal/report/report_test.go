@@ -0,0 +1,118 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/export"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC)
+
+	groups := []export.Titled{
+		{
+			Group: grouping.Group{
+				Name:     "Naples",
+				Kind:     grouping.KindLocation,
+				TripType: "day",
+				Start:    start.AddDate(1, 0, 0),
+				End:      start.AddDate(1, 0, 0).Add(time.Hour * 5),
+				Photos: []grouping.Photo{
+					{Timestamp: start.AddDate(1, 0, 0), Latitude: 40.85, Longitude: 14.27},
+				},
+				Titles: []string{"A day out in Naples"},
+			},
+			Title: "A day out in Naples",
+		},
+		{
+			Group: grouping.Group{
+				Name:     "Rome",
+				Kind:     grouping.KindLocation,
+				TripType: "weekend",
+				Start:    start,
+				End:      start.Add(time.Hour * 26),
+				Photos: []grouping.Photo{
+					{Timestamp: start, Latitude: 41.8, Longitude: 12.4},
+					{Timestamp: start.Add(time.Hour * 26), Latitude: 41.9, Longitude: 12.5, Video: true},
+				},
+				Titles: []string{"A weekend in Rome", "Rome <& Lazio>"},
+			},
+			Title: "Roman holiday",
+		},
+	}
+
+	var b bytes.Buffer
+
+	assert.NoError(t, Write(&b, groups, time.Date(2023, 05, 01, 12, 0, 0, 0, time.UTC)))
+
+	got := b.String()
+	assert.Contains(t, got, "3 photos, generated 1 May 2023 12:00 UTC")
+	assert.Contains(t, got, `<path id="world" d="M-168,-65.5L-163,-69`)
+	assert.Contains(t, got, "Rome &lt;&amp; Lazio&gt;")
+	assert.Contains(t, got, "2 Apr 2022 – 3 Apr 2022 (2 days)")
+	assert.Contains(t, got, "1 photo, 1 video, across")
+	assert.NotContains(t, got, "ZgotmplZ")
+
+	// years are in order, with the groups which started in them.
+	assert.Less(t, strings.Index(got, `id="year-2022"`), strings.Index(got, "Roman holiday"))
+	assert.Less(t, strings.Index(got, "Roman holiday"), strings.Index(got, `id="year-2023"`))
+	assert.Less(t, strings.Index(got, `id="year-2023"`), strings.Index(got, "<h3>A day out in Naples</h3>"))
+
+	// nothing is loaded from elsewhere.
+	assert.NotContains(t, got, "<script")
+	assert.NotContains(t, got, "http")
+}
+
+func TestWrite_Overlapping(t *testing.T) {
+	start := time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC)
+	rome := []grouping.Photo{
+		{Timestamp: start, Latitude: 41.8, Longitude: 12.4},
+		{Timestamp: start.Add(time.Hour * 26), Latitude: 41.9, Longitude: 12.5},
+	}
+	naples := grouping.Photo{Timestamp: start.Add(time.Hour * 50), Latitude: 40.85, Longitude: 14.27}
+
+	groups := []export.Titled{
+		{
+			Group: grouping.Group{Name: "Rome", Start: start, End: start.Add(time.Hour * 26), Photos: rome},
+			Title: "Roman holiday",
+		},
+		{
+			// the country holds the photos of the city as well.
+			Group: grouping.Group{
+				Name:   "Italy",
+				Start:  start,
+				End:    start.Add(time.Hour * 50),
+				Photos: append(append([]grouping.Photo{}, rome...), naples),
+			},
+			Title: "A trip to Italy",
+		},
+	}
+
+	var b bytes.Buffer
+
+	assert.NoError(t, Write(&b, groups, time.Date(2023, 05, 01, 12, 0, 0, 0, time.UTC)))
+	assert.Contains(t, b.String(), "2 groups of 3 photos")
+}
+
+func TestStack(t *testing.T) {
+	bars := []bar{
+		{X: 0, Width: 100},
+		{X: 50, Width: 100},
+		{X: 120, Width: 10},
+		{X: 200, Width: 10},
+	}
+
+	assert.Equal(t, 2, stack(bars))
+
+	lanes := make([]int, 0, len(bars))
+	for _, b := range bars {
+		lanes = append(lanes, b.Y)
+	}
+
+	assert.Equal(t, []int{20, 20 + laneHeight, 20, 20}, lanes)
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	log "github.com/sirupsen/logrus"
+)
+
+// JobStatus describes where a Job is in its lifecycle.
+type JobStatus string
+
+const (
+	// JobQueued is a Job waiting for a worker.
+	JobQueued JobStatus = "queued"
+	// JobRunning is a Job being run through the pipeline.
+	JobRunning JobStatus = "running"
+	// JobDone is a Job which finished, its result can be retrieved.
+	JobDone JobStatus = "done"
+	// JobFailed is a Job where the pipeline returned an error.
+	JobFailed JobStatus = "failed"
+	// JobCancelled is a Job which was cancelled before it finished.
+	JobCancelled JobStatus = "cancelled"
+)
+
+var (
+	// ErrQueueFull is returned by Queue.Submit when there are already Queue.size jobs waiting for a worker.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrJobNotFound is returned when a job ID doesn't exist, or the job has expired.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished is returned by Queue.Cancel when the job has already finished.
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// Job is a snapshot of a grouping request submitted to a Queue. Result is only set once the Status is JobDone.
+type Job struct {
+	ID       string
+	Status   JobStatus
+	Progress grouping.ProgressSnapshot
+	Error    string
+	Created  time.Time
+	Started  time.Time
+	Finished time.Time
+	Result   *grouping.Result
+}
+
+// job is the state of a submitted grouping request, guarded by Queue.mu.
+type job struct {
+	id       string
+	source   grouping.Source
+	status   JobStatus
+	progress *grouping.Progress
+	err      error
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	result   *grouping.Result
+	cancel   context.CancelFunc
+}
+
+// Queue runs grouping requests in the background. Jobs are submitted to a bounded queue and are run by Queue.Run
+// workers, in the same way that Consumer.Run(s) consume photos, so that only a fixed number of jobs run at once.
+//
+// Finished jobs are kept for retention so that their results can be retrieved, and are removed by Queue.RunExpiry.
+type Queue struct {
+	pipeline  *grouping.Pipeline
+	size      int
+	retention time.Duration
+	// ready is signalled when a job is added to pending, so that a waiting worker can take it.
+	ready chan struct{}
+
+	mu sync.RWMutex
+	// pending holds the queued jobs, oldest first.
+	pending []*job
+	jobs    map[string]*job
+}
+
+// NewQueue creates a Queue which holds up to size jobs waiting for a worker, and keeps finished jobs for retention.
+func NewQueue(pipeline *grouping.Pipeline, size int, retention time.Duration) *Queue {
+	return &Queue{
+		pipeline:  pipeline,
+		size:      size,
+		retention: retention,
+		ready:     make(chan struct{}, 1),
+		jobs:      make(map[string]*job),
+	}
+}
+
+// Submit adds a job which runs source through the pipeline and returns its ID. If the queue is full ErrQueueFull is
+// returned.
+func (q *Queue) Submit(source grouping.Source) (string, error) {
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+
+	j := &job{
+		id:       id,
+		source:   source,
+		status:   JobQueued,
+		progress: &grouping.Progress{},
+		created:  time.Now(),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) >= q.size {
+		return "", ErrQueueFull
+	}
+
+	q.pending = append(q.pending, j)
+	q.jobs[id] = j
+	q.signal()
+
+	return id, nil
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (q *Queue) Get(id string) (Job, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	snapshot := Job{
+		ID:       j.id,
+		Status:   j.status,
+		Progress: j.progress.Snapshot(),
+		Created:  j.created,
+		Started:  j.started,
+		Finished: j.finished,
+		Result:   j.result,
+	}
+
+	if j.err != nil {
+		snapshot.Error = j.err.Error()
+	}
+
+	return snapshot, nil
+}
+
+// Cancel stops the job with the given ID. A queued job is cancelled straight away and removed from the queue, freeing
+// its space, a running job is cancelled once the pipeline has stopped.
+func (q *Queue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	switch j.status {
+	case JobQueued:
+		j.status = JobCancelled
+		j.finished = time.Now()
+		j.source = nil
+
+		for i, queued := range q.pending {
+			if queued == j {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+	case JobRunning:
+		j.cancel()
+	default:
+		return ErrJobFinished
+	}
+
+	return nil
+}
+
+// Run is used to run submitted jobs one at a time until ctx is done. Cancelling ctx also cancels the running job.
+func (q *Queue) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.ready:
+			for {
+				j, jobCtx := q.next(ctx)
+				if j == nil {
+					break
+				}
+
+				q.run(jobCtx, j)
+			}
+		}
+	}
+}
+
+// next takes the oldest queued job and marks it as running, returning nil if there are no queued jobs or ctx is done.
+// The returned context is derived from ctx, and is cancelled by Queue.Cancel or once the job has run.
+func (q *Queue) next(ctx context.Context) (*job, context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 || ctx.Err() != nil {
+		return nil, nil
+	}
+
+	j := q.pending[0]
+	q.pending = q.pending[1:]
+
+	// another worker can take any job still queued.
+	if len(q.pending) > 0 {
+		q.signal()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	j.status = JobRunning
+	j.started = time.Now()
+	j.cancel = cancel
+
+	return j, ctx
+}
+
+// signal wakes a worker waiting in Run, if one isn't already due to wake. q.mu must be held.
+func (q *Queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// run runs a single job, which next has marked as running, through the pipeline.
+func (q *Queue) run(ctx context.Context, j *job) {
+	defer j.cancel()
+
+	result, err := q.pipeline.RunWithProgress(ctx, j.source, j.progress)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j.finished = time.Now()
+	j.source = nil
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.status = JobCancelled
+	case err != nil:
+		log.WithError(err).WithField("job", j.id).Error("running job")
+		j.status = JobFailed
+		j.err = err
+	default:
+		j.status = JobDone
+		j.result = &result
+	}
+}
+
+// RunExpiry is used to remove jobs which finished longer than retention ago until ctx is done, checking every minute,
+// or every retention if that's shorter. Jobs are never removed if retention isn't positive.
+func (q *Queue) RunExpiry(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if q.retention <= 0 {
+		return
+	}
+
+	interval := time.Minute
+	if q.retention < interval {
+		interval = q.retention
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.expire()
+		}
+	}
+}
+
+// expire removes jobs which finished longer than retention ago.
+func (q *Queue) expire() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for id, j := range q.jobs {
+		if !j.finished.IsZero() && time.Since(j.finished) > q.retention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random 128 bit hex encoded ID.
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating job id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+	"googlemaps.github.io/maps"
+)
+
+// blockingGeocoder blocks every request until ctx is done.
+type blockingGeocoder struct{}
+
+func (b blockingGeocoder) ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+var testPhotos = []grouping.Photo{
+	{
+		Timestamp: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+		Latitude:  51.5072,
+		Longitude: -0.1276,
+	},
+	{
+		Timestamp: time.Date(2022, 04, 02, 12, 0, 0, 0, time.UTC),
+		Latitude:  51.5080,
+		Longitude: -0.1280,
+	},
+}
+
+// startWorkers runs a single Queue.Run until the test finishes.
+func startWorkers(t *testing.T, q *Queue) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+
+	wg.Add(1)
+	go q.Run(ctx, wg)
+
+	t.Cleanup(
+		func() {
+			cancel()
+			wg.Wait()
+		},
+	)
+}
+
+// waitForStatus waits for the job to reach status and returns it.
+func waitForStatus(t *testing.T, q *Queue, id string, status JobStatus) Job {
+	var job Job
+
+	assert.Eventually(
+		t, func() bool {
+			var err error
+			job, err = q.Get(id)
+			return err == nil && job.Status == status
+		}, time.Second, time.Millisecond*5,
+	)
+
+	return job
+}
+
+func TestQueue_Run(t *testing.T) {
+	q := NewQueue(grouping.New(mockGeocoder{}, grouping.DefaultOptions()), 1, time.Hour)
+	startWorkers(t, q)
+
+	id, err := q.Submit(grouping.Photos(testPhotos...))
+	assert.NoError(t, err)
+
+	job := waitForStatus(t, q, id, JobDone)
+
+	assert.Equal(t, id, job.ID)
+	assert.Equal(
+		t, grouping.ProgressSnapshot{
+			Read:     2,
+			Geocoded: 2,
+			Failed:   0,
+		}, job.Progress,
+	)
+	assert.False(t, job.Started.IsZero())
+	assert.False(t, job.Finished.IsZero())
+	assert.Empty(t, job.Error)
+
+	if assert.NotNil(t, job.Result) {
+		assert.Len(t, job.Result.Groups, 1)
+		assert.Equal(t, "London", job.Result.Groups[0].Name)
+	}
+}
+
+func TestQueue_Submit(t *testing.T) {
+	q := NewQueue(grouping.New(mockGeocoder{}, grouping.DefaultOptions()), 1, time.Hour)
+
+	_, err := q.Submit(grouping.Photos(testPhotos...))
+	assert.NoError(t, err)
+
+	_, err = q.Submit(grouping.Photos(testPhotos...))
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestQueue_SubmitAfterCancel(t *testing.T) {
+	q := NewQueue(grouping.New(mockGeocoder{}, grouping.DefaultOptions()), 1, time.Hour)
+
+	id, err := q.Submit(grouping.Photos(testPhotos...))
+	assert.NoError(t, err)
+	assert.NoError(t, q.Cancel(id))
+
+	// the cancelled job no longer takes up space in the queue
+	next, err := q.Submit(grouping.Photos(testPhotos...))
+	assert.NoError(t, err)
+
+	startWorkers(t, q)
+	waitForStatus(t, q, next, JobDone)
+
+	job, err := q.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, JobCancelled, job.Status)
+	assert.True(t, job.Started.IsZero())
+}
+
+func TestQueue_Cancel(t *testing.T) {
+	tests := []struct {
+		name           string
+		geocoder       grouping.Geocoder
+		workers        bool
+		waitFor        JobStatus
+		id             string
+		expectedStatus JobStatus
+		expectedErr    error
+	}{
+		{
+			name:           "cancels queued job",
+			geocoder:       mockGeocoder{},
+			expectedStatus: JobCancelled,
+		},
+		{
+			name:           "cancels running job",
+			geocoder:       blockingGeocoder{},
+			workers:        true,
+			waitFor:        JobRunning,
+			expectedStatus: JobCancelled,
+		},
+		{
+			name:           "errors cancelling finished job",
+			geocoder:       mockGeocoder{},
+			workers:        true,
+			waitFor:        JobDone,
+			expectedStatus: JobDone,
+			expectedErr:    ErrJobFinished,
+		},
+		{
+			name:        "errors cancelling unknown job",
+			geocoder:    mockGeocoder{},
+			id:          "unknown",
+			expectedErr: ErrJobNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				q := NewQueue(grouping.New(tt.geocoder, grouping.DefaultOptions()), 1, time.Hour)
+				if tt.workers {
+					startWorkers(t, q)
+				}
+
+				id, err := q.Submit(grouping.Photos(testPhotos...))
+				assert.NoError(t, err)
+
+				if tt.waitFor != "" {
+					waitForStatus(t, q, id, tt.waitFor)
+				}
+
+				if tt.id != "" {
+					id = tt.id
+				}
+
+				err = q.Cancel(id)
+				assert.ErrorIs(t, err, tt.expectedErr)
+
+				if tt.expectedStatus != "" {
+					job := waitForStatus(t, q, id, tt.expectedStatus)
+					assert.Equal(t, tt.expectedStatus == JobDone, job.Result != nil)
+				}
+			},
+		)
+	}
+}
+
+func TestQueue_RunExpiry(t *testing.T) {
+	q := NewQueue(grouping.New(mockGeocoder{}, grouping.DefaultOptions()), 2, time.Millisecond*10)
+
+	id, err := q.Submit(grouping.Photos(testPhotos...))
+	assert.NoError(t, err)
+	assert.NoError(t, q.Cancel(id))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+
+	wg.Add(1)
+	go q.RunExpiry(ctx, wg)
+
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// the job expires without anything else being submitted
+	assert.Eventually(
+		t, func() bool {
+			_, err := q.Get(id)
+			return errors.Is(err, ErrJobNotFound)
+		}, time.Second, time.Millisecond*5,
+	)
+}
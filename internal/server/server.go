@@ -0,0 +1,490 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// DefaultOptions are the Options used when no other configuration is provided.
+	DefaultOptions = Options{
+		MaxBodyBytes:   10 << 20,
+		RequestTimeout: time.Minute * 5,
+	}
+
+	// errBodyTooLarge is returned by readBody when the request body is larger than Options.MaxBodyBytes.
+	errBodyTooLarge = errors.New("request body too large")
+)
+
+// Options configures the Server. MaxBodyBytes is the largest request body accepted and RequestTimeout is how long a
+// single grouping request can run for before it is cancelled.
+type Options struct {
+	MaxBodyBytes   int64
+	RequestTimeout time.Duration
+}
+
+// Server exposes a grouping.Pipeline over HTTP.
+//
+//	POST   /v1/group             groups a JSON or CSV list of photos
+//	POST   /v1/jobs              submits a JSON or CSV list of photos to be grouped in the background
+//	GET    /v1/jobs/{id}         reports the status and progress of a job
+//	GET    /v1/jobs/{id}/result  returns the groups of a finished job
+//	DELETE /v1/jobs/{id}         cancels a job
+//	GET    /metrics              exposes the pipeline's Prometheus metrics
+//	GET    /healthz              reports the process is alive
+//	GET    /readyz               reports the server is ready to accept requests
+type Server struct {
+	pipeline *grouping.Pipeline
+	queue    *Queue
+	options  Options
+	ready    int32
+	mux      *http.ServeMux
+}
+
+// New creates a Server which runs each request through the pipeline, and submits jobs to the queue. The Server starts
+// as ready.
+func New(pipeline *grouping.Pipeline, queue *Queue, options Options) *Server {
+	s := &Server{
+		pipeline: pipeline,
+		queue:    queue,
+		options:  options,
+		ready:    1,
+		mux:      http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/v1/group", s.handleGroup)
+	s.mux.HandleFunc("/v1/jobs", s.handleSubmit)
+	s.mux.HandleFunc("/v1/jobs/", s.handleJob)
+	s.mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/readyz", s.handleReady)
+
+	return s
+}
+
+// ServeHTTP allows Server to be used as a http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetReady changes whether /readyz reports the Server as ready, i.e. so that a load balancer stops sending requests
+// while the Server is shutting down.
+func (s *Server) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&s.ready, v)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.ready) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, statusResponse{Status: "not ready"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{Status: "ready"})
+}
+
+// handleGroup reads the photos from the request body and runs them through the pipeline. The request context is
+// used, so that the pipeline is cancelled if the client goes away.
+func (s *Server) handleGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	source, err := s.source(r)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errBodyTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	ctx := r.Context()
+	if s.options.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.options.RequestTimeout)
+		defer cancel()
+	}
+
+	result, err := s.pipeline.Run(ctx, source)
+	if err != nil {
+		log.WithError(err).Error("grouping photos")
+
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		writeError(w, status, fmt.Errorf("grouping photos: %w", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newGroupResponse(result))
+}
+
+// handleSubmit reads the photos from the request body and submits them to the queue. Unlike handleGroup, the job
+// isn't cancelled if the client goes away, see handleJob.
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	source, err := s.source(r)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errBodyTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	id, err := s.queue.Submit(source)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrQueueFull) {
+			status = http.StatusServiceUnavailable
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	job, err := s.queue.Get(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/jobs/"+id)
+	writeJSON(w, http.StatusAccepted, newJobResponse(job))
+}
+
+// handleJob serves the status, result and cancellation of a single job.
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	id, resource := splitJobPath(r.URL.Path)
+	if id == "" || (resource != "" && resource != "result") {
+		writeError(w, http.StatusNotFound, ErrJobNotFound)
+		return
+	}
+
+	switch {
+	case resource == "" && r.Method == http.MethodGet:
+		job, err := s.queue.Get(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newJobResponse(job))
+	case resource == "" && r.Method == http.MethodDelete:
+		if err := s.queue.Cancel(id); err != nil {
+			status := http.StatusNotFound
+			if errors.Is(err, ErrJobFinished) {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err)
+			return
+		}
+
+		job, err := s.queue.Get(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, newJobResponse(job))
+	case resource == "result" && r.Method == http.MethodGet:
+		job, err := s.queue.Get(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+
+		if job.Result == nil {
+			writeError(w, http.StatusConflict, fmt.Errorf("job is %s", job.Status))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newGroupResponse(*job.Result))
+	default:
+		if resource == "" {
+			w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodDelete}, ", "))
+		} else {
+			w.Header().Set("Allow", http.MethodGet)
+		}
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// splitJobPath splits /v1/jobs/{id}/{resource} into the id and resource, resource is empty for /v1/jobs/{id}.
+func splitJobPath(path string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/v1/jobs/"), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[1]
+}
+
+// source reads the request body and returns a grouping.Source for it, based on the Content-Type of the request.
+// CSV bodies use the same format as the CLI, anything else is decoded as a groupRequest.
+func (s *Server) source(r *http.Request) (grouping.Source, error) {
+	body, err := readBody(r.Body, s.options.MaxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		return grouping.CSV(bytes.NewReader(body)), nil
+	}
+
+	var request groupRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("decoding request: %w", err)
+	}
+
+	photos, err := request.photos()
+	if err != nil {
+		return nil, err
+	}
+
+	return grouping.Photos(photos...), nil
+}
+
+// readBody reads at most maxBytes from body, if there is more errBodyTooLarge is returned.
+func readBody(body io.Reader, maxBytes int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading request: %w", err)
+	}
+
+	if int64(len(b)) > maxBytes {
+		return nil, errBodyTooLarge
+	}
+
+	return b, nil
+}
+
+// groupRequest is the JSON body of a request to /v1/group.
+type groupRequest struct {
+	Photos []photoRequest `json:"photos"`
+}
+
+type photoRequest struct {
+	ID        string    `json:"id"`
+	Video     bool      `json:"video"`
+	Timestamp time.Time `json:"timestamp"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+}
+
+// photos returns the photos in the request. A photo without a timestamp, or with a latitude or longitude out of range,
+// is an error, so that a request with a typo is rejected rather than geocoded.
+func (g groupRequest) photos() ([]grouping.Photo, error) {
+	photos := make([]grouping.Photo, 0, len(g.Photos))
+
+	for i, p := range g.Photos {
+		switch {
+		case p.Timestamp.IsZero():
+			return nil, fmt.Errorf("photo %d: missing timestamp", i)
+		case p.Latitude < -90 || p.Latitude > 90:
+			return nil, fmt.Errorf("photo %d: latitude %v out of range", i, p.Latitude)
+		case p.Longitude < -180 || p.Longitude > 180:
+			return nil, fmt.Errorf("photo %d: longitude %v out of range", i, p.Longitude)
+		}
+
+		photos = append(
+			photos, grouping.Photo{
+				ID:        p.ID,
+				Video:     p.Video,
+				Timestamp: p.Timestamp,
+				Latitude:  p.Latitude,
+				Longitude: p.Longitude,
+			},
+		)
+	}
+
+	return photos, nil
+}
+
+// groupResponse is the JSON body of a successful response from /v1/group.
+type groupResponse struct {
+	Groups   []groupJSON   `json:"groups"`
+	Unplaced []photoJSON   `json:"unplaced"`
+	Stats    statsResponse `json:"stats"`
+}
+
+type groupJSON struct {
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind"`
+	TripType    string      `json:"tripType"`
+	Start       time.Time   `json:"start"`
+	End         time.Time   `json:"end"`
+	Titles      []string    `json:"titles"`
+	Explanation string      `json:"explanation,omitempty"`
+	Photos      []photoJSON `json:"photos"`
+}
+
+type photoJSON struct {
+	ID        string    `json:"id,omitempty"`
+	Video     bool      `json:"video,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Addresses []string  `json:"addresses"`
+	Status    string    `json:"status"`
+}
+
+type statsResponse struct {
+	Requests  int64 `json:"requests"`
+	Coalesced int64 `json:"coalesced"`
+	Reused    int64 `json:"reused"`
+	Geocoded  int64 `json:"geocoded"`
+	Failed    int64 `json:"failed"`
+}
+
+// jobResponse is the JSON body of a response from /v1/jobs.
+type jobResponse struct {
+	ID       string           `json:"id"`
+	Status   JobStatus        `json:"status"`
+	Progress progressResponse `json:"progress"`
+	Error    string           `json:"error,omitempty"`
+	Created  time.Time        `json:"created"`
+	Started  *time.Time       `json:"started,omitempty"`
+	Finished *time.Time       `json:"finished,omitempty"`
+}
+
+type progressResponse struct {
+	Read     int64 `json:"read"`
+	Geocoded int64 `json:"geocoded"`
+	Failed   int64 `json:"failed"`
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func newGroupResponse(result grouping.Result) groupResponse {
+	response := groupResponse{
+		Groups:   make([]groupJSON, 0, len(result.Groups)),
+		Unplaced: newPhotosJSON(result.Unplaced),
+		Stats: statsResponse{
+			Requests:  result.Stats.Requests,
+			Coalesced: result.Stats.Coalesced,
+			Reused:    result.Stats.Reused,
+			Geocoded:  result.Stats.Geocoded,
+			Failed:    result.Stats.Failed,
+		},
+	}
+
+	for _, group := range result.Groups {
+		response.Groups = append(
+			response.Groups, groupJSON{
+				Name:        group.Name,
+				Kind:        string(group.Kind),
+				TripType:    group.TripType,
+				Start:       group.Start,
+				End:         group.End,
+				Titles:      group.Titles,
+				Explanation: group.Explanation,
+				Photos:      newPhotosJSON(group.Photos),
+			},
+		)
+	}
+
+	return response
+}
+
+func newJobResponse(job Job) jobResponse {
+	response := jobResponse{
+		ID:     job.ID,
+		Status: job.Status,
+		Progress: progressResponse{
+			Read:     job.Progress.Read,
+			Geocoded: job.Progress.Geocoded,
+			Failed:   job.Progress.Failed,
+		},
+		Error:   job.Error,
+		Created: job.Created,
+	}
+
+	if !job.Started.IsZero() {
+		response.Started = &job.Started
+	}
+
+	if !job.Finished.IsZero() {
+		response.Finished = &job.Finished
+	}
+
+	return response
+}
+
+func newPhotosJSON(photos []grouping.Photo) []photoJSON {
+	photosJSON := make([]photoJSON, 0, len(photos))
+
+	for _, photo := range photos {
+		addresses := make([]string, 0, len(photo.Addresses))
+		for address := range photo.Addresses {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+
+		photosJSON = append(
+			photosJSON, photoJSON{
+				ID:        photo.ID,
+				Video:     photo.Video,
+				Timestamp: photo.Timestamp,
+				Latitude:  photo.Latitude,
+				Longitude: photo.Longitude,
+				Addresses: addresses,
+				Status:    photo.Status.String(),
+			},
+		)
+	}
+
+	return photosJSON
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Error("writing response")
+	}
+}
@@ -0,0 +1,363 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+	"googlemaps.github.io/maps"
+)
+
+// mockGeocoder resolves photos in London to a locality, everything else has no results.
+type mockGeocoder struct{}
+
+func (m mockGeocoder) ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	if r.LatLng.Lat > 51 && r.LatLng.Lat < 52 {
+		return []maps.GeocodingResult{
+			{
+				AddressComponents: []maps.AddressComponent{
+					{
+						LongName: "London",
+						Types:    []string{"locality"},
+					},
+				},
+			},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// newTestServer returns a Server using mockGeocoder, along with its Queue. The Queue has no workers running.
+func newTestServer(options Options) (*Server, *Queue) {
+	pipeline := grouping.New(mockGeocoder{}, grouping.DefaultOptions())
+	queue := NewQueue(pipeline, 1, time.Hour)
+
+	return New(pipeline, queue, options), queue
+}
+
+func TestServer_Group(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		contentType    string
+		body           string
+		maxBodyBytes   int64
+		expectedStatus int
+		expectedGroups []string
+		expectedError  string
+	}{
+		{
+			name:        "groups json photos",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body: `{"photos": [
+				{"timestamp": "2022-04-02T10:00:00Z", "latitude": 51.5072, "longitude": -0.1276},
+				{"timestamp": "2022-04-02T12:00:00Z", "latitude": 51.5080, "longitude": -0.1280}
+			]}`,
+			expectedStatus: http.StatusOK,
+			expectedGroups: []string{"London"},
+		},
+		{
+			name:           "groups csv photos",
+			method:         http.MethodPost,
+			contentType:    "text/csv; charset=utf-8",
+			body:           "2022-04-02T10:00:00Z,51.5072,-0.1276\n2022-04-02T12:00:00Z,51.5080,-0.1280\n",
+			expectedStatus: http.StatusOK,
+			expectedGroups: []string{"London"},
+		},
+		{
+			name:           "rejects other methods",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedError:  "method not allowed",
+		},
+		{
+			name:           "rejects invalid json",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			body:           `{"photos":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "decoding request",
+		},
+		{
+			name:           "rejects photos without a timestamp",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			body:           `{"photos": [{"latitude": 51.5072, "longitude": -0.1276}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "photo 0: missing timestamp",
+		},
+		{
+			name:           "rejects latitudes out of range",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			body:           `{"photos": [{"timestamp": "2022-04-02T10:00:00Z", "latitude": 151.5, "longitude": -0.1276}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "photo 0: latitude 151.5 out of range",
+		},
+		{
+			name:           "rejects longitudes out of range",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			body:           `{"photos": [{"timestamp": "2022-04-02T10:00:00Z", "latitude": 51.5072, "longitude": -180.5}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "photo 0: longitude -180.5 out of range",
+		},
+		{
+			name:           "rejects large bodies",
+			method:         http.MethodPost,
+			contentType:    "text/csv",
+			body:           "2022-04-02T10:00:00Z,51.5072,-0.1276\n",
+			maxBodyBytes:   10,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedError:  "request body too large",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				options := DefaultOptions
+				if tt.maxBodyBytes > 0 {
+					options.MaxBodyBytes = tt.maxBodyBytes
+				}
+
+				s, _ := newTestServer(options)
+
+				r := httptest.NewRequest(tt.method, "/v1/group", strings.NewReader(tt.body))
+				r.Header.Set("Content-Type", tt.contentType)
+				w := httptest.NewRecorder()
+
+				s.ServeHTTP(w, r)
+
+				assert.Equal(t, tt.expectedStatus, w.Code)
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+				if tt.expectedError != "" {
+					var got errorResponse
+					assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+					assert.Contains(t, got.Error, tt.expectedError)
+					return
+				}
+
+				var got groupResponse
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+
+				names := make([]string, 0)
+				for _, group := range got.Groups {
+					names = append(names, group.Name)
+				}
+
+				assert.Equal(t, tt.expectedGroups, names)
+				assert.Len(t, got.Groups[0].Photos, 2)
+				assert.Equal(t, []string{"London"}, got.Groups[0].Photos[0].Addresses)
+				assert.Equal(t, "resolved", got.Groups[0].Photos[0].Status)
+				assert.NotEmpty(t, got.Groups[0].Titles)
+			},
+		)
+	}
+}
+
+func TestServer_GroupCancelled(t *testing.T) {
+	s, _ := newTestServer(DefaultOptions)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/group", strings.NewReader(`{"photos": []}`)).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	s.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "context canceled")
+}
+
+func TestServer_GroupTimeout(t *testing.T) {
+	pipeline := grouping.New(blockingGeocoder{}, grouping.DefaultOptions())
+	s := New(pipeline, NewQueue(pipeline, 1, time.Hour), Options{MaxBodyBytes: 1 << 10, RequestTimeout: time.Millisecond})
+
+	body := `{"photos": [{"timestamp": "2022-04-02T10:00:00Z", "latitude": 51.5072, "longitude": -0.1276}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/group", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	s.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "context deadline exceeded")
+}
+
+func TestServer_GroupExplain(t *testing.T) {
+	options := grouping.DefaultOptions()
+	options.Explain = true
+
+	pipeline := grouping.New(mockGeocoder{}, options)
+	s := New(pipeline, NewQueue(pipeline, 1, time.Hour), DefaultOptions)
+
+	body := `{"photos": [{"timestamp": "2022-04-02T10:00:00Z", "latitude": 51.5072, "longitude": -0.1276}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/group", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	s.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var got groupResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Len(t, got.Groups, 1)
+	assert.Contains(t, got.Groups[0].Explanation, "trip type: day")
+}
+
+func TestServer_Metrics(t *testing.T) {
+	s, _ := newTestServer(DefaultOptions)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/group", strings.NewReader("2022-04-02T10:00:00Z,51.5072,-0.1276\n"))
+	r.Header.Set("Content-Type", "text/csv")
+	s.ServeHTTP(httptest.NewRecorder(), r)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "photo_grouping_rows_read_total")
+	assert.Contains(t, w.Body.String(), `photo_grouping_geocode_duration_seconds_count{request="locality"}`)
+	assert.Contains(t, w.Body.String(), `photo_grouping_groups_produced_total{kind="location"}`)
+}
+
+func TestServer_Health(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		ready          bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "healthy",
+			path:           "/healthz",
+			ready:          true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "healthy while not ready",
+			path:           "/healthz",
+			ready:          false,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "ready",
+			path:           "/readyz",
+			ready:          true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ready",
+		},
+		{
+			name:           "not ready",
+			path:           "/readyz",
+			ready:          false,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "not ready",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				s, _ := newTestServer(DefaultOptions)
+				s.SetReady(tt.ready)
+
+				w := httptest.NewRecorder()
+				s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+				var got statusResponse
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+
+				assert.Equal(t, tt.expectedStatus, w.Code)
+				assert.Equal(t, tt.expectedBody, got.Status)
+			},
+		)
+	}
+}
+
+func TestServer_Jobs(t *testing.T) {
+	s, q := newTestServer(DefaultOptions)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := do(http.MethodPost, "/v1/jobs", "2022-04-02T10:00:00Z,51.5072,-0.1276\n2022-04-02T12:00:00Z,51.5080,-0.1280\n")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var submitted jobResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&submitted))
+	assert.Equal(t, JobQueued, submitted.Status)
+	assert.Equal(t, "/v1/jobs/"+submitted.ID, w.Header().Get("Location"))
+
+	w = do(http.MethodGet, "/v1/jobs/"+submitted.ID+"/result", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "job is queued")
+
+	startWorkers(t, q)
+	waitForStatus(t, q, submitted.ID, JobDone)
+
+	w = do(http.MethodGet, "/v1/jobs/"+submitted.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status jobResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	assert.Equal(t, JobDone, status.Status)
+	assert.Equal(t, progressResponse{Read: 2, Geocoded: 2}, status.Progress)
+	assert.NotNil(t, status.Started)
+	assert.NotNil(t, status.Finished)
+
+	w = do(http.MethodGet, "/v1/jobs/"+submitted.ID+"/result", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result groupResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Len(t, result.Groups, 1)
+	assert.Equal(t, "London", result.Groups[0].Name)
+
+	w = do(http.MethodDelete, "/v1/jobs/"+submitted.ID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = do(http.MethodGet, "/v1/jobs/unknown", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = do(http.MethodGet, "/v1/jobs/"+submitted.ID+"/other", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = do(http.MethodPut, "/v1/jobs/"+submitted.ID, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, DELETE", w.Header().Get("Allow"))
+}
+
+func TestServer_CancelJob(t *testing.T) {
+	s, q := newTestServer(DefaultOptions)
+
+	id, err := q.Submit(grouping.Photos(testPhotos...))
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/v1/jobs/"+id, nil))
+
+	var got jobResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, JobCancelled, got.Status)
+}
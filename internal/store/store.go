@@ -0,0 +1,885 @@
+// Package store persists photos, their geocoding, and the groups and titles chosen for them in a SQLite database. This
+// allows runs to be incremental, only photos which haven't been geocoded before are geocoded, groups to be queried
+// after a run, and titles chosen by the user to survive regrouping.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	// registers the pure Go "sqlite" driver, so that no cgo toolchain is needed.
+	_ "modernc.org/sqlite"
+)
+
+var (
+	// ErrGroupNotFound is returned when there is no group with the given ID.
+	ErrGroupNotFound = errors.New("group not found")
+)
+
+// migrations are run in order against a database, each is only run once, see migrate.
+var migrations = []string{
+	schema,
+	// photos read from a library or files have an ID, see grouping.Photo.
+	"ALTER TABLE photos ADD COLUMN source_id TEXT NOT NULL DEFAULT ''",
+	// videos are grouped alongside photos, see grouping.Photo.
+	"ALTER TABLE photos ADD COLUMN video INTEGER NOT NULL DEFAULT 0",
+	// photos are identified by their source ID as well as their time and place, so that burst shots aren't merged.
+	// SQLite can't drop a constraint, so the table is rebuilt, see migrate.
+	`
+CREATE TABLE photos_new (
+	id        INTEGER PRIMARY KEY,
+	timestamp TEXT NOT NULL,
+	latitude  REAL NOT NULL,
+	longitude REAL NOT NULL,
+	status    INTEGER NOT NULL,
+	source_id TEXT NOT NULL DEFAULT '',
+	video     INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (source_id, timestamp, latitude, longitude)
+);
+
+INSERT INTO photos_new (id, timestamp, latitude, longitude, status, source_id, video)
+SELECT id, timestamp, latitude, longitude, status, source_id, video FROM photos;
+
+DROP TABLE photos;
+
+ALTER TABLE photos_new RENAME TO photos;
+`,
+	// a watched folder's ingested files, the photos still being grouped, and the events not delivered yet, see Ingest.
+	`
+CREATE TABLE ingested_files (
+	name TEXT PRIMARY KEY
+);
+
+CREATE TABLE streaming_photos (
+	photo_id INTEGER PRIMARY KEY REFERENCES photos (id) ON DELETE CASCADE
+);
+
+CREATE TABLE undelivered_events (
+	id   INTEGER PRIMARY KEY,
+	data BLOB NOT NULL
+);
+`,
+}
+
+// schema creates every table, it's safe to run against an existing database. Timestamps are stored as RFC 3339 text,
+// so that a photo's offset is kept, with the years a group covers stored separately to query by.
+const schema = `
+CREATE TABLE IF NOT EXISTS photos (
+	id        INTEGER PRIMARY KEY,
+	timestamp TEXT NOT NULL,
+	latitude  REAL NOT NULL,
+	longitude REAL NOT NULL,
+	status    INTEGER NOT NULL,
+	UNIQUE (timestamp, latitude, longitude)
+);
+
+CREATE TABLE IF NOT EXISTS addresses (
+	photo_id INTEGER NOT NULL REFERENCES photos (id) ON DELETE CASCADE,
+	name     TEXT NOT NULL,
+	type     TEXT NOT NULL,
+	PRIMARY KEY (photo_id, name, type)
+);
+
+CREATE INDEX IF NOT EXISTS addresses_type_name ON addresses (type, name);
+
+CREATE TABLE IF NOT EXISTS groups (
+	id         INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	kind       TEXT NOT NULL,
+	trip_type  TEXT NOT NULL,
+	start_time TEXT NOT NULL,
+	end_time   TEXT NOT NULL,
+	start_year INTEGER NOT NULL,
+	end_year   INTEGER NOT NULL,
+	title      TEXT NOT NULL,
+	overridden INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS group_photos (
+	group_id INTEGER NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+	photo_id INTEGER NOT NULL REFERENCES photos (id) ON DELETE CASCADE,
+	PRIMARY KEY (group_id, photo_id)
+);
+
+CREATE TABLE IF NOT EXISTS titles (
+	group_id INTEGER NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	title    TEXT NOT NULL,
+	PRIMARY KEY (group_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS title_overrides (
+	id         INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	kind       TEXT NOT NULL,
+	start_time TEXT NOT NULL,
+	end_time   TEXT NOT NULL,
+	title      TEXT NOT NULL
+);
+`
+
+// Group is a grouping.Group saved in a Store. Title is the title chosen for the group, the user's title if
+// Overridden, otherwise the first of Titles.
+type Group struct {
+	grouping.Group
+	ID         int64
+	Title      string
+	Overridden bool
+}
+
+// Query filters the groups returned by Store.Groups, any field which isn't set matches every group. Year matches
+// groups which cover any part of the year, Country groups with a photo in the country, and Name and TripType match
+// exactly. Country and Name aren't case sensitive.
+type Query struct {
+	Year     int
+	Country  string
+	Name     string
+	TripType string
+}
+
+// Store is a SQLite database of photos and groups. A Store can be safely used concurrently.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the database at path, creating it if it doesn't exist.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	// SQLite only allows a single writer, and foreign keys are enabled per connection.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("enabling foreign keys: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// migrate runs every migration which hasn't been run against the database yet. The number of migrations run is kept
+// in the database's user_version. Migrations are run before foreign keys are enabled, so that a table can be rebuilt
+// without its references being deleted.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("beginning migration: %w", err)
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("running migration %d: %w", i+1, err)
+		}
+
+		// PRAGMA doesn't support parameters, i is never user input.
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("running migration %d: %w", i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("running migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Photos returns every stored photo, ordered by timestamp. Photos where geocoding failed are returned with the
+// grouping.GeocodePending status and no addresses, so that they are geocoded again.
+func (s *Store) Photos(ctx context.Context) ([]grouping.Photo, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT p.id, p.source_id, p.video, p.timestamp, p.latitude, p.longitude, p.status, a.name, a.type
+FROM photos p
+LEFT JOIN addresses a ON a.photo_id = p.id
+ORDER BY p.id, a.name, a.type`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying photos: %w", err)
+	}
+	defer rows.Close()
+
+	photos, err := scanPhotos(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range photos {
+		if photos[i].Status == grouping.GeocodeFailed {
+			photos[i].Status = grouping.GeocodePending
+		}
+	}
+
+	sort.SliceStable(
+		photos, func(i, j int) bool {
+			return photos[i].Timestamp.Before(photos[j].Timestamp)
+		},
+	)
+
+	return photos, nil
+}
+
+// Merge returns a Source which sends every stored photo, then every photo from source which isn't stored. Stored
+// photos keep their geocoding, so that they aren't geocoded again.
+func Merge(stored []grouping.Photo, source grouping.Source) grouping.Source {
+	return grouping.SourceFunc(
+		func(ctx context.Context) <-chan grouping.Photo {
+			photoChan := make(chan grouping.Photo)
+
+			go func() {
+				defer close(photoChan)
+
+				seen := make(map[photoKey]struct{}, len(stored))
+
+				for _, photo := range stored {
+					seen[keyOf(photo)] = struct{}{}
+
+					select {
+					case <-ctx.Done():
+						return
+					case photoChan <- photo:
+					}
+				}
+
+				for photo := range source.Read(ctx) {
+					if _, ok := seen[keyOf(photo)]; ok {
+						continue
+					}
+					seen[keyOf(photo)] = struct{}{}
+
+					select {
+					case <-ctx.Done():
+						return
+					case photoChan <- photo:
+					}
+				}
+			}()
+
+			return photoChan
+		},
+	)
+}
+
+// Save stores every photo in the result along with its geocoding, and replaces every stored group with the groups in
+// the result. A title chosen with SetTitle is kept for any new group with the same name and kind which overlaps the
+// group it was chosen for. Save returns the saved groups.
+func (s *Store) Save(ctx context.Context, result grouping.Result) ([]Group, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	photoIDs := make(map[photoKey]int64)
+
+	for _, group := range result.Groups {
+		if err := savePhotos(ctx, tx, group.Photos, photoIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := savePhotos(ctx, tx, result.Unplaced, photoIDs); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM groups"); err != nil {
+		return nil, fmt.Errorf("deleting groups: %w", err)
+	}
+
+	groups, err := saveGroups(ctx, tx, result.Groups, photoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing: %w", err)
+	}
+
+	return groups, nil
+}
+
+// Groups returns the stored groups matching the Query, ordered by start time, then name.
+func (s *Store) Groups(ctx context.Context, query Query) ([]Group, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if query.Year != 0 {
+		conditions = append(conditions, "g.start_year <= ? AND g.end_year >= ?")
+		args = append(args, query.Year, query.Year)
+	}
+
+	if query.Country != "" {
+		conditions = append(
+			conditions, `EXISTS (
+	SELECT 1 FROM group_photos gp
+	JOIN addresses a ON a.photo_id = gp.photo_id
+	WHERE gp.group_id = g.id AND a.type = 'country' AND a.name = ? COLLATE NOCASE
+)`,
+		)
+		args = append(args, query.Country)
+	}
+
+	if query.Name != "" {
+		conditions = append(conditions, "g.name = ? COLLATE NOCASE")
+		args = append(args, query.Name)
+	}
+
+	if query.TripType != "" {
+		conditions = append(conditions, "g.trip_type = ?")
+		args = append(args, query.TripType)
+	}
+
+	statement := "SELECT g.id FROM groups g"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY g.id"
+
+	ids, err := queryIDs(ctx, s.db, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying groups: %w", err)
+	}
+
+	groups := make([]Group, 0, len(ids))
+
+	for _, id := range ids {
+		group, err := s.Group(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(
+		groups, func(i, j int) bool {
+			if !groups[i].Start.Equal(groups[j].Start) {
+				return groups[i].Start.Before(groups[j].Start)
+			}
+			return groups[i].Name < groups[j].Name
+		},
+	)
+
+	return groups, nil
+}
+
+// Group returns the stored group with the given ID, if it doesn't exist ErrGroupNotFound is returned.
+func (s *Store) Group(ctx context.Context, id int64) (Group, error) {
+	group := Group{
+		ID: id,
+	}
+
+	var kind, start, end string
+
+	err := s.db.QueryRowContext(
+		ctx, "SELECT name, kind, trip_type, start_time, end_time, title, overridden FROM groups WHERE id = ?", id,
+	).Scan(&group.Name, &kind, &group.TripType, &start, &end, &group.Title, &group.Overridden)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Group{}, ErrGroupNotFound
+	}
+	if err != nil {
+		return Group{}, fmt.Errorf("querying group: %w", err)
+	}
+
+	group.Kind = grouping.GroupKind(kind)
+
+	if group.Start, err = parseTime(start); err != nil {
+		return Group{}, err
+	}
+
+	if group.End, err = parseTime(end); err != nil {
+		return Group{}, err
+	}
+
+	titles, err := s.db.QueryContext(ctx, "SELECT title FROM titles WHERE group_id = ? ORDER BY position", id)
+	if err != nil {
+		return Group{}, fmt.Errorf("querying titles: %w", err)
+	}
+	defer titles.Close()
+
+	for titles.Next() {
+		var title string
+		if err := titles.Scan(&title); err != nil {
+			return Group{}, fmt.Errorf("scanning title: %w", err)
+		}
+
+		group.Titles = append(group.Titles, title)
+	}
+
+	if err := titles.Err(); err != nil {
+		return Group{}, fmt.Errorf("querying titles: %w", err)
+	}
+
+	photos, err := s.db.QueryContext(
+		ctx, `
+SELECT p.id, p.source_id, p.video, p.timestamp, p.latitude, p.longitude, p.status, a.name, a.type
+FROM group_photos gp
+JOIN photos p ON p.id = gp.photo_id
+LEFT JOIN addresses a ON a.photo_id = p.id
+WHERE gp.group_id = ?
+ORDER BY p.id, a.name, a.type`, id,
+	)
+	if err != nil {
+		return Group{}, fmt.Errorf("querying photos: %w", err)
+	}
+	defer photos.Close()
+
+	if group.Photos, err = scanPhotos(photos); err != nil {
+		return Group{}, err
+	}
+
+	sort.SliceStable(
+		group.Photos, func(i, j int) bool {
+			return group.Photos[i].Timestamp.Before(group.Photos[j].Timestamp)
+		},
+	)
+
+	return group, nil
+}
+
+// SetTitle sets the title of the group with the given ID, replacing the suggested title. The title is kept when photos
+// are regrouped, see Store.Save.
+func (s *Store) SetTitle(ctx context.Context, id int64, title string) error {
+	return s.override(ctx, id, &title)
+}
+
+// ResetTitle removes a title set with SetTitle, so that the group's first suggested title is used again.
+func (s *Store) ResetTitle(ctx context.Context, id int64) error {
+	return s.override(ctx, id, nil)
+}
+
+// override replaces any override for the group with the given ID with title, or removes it if title is nil.
+func (s *Store) override(ctx context.Context, id int64, title *string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		group      grouping.Group
+		kind       string
+		start, end string
+	)
+
+	err = tx.QueryRowContext(ctx, "SELECT name, kind, start_time, end_time FROM groups WHERE id = ?", id).
+		Scan(&group.Name, &kind, &start, &end)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("querying group: %w", err)
+	}
+
+	group.Kind = grouping.GroupKind(kind)
+
+	if group.Start, err = parseTime(start); err != nil {
+		return err
+	}
+
+	if group.End, err = parseTime(end); err != nil {
+		return err
+	}
+
+	overrides, err := loadOverrides(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for o := overrides.match(group); o != nil; o = overrides.match(group) {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM title_overrides WHERE id = ?", o.id); err != nil {
+			return fmt.Errorf("deleting override: %w", err)
+		}
+
+		overrides = overrides.without(o.id)
+	}
+
+	if title == nil {
+		_, err = tx.ExecContext(
+			ctx, `
+UPDATE groups
+SET title = COALESCE((SELECT title FROM titles WHERE group_id = groups.id ORDER BY position LIMIT 1), ''),
+	overridden = 0
+WHERE id = ?`, id,
+		)
+		if err != nil {
+			return fmt.Errorf("updating group: %w", err)
+		}
+	} else {
+		_, err = tx.ExecContext(
+			ctx, "INSERT INTO title_overrides (name, kind, start_time, end_time, title) VALUES (?, ?, ?, ?, ?)",
+			group.Name, kind, start, end, *title,
+		)
+		if err != nil {
+			return fmt.Errorf("inserting override: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE groups SET title = ?, overridden = 1 WHERE id = ?", *title, id); err != nil {
+			return fmt.Errorf("updating group: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+
+	return nil
+}
+
+// photoKey identifies a photo, two photos with the same key are the same photo. Photos read from a CSV don't have an
+// ID, so they're identified by their time and place alone.
+type photoKey struct {
+	id        string
+	timestamp string
+	latitude  float64
+	longitude float64
+}
+
+func keyOf(photo grouping.Photo) photoKey {
+	return photoKey{
+		id:        photo.ID,
+		timestamp: formatTime(photo.Timestamp),
+		latitude:  photo.Latitude,
+		longitude: photo.Longitude,
+	}
+}
+
+// savePhotos saves each photo which isn't in photoIDs yet, adding its ID to photoIDs.
+func savePhotos(ctx context.Context, tx *sql.Tx, photos []grouping.Photo, photoIDs map[photoKey]int64) error {
+	for _, photo := range photos {
+		if _, ok := photoIDs[keyOf(photo)]; ok {
+			continue
+		}
+
+		id, err := savePhoto(ctx, tx, photo)
+		if err != nil {
+			return err
+		}
+
+		photoIDs[keyOf(photo)] = id
+	}
+
+	return nil
+}
+
+// savePhoto inserts the photo, or updates its geocoding if it's already stored, and returns its ID.
+func savePhoto(ctx context.Context, tx *sql.Tx, photo grouping.Photo) (int64, error) {
+	var id int64
+
+	err := tx.QueryRowContext(
+		ctx, `
+INSERT INTO photos (source_id, video, timestamp, latitude, longitude, status) VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (source_id, timestamp, latitude, longitude) DO UPDATE SET
+	status = excluded.status,
+	video = excluded.video
+RETURNING id`,
+		photo.ID, photo.Video, formatTime(photo.Timestamp), photo.Latitude, photo.Longitude, int(photo.Status),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("saving photo: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM addresses WHERE photo_id = ?", id); err != nil {
+		return 0, fmt.Errorf("deleting addresses: %w", err)
+	}
+
+	for name := range photo.Addresses {
+		types := photo.AddressTypes[name]
+		if len(types) == 0 {
+			types = []string{""}
+		}
+
+		for _, addressType := range types {
+			_, err := tx.ExecContext(
+				ctx, "INSERT OR IGNORE INTO addresses (photo_id, name, type) VALUES (?, ?, ?)", id, name, addressType,
+			)
+			if err != nil {
+				return 0, fmt.Errorf("saving address: %w", err)
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// saveGroups inserts each group, whose photos must already be in photoIDs, along with the title chosen for it. A
+// group matching a title override is given its title, and the override is updated to follow the group.
+func saveGroups(ctx context.Context, tx *sql.Tx, groups []grouping.Group, photoIDs map[photoKey]int64) ([]Group, error) {
+	overrides, err := loadOverrides(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make([]Group, 0, len(groups))
+
+	for _, group := range groups {
+		g := Group{
+			Group: group,
+		}
+
+		if len(group.Titles) > 0 {
+			g.Title = group.Titles[0]
+		}
+
+		if o := overrides.match(group); o != nil {
+			g.Title = o.title
+			g.Overridden = true
+
+			// the override follows the group as it grows.
+			if err := updateOverride(ctx, tx, o.id, group); err != nil {
+				return nil, err
+			}
+		}
+
+		g.ID, err = saveGroup(ctx, tx, g, photoIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		saved = append(saved, g)
+	}
+
+	return saved, nil
+}
+
+// saveGroup inserts the group, its titles, and links it to its photos, returning its ID.
+func saveGroup(ctx context.Context, tx *sql.Tx, group Group, photoIDs map[photoKey]int64) (int64, error) {
+	result, err := tx.ExecContext(
+		ctx, `
+INSERT INTO groups (name, kind, trip_type, start_time, end_time, start_year, end_year, title, overridden)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		group.Name, string(group.Kind), group.TripType, formatTime(group.Start), formatTime(group.End),
+		group.Start.Year(), group.End.Year(), group.Title, group.Overridden,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("saving group: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("saving group: %w", err)
+	}
+
+	for position, title := range group.Titles {
+		_, err := tx.ExecContext(
+			ctx, "INSERT INTO titles (group_id, position, title) VALUES (?, ?, ?)", id, position, title,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("saving title: %w", err)
+		}
+	}
+
+	for _, photo := range group.Photos {
+		_, err := tx.ExecContext(
+			ctx, "INSERT OR IGNORE INTO group_photos (group_id, photo_id) VALUES (?, ?)", id, photoIDs[keyOf(photo)],
+		)
+		if err != nil {
+			return 0, fmt.Errorf("saving group photo: %w", err)
+		}
+	}
+
+	return id, nil
+}
+
+// titleOverride is a title chosen by the user for the group with name and kind, between start and end.
+type titleOverride struct {
+	id    int64
+	name  string
+	kind  string
+	start time.Time
+	end   time.Time
+	title string
+}
+
+type overrides []titleOverride
+
+// match returns the override for the group, or nil if there isn't one.
+func (o overrides) match(group grouping.Group) *titleOverride {
+	for i := range o {
+		if o[i].name == group.Name && o[i].kind == string(group.Kind) &&
+			!o[i].start.After(group.End) && !o[i].end.Before(group.Start) {
+			return &o[i]
+		}
+	}
+
+	return nil
+}
+
+// without returns the overrides other than the one with id.
+func (o overrides) without(id int64) overrides {
+	remaining := make(overrides, 0, len(o))
+
+	for _, override := range o {
+		if override.id != id {
+			remaining = append(remaining, override)
+		}
+	}
+
+	return remaining
+}
+
+func loadOverrides(ctx context.Context, tx *sql.Tx) (overrides, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, name, kind, start_time, end_time, title FROM title_overrides ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("querying overrides: %w", err)
+	}
+	defer rows.Close()
+
+	loaded := make(overrides, 0)
+
+	for rows.Next() {
+		var (
+			o          titleOverride
+			start, end string
+		)
+
+		if err := rows.Scan(&o.id, &o.name, &o.kind, &start, &end, &o.title); err != nil {
+			return nil, fmt.Errorf("scanning override: %w", err)
+		}
+
+		if o.start, err = parseTime(start); err != nil {
+			return nil, err
+		}
+
+		if o.end, err = parseTime(end); err != nil {
+			return nil, err
+		}
+
+		loaded = append(loaded, o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying overrides: %w", err)
+	}
+
+	return loaded, nil
+}
+
+func updateOverride(ctx context.Context, tx *sql.Tx, id int64, group grouping.Group) error {
+	_, err := tx.ExecContext(
+		ctx, "UPDATE title_overrides SET start_time = ?, end_time = ? WHERE id = ?",
+		formatTime(group.Start), formatTime(group.End), id,
+	)
+	if err != nil {
+		return fmt.Errorf("updating override: %w", err)
+	}
+
+	return nil
+}
+
+// scanPhotos reads photos from rows of id, source id, timestamp, latitude, longitude, status, address name and
+// address type, ordered by id, with a row for each address type. A photo's address types are returned in alphabetical
+// order.
+func scanPhotos(rows *sql.Rows) ([]grouping.Photo, error) {
+	photos := make([]grouping.Photo, 0)
+	lastID := int64(-1)
+
+	for rows.Next() {
+		var (
+			id                int64
+			timestamp         string
+			photo             grouping.Photo
+			status            int
+			name, addressType sql.NullString
+		)
+
+		err := rows.Scan(&id, &photo.ID, &photo.Video, &timestamp, &photo.Latitude, &photo.Longitude, &status, &name, &addressType)
+		if err != nil {
+			return nil, fmt.Errorf("scanning photo: %w", err)
+		}
+
+		if id != lastID {
+			var err error
+			if photo.Timestamp, err = parseTime(timestamp); err != nil {
+				return nil, err
+			}
+
+			photo.Status = grouping.GeocodeStatus(status)
+			photos = append(photos, photo)
+			lastID = id
+		}
+
+		if !name.Valid {
+			continue
+		}
+
+		last := &photos[len(photos)-1]
+
+		if last.Addresses == nil {
+			last.Addresses = make(map[string]struct{})
+			last.AddressTypes = make(map[string][]string)
+		}
+
+		last.Addresses[name.String] = struct{}{}
+
+		if addressType.String != "" {
+			last.AddressTypes[name.String] = append(last.AddressTypes[name.String], addressType.String)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying photos: %w", err)
+	}
+
+	return photos, nil
+}
+
+func queryIDs(ctx context.Context, db *sql.DB, statement string, args ...interface{}) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing time: %w", err)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,329 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPhoto(timestamp time.Time, latitude float64, addresses map[string][]string) grouping.Photo {
+	photo := grouping.Photo{
+		Timestamp: timestamp,
+		Latitude:  latitude,
+		Longitude: -0.1276,
+		Status:    grouping.GeocodeUnresolved,
+	}
+
+	if len(addresses) > 0 {
+		photo.Addresses = make(map[string]struct{})
+		photo.AddressTypes = addresses
+		photo.Status = grouping.GeocodeResolved
+
+		for name := range addresses {
+			photo.Addresses[name] = struct{}{}
+		}
+	}
+
+	return photo
+}
+
+var (
+	london = map[string][]string{"London": {"locality"}, "United Kingdom": {"country"}}
+	rome   = map[string][]string{"Rome": {"locality"}, "Italy": {"country"}}
+
+	londonPhoto = testPhoto(time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC), 51.5072, london)
+	romePhoto   = testPhoto(time.Date(2023, 06, 10, 10, 0, 0, 0, time.UTC), 41.9028, rome)
+	romePhoto2  = testPhoto(time.Date(2023, 06, 11, 10, 0, 0, 0, time.UTC), 41.9028, rome)
+	seaPhoto    = testPhoto(time.Date(2023, 06, 12, 10, 0, 0, 0, time.UTC), 10, nil)
+	testResult  = grouping.Result{
+		Groups: []grouping.Group{
+			testGroup("London", "A day out in London", londonPhoto),
+			testGroup("Rome", "A trip to Rome", romePhoto),
+		},
+		Unplaced: []grouping.Photo{seaPhoto},
+	}
+)
+
+func withID(photo grouping.Photo, id string) grouping.Photo {
+	photo.ID = id
+	return photo
+}
+
+func testGroup(name, title string, photos ...grouping.Photo) grouping.Group {
+	return grouping.Group{
+		Name:     name,
+		Kind:     grouping.KindLocation,
+		TripType: "day",
+		Start:    photos[0].Timestamp,
+		End:      photos[len(photos)-1].Timestamp,
+		Photos:   photos,
+		Titles:   []string{title, name + " in spring"},
+	}
+}
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := Open(filepath.Join(t.TempDir(), "photos.db"))
+	assert.NoError(t, err)
+
+	t.Cleanup(
+		func() {
+			assert.NoError(t, s.Close())
+		},
+	)
+
+	return s
+}
+
+func TestStore_Save(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	saved, err := s.Save(ctx, testResult)
+	assert.NoError(t, err)
+	assert.Len(t, saved, 2)
+	assert.Equal(t, "A day out in London", saved[0].Title)
+	assert.False(t, saved[0].Overridden)
+
+	got, err := s.Group(ctx, saved[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, saved[0], got)
+
+	photos, err := s.Photos(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []grouping.Photo{londonPhoto, romePhoto, seaPhoto}, photos)
+
+	// saving again replaces the groups, without duplicating photos
+	saved, err = s.Save(ctx, testResult)
+	assert.NoError(t, err)
+
+	groups, err := s.Groups(ctx, Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, saved, groups)
+
+	photos, err = s.Photos(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, photos, 3)
+}
+
+func TestOpen_Migrates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photos.db")
+
+	// a database created before photos had an ID
+	db, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+
+	_, err = db.Exec(schema)
+	assert.NoError(t, err)
+
+	_, err = db.Exec(
+		"INSERT INTO photos (timestamp, latitude, longitude, status) VALUES (?, ?, ?, ?)",
+		"2022-04-02T10:00:00Z", 51.5072, -0.1276, int(grouping.GeocodeResolved),
+	)
+	assert.NoError(t, err)
+
+	_, err = db.Exec("INSERT INTO addresses (photo_id, name, type) VALUES (1, 'London', 'locality')")
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	s, err := Open(path)
+	assert.NoError(t, err)
+
+	photos, err := s.Photos(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, photos, 1)
+	assert.Equal(t, "", photos[0].ID)
+	assert.False(t, photos[0].Video)
+	// rebuilding the photos table keeps the addresses which reference it
+	assert.Equal(t, map[string][]string{"London": {"locality"}}, photos[0].AddressTypes)
+
+	// photos at the same time and place with different IDs are different photos
+	_, err = s.Save(context.Background(), grouping.Result{Unplaced: []grouping.Photo{withID(photos[0], "IMG_0001.jpg")}})
+	assert.NoError(t, err)
+
+	photos, err = s.Photos(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, photos, 2)
+	assert.NoError(t, s.Close())
+
+	// opening it again doesn't run the migrations again
+	s, err = Open(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+}
+
+func TestStore_Photos(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	failed := testPhoto(time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC), 51.5072, nil)
+	failed.Status = grouping.GeocodeFailed
+	failed.ID = "VID_0001.mp4"
+	failed.Video = true
+
+	_, err := s.Save(ctx, grouping.Result{Unplaced: []grouping.Photo{failed}})
+	assert.NoError(t, err)
+
+	photos, err := s.Photos(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, photos, 1)
+	assert.Equal(t, grouping.GeocodePending, photos[0].Status)
+	assert.Equal(t, "VID_0001.mp4", photos[0].ID)
+	assert.True(t, photos[0].Video)
+}
+
+func TestStore_Groups(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.Save(ctx, testResult)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{
+			name:     "returns every group",
+			query:    Query{},
+			expected: []string{"London", "Rome"},
+		},
+		{
+			name:     "returns groups in a year",
+			query:    Query{Year: 2022},
+			expected: []string{"London"},
+		},
+		{
+			name:     "returns groups in a country",
+			query:    Query{Country: "italy"},
+			expected: []string{"Rome"},
+		},
+		{
+			name:     "returns groups by name and trip type",
+			query:    Query{Name: "london", TripType: "day"},
+			expected: []string{"London"},
+		},
+		{
+			name:     "returns no groups when nothing matches",
+			query:    Query{Year: 2023, Country: "United Kingdom"},
+			expected: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				groups, err := s.Groups(ctx, tt.query)
+				assert.NoError(t, err)
+
+				names := make([]string, 0, len(groups))
+				for _, group := range groups {
+					names = append(names, group.Name)
+				}
+
+				assert.Equal(t, tt.expected, names)
+			},
+		)
+	}
+}
+
+func TestStore_SetTitle(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	saved, err := s.Save(ctx, testResult)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.SetTitle(ctx, saved[1].ID, "Roman holiday"))
+	assert.ErrorIs(t, s.SetTitle(ctx, 100, "Missing"), ErrGroupNotFound)
+
+	got, err := s.Group(ctx, saved[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Roman holiday", got.Title)
+	assert.True(t, got.Overridden)
+
+	// regrouping with another photo keeps the title, and it follows the group as it grows
+	regrouped := grouping.Result{
+		Groups: []grouping.Group{
+			testGroup("London", "A day out in London", londonPhoto),
+			testGroup("Rome", "A weekend in Rome", romePhoto, romePhoto2),
+		},
+	}
+
+	saved, err = s.Save(ctx, regrouped)
+	assert.NoError(t, err)
+	assert.Equal(t, "A day out in London", saved[0].Title)
+	assert.Equal(t, "Roman holiday", saved[1].Title)
+	assert.True(t, saved[1].Overridden)
+
+	regrouped.Groups[1] = testGroup("Rome", "A weekend in Rome", romePhoto2)
+
+	saved, err = s.Save(ctx, regrouped)
+	assert.NoError(t, err)
+	assert.Equal(t, "Roman holiday", saved[1].Title)
+
+	assert.NoError(t, s.ResetTitle(ctx, saved[1].ID))
+
+	got, err = s.Group(ctx, saved[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "A weekend in Rome", got.Title)
+	assert.False(t, got.Overridden)
+
+	saved, err = s.Save(ctx, regrouped)
+	assert.NoError(t, err)
+	assert.Equal(t, "A weekend in Rome", saved[1].Title)
+}
+
+func TestMerge(t *testing.T) {
+	stored := []grouping.Photo{londonPhoto}
+
+	pending := londonPhoto
+	pending.Addresses = nil
+	pending.AddressTypes = nil
+	pending.Status = grouping.GeocodePending
+
+	newPhoto := romePhoto
+	newPhoto.Addresses = nil
+	newPhoto.AddressTypes = nil
+	newPhoto.Status = grouping.GeocodePending
+
+	got := make([]grouping.Photo, 0)
+	for photo := range Merge(stored, grouping.Photos(pending, newPhoto, newPhoto)).Read(context.Background()) {
+		got = append(got, photo)
+	}
+
+	assert.Equal(t, []grouping.Photo{londonPhoto, newPhoto}, got)
+}
+
+func TestMerge_Burst(t *testing.T) {
+	first := withID(londonPhoto, "IMG_0001.jpg")
+	second := withID(londonPhoto, "IMG_0002.jpg")
+
+	got := make([]grouping.Photo, 0)
+	for photo := range Merge([]grouping.Photo{first}, grouping.Photos(first, second)).Read(context.Background()) {
+		got = append(got, photo)
+	}
+
+	assert.Equal(t, []grouping.Photo{first, second}, got)
+}
+
+func TestStore_Save_Burst(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	first := withID(londonPhoto, "IMG_0001.jpg")
+	second := withID(londonPhoto, "IMG_0002.jpg")
+
+	saved, err := s.Save(ctx, grouping.Result{Groups: []grouping.Group{testGroup("London", "A day out in London", first, second)}})
+	assert.NoError(t, err)
+
+	group, err := s.Group(ctx, saved[0].ID)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []grouping.Photo{first, second}, group.Photos)
+}
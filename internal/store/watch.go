@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+)
+
+// Ingest is the change to a watched folder's groups made by ingesting File. Closed are the groups which can no longer
+// change, which are added to the stored groups, and Streaming the photos which can still be grouped, including those
+// which couldn't be geocoded yet, which replace those of the previous Ingest. Events are the encoded notifications of the change, which are kept until Delivered.
+type Ingest struct {
+	File      string
+	Closed    []grouping.Group
+	Streaming []grouping.Photo
+	Events    [][]byte
+}
+
+// Event is an event saved by SaveIngest which hasn't been delivered yet.
+type Event struct {
+	ID   int64
+	Data []byte
+}
+
+// Ingested reports whether the file called name has been saved by SaveIngest.
+func (s *Store) Ingested(ctx context.Context, name string) (bool, error) {
+	var ingested bool
+
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM ingested_files WHERE name = ?)", name).Scan(&ingested)
+	if err != nil {
+		return false, fmt.Errorf("querying ingested files: %w", err)
+	}
+
+	return ingested, nil
+}
+
+// SaveIngest saves the Ingest in a single transaction, so that the file is only recorded as ingested along with its
+// groups, photos and events. Closed groups are given the title chosen for them like Save, without replacing the groups
+// which are already stored.
+func (s *Store) SaveIngest(ctx context.Context, ingest Ingest) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO ingested_files (name) VALUES (?)", ingest.File); err != nil {
+		return fmt.Errorf("saving ingested file: %w", err)
+	}
+
+	photoIDs := make(map[photoKey]int64)
+
+	for _, group := range ingest.Closed {
+		if err := savePhotos(ctx, tx, group.Photos, photoIDs); err != nil {
+			return err
+		}
+	}
+
+	if _, err := saveGroups(ctx, tx, ingest.Closed, photoIDs); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM streaming_photos"); err != nil {
+		return fmt.Errorf("deleting streaming photos: %w", err)
+	}
+
+	if err := savePhotos(ctx, tx, ingest.Streaming, photoIDs); err != nil {
+		return err
+	}
+
+	for _, photo := range ingest.Streaming {
+		_, err := tx.ExecContext(
+			ctx, "INSERT OR IGNORE INTO streaming_photos (photo_id) VALUES (?)", photoIDs[keyOf(photo)],
+		)
+		if err != nil {
+			return fmt.Errorf("saving streaming photo: %w", err)
+		}
+	}
+
+	for _, event := range ingest.Events {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO undelivered_events (data) VALUES (?)", event); err != nil {
+			return fmt.Errorf("saving event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+
+	return nil
+}
+
+// Streaming returns the photos saved by the last SaveIngest which can still be grouped, ordered by timestamp.
+func (s *Store) Streaming(ctx context.Context) ([]grouping.Photo, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT p.id, p.source_id, p.video, p.timestamp, p.latitude, p.longitude, p.status, a.name, a.type
+FROM streaming_photos sp
+JOIN photos p ON p.id = sp.photo_id
+LEFT JOIN addresses a ON a.photo_id = p.id
+ORDER BY p.id, a.name, a.type`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying streaming photos: %w", err)
+	}
+	defer rows.Close()
+
+	photos, err := scanPhotos(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(
+		photos, func(i, j int) bool {
+			return photos[i].Timestamp.Before(photos[j].Timestamp)
+		},
+	)
+
+	return photos, nil
+}
+
+// Undelivered returns the events saved by SaveIngest which haven't been Delivered, in the order they were saved.
+func (s *Store) Undelivered(ctx context.Context) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, data FROM undelivered_events ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("querying events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0)
+
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.ID, &event.Data); err != nil {
+			return nil, fmt.Errorf("scanning event: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying events: %w", err)
+	}
+
+	return events, nil
+}
+
+// Delivered removes the events with the given IDs, once they've been delivered.
+func (s *Store) Delivered(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	statement := "DELETE FROM undelivered_events WHERE id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+
+	if _, err := s.db.ExecContext(ctx, statement, args...); err != nil {
+		return fmt.Errorf("deleting events: %w", err)
+	}
+
+	return nil
+}
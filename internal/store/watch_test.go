@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_SaveIngest(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	ingested, err := s.Ingested(ctx, "day1.csv")
+	assert.NoError(t, err)
+	assert.False(t, ingested)
+
+	err = s.SaveIngest(
+		ctx, Ingest{
+			File:      "day1.csv",
+			Closed:    []grouping.Group{testGroup("London", "A day out in London", londonPhoto)},
+			Streaming: []grouping.Photo{romePhoto, romePhoto},
+			Events:    [][]byte{[]byte("closed"), []byte("opened")},
+		},
+	)
+	assert.NoError(t, err)
+
+	ingested, err = s.Ingested(ctx, "day1.csv")
+	assert.NoError(t, err)
+	assert.True(t, ingested)
+
+	streaming, err := s.Streaming(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []grouping.Photo{romePhoto}, streaming)
+
+	// closed groups are added to the stored groups, and the streaming photos replaced
+	err = s.SaveIngest(
+		ctx, Ingest{
+			File:      "day2.csv",
+			Closed:    []grouping.Group{testGroup("Rome", "A trip to Rome", romePhoto, romePhoto2)},
+			Streaming: []grouping.Photo{seaPhoto},
+		},
+	)
+	assert.NoError(t, err)
+
+	groups, err := s.Groups(ctx, Query{})
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	streaming, err = s.Streaming(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []grouping.Photo{seaPhoto}, streaming)
+
+	// a file can only be ingested once
+	assert.Error(t, s.SaveIngest(ctx, Ingest{File: "day1.csv"}))
+
+	undelivered, err := s.Undelivered(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, undelivered, 2)
+	assert.Equal(t, []byte("closed"), undelivered[0].Data)
+
+	assert.NoError(t, s.Delivered(ctx, undelivered[0].ID))
+
+	undelivered, err = s.Undelivered(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, undelivered, 1)
+	assert.Equal(t, []byte("opened"), undelivered[0].Data)
+}
@@ -0,0 +1,165 @@
+// Package tracing sets up OpenTelemetry tracing for the pipeline. Spans are created through Tracer, which uses the
+// global TracerProvider, so until Setup is called every span is a no-op.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName  = "github.com/JackFazackerley/photo-grouping"
+	serviceName = "photo-grouping"
+)
+
+// The attribute keys set on the pipeline's spans.
+const (
+	// Cell is the geocoding cache grid cell of the photo, as "row,column".
+	Cell = attribute.Key("geo.cell")
+	// Provider is the geocoding provider used, i.e. "google".
+	Provider = attribute.Key("geocode.provider")
+	// Retries is the number of times a photo's geocoding request was retried after a transient error.
+	Retries = attribute.Key("geocode.retries")
+	// Outcome is how the photo was geocoded, fetched, coalesced or reused, see metrics.CacheLookups.
+	Outcome = attribute.Key("geocode.outcome")
+	// Status is the heap.GeocodeStatus of the photo once geocoded.
+	Status = attribute.Key("geocode.status")
+	// Row is the line of the CSV the photo was read from.
+	Row = attribute.Key("csv.row")
+	// Rejected is the reason a row was rejected, see metrics.RowsRejected.
+	Rejected = attribute.Key("csv.rejected")
+	// Timestamp is when the photo was taken.
+	Timestamp = attribute.Key("photo.timestamp")
+	// Latitude is the latitude of the photo.
+	Latitude = attribute.Key("photo.latitude")
+	// Longitude is the longitude of the photo.
+	Longitude = attribute.Key("photo.longitude")
+	// Photos is the number of photos handled by a span.
+	Photos = attribute.Key("photos")
+	// Groups is the number of groups produced by a span.
+	Groups = attribute.Key("groups")
+	// GroupName is the name of the group a photo was placed in.
+	GroupName = attribute.Key("group.name")
+	// GroupKind is the kind of the group a photo was placed in, location or cluster.
+	GroupKind = attribute.Key("group.kind")
+)
+
+var (
+	// ErrNoExporter is returned by Setup when neither an endpoint or file is given.
+	ErrNoExporter = errors.New("no trace exporter")
+)
+
+// Options configures where spans are exported. Endpoint is the host and port of an OTLP/HTTP collector, i.e.
+// "localhost:4318", and File is the path of a file spans are written to as JSON, one per line. Either or both can
+// be set. Insecure disables TLS when connecting to Endpoint.
+type Options struct {
+	Endpoint string
+	Insecure bool
+	File     string
+}
+
+// Tracer returns the tracer used to create the pipeline's spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// AddSpan returns spans with span added, unless it isn't valid, such as when tracing isn't set up. spans isn't
+// modified, so that photos copied from each other don't share spans.
+func AddSpan(spans []trace.SpanContext, span trace.SpanContext) []trace.SpanContext {
+	if !span.IsValid() {
+		return spans
+	}
+
+	added := make([]trace.SpanContext, 0, len(spans)+1)
+
+	return append(append(added, spans...), span)
+}
+
+// Links returns a link to each of spans.
+func Links(spans []trace.SpanContext) []trace.Link {
+	links := make([]trace.Link, 0, len(spans))
+	for _, span := range spans {
+		links = append(links, trace.Link{SpanContext: span})
+	}
+
+	return links
+}
+
+// Setup installs a global TracerProvider which exports spans as described by options. The returned function flushes
+// any remaining spans and must be called before the process exits.
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	if options.Endpoint == "" && options.File == "" {
+		return nil, ErrNoExporter
+	}
+
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(
+			resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName)),
+		),
+	}
+
+	var file *os.File
+
+	if options.Endpoint != "" {
+		clientOptions := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(options.Endpoint),
+		}
+		if options.Insecure {
+			clientOptions = append(clientOptions, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err := otlptracehttp.New(ctx, clientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("creating otlp exporter: %w", err)
+		}
+
+		providerOptions = append(providerOptions, sdktrace.WithBatcher(exporter))
+	}
+
+	if options.File != "" {
+		var err error
+
+		file, err = os.Create(options.File)
+		if err != nil {
+			return nil, fmt.Errorf("creating trace file: %w", err)
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("creating file exporter: %w", err)
+		}
+
+		providerOptions = append(providerOptions, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(providerOptions...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("shutting down tracing: %w", err)
+		}
+
+		return nil
+	}, nil
+}
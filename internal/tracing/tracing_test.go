@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	path := filepath.Join(t.TempDir(), "trace.json")
+
+	shutdown, err := Setup(context.Background(), Options{File: path})
+	assert.NoError(t, err)
+
+	_, span := Tracer().Start(context.Background(), "getGeocoding")
+	span.SetAttributes(Provider.String("google"))
+	span.End()
+
+	assert.NoError(t, shutdown(context.Background()))
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), `"Name":"getGeocoding"`)
+	assert.Contains(t, string(contents), `"geocode.provider"`)
+	assert.Contains(t, string(contents), `"photo-grouping"`)
+}
+
+func TestSetupErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     Options
+		expectedErr string
+	}{
+		{
+			name:        "errors without exporter",
+			options:     Options{},
+			expectedErr: "no trace exporter",
+		},
+		{
+			name: "errors creating trace file",
+			options: Options{
+				File: filepath.Join("not", "a", "directory", "trace.json"),
+			},
+			expectedErr: "creating trace file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				_, err := Setup(context.Background(), tt.options)
+
+				assert.Contains(t, err.Error(), tt.expectedErr)
+			},
+		)
+	}
+}
@@ -0,0 +1,355 @@
+// Package video reads the creation time and location of a video from its MP4 or QuickTime metadata, so that videos can
+// be grouped alongside photos.
+//
+// Phones write the location as an ISO 6709 string, either in the udta box as ©xyz, as Android does, or as the
+// com.apple.quicktime.location.ISO6709 key in the meta box, as iPhones do. iPhones also write the creation time with
+// its offset as com.apple.quicktime.creationdate, otherwise the creation time in the mvhd box is used, which is UTC.
+package video
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/isobmff"
+)
+
+// The keys in a QuickTime meta box which are read.
+const (
+	keyLocation     = "com.apple.quicktime.location.ISO6709"
+	keyCreationDate = "com.apple.quicktime.creationdate"
+)
+
+// epochOffset is the seconds from 1904, which mvhd creation times are counted from, to the Unix epoch.
+const epochOffset = 2082844800
+
+var (
+	// iso6709 matches the latitude and longitude at the start of an ISO 6709 string, such as "+51.5072-000.1276/".
+	iso6709 = regexp.MustCompile(`^([+-])(\d+(?:\.\d+)?)([+-])(\d+(?:\.\d+)?)`)
+)
+
+// Metadata is what's known about a video from its metadata. Taken is zero if the creation time isn't known, and Located
+// is false if the location isn't.
+type Metadata struct {
+	Taken     time.Time
+	Latitude  float64
+	Longitude float64
+	Located   bool
+}
+
+// Read reads the metadata of an MP4 or QuickTime file of size bytes, returning false if it has no moov box.
+func Read(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	top, err := isobmff.Boxes(r, 0, size)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	moov, ok := isobmff.Find(top, "moov")
+	if !ok {
+		return Metadata{}, false, nil
+	}
+
+	children, err := isobmff.Boxes(r, moov.Offset, moov.Offset+moov.Size)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	metadata := Metadata{}
+
+	if mvhd, ok := isobmff.Find(children, "mvhd"); ok {
+		if metadata.Taken, err = readMovieHeader(r, mvhd); err != nil {
+			return Metadata{}, false, err
+		}
+	}
+
+	if udta, ok := isobmff.Find(children, "udta"); ok {
+		if err := readUserData(r, udta, &metadata); err != nil {
+			return Metadata{}, false, err
+		}
+	}
+
+	if meta, ok := isobmff.Find(children, "meta"); ok {
+		if err := readMeta(r, meta, &metadata); err != nil {
+			return Metadata{}, false, err
+		}
+	}
+
+	return metadata, true, nil
+}
+
+// readMovieHeader returns the creation time in the mvhd box, which is the seconds since 1904 in UTC. A creation time
+// of 0 means it isn't set.
+func readMovieHeader(r io.ReaderAt, mvhd isobmff.Box) (time.Time, error) {
+	data, err := mvhd.Bytes(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	c := isobmff.NewCursor(data)
+
+	size := 4
+	if c.Uint(1) == 1 {
+		size = 8
+	}
+	c.Uint(3)
+
+	seconds := c.Uint(size)
+	if c.Err() != nil {
+		return time.Time{}, fmt.Errorf("reading mvhd box: %w", c.Err())
+	}
+
+	if seconds == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(int64(seconds)-epochOffset, 0).UTC(), nil
+}
+
+// readUserData sets the location from the ©xyz box in udta, which is the length of the string and its language
+// followed by the string.
+func readUserData(r io.ReaderAt, udta isobmff.Box, metadata *Metadata) error {
+	children, err := isobmff.Boxes(r, udta.Offset, udta.Offset+udta.Size)
+	if err != nil {
+		return err
+	}
+
+	xyz, ok := isobmff.Find(children, "\xa9xyz")
+	if !ok {
+		return nil
+	}
+
+	data, err := xyz.Bytes(r)
+	if err != nil {
+		return err
+	}
+
+	c := isobmff.NewCursor(data)
+	length := c.Uint(2)
+	c.Uint(2)
+	value := c.Bytes(int(length))
+
+	if c.Err() != nil {
+		return fmt.Errorf("reading ©xyz box: %w", c.Err())
+	}
+
+	return metadata.setLocation(string(value))
+}
+
+// readMeta sets the creation time and location from the QuickTime meta box, where the keys box names each item and
+// the ilst box holds their values, by the index of their key from 1.
+func readMeta(r io.ReaderAt, meta isobmff.Box, metadata *Metadata) error {
+	start := meta.Offset
+
+	// in an MP4 meta is a full box, with a version and flags before its children, but in QuickTime it isn't.
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, start); err != nil {
+		return fmt.Errorf("reading meta box: %w", err)
+	}
+	if string(header[4:]) != "hdlr" && string(header[4:]) != "keys" {
+		start += 4
+	}
+
+	children, err := isobmff.Boxes(r, start, meta.Offset+meta.Size)
+	if err != nil {
+		return err
+	}
+
+	keysBox, keysOK := isobmff.Find(children, "keys")
+	ilst, ilstOK := isobmff.Find(children, "ilst")
+	if !keysOK || !ilstOK {
+		return nil
+	}
+
+	keys, err := readKeys(r, keysBox)
+	if err != nil {
+		return err
+	}
+
+	items, err := isobmff.Boxes(r, ilst.Offset, ilst.Offset+ilst.Size)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		index := int(isobmff.NewCursor([]byte(item.Type)).Uint(4))
+		if index < 1 || index > len(keys) {
+			continue
+		}
+
+		key := keys[index-1]
+		if key != keyLocation && key != keyCreationDate {
+			continue
+		}
+
+		value, err := readValue(r, item)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+
+		if key == keyLocation {
+			if err := metadata.setLocation(value); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		taken, err := parseCreationDate(value)
+		if err != nil {
+			return err
+		}
+
+		metadata.Taken = taken
+	}
+
+	return nil
+}
+
+// readKeys returns the keys in the keys box, which are its version and flags, the number of keys, then each key's
+// size, namespace and name.
+func readKeys(r io.ReaderAt, keysBox isobmff.Box) ([]string, error) {
+	data, err := keysBox.Bytes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := isobmff.NewCursor(data)
+	c.Uint(4)
+	count := c.Uint(4)
+
+	keys := make([]string, 0)
+
+	for i := uint64(0); i < count && c.Err() == nil; i++ {
+		size := int(c.Uint(4))
+		c.Uint(4)
+		keys = append(keys, string(c.Bytes(size-8)))
+	}
+
+	if c.Err() != nil {
+		return nil, fmt.Errorf("reading keys box: %w", c.Err())
+	}
+
+	return keys, nil
+}
+
+// readValue returns the UTF-8 value in the data box of an ilst item, which follows its type and locale.
+func readValue(r io.ReaderAt, item isobmff.Box) (string, error) {
+	children, err := isobmff.Boxes(r, item.Offset, item.Offset+item.Size)
+	if err != nil {
+		return "", err
+	}
+
+	data, ok := isobmff.Find(children, "data")
+	if !ok {
+		return "", errors.New("no data box")
+	}
+
+	contents, err := data.Bytes(r)
+	if err != nil {
+		return "", err
+	}
+
+	if len(contents) < 8 {
+		return "", isobmff.ErrTruncated
+	}
+
+	return string(contents[8:]), nil
+}
+
+// parseCreationDate parses com.apple.quicktime.creationdate, which is ISO 8601 with an offset, such as
+// 2022-04-02T10:52:59+0100.
+func parseCreationDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05-0700", time.RFC3339Nano} {
+		if taken, err := time.Parse(layout, value); err == nil {
+			return taken, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("parsing creation date %q", value)
+}
+
+// setLocation sets the location from an ISO 6709 string, such as "+51.5072-000.1276+021.000/".
+func (m *Metadata) setLocation(value string) error {
+	latitude, longitude, err := parseISO6709(value)
+	if err != nil {
+		return err
+	}
+
+	m.Latitude = latitude
+	m.Longitude = longitude
+	m.Located = true
+
+	return nil
+}
+
+// parseISO6709 parses the latitude and longitude at the start of an ISO 6709 string, ignoring any altitude or CRS after
+// them. Each can be in degrees, degrees and minutes, or degrees, minutes and seconds; such as +51.5072, +5130.432 or
+// +513025.92 for a latitude.
+func parseISO6709(value string) (float64, float64, error) {
+	match := iso6709.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, 0, fmt.Errorf("invalid iso 6709 location %q", value)
+	}
+
+	latitude, err := parseSexagesimal(match[1], match[2], 2)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid iso 6709 location %q: %w", value, err)
+	}
+
+	longitude, err := parseSexagesimal(match[3], match[4], 3)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid iso 6709 location %q: %w", value, err)
+	}
+
+	if math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return 0, 0, fmt.Errorf("iso 6709 location %q out of range", value)
+	}
+
+	return latitude, longitude, nil
+}
+
+// parseSexagesimal parses a coordinate whose whole part is degrees digits long for degrees, 2 more for minutes, or 4
+// more for seconds.
+func parseSexagesimal(sign, value string, degrees int) (float64, error) {
+	whole := strings.IndexByte(value, '.')
+	if whole < 0 {
+		whole = len(value)
+	}
+
+	var digits []string
+
+	switch whole {
+	case degrees:
+		digits = []string{value}
+	case degrees + 2:
+		digits = []string{value[:degrees], value[degrees:]}
+	case degrees + 4:
+		digits = []string{value[:degrees], value[degrees : degrees+2], value[degrees+2:]}
+	default:
+		return 0, fmt.Errorf("unexpected number of digits in %q", value)
+	}
+
+	coordinate := 0.0
+	scale := 1.0
+
+	for _, part := range digits {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		coordinate += number / scale
+		scale *= 60
+	}
+
+	if sign == "-" {
+		coordinate = -coordinate
+	}
+
+	return coordinate, nil
+}
@@ -0,0 +1,184 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildBox(kind string, contents ...[]byte) []byte {
+	data := bytes.Join(contents, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)+8))
+	copy(header[4:], kind)
+
+	return append(header, data...)
+}
+
+func buildMovieHeader(created time.Time) []byte {
+	contents := make([]byte, 100)
+	binary.BigEndian.PutUint32(contents[4:], uint32(created.Unix()+epochOffset))
+
+	return buildBox("mvhd", contents)
+}
+
+func buildXYZ(location string) []byte {
+	contents := make([]byte, 4)
+	binary.BigEndian.PutUint16(contents, uint16(len(location)))
+	binary.BigEndian.PutUint16(contents[2:], 0x15c7)
+
+	return buildBox("udta", buildBox("\xa9xyz", contents, []byte(location)))
+}
+
+// buildMeta returns a QuickTime meta box with a key and value for each pair of values.
+func buildMeta(values ...string) []byte {
+	keys := make([]byte, 8)
+	binary.BigEndian.PutUint32(keys[4:], uint32(len(values)/2))
+
+	items := make([][]byte, 0)
+
+	for i := 0; i < len(values); i += 2 {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint32(key, uint32(len(values[i])+8))
+		copy(key[4:], "mdta")
+		keys = append(append(keys, key...), values[i]...)
+
+		index := make([]byte, 4)
+		binary.BigEndian.PutUint32(index, uint32(i/2+1))
+		items = append(items, buildBox(string(index), buildBox("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(values[i+1]))))
+	}
+
+	return buildBox("meta", buildBox("hdlr", make([]byte, 24)), buildBox("keys", keys), buildBox("ilst", items...))
+}
+
+func buildMovie(boxes ...[]byte) []byte {
+	return append(buildBox("ftyp", []byte("qt  "), make([]byte, 4)), buildBox("moov", boxes...)...)
+}
+
+func TestRead(t *testing.T) {
+	created := time.Date(2022, 04, 02, 9, 52, 59, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		file     []byte
+		expected Metadata
+		ok       bool
+		err      string
+	}{
+		{
+			name:     "reads android video",
+			file:     buildMovie(buildMovieHeader(created), buildXYZ("+51.5072-000.1276/")),
+			expected: Metadata{Taken: created, Latitude: 51.5072, Longitude: -0.1276, Located: true},
+			ok:       true,
+		},
+		{
+			name: "reads iphone video",
+			file: buildMovie(
+				buildMovieHeader(created),
+				buildMeta(
+					"com.apple.quicktime.make", "Apple",
+					keyLocation, "+51.5072-000.1276+021.000/",
+					keyCreationDate, "2022-04-02T10:52:59+0100",
+				),
+			),
+			expected: Metadata{
+				Taken:     time.Date(2022, 04, 02, 10, 52, 59, 0, time.FixedZone("", 60*60)),
+				Latitude:  51.5072,
+				Longitude: -0.1276,
+				Located:   true,
+			},
+			ok: true,
+		},
+		{
+			name:     "reads video without location",
+			file:     buildMovie(buildMovieHeader(created)),
+			expected: Metadata{Taken: created},
+			ok:       true,
+		},
+		{
+			name:     "file without moov",
+			file:     buildBox("ftyp", []byte("isom"), make([]byte, 4)),
+			expected: Metadata{},
+		},
+		{
+			name: "rejects invalid location",
+			file: buildMovie(buildMovieHeader(created), buildXYZ("+91.0000-000.1276/")),
+			err:  `iso 6709 location "+91.0000-000.1276/" out of range`,
+		},
+		{
+			name: "rejects truncated file",
+			file: buildMovie(buildMovieHeader(created))[:40],
+			err:  "reading moov box: invalid size",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, ok, err := Read(bytes.NewReader(tt.file), int64(len(tt.file)))
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+					return
+				}
+
+				assert.NoError(t, err)
+				assert.Equal(t, tt.ok, ok)
+				assert.Equal(t, tt.expected.Taken.Format(time.RFC3339Nano), got.Taken.Format(time.RFC3339Nano))
+				assert.InDelta(t, tt.expected.Latitude, got.Latitude, 1e-9)
+				assert.InDelta(t, tt.expected.Longitude, got.Longitude, 1e-9)
+				assert.Equal(t, tt.expected.Located, got.Located)
+			},
+		)
+	}
+}
+
+func TestParseISO6709(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		latitude  float64
+		longitude float64
+		err       string
+	}{
+		{
+			name:      "degrees",
+			value:     "-33.8568+151.2153+005.000/",
+			latitude:  -33.8568,
+			longitude: 151.2153,
+		},
+		{
+			name:      "degrees and minutes",
+			value:     "+5130.432-00007.656/",
+			latitude:  51.5072,
+			longitude: -0.1276,
+		},
+		{
+			name:      "degrees, minutes and seconds",
+			value:     "+513025.92-0000739.36/",
+			latitude:  51.5072,
+			longitude: -0.1276,
+		},
+		{
+			name:  "invalid",
+			value: "51.5072,-0.1276",
+			err:   `invalid iso 6709 location "51.5072,-0.1276"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				latitude, longitude, err := parseISO6709(tt.value)
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+					return
+				}
+
+				assert.NoError(t, err)
+				assert.InDelta(t, tt.latitude, latitude, 1e-9)
+				assert.InDelta(t, tt.longitude, longitude, 1e-9)
+			},
+		)
+	}
+}
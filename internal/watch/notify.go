@@ -0,0 +1,144 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+)
+
+// EventType describes how a group changed.
+type EventType string
+
+const (
+	// EventOpened is the EventType of a group which didn't exist before, and can still change.
+	EventOpened EventType = "opened"
+	// EventUpdated is the EventType of an open group which new photos have been added to.
+	EventUpdated EventType = "updated"
+	// EventClosed is the EventType of a group which can no longer change.
+	EventClosed EventType = "closed"
+)
+
+// Event is a change to a group, caused by ingesting File.
+type Event struct {
+	Type  EventType
+	File  string
+	Group grouping.Group
+}
+
+// Notifier is told about every Event, once the change including them has been saved. Events are sent again if Notify
+// returns an error, so a Notifier may see an Event more than once.
+type Notifier interface {
+	Notify(ctx context.Context, events []Event) error
+}
+
+// Writer is a Notifier which writes each Event to an io.Writer as a line of JSON.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter creates a Writer which writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Notify writes each Event as a line of JSON.
+func (n *Writer) Notify(ctx context.Context, events []Event) error {
+	encoder := json.NewEncoder(n.w)
+
+	for _, event := range events {
+		if err := encoder.Encode(newEventJSON(event)); err != nil {
+			return fmt.Errorf("writing event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Webhook is a Notifier which POSTs the events to a URL as JSON.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a Webhook which POSTs to url, giving up on a request after timeout.
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{
+		url: url,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Notify POSTs the events in a single request, returning an error if it fails or the response isn't a 2xx.
+func (n *Webhook) Notify(ctx context.Context, events []Event) error {
+	body := webhookJSON{
+		Events: make([]eventJSON, 0, len(events)),
+	}
+
+	for _, event := range events {
+		body.Events = append(body.Events, newEventJSON(event))
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encoding events: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("sending events: %w", err)
+	}
+	defer response.Body.Close()
+
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("sending events: unexpected status %s", response.Status)
+	}
+
+	return nil
+}
+
+// webhookJSON is the JSON body sent by a Webhook.
+type webhookJSON struct {
+	Events []eventJSON `json:"events"`
+}
+
+type eventJSON struct {
+	Type     EventType `json:"type"`
+	File     string    `json:"file"`
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"`
+	TripType string    `json:"tripType"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Titles   []string  `json:"titles"`
+	Photos   int       `json:"photos"`
+}
+
+func newEventJSON(event Event) eventJSON {
+	return eventJSON{
+		Type:     event.Type,
+		File:     event.File,
+		Name:     event.Group.Name,
+		Kind:     string(event.Group.Kind),
+		TripType: event.Group.TripType,
+		Start:    event.Group.Start,
+		End:      event.Group.End,
+		Titles:   event.Group.Titles,
+		Photos:   len(event.Group.Photos),
+	}
+}
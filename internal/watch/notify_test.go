@@ -0,0 +1,91 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvents = []Event{
+	{
+		Type: EventClosed,
+		File: "day1.csv",
+		Group: grouping.Group{
+			Name:     "London",
+			Kind:     grouping.KindLocation,
+			TripType: "day",
+			Start:    time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+			End:      time.Date(2022, 04, 02, 12, 0, 0, 0, time.UTC),
+			Photos:   make([]grouping.Photo, 2),
+			Titles:   []string{"A day out in London"},
+		},
+	},
+}
+
+const testEventJSON = `{"type":"closed","file":"day1.csv","name":"London","kind":"location","tripType":"day",` +
+	`"start":"2022-04-02T10:00:00Z","end":"2022-04-02T12:00:00Z","titles":["A day out in London"],"photos":2}`
+
+func TestWriter_Notify(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	err := NewWriter(buf).Notify(context.Background(), testEvents)
+	assert.NoError(t, err)
+
+	assert.Equal(t, testEventJSON+"\n", buf.String())
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		expectedErr string
+	}{
+		{
+			name:   "posts events",
+			status: http.StatusNoContent,
+		},
+		{
+			name:        "returns error for unsuccessful status",
+			status:      http.StatusBadGateway,
+			expectedErr: "sending events: unexpected status 502 Bad Gateway",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var body []byte
+
+				server := httptest.NewServer(
+					http.HandlerFunc(
+						func(w http.ResponseWriter, r *http.Request) {
+							assert.Equal(t, http.MethodPost, r.Method)
+							assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+							body, _ = io.ReadAll(r.Body)
+							w.WriteHeader(tt.status)
+						},
+					),
+				)
+				defer server.Close()
+
+				err := NewWebhook(server.URL, time.Second).Notify(context.Background(), testEvents)
+				if tt.expectedErr != "" {
+					assert.EqualError(t, err, tt.expectedErr)
+					return
+				}
+
+				assert.NoError(t, err)
+				assert.True(t, json.Valid(body))
+				assert.Equal(t, `{"events":[`+testEventJSON+`]}`, string(body))
+			},
+		)
+	}
+}
@@ -0,0 +1,316 @@
+// Package watch groups photos as files of them are added to a directory. Each new file is read and geocoded, and the
+// groups it changes are saved to a store.Store and passed to a Notifier.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/store"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// DefaultOptions are the Options used when no other configuration is provided.
+	DefaultOptions = Options{
+		Pattern: "*.csv",
+		Settle:  time.Second * 2,
+	}
+)
+
+// Options configures a Watcher. Only files whose names match Pattern, see filepath.Match, are ingested, and each is
+// read as a CSV, see grouping.CSV. Settle is how long a file must go without being written to before it's ingested, so
+// that files which are still being copied into the directory aren't read part way through.
+type Options struct {
+	Pattern string
+	Settle  time.Duration
+}
+
+// Watcher ingests every file added to a directory into a grouping.Stream. Each file is only ingested once, even if it
+// is written to again later.
+type Watcher struct {
+	dir      string
+	store    *store.Store
+	dbPath   string
+	options  Options
+	notifier Notifier
+
+	stream *grouping.Stream
+	// open holds the groups which were open once the last file was ingested.
+	open []grouping.Group
+	// closed holds the groups emitted by stream since the last file was ingested.
+	closed []grouping.Group
+}
+
+// New creates a Watcher for dir, which saves its progress to photoStore, the database at dbPath. The photos which were
+// still being grouped when the store was last saved are restored, without geocoding them again.
+func New(
+	ctx context.Context, pipeline *grouping.Pipeline, dir string, photoStore *store.Store, dbPath string,
+	notifier Notifier, options Options,
+) (*Watcher, error) {
+	w := &Watcher{
+		dir:      dir,
+		store:    photoStore,
+		dbPath:   dbPath,
+		options:  options,
+		notifier: notifier,
+	}
+
+	var err error
+
+	w.stream, err = pipeline.NewStream(
+		func(group grouping.Group) {
+			w.closed = append(w.closed, group)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	streaming, err := photoStore.Streaming(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w.stream.Restore(streaming...)
+	w.open = w.stream.Open()
+
+	return w, nil
+}
+
+// Run ingests any files added to the directory while the Watcher wasn't running, then ingests each new file as it is
+// added until ctx is done. Failing to ingest a file is logged rather than stopping the Watcher.
+func (w *Watcher) Run(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// the directory is watched before it's read, so that no file is missed between the two.
+	if err := watcher.Add(w.dir); err != nil {
+		return fmt.Errorf("watching %s: %w", w.dir, err)
+	}
+
+	// events which couldn't be delivered before the Watcher last stopped are delivered first.
+	if err := w.deliver(ctx); err != nil && ctx.Err() == nil {
+		log.WithError(err).Error("notifying")
+	}
+
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", w.dir, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !w.matches(entry.Name()) {
+			continue
+		}
+
+		w.ingestAndLog(ctx, entry.Name())
+	}
+
+	ready := make(chan string)
+	timers := make(map[string]*time.Timer)
+
+	defer func() {
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			name := filepath.Base(event.Name)
+			if !(event.Has(fsnotify.Create) || event.Has(fsnotify.Write)) || !w.matches(name) {
+				continue
+			}
+
+			if timer, ok := timers[name]; ok {
+				timer.Reset(w.options.Settle)
+				continue
+			}
+
+			timers[name] = time.AfterFunc(
+				w.options.Settle, func() {
+					select {
+					case <-ctx.Done():
+					case ready <- name:
+					}
+				},
+			)
+		case name := <-ready:
+			delete(timers, name)
+			w.ingestAndLog(ctx, name)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			log.WithError(err).Error("watching directory")
+		}
+	}
+}
+
+// ingestAndLog ingests the file called name, logging any error unless ctx is done.
+func (w *Watcher) ingestAndLog(ctx context.Context, name string) {
+	if err := w.ingest(ctx, name); err != nil && ctx.Err() == nil {
+		log.WithError(err).WithField("file", name).Error("ingesting file")
+	}
+}
+
+// ingest adds the photos in the file called name to the stream, saves the change to the store, and notifies the
+// Notifier of every group that changed. Photos which couldn't be geocoded are saved along with the open groups, so
+// that they're geocoded again with the next file, rather than lost once the file is recorded as ingested. Files which have already been ingested are skipped. Events are saved along with
+// the change and only removed once they've been delivered, so that if the Notifier fails they're sent again with the
+// next file.
+func (w *Watcher) ingest(ctx context.Context, name string) error {
+	ingested, err := w.store.Ingested(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if ingested {
+		return nil
+	}
+
+	file, err := os.Open(filepath.Join(w.dir, name))
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	if err := w.stream.Add(ctx, grouping.CSV(file)); err != nil {
+		return err
+	}
+
+	open := w.stream.Open()
+	events := w.events(name, open)
+
+	ingest := store.Ingest{
+		File:      name,
+		Closed:    w.closed,
+		Streaming: append(w.stream.Pending(), w.stream.Unplaced()...),
+	}
+
+	for _, group := range open {
+		ingest.Streaming = append(ingest.Streaming, group.Photos...)
+	}
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("encoding event: %w", err)
+		}
+
+		ingest.Events = append(ingest.Events, data)
+	}
+
+	if err := w.store.SaveIngest(ctx, ingest); err != nil {
+		return err
+	}
+
+	w.open = open
+	w.closed = nil
+
+	log.WithFields(
+		log.Fields{
+			"file":   name,
+			"events": len(events),
+			"open":   len(open),
+		},
+	).Info("ingested file")
+
+	return w.deliver(ctx)
+}
+
+// deliver notifies the Notifier of every event which hasn't been delivered yet, in the order they were saved.
+func (w *Watcher) deliver(ctx context.Context) error {
+	undelivered, err := w.store.Undelivered(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(undelivered) == 0 {
+		return nil
+	}
+
+	events := make([]Event, 0, len(undelivered))
+	ids := make([]int64, 0, len(undelivered))
+
+	for _, e := range undelivered {
+		var event Event
+		if err := json.Unmarshal(e.Data, &event); err != nil {
+			return fmt.Errorf("decoding event: %w", err)
+		}
+
+		events = append(events, event)
+		ids = append(ids, e.ID)
+	}
+
+	if err := w.notifier.Notify(ctx, events); err != nil {
+		return fmt.Errorf("notifying: %w", err)
+	}
+
+	return w.store.Delivered(ctx, ids...)
+}
+
+// events returns an Event for each group that closed, and each open group which is new or has changed since the last
+// file was ingested, ordered by Group.Start.
+func (w *Watcher) events(name string, open []grouping.Group) []Event {
+	events := make([]Event, 0, len(w.closed)+len(open))
+
+	for _, group := range w.closed {
+		events = append(events, Event{Type: EventClosed, File: name, Group: group})
+	}
+
+	previous := make(map[string]grouping.Group, len(w.open))
+	for _, group := range w.open {
+		previous[group.Name] = group
+	}
+
+	for _, group := range open {
+		before, ok := previous[group.Name]
+
+		// a group with the same name which started outside of this one is an earlier visit, which has since closed.
+		if !ok || before.Start.Before(group.Start) || before.Start.After(group.End) {
+			events = append(events, Event{Type: EventOpened, File: name, Group: group})
+			continue
+		}
+
+		if !before.Start.Equal(group.Start) || !before.End.Equal(group.End) || len(before.Photos) != len(group.Photos) {
+			events = append(events, Event{Type: EventUpdated, File: name, Group: group})
+		}
+	}
+
+	sort.SliceStable(
+		events, func(i, j int) bool {
+			return events[i].Group.Start.Before(events[j].Group.Start)
+		},
+	)
+
+	return events
+}
+
+// matches reports whether a file called name should be ingested. The database, and the journals SQLite keeps beside
+// it, are never ingested.
+func (w *Watcher) matches(name string) bool {
+	matched, err := filepath.Match(w.options.Pattern, name)
+
+	return err == nil && matched && !strings.HasPrefix(filepath.Join(w.dir, name), filepath.Clean(w.dbPath))
+}
@@ -0,0 +1,311 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/store"
+	"github.com/JackFazackerley/photo-grouping/pkg/grouping"
+	"github.com/stretchr/testify/assert"
+	"googlemaps.github.io/maps"
+)
+
+// mockGeocoder resolves every photo with a latitude between 51 and 52 to London, and every other photo to Paris. If
+// err is set it's returned instead.
+type mockGeocoder struct {
+	err error
+}
+
+func (m mockGeocoder) ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	name := "Paris"
+	if r.LatLng.Lat > 51 && r.LatLng.Lat < 52 {
+		name = "London"
+	}
+
+	return []maps.GeocodingResult{
+		{
+			AddressComponents: []maps.AddressComponent{
+				{
+					LongName: name,
+					Types:    []string{"locality"},
+				},
+			},
+		},
+	}, nil
+}
+
+// mockNotifier records every Event it's notified of, unless err is set, in which case it's returned instead.
+type mockNotifier struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (m *mockNotifier) Notify(ctx context.Context, events []Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+
+	m.events = append(m.events, events...)
+
+	return nil
+}
+
+func (m *mockNotifier) Events() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Event(nil), m.events...)
+}
+
+// summary is an Event reduced to what the tests check.
+type summary struct {
+	Type   EventType
+	File   string
+	Name   string
+	Photos int
+}
+
+func summarise(events []Event) []summary {
+	summaries := make([]summary, 0, len(events))
+
+	for _, event := range events {
+		summaries = append(
+			summaries, summary{
+				Type:   event.Type,
+				File:   event.File,
+				Name:   event.Group.Name,
+				Photos: len(event.Group.Photos),
+			},
+		)
+	}
+
+	return summaries
+}
+
+func writeFile(t *testing.T, dir, name string, rows ...string) {
+	t.Helper()
+
+	err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(rows, "\n")), 0o600)
+	assert.NoError(t, err)
+}
+
+// newTestWatcher creates a Watcher for dir, saving to a database in dir which is closed once the test has finished.
+func newTestWatcher(t *testing.T, dir string, notifier Notifier) *Watcher {
+	t.Helper()
+
+	return newTestWatcherWith(t, dir, notifier, mockGeocoder{})
+}
+
+// newTestWatcherWith creates a Watcher like newTestWatcher, which geocodes photos with geocoder.
+func newTestWatcherWith(t *testing.T, dir string, notifier Notifier, geocoder grouping.Geocoder) *Watcher {
+	t.Helper()
+
+	options := grouping.DefaultOptions()
+	options.Workers = 1
+
+	path := filepath.Join(dir, "photos.db")
+
+	photoStore, err := store.Open(path)
+	assert.NoError(t, err)
+	t.Cleanup(func() { photoStore.Close() })
+
+	w, err := New(
+		context.Background(), grouping.New(geocoder, options), dir, photoStore, path, notifier, Options{
+			Pattern: "*.csv",
+			Settle:  time.Millisecond * 10,
+		},
+	)
+	assert.NoError(t, err)
+
+	return w
+}
+
+func TestWatcher_ingest(t *testing.T) {
+	dir := t.TempDir()
+	notifier := &mockNotifier{}
+
+	writeFile(t, dir, "day1.csv", "2022-04-02T10:00:00Z,51.5072,-0.1276", "2022-04-02T12:00:00Z,51.5072,-0.1276")
+	writeFile(t, dir, "day2.csv", "2022-04-02T18:00:00Z,51.5072,-0.1276", "2022-04-03T10:00:00Z,48.8566,2.3522")
+	writeFile(t, dir, "day3.csv", "2022-04-10T10:00:00Z,51.5072,-0.1276")
+
+	w := newTestWatcher(t, dir, notifier)
+
+	assert.NoError(t, w.ingest(context.Background(), "day1.csv"))
+	assert.Equal(
+		t, []summary{
+			{Type: EventOpened, File: "day1.csv", Name: "London", Photos: 1},
+		}, summarise(notifier.Events()),
+	)
+
+	// ingesting the same file again does nothing
+	assert.NoError(t, w.ingest(context.Background(), "day1.csv"))
+	assert.Len(t, notifier.Events(), 1)
+
+	notifier.events = nil
+	assert.NoError(t, w.ingest(context.Background(), "day2.csv"))
+	assert.Equal(
+		t, []summary{
+			{Type: EventUpdated, File: "day2.csv", Name: "London", Photos: 3},
+		}, summarise(notifier.Events()),
+	)
+
+	// a watcher started later carries on from the saved state
+	w = newTestWatcher(t, dir, notifier)
+
+	notifier.events = nil
+	assert.NoError(t, w.ingest(context.Background(), "day3.csv"))
+	assert.Equal(
+		t, []summary{
+			{Type: EventClosed, File: "day3.csv", Name: "London", Photos: 3},
+			{Type: EventClosed, File: "day3.csv", Name: "Paris", Photos: 1},
+		}, summarise(notifier.Events()),
+	)
+
+	groups, err := w.store.Groups(context.Background(), store.Query{})
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	// the photo from the later visit is held until newer photos arrive
+	streaming, err := w.store.Streaming(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, streaming, 1)
+}
+
+func TestWatcher_ingestNotifyFails(t *testing.T) {
+	dir := t.TempDir()
+	notifier := &mockNotifier{err: errors.New("webhook unavailable")}
+
+	writeFile(t, dir, "day1.csv", "2022-04-02T10:00:00Z,51.5072,-0.1276", "2022-04-02T12:00:00Z,51.5072,-0.1276")
+	writeFile(t, dir, "day2.csv", "2022-04-02T18:00:00Z,51.5072,-0.1276", "2022-04-03T10:00:00Z,48.8566,2.3522")
+
+	w := newTestWatcher(t, dir, notifier)
+
+	assert.EqualError(t, w.ingest(context.Background(), "day1.csv"), "notifying: webhook unavailable")
+	assert.Empty(t, notifier.Events())
+
+	// the file isn't ingested again, but the events are sent with those of the next file
+	notifier.err = nil
+	assert.NoError(t, w.ingest(context.Background(), "day1.csv"))
+	assert.Empty(t, notifier.Events())
+
+	assert.NoError(t, w.ingest(context.Background(), "day2.csv"))
+	assert.Equal(
+		t, []summary{
+			{Type: EventOpened, File: "day1.csv", Name: "London", Photos: 1},
+			{Type: EventUpdated, File: "day2.csv", Name: "London", Photos: 3},
+		}, summarise(notifier.Events()),
+	)
+
+	undelivered, err := w.store.Undelivered(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, undelivered)
+}
+
+func TestWatcher_ingestGeocodingFails(t *testing.T) {
+	dir := t.TempDir()
+	notifier := &mockNotifier{}
+
+	writeFile(t, dir, "day1.csv", "2022-04-02T10:00:00Z,51.5072,-0.1276", "2022-04-02T12:00:00Z,51.5072,-0.1276")
+	writeFile(t, dir, "day2.csv", "2022-04-02T18:00:00Z,51.5072,-0.1276", "2022-04-03T10:00:00Z,48.8566,2.3522")
+
+	w := newTestWatcherWith(t, dir, notifier, mockGeocoder{err: errors.New("over query limit")})
+
+	assert.NoError(t, w.ingest(context.Background(), "day1.csv"))
+	assert.Empty(t, notifier.Events())
+
+	// the photos which couldn't be geocoded are saved, rather than lost once the file is recorded as ingested
+	streaming, err := w.store.Streaming(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, streaming, 2)
+	assert.Equal(t, grouping.GeocodeFailed, streaming[0].Status)
+
+	// a watcher started once geocoding works again geocodes them with the next file
+	w = newTestWatcher(t, dir, notifier)
+
+	assert.NoError(t, w.ingest(context.Background(), "day2.csv"))
+	assert.Equal(
+		t, []summary{
+			{Type: EventOpened, File: "day2.csv", Name: "London", Photos: 3},
+		}, summarise(notifier.Events()),
+	)
+}
+
+func TestWatcher_Run(t *testing.T) {
+	dir := t.TempDir()
+	notifier := &mockNotifier{}
+
+	// added before the watcher started
+	writeFile(t, dir, "existing.csv", "2022-04-02T10:00:00Z,51.5072,-0.1276", "2022-04-02T12:00:00Z,51.5072,-0.1276")
+
+	w := newTestWatcher(t, dir, notifier)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	assert.Eventually(
+		t, func() bool {
+			return len(notifier.Events()) == 1
+		}, time.Second, time.Millisecond*10,
+	)
+
+	writeFile(t, dir, "ignored.txt", "2022-04-02T14:00:00Z,51.5072,-0.1276")
+	writeFile(t, dir, "new.csv", "2022-04-02T14:00:00Z,51.5072,-0.1276")
+
+	assert.Eventually(
+		t, func() bool {
+			return len(notifier.Events()) == 2
+		}, time.Second, time.Millisecond*10,
+	)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	assert.Equal(
+		t, []summary{
+			{Type: EventOpened, File: "existing.csv", Name: "London", Photos: 1},
+			{Type: EventUpdated, File: "new.csv", Name: "London", Photos: 2},
+		}, summarise(notifier.Events()),
+	)
+}
+
+func TestWatcher_matches(t *testing.T) {
+	w := &Watcher{
+		dir:     "photos",
+		dbPath:  "photos/photos.db",
+		options: Options{Pattern: "*"},
+	}
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{name: "day1.csv", expected: true},
+		{name: "photos.db", expected: false},
+		{name: "photos.db-journal", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, w.matches(tt.name))
+			},
+		)
+	}
+}
@@ -0,0 +1,381 @@
+package xmp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/JackFazackerley/photo-grouping/internal/isobmff"
+)
+
+// maxScanSize is how far into a file the start of a packet is looked for, when the file's format doesn't say where its
+// XMP is.
+const maxScanSize = 1024 * 1024
+
+// tagXMP is the TIFF tag of IFD0 which holds a TIFF's XMP.
+const tagXMP = 700
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+	// pngKeyword is the keyword of the iTXt chunk which holds a PNG's XMP.
+	pngKeyword = []byte("XML:com.adobe.xmp\x00")
+
+	// uuidXMP is the user type of the uuid box which holds the XMP of an ISOBMFF file, such as a CR3.
+	uuidXMP = []byte{0xbe, 0x7a, 0xcf, 0xcb, 0x97, 0xa9, 0x42, 0xe8, 0x9c, 0x71, 0x99, 0x94, 0x91, 0xe3, 0xaf, 0xac}
+)
+
+// Extract reads the XMP packet embedded in a photo's file of size bytes, returning false if it has none. The packet is
+// read from where the file's format keeps it; the APP1 segment of a JPEG, the iTXt chunk of a PNG, tag 700 of a TIFF
+// and the RAW formats built on it, or the uuid or XMP_ box of a CR3, MP4 or QuickTime file. Other files, such as HEIF
+// and RAF, and files without XMP where their format keeps it, have their first 1MiB scanned for a packet instead, so
+// that only as much of a large file as is needed is read.
+func Extract(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	packet, err := locatePacket(r, size)
+
+	return parsePacket(packet, err)
+}
+
+// ExtractISOBMFF reads the XMP packet in the uuid or XMP_ box of an ISOBMFF file of size bytes, such as an MP4 or
+// QuickTime video, returning false if it has none. Unlike Extract the file is never scanned, as videos only keep their
+// XMP in these boxes, and are too large to scan.
+func ExtractISOBMFF(r io.ReaderAt, size int64) (Metadata, bool, error) {
+	packet, err := isobmffPacket(r, size)
+
+	return parsePacket(packet, err)
+}
+
+// parsePacket parses the packet found by Extract, err is the error of finding it.
+func parsePacket(packet []byte, err error) (Metadata, bool, error) {
+	if err != nil || packet == nil {
+		return Metadata{}, false, err
+	}
+
+	// some writers pad the packet with nulls, which aren't valid XML.
+	metadata, err := Parse(bytes.NewReader(bytes.TrimRight(packet, "\x00")))
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	return metadata, true, nil
+}
+
+// locatePacket returns the packet in the file, found by its format, or nil if it has none.
+func locatePacket(r io.ReaderAt, size int64) ([]byte, error) {
+	header := make([]byte, 12)
+
+	n, err := r.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	header = header[:n]
+
+	var packet []byte
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0xff, 0xd8}):
+		packet, err = jpegPacket(r, size)
+	case bytes.HasPrefix(header, pngSignature):
+		packet, err = pngPacket(r, size)
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		packet, err = isobmffPacket(r, size)
+	case bytes.HasPrefix(header, []byte("II")), bytes.HasPrefix(header, []byte("MM")):
+		packet, err = tiffPacket(r, size)
+	}
+
+	if err != nil || packet != nil {
+		return packet, err
+	}
+
+	return findPacket(io.NewSectionReader(r, 0, size), maxScanSize)
+}
+
+// jpegPacket returns the packet in the XMP segment of a JPEG. Only the segments before the image data are read.
+func jpegPacket(r io.ReaderAt, size int64) ([]byte, error) {
+	marker := make([]byte, 4)
+	identifier := make([]byte, len(jpegIdentifier))
+
+	for offset := int64(2); offset+4 <= size; {
+		if _, err := r.ReadAt(marker, offset); err != nil {
+			return nil, fmt.Errorf("reading jpeg: %w", err)
+		}
+
+		if marker[0] != 0xff {
+			return nil, errors.New("reading jpeg: invalid marker")
+		}
+
+		if marker[1] == 0xda || marker[1] == 0xd9 {
+			break
+		}
+
+		length := int64(binary.BigEndian.Uint16(marker[2:]))
+		end := offset + 2 + length
+
+		if marker[1] == 0xe1 && end <= size && length-2 > int64(len(identifier)) {
+			if _, err := r.ReadAt(identifier, offset+4); err != nil {
+				return nil, fmt.Errorf("reading jpeg: %w", err)
+			}
+
+			if bytes.Equal(identifier, jpegIdentifier) {
+				return readSection(r, offset+4+int64(len(identifier)), end)
+			}
+		}
+
+		offset = end
+	}
+
+	return nil, nil
+}
+
+// pngPacket returns the packet in the iTXt chunk of a PNG with the XMP keyword, which may be compressed.
+func pngPacket(r io.ReaderAt, size int64) ([]byte, error) {
+	header := make([]byte, 8)
+
+	for offset := int64(len(pngSignature)); offset+8 <= size; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, fmt.Errorf("reading png: %w", err)
+		}
+
+		length := int64(binary.BigEndian.Uint32(header))
+
+		switch string(header[4:]) {
+		case "iTXt":
+			data, err := readSection(r, offset+8, offset+8+length)
+			if err != nil {
+				return nil, err
+			}
+
+			if packet, ok, err := pngText(data); ok || err != nil {
+				return packet, err
+			}
+		case "IEND":
+			return nil, nil
+		}
+
+		// each chunk is its length and type, its data, then a CRC.
+		offset += 12 + length
+	}
+
+	return nil, nil
+}
+
+// pngText returns the text of an iTXt chunk, if its keyword is the XMP keyword. The text follows the keyword, whether
+// it's compressed, the compression method, then the language and translated keyword, which each end with a null.
+func pngText(data []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(data, pngKeyword) {
+		return nil, false, nil
+	}
+
+	rest := data[len(pngKeyword):]
+	if len(rest) < 2 {
+		return nil, false, errors.New("reading png: invalid iTXt chunk")
+	}
+
+	compressed := rest[0] == 1
+	rest = rest[2:]
+
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return nil, false, errors.New("reading png: invalid iTXt chunk")
+		}
+
+		rest = rest[end+1:]
+	}
+
+	if !compressed {
+		return rest, true, nil
+	}
+
+	z, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return nil, false, fmt.Errorf("reading png: %w", err)
+	}
+	defer z.Close()
+
+	packet, err := io.ReadAll(io.LimitReader(z, maxPacketSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("reading png: %w", err)
+	}
+
+	if len(packet) > maxPacketSize {
+		return nil, false, ErrPacketTooLarge
+	}
+
+	return packet, true, nil
+}
+
+// isobmffPacket returns the packet in the XMP uuid box of an ISOBMFF file, either at the top level, as in a CR3, or in
+// its moov box, or in the XMP_ box of its moov box's udta box, as in a QuickTime file.
+func isobmffPacket(r io.ReaderAt, size int64) ([]byte, error) {
+	top, err := isobmff.Boxes(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if packet, err := uuidPacket(r, top); err != nil || packet != nil {
+		return packet, err
+	}
+
+	moov, ok := isobmff.Find(top, "moov")
+	if !ok {
+		return nil, nil
+	}
+
+	children, err := isobmff.Boxes(r, moov.Offset, moov.Offset+moov.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	if packet, err := uuidPacket(r, children); err != nil || packet != nil {
+		return packet, err
+	}
+
+	udta, ok := isobmff.Find(children, "udta")
+	if !ok {
+		return nil, nil
+	}
+
+	entries, err := isobmff.Boxes(r, udta.Offset, udta.Offset+udta.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, ok := isobmff.Find(entries, "XMP_"); ok {
+		return b.Bytes(r)
+	}
+
+	return nil, nil
+}
+
+// uuidPacket returns the contents of the XMP uuid box in boxes, after its user type.
+func uuidPacket(r io.ReaderAt, boxes []isobmff.Box) ([]byte, error) {
+	userType := make([]byte, len(uuidXMP))
+
+	for _, b := range boxes {
+		if b.Type != "uuid" || b.Size < int64(len(uuidXMP)) {
+			continue
+		}
+
+		if _, err := r.ReadAt(userType, b.Offset); err != nil {
+			return nil, fmt.Errorf("reading uuid box: %w", err)
+		}
+
+		if bytes.Equal(userType, uuidXMP) {
+			return readSection(r, b.Offset+int64(len(uuidXMP)), b.Offset+b.Size)
+		}
+	}
+
+	return nil, nil
+}
+
+// tiffPacket returns the packet in tag 700 of IFD0 of a TIFF, which RAW formats such as DNG, NEF and ARW are built on.
+func tiffPacket(r io.ReaderAt, size int64) ([]byte, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("reading tiff header: %w", err)
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if string(header[:2]) == "MM" {
+		order = binary.BigEndian
+	}
+
+	offset := int64(order.Uint32(header[4:]))
+
+	count := make([]byte, 2)
+	if _, err := r.ReadAt(count, offset); err != nil {
+		return nil, fmt.Errorf("reading tiff ifd: %w", err)
+	}
+
+	entries := make([]byte, int(order.Uint16(count))*12)
+	if _, err := r.ReadAt(entries, offset+2); err != nil {
+		return nil, fmt.Errorf("reading tiff ifd: %w", err)
+	}
+
+	for raw := entries; len(raw) >= 12; raw = raw[12:] {
+		if order.Uint16(raw) != tagXMP {
+			continue
+		}
+
+		// the packet is an array of bytes, which is stored at the offset in the entry unless it fits within it.
+		length := int64(order.Uint32(raw[4:]))
+		if length <= 4 {
+			return nil, nil
+		}
+
+		start := int64(order.Uint32(raw[8:]))
+
+		return readSection(r, start, start+length)
+	}
+
+	return nil, nil
+}
+
+// readSection reads r from start to end, which must be a packet no larger than 4MiB.
+func readSection(r io.ReaderAt, start, end int64) ([]byte, error) {
+	if end-start > maxPacketSize {
+		return nil, ErrPacketTooLarge
+	}
+
+	if start < 0 || end < start {
+		return nil, errors.New("reading xmp: invalid offset")
+	}
+
+	data := make([]byte, end-start)
+	if _, err := r.ReadAt(data, start); err != nil {
+		return nil, fmt.Errorf("reading xmp: %w", err)
+	}
+
+	return data, nil
+}
+
+// findPacket returns the first x:xmpmeta element in r, or nil if there isn't one starting within the first limit
+// bytes. Only as much of r as is needed to find the end of the packet is read.
+func findPacket(r io.Reader, limit int64) ([]byte, error) {
+	buffer := make([]byte, 0, chunkSize*2)
+	chunk := make([]byte, chunkSize)
+	found := false
+	read := int64(0)
+
+	for {
+		if !found && read >= limit {
+			return nil, nil
+		}
+
+		n, err := r.Read(chunk)
+		buffer = append(buffer, chunk[:n]...)
+		read += int64(n)
+
+		if !found {
+			if i := bytes.Index(buffer, packetStart); i >= 0 {
+				buffer = append(buffer[:0], buffer[i:]...)
+				found = true
+			} else if len(buffer) >= len(packetStart) {
+				// keep enough of the end of the buffer to find a start which spans two chunks.
+				buffer = append(buffer[:0], buffer[len(buffer)-len(packetStart)+1:]...)
+			}
+		}
+
+		if found {
+			if i := bytes.Index(buffer, packetEnd); i >= 0 {
+				return buffer[:i+len(packetEnd)], nil
+			}
+
+			if len(buffer) > maxPacketSize {
+				return nil, ErrPacketTooLarge
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading file: %w", err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package xmp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// box returns an ISOBMFF box of kind holding content.
+func box(kind string, content ...[]byte) []byte {
+	data := bytes.Join(content, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)+8))
+	copy(header[4:], kind)
+
+	return append(header, data...)
+}
+
+// chunk returns a PNG chunk of kind holding data, with an empty CRC.
+func chunk(kind string, data []byte) []byte {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], kind)
+
+	return append(append(header, data...), 0, 0, 0, 0)
+}
+
+// littleTIFF returns a little-endian TIFF whose IFD0 holds packet in tag 700.
+func littleTIFF(packet []byte) []byte {
+	data := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry, tagXMP)
+	binary.LittleEndian.PutUint16(entry[2:], 7)
+	binary.LittleEndian.PutUint32(entry[4:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(entry[8:], 26)
+
+	data = append(data, entry...)
+	data = append(data, 0, 0, 0, 0)
+
+	return append(data, packet...)
+}
+
+func compressed(data []byte) []byte {
+	var b bytes.Buffer
+
+	z := zlib.NewWriter(&b)
+	z.Write(data)
+	z.Close()
+
+	return b.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	packet := []byte(sidecar)
+
+	jpeg, err := EmbedJPEG([]byte{0xff, 0xd8, 0xff, 0xda, 0x00, 0x02, 0xff, 0xd9}, packet)
+	assert.NoError(t, err)
+
+	// the packet spans several chunks, so the start and end are found across reads.
+	scanned := append(bytes.Repeat([]byte{0xff}, chunkSize-5), packet...)
+	scanned = append(scanned, bytes.Repeat([]byte{0x00}, chunkSize)...)
+
+	tests := []struct {
+		name     string
+		file     []byte
+		expected bool
+	}{
+		{
+			name:     "jpeg",
+			file:     jpeg,
+			expected: true,
+		},
+		{
+			name:     "png",
+			file:     bytes.Join([][]byte{pngSignature, chunk("IHDR", make([]byte, 13)), chunk("iTXt", append(append(pngKeyword, 0, 0, 0, 0), packet...)), chunk("IEND", nil)}, nil),
+			expected: true,
+		},
+		{
+			name:     "compressed png",
+			file:     bytes.Join([][]byte{pngSignature, chunk("iTXt", append(append(pngKeyword, 1, 0, 0, 0), compressed(packet)...)), chunk("IEND", nil)}, nil),
+			expected: true,
+		},
+		{
+			name:     "tiff",
+			file:     littleTIFF(append(packet, 0, 0)),
+			expected: true,
+		},
+		{
+			name:     "cr3",
+			file:     append(box("ftyp", []byte("crx ")), box("uuid", uuidXMP, packet)...),
+			expected: true,
+		},
+		{
+			name:     "quicktime",
+			file:     append(box("ftyp", []byte("qt  ")), box("moov", box("udta", box("XMP_", packet)))...),
+			expected: true,
+		},
+		{
+			name:     "scanned",
+			file:     scanned,
+			expected: true,
+		},
+		{
+			name:     "scanned past the limit",
+			file:     append(make([]byte, maxScanSize+chunkSize), packet...),
+			expected: false,
+		},
+		{
+			name:     "no packet",
+			file:     bytes.Repeat([]byte{0xff}, chunkSize*2),
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, ok, err := Extract(bytes.NewReader(tt.file), int64(len(tt.file)))
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, ok)
+
+				if tt.expected {
+					assert.Equal(t, []string{"London", "Family"}, got.Keywords)
+				}
+			},
+		)
+	}
+}
+
+func TestExtractISOBMFF(t *testing.T) {
+	packet := []byte(sidecar)
+
+	movie := append(box("ftyp", []byte("qt  ")), box("moov", box("udta", box("XMP_", packet)))...)
+
+	got, ok, err := ExtractISOBMFF(bytes.NewReader(movie), int64(len(movie)))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"London", "Family"}, got.Keywords)
+
+	// a packet in the video's data isn't found, as it's never scanned.
+	movie = append(box("ftyp", []byte("qt  ")), box("mdat", packet)...)
+
+	_, ok, err = ExtractISOBMFF(bytes.NewReader(movie), int64(len(movie)))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
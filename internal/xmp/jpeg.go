@@ -0,0 +1,111 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// maxSegmentSize is the most data a JPEG segment can hold, after its length.
+const maxSegmentSize = 0xffff - 2
+
+var (
+	// ErrNotJPEG is returned by EmbedJPEG when the file isn't a JPEG.
+	ErrNotJPEG = errors.New("not a jpeg")
+
+	// jpegIdentifier starts the APP1 segment which holds a JPEG's XMP.
+	jpegIdentifier = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+	packetHeader  = []byte(xpacketBegin + "\n")
+	packetTrailer = []byte("\n" + xpacketEnd)
+)
+
+// EmbeddedJPEG returns the XMP packet embedded in a JPEG, or nil if it has none.
+func EmbeddedJPEG(jpeg []byte) ([]byte, error) {
+	start, end, err := findSegment(jpeg)
+	if err != nil || start < 0 {
+		return nil, err
+	}
+
+	return jpeg[start+4+len(jpegIdentifier) : end], nil
+}
+
+// EmbedJPEG returns jpeg with its XMP replaced by packet, or with packet added after its APP0 and APP1 segments if it has
+// no XMP. The rest of the file is copied as it is.
+func EmbedJPEG(jpeg, packet []byte) ([]byte, error) {
+	start, end, err := findSegment(jpeg)
+	if err != nil {
+		return nil, err
+	}
+
+	if start < 0 {
+		start, end = insertionPoint(jpeg), insertionPoint(jpeg)
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(packet), []byte("<?xpacket")) {
+		packet = bytes.Join([][]byte{packetHeader, packet, packetTrailer}, nil)
+	}
+
+	size := len(jpegIdentifier) + len(packet)
+	if size > maxSegmentSize {
+		return nil, fmt.Errorf("embedding xmp: packet of %d bytes is too large for a jpeg segment", size)
+	}
+
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(size+2))
+
+	embedded := make([]byte, 0, len(jpeg)+len(segment)+size)
+	embedded = append(embedded, jpeg[:start]...)
+	embedded = append(embedded, segment...)
+	embedded = append(embedded, jpegIdentifier...)
+	embedded = append(embedded, packet...)
+
+	return append(embedded, jpeg[end:]...), nil
+}
+
+// findSegment returns the start and end of the XMP segment in jpeg, or -1 if it has none. Only the segments before the
+// image data are read.
+func findSegment(jpeg []byte) (int, int, error) {
+	if !bytes.HasPrefix(jpeg, []byte{0xff, 0xd8}) {
+		return 0, 0, ErrNotJPEG
+	}
+
+	for offset := 2; offset+4 <= len(jpeg); {
+		if jpeg[offset] != 0xff {
+			return 0, 0, errors.New("reading jpeg: invalid marker")
+		}
+
+		marker := jpeg[offset+1]
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+
+		// the length includes its own two bytes, so anything shorter is corrupt.
+		length := int(binary.BigEndian.Uint16(jpeg[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(jpeg) {
+			return 0, 0, errors.New("reading jpeg: invalid segment length")
+		}
+
+		if marker == 0xe1 && bytes.HasPrefix(jpeg[offset+4:end], jpegIdentifier) {
+			return offset, end, nil
+		}
+
+		offset = end
+	}
+
+	return -1, -1, nil
+}
+
+// insertionPoint returns the offset after the APP0 and APP1 segments at the start of jpeg, where a JFIF or EXIF
+// segment must stay first.
+func insertionPoint(jpeg []byte) int {
+	offset := 2
+
+	for offset+4 <= len(jpeg) && jpeg[offset] == 0xff && (jpeg[offset+1] == 0xe0 || jpeg[offset+1] == 0xe1) {
+		offset += 2 + int(binary.BigEndian.Uint16(jpeg[offset+2:]))
+	}
+
+	return offset
+}
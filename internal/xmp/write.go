@@ -0,0 +1,438 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// The namespaces of the properties which are written.
+const (
+	namespacePhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	namespaceIPTCCore  = "http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/"
+	namespaceXML       = "http://www.w3.org/XML/1998/namespace"
+	// namespacePhotoGrouping holds the properties Update keeps to itself, rather than those read by other tools.
+	namespacePhotoGrouping = "https://github.com/JackFazackerley/photo-grouping/xmp/1.0/"
+)
+
+// The processing instructions which wrap a packet.
+const (
+	xpacketBegin = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>`
+	xpacketEnd   = `<?xpacket end="w"?>`
+)
+
+var (
+	description = xml.Name{Space: namespaceRDF, Local: "Description"}
+	rdfRoot     = xml.Name{Space: namespaceRDF, Local: "RDF"}
+	rdfAbout    = xml.Name{Space: namespaceRDF, Local: "about"}
+	city        = xml.Name{Space: namespacePhotoshop, Local: "City"}
+	state       = xml.Name{Space: namespacePhotoshop, Local: "State"}
+	country     = xml.Name{Space: namespacePhotoshop, Local: "Country"}
+	location    = xml.Name{Space: namespaceIPTCCore, Local: "Location"}
+	// writtenKeywords records the keywords Update added to dc:subject, so that they can be replaced by the next Update.
+	writtenKeywords = xml.Name{Space: namespacePhotoGrouping, Local: "Keywords"}
+
+	// textEscaper and attrEscaper escape character data and attribute values, unlike xml.EscapeText, new lines in
+	// character data are kept as they are so that the layout of a packet is kept.
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer(
+		"&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;",
+	)
+
+	// emptyPacket is the packet a sidecar is created from.
+	emptyPacket = []byte(xpacketBegin + `
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+ </rdf:RDF>
+</x:xmpmeta>
+` + xpacketEnd + "\n")
+)
+
+// Tags are the properties written into a photo's XMP by Update. Keywords are written to dc:subject, the location to
+// Iptc4xmpCore:Location, which is the place within the city, and the city, state and country to photoshop:City,
+// photoshop:State and photoshop:Country.
+type Tags struct {
+	Keywords []string
+	Location string
+	City     string
+	State    string
+	Country  string
+}
+
+// properties returns the properties written for the tags, and so replaced in the packet. Empty fields are left as they
+// are, and keywords are merged by Update.
+func (t Tags) properties() map[xml.Name]string {
+	properties := make(map[xml.Name]string)
+
+	for name, value := range map[xml.Name]string{location: t.Location, city: t.City, state: t.State, country: t.Country} {
+		if value != "" {
+			properties[name] = value
+		}
+	}
+
+	return properties
+}
+
+// SidecarPaths returns the paths an XMP sidecar of the photo at path may have, in the order they're looked for.
+// darktable names a sidecar after the whole file name, such as IMG_0001.CR3.xmp, whereas Lightroom replaces the
+// extension, such as IMG_0001.xmp.
+func SidecarPaths(path string) []string {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+
+	return []string{path + ".xmp", path + ".XMP", base + ".xmp", base + ".XMP"}
+}
+
+// Update returns packet with tags written into it, or a new packet if packet is empty. The keywords are added to any
+// the packet already has, and the other tags replace the properties already set. The rest of the packet is kept as it
+// is, so that the edits kept in a sidecar by Lightroom or darktable aren't lost.
+//
+// The keywords Update adds are recorded in the packet, and are replaced by the keywords of the next Update, so that a
+// photo whose group is retitled doesn't keep its old title. Keywords the packet already had are never removed.
+//
+// The tags are written in an rdf:Description of their own, at the end of rdf:RDF, and are removed from wherever they
+// were in the packet. Updating a packet with the same tags again doesn't change it.
+func Update(packet []byte, tags Tags) ([]byte, error) {
+	if len(bytes.TrimSpace(packet)) == 0 {
+		packet = emptyPacket
+	}
+
+	existing, written, err := parse(bytes.NewReader(packet))
+	if err != nil {
+		return nil, err
+	}
+
+	kept := removeKeywords(existing.Keywords, written)
+
+	u := updater{
+		properties: tags.properties(),
+		keywords:   mergeKeywords(kept, tags.Keywords),
+		written:    removeKeywords(mergeKeywords(nil, tags.Keywords), kept),
+		replace:    len(written) > 0,
+		scopes:     []map[string]string{{"xml": namespaceXML}},
+	}
+
+	if err := u.update(packet); err != nil {
+		return nil, err
+	}
+
+	return u.out.Bytes(), nil
+}
+
+// removeKeywords returns keywords without those in removed.
+func removeKeywords(keywords, removed []string) []string {
+	remove := make(map[string]struct{}, len(removed))
+	for _, keyword := range removed {
+		remove[keyword] = struct{}{}
+	}
+
+	kept := make([]string, 0, len(keywords))
+
+	for _, keyword := range keywords {
+		if _, ok := remove[keyword]; !ok {
+			kept = append(kept, keyword)
+		}
+	}
+
+	return kept
+}
+
+func mergeKeywords(existing, added []string) []string {
+	keywords := make([]string, 0, len(existing)+len(added))
+	seen := make(map[string]struct{})
+
+	for _, keyword := range append(append([]string(nil), existing...), added...) {
+		if _, ok := seen[keyword]; ok || keyword == "" {
+			continue
+		}
+
+		seen[keyword] = struct{}{}
+		keywords = append(keywords, keyword)
+	}
+
+	return keywords
+}
+
+// updater rewrites a packet token by token. Tokens are read raw, so that each element keeps the prefix it was written
+// with, and namespaces are resolved using scopes, the prefixes declared by each element being read.
+type updater struct {
+	properties map[xml.Name]string
+	keywords   []string
+	// written is the keywords being added which the packet didn't already have, which are recorded in writtenKeywords.
+	written []string
+	// replace is whether dc:subject is removed even when there are no keywords, as it only held written keywords.
+	replace bool
+
+	out    bytes.Buffer
+	scopes []map[string]string
+
+	// skip is the depth within an element being removed, or 0.
+	skip int
+	// rdfDepth is the depth of rdf:RDF, or 0 if it hasn't been read.
+	rdfDepth int
+	// inserted is whether the tags have been written.
+	inserted bool
+
+	// description holds each rdf:Description within rdf:RDF until it ends, so that it can be removed if it's left
+	// empty, such as the one the tags were written in by a previous Update.
+	description *bytes.Buffer
+	hasContent  bool
+	// trimSpace is set once an rdf:Description has been removed, so that the whitespace after it is too.
+	trimSpace bool
+}
+
+func (u *updater) update(packet []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("decoding xmp: %w", err)
+		}
+
+		trimSpace := u.trimSpace
+		u.trimSpace = false
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			u.start(t)
+		case xml.EndElement:
+			u.end(t)
+		case xml.CharData:
+			if u.skip > 0 || (trimSpace && len(bytes.TrimSpace(t)) == 0) {
+				continue
+			}
+
+			if len(bytes.TrimSpace(t)) > 0 {
+				u.hasContent = true
+			}
+
+			_, _ = textEscaper.WriteString(u.writer(), string(t))
+		case xml.Comment:
+			if u.skip == 0 {
+				fmt.Fprintf(u.writer(), "<!--%s-->", t)
+			}
+		case xml.ProcInst:
+			if u.skip == 0 {
+				fmt.Fprintf(u.writer(), "<?%s %s?>", t.Target, t.Inst)
+			}
+		case xml.Directive:
+			if u.skip == 0 {
+				fmt.Fprintf(u.writer(), "<!%s>", t)
+			}
+		}
+	}
+
+	if !u.inserted {
+		return errors.New("decoding xmp: no rdf:RDF element")
+	}
+
+	return nil
+}
+
+// writer returns where tokens are written, which is the current rdf:Description if there is one.
+func (u *updater) writer() *bytes.Buffer {
+	if u.description != nil {
+		return u.description
+	}
+
+	return &u.out
+}
+
+func (u *updater) start(element xml.StartElement) {
+	scope := make(map[string]string)
+	for prefix, namespace := range u.scopes[len(u.scopes)-1] {
+		scope[prefix] = namespace
+	}
+
+	for _, attr := range element.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			scope[attr.Name.Local] = attr.Value
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			scope[""] = attr.Value
+		}
+	}
+
+	u.scopes = append(u.scopes, scope)
+	depth := len(u.scopes) - 1
+
+	if u.skip > 0 {
+		u.skip++
+		return
+	}
+
+	name := resolve(scope, element.Name, true)
+
+	_, ok := u.properties[name]
+	if ok || name == writtenKeywords || (name == subject && (len(u.keywords) > 0 || u.replace)) {
+		u.skip = 1
+		return
+	}
+
+	switch {
+	case name == rdfRoot && u.rdfDepth == 0:
+		u.rdfDepth = depth
+	case name == description && u.rdfDepth > 0 && depth == u.rdfDepth+1:
+		u.description = &bytes.Buffer{}
+		u.hasContent = false
+		element.Attr = u.filter(scope, element.Attr)
+	case u.description != nil:
+		u.hasContent = true
+	}
+
+	writeStart(u.writer(), element)
+}
+
+// filter removes the properties being written from the attributes of an rdf:Description.
+func (u *updater) filter(scope map[string]string, attrs []xml.Attr) []xml.Attr {
+	filtered := make([]xml.Attr, 0, len(attrs))
+
+	for _, attr := range attrs {
+		name := resolve(scope, attr.Name, false)
+
+		if _, ok := u.properties[name]; ok {
+			continue
+		}
+
+		declaration := attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns")
+		if !declaration && name != rdfAbout {
+			u.hasContent = true
+		}
+
+		filtered = append(filtered, attr)
+	}
+
+	return filtered
+}
+
+func (u *updater) end(element xml.EndElement) {
+	scope := u.scopes[len(u.scopes)-1]
+	depth := len(u.scopes) - 1
+	u.scopes = u.scopes[:depth]
+
+	if u.skip > 0 {
+		u.skip--
+		return
+	}
+
+	name := resolve(scope, element.Name, true)
+
+	switch {
+	case name == rdfRoot && depth == u.rdfDepth && !u.inserted:
+		u.writeTags(element.Name.Space)
+		u.inserted = true
+	case name == description && depth == u.rdfDepth+1 && u.description != nil:
+		writeEnd(u.description, element)
+
+		if u.hasContent {
+			u.out.Write(u.description.Bytes())
+		} else {
+			u.trimSpace = true
+		}
+
+		u.description = nil
+
+		return
+	}
+
+	writeEnd(u.writer(), element)
+}
+
+// writeTags writes the tags in an rdf:Description, using the prefix rdf:RDF was written with. The prefixes of the tags
+// are declared on the rdf:Description, so they can't clash with the rest of the packet.
+func (u *updater) writeTags(rdf string) {
+	if len(u.properties) == 0 && len(u.keywords) == 0 && len(u.written) == 0 {
+		return
+	}
+
+	qualify := func(local string) string {
+		if rdf == "" {
+			return local
+		}
+
+		return rdf + ":" + local
+	}
+
+	out := &u.out
+
+	fmt.Fprintf(
+		out, `<%s %s="" xmlns:dc="%s" xmlns:photoshop="%s" xmlns:Iptc4xmpCore="%s" xmlns:photogrouping="%s"`,
+		qualify("Description"), qualify("about"), namespaceDC, namespacePhotoshop, namespaceIPTCCore,
+		namespacePhotoGrouping,
+	)
+
+	prefixes := map[string]string{namespacePhotoshop: "photoshop", namespaceIPTCCore: "Iptc4xmpCore"}
+
+	for _, name := range []xml.Name{location, city, state, country} {
+		if value, ok := u.properties[name]; ok {
+			fmt.Fprintf(out, "\n   %s:%s=\"", prefixes[name.Space], name.Local)
+			_, _ = attrEscaper.WriteString(out, value)
+			out.WriteString(`"`)
+		}
+	}
+
+	out.WriteString(">\n")
+
+	writeBag(out, "dc:subject", qualify, u.keywords)
+	writeBag(out, "photogrouping:Keywords", qualify, u.written)
+
+	fmt.Fprintf(out, "  </%s>\n ", qualify("Description"))
+}
+
+// writeBag writes the property name holding keywords as an rdf:Bag, unless there are none.
+func writeBag(out *bytes.Buffer, name string, qualify func(string) string, keywords []string) {
+	if len(keywords) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "   <%s>\n    <%s>\n", name, qualify("Bag"))
+
+	for _, keyword := range keywords {
+		fmt.Fprintf(out, "     <%s>", qualify("li"))
+		_, _ = textEscaper.WriteString(out, keyword)
+		fmt.Fprintf(out, "</%s>\n", qualify("li"))
+	}
+
+	fmt.Fprintf(out, "    </%s>\n   </%s>\n", qualify("Bag"), name)
+}
+
+// resolve returns the namespace and local name of a raw name. Attributes without a prefix have no namespace, rather
+// than the default namespace.
+func resolve(scope map[string]string, name xml.Name, element bool) xml.Name {
+	if name.Space == "" && !element {
+		return xml.Name{Local: name.Local}
+	}
+
+	return xml.Name{Space: scope[name.Space], Local: name.Local}
+}
+
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
+
+func writeStart(w *bytes.Buffer, element xml.StartElement) {
+	w.WriteString("<" + qualified(element.Name))
+
+	for _, attr := range element.Attr {
+		w.WriteString(" " + qualified(attr.Name) + `="`)
+		_, _ = attrEscaper.WriteString(w, attr.Value)
+		w.WriteString(`"`)
+	}
+
+	w.WriteString(">")
+}
+
+func writeEnd(w *bytes.Buffer, element xml.EndElement) {
+	w.WriteString("</" + qualified(element.Name) + ">")
+}
@@ -0,0 +1,187 @@
+package xmp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lightroomSidecar is a sidecar written by Lightroom, with an edit and a city already set.
+const lightroomSidecar = `<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="Adobe XMP Core 7.0-c000">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:crs="http://ns.adobe.com/camera-raw-settings/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    crs:Exposure2012="+0.35"
+    photoshop:City="Londres">
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>Family</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+`
+
+func TestUpdate(t *testing.T) {
+	tags := Tags{
+		Keywords: []string{"A day out in London", "day", "Family"},
+		Location: "Westminster",
+		City:     "London",
+		Country:  "United Kingdom",
+	}
+
+	tests := []struct {
+		name     string
+		packet   string
+		keywords []string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "creates packet",
+			packet:   "",
+			keywords: []string{"A day out in London", "day", "Family"},
+			contains: []string{
+				`<?xpacket begin=`,
+				`photoshop:City="London"`,
+				`photoshop:Country="United Kingdom"`,
+				`Iptc4xmpCore:Location="Westminster"`,
+			},
+			excludes: []string{"photoshop:State"},
+		},
+		{
+			name:     "keeps the rest of an existing packet",
+			packet:   lightroomSidecar,
+			keywords: []string{"Family", "A day out in London", "day"},
+			contains: []string{
+				`x:xmptk="Adobe XMP Core 7.0-c000"`,
+				`crs:Exposure2012="+0.35"`,
+				`photoshop:City="London"`,
+			},
+			excludes: []string{"Londres", "&#xA;"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := Update([]byte(tt.packet), tags)
+				assert.NoError(t, err)
+
+				metadata, err := Parse(bytes.NewReader(got))
+				assert.NoError(t, err)
+				assert.Equal(t, tt.keywords, metadata.Keywords)
+
+				for _, s := range tt.contains {
+					assert.Contains(t, string(got), s)
+				}
+
+				for _, s := range tt.excludes {
+					assert.NotContains(t, string(got), s)
+				}
+
+				again, err := Update(got, tags)
+				assert.NoError(t, err)
+				assert.Equal(t, string(got), string(again))
+			},
+		)
+	}
+}
+
+func TestUpdate_Retitled(t *testing.T) {
+	first, err := Update([]byte(lightroomSidecar), Tags{Keywords: []string{"A day out in London", "day", "Family"}})
+	assert.NoError(t, err)
+
+	got, err := Update(first, Tags{Keywords: []string{"Visiting London in April", "day"}})
+	assert.NoError(t, err)
+
+	metadata, err := Parse(bytes.NewReader(got))
+	assert.NoError(t, err)
+	// the old title is replaced, and Family is kept as the packet already had it.
+	assert.Equal(t, []string{"Family", "Visiting London in April", "day"}, metadata.Keywords)
+
+	got, err = Update(got, Tags{})
+	assert.NoError(t, err)
+
+	metadata, err = Parse(bytes.NewReader(got))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Family"}, metadata.Keywords)
+	assert.NotContains(t, string(got), "photogrouping:Keywords")
+}
+
+func TestUpdate_Errors(t *testing.T) {
+	_, err := Update([]byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"></x:xmpmeta>`), Tags{City: "London"})
+	assert.EqualError(t, err, "decoding xmp: no rdf:RDF element")
+
+	_, err = Update([]byte(`<x:xmpmeta>`), Tags{City: "London"})
+	assert.EqualError(t, err, "decoding xmp: XML syntax error on line 1: unexpected EOF")
+}
+
+func TestEmbedJPEG(t *testing.T) {
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x04, 0x00, 0x00, 0xff, 0xda, 0x00, 0x02, 0xff, 0xd9}
+
+	packet, err := EmbeddedJPEG(jpeg)
+	assert.NoError(t, err)
+	assert.Nil(t, packet)
+
+	packet, err = Update(packet, Tags{City: "London"})
+	assert.NoError(t, err)
+
+	embedded, err := EmbedJPEG(jpeg, packet)
+	assert.NoError(t, err)
+	// the XMP is added after the APP0 segment, and the image data is untouched.
+	assert.Equal(t, jpeg[:8], embedded[:8])
+	assert.Equal(t, []byte{0xff, 0xe1}, embedded[8:10])
+	assert.True(t, bytes.HasSuffix(embedded, jpeg[8:]))
+
+	packet, err = EmbeddedJPEG(embedded)
+	assert.NoError(t, err)
+	assert.Contains(t, string(packet), `photoshop:City="London"`)
+
+	packet, err = Update(packet, Tags{City: "Paris"})
+	assert.NoError(t, err)
+
+	replaced, err := EmbedJPEG(embedded, packet)
+	assert.NoError(t, err)
+	assert.Equal(t, len(embedded)-len("London")+len("Paris"), len(replaced))
+	assert.Equal(t, 1, strings.Count(string(replaced), string(jpegIdentifier)))
+	assert.Contains(t, string(replaced), `photoshop:City="Paris"`)
+
+	_, err = EmbedJPEG([]byte("not a jpeg"), packet)
+	assert.ErrorIs(t, err, ErrNotJPEG)
+}
+
+func TestEmbedJPEG_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		jpeg []byte
+	}{
+		{
+			name: "segment length of zero",
+			jpeg: []byte{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x00, 0xff, 0xd9},
+		},
+		{
+			name: "segment length of one",
+			jpeg: []byte{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x01, 0xff, 0xd9},
+		},
+		{
+			name: "segment longer than the file",
+			jpeg: []byte{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x40, 0xff, 0xd9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				_, err := EmbeddedJPEG(tt.jpeg)
+				assert.EqualError(t, err, "reading jpeg: invalid segment length")
+
+				_, err = EmbedJPEG(tt.jpeg, emptyPacket)
+				assert.EqualError(t, err, "reading jpeg: invalid segment length")
+			},
+		)
+	}
+}
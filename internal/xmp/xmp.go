@@ -0,0 +1,272 @@
+// Package xmp reads the capture time, location and keywords of a photo from XMP metadata. XMP is either embedded in
+// the photo's file, or kept in a sidecar next to it by editors such as Lightroom and darktable, which hold any edits
+// made to the capture time or location, see Precedence.
+package xmp
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The namespaces of the properties which are read.
+const (
+	namespaceRDF  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	namespaceEXIF = "http://ns.adobe.com/exif/1.0/"
+	namespaceDC   = "http://purl.org/dc/elements/1.1/"
+)
+
+const (
+	// chunkSize is how much of a file is read at a time while looking for an embedded packet.
+	chunkSize = 64 * 1024
+	// maxPacketSize is the largest embedded packet which is read, XMP packets are rarely more than a few kilobytes.
+	maxPacketSize = 4 * 1024 * 1024
+)
+
+var (
+	// ErrPacketTooLarge is returned by Extract when an embedded packet is larger than 4MiB.
+	ErrPacketTooLarge = errors.New("xmp packet too large")
+
+	dateTimeOriginal = xml.Name{Space: namespaceEXIF, Local: "DateTimeOriginal"}
+	gpsLatitude      = xml.Name{Space: namespaceEXIF, Local: "GPSLatitude"}
+	gpsLongitude     = xml.Name{Space: namespaceEXIF, Local: "GPSLongitude"}
+	subject          = xml.Name{Space: namespaceDC, Local: "subject"}
+	listItem         = xml.Name{Space: namespaceRDF, Local: "li"}
+
+	packetStart = []byte("<x:xmpmeta")
+	packetEnd   = []byte("</x:xmpmeta>")
+)
+
+// Metadata is what's known about a photo from its XMP. Taken is zero if the capture time isn't known, and Located is
+// false if the location isn't.
+type Metadata struct {
+	Taken     time.Time
+	Latitude  float64
+	Longitude float64
+	Located   bool
+	Keywords  []string
+}
+
+// Merge returns preferred, with any fields it doesn't set taken from fallback. The location and keywords are each
+// taken as a whole, so a latitude from one is never paired with a longitude from the other.
+func Merge(preferred, fallback Metadata) Metadata {
+	merged := preferred
+
+	if merged.Taken.IsZero() {
+		merged.Taken = fallback.Taken
+	}
+
+	if !merged.Located {
+		merged.Latitude = fallback.Latitude
+		merged.Longitude = fallback.Longitude
+		merged.Located = fallback.Located
+	}
+
+	if len(merged.Keywords) == 0 {
+		merged.Keywords = fallback.Keywords
+	}
+
+	return merged
+}
+
+// Precedence decides which of a photo's sidecar and embedded XMP is used when both set the same field.
+type Precedence int
+
+const (
+	// PreferSidecar uses the sidecar over the embedded XMP, as edits made in Lightroom or darktable to a photo are
+	// written to its sidecar, leaving the file untouched.
+	PreferSidecar Precedence = iota
+	// PreferEmbedded uses the embedded XMP over the sidecar, for when the file was edited after the sidecar was
+	// written.
+	PreferEmbedded
+)
+
+// ParsePrecedence returns the Precedence named by value, either "sidecar" or "embedded".
+func ParsePrecedence(value string) (Precedence, error) {
+	switch value {
+	case "sidecar":
+		return PreferSidecar, nil
+	case "embedded":
+		return PreferEmbedded, nil
+	default:
+		return 0, fmt.Errorf("unknown xmp precedence %q", value)
+	}
+}
+
+func (p Precedence) String() string {
+	if p == PreferEmbedded {
+		return "embedded"
+	}
+
+	return "sidecar"
+}
+
+// Combine merges a photo's embedded and sidecar XMP, each field is taken from the preferred one if it's set there.
+func (p Precedence) Combine(embedded, sidecar Metadata) Metadata {
+	if p == PreferEmbedded {
+		return Merge(embedded, sidecar)
+	}
+
+	return Merge(sidecar, embedded)
+}
+
+// Parse reads a packet of XMP, such as a sidecar. Properties can be written either as attributes of rdf:Description,
+// or as elements within it, and both are read.
+func Parse(r io.Reader) (Metadata, error) {
+	metadata, _, err := parse(r)
+	return metadata, err
+}
+
+// parse reads a packet in the same way as Parse, also returning the keywords Update recorded writing into it.
+func parse(r io.Reader) (Metadata, []string, error) {
+	decoder := xml.NewDecoder(r)
+	properties := make(map[xml.Name]string)
+	keywords := make([]string, 0)
+	written := make([]string, 0)
+	// bag is the list of keywords being read, or nil when not within dc:subject or the written keywords.
+	var bag *[]string
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Metadata{}, nil, fmt.Errorf("decoding xmp: %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			for _, attr := range element.Attr {
+				properties[attr.Name] = attr.Value
+			}
+
+			switch {
+			case element.Name == subject:
+				bag = &keywords
+			case element.Name == writtenKeywords:
+				bag = &written
+			case bag != nil && element.Name == listItem,
+				element.Name == dateTimeOriginal || element.Name == gpsLatitude || element.Name == gpsLongitude:
+				var value string
+				if err := decoder.DecodeElement(&value, &element); err != nil {
+					return Metadata{}, nil, fmt.Errorf("decoding xmp: %w", err)
+				}
+
+				if element.Name == listItem {
+					*bag = append(*bag, strings.TrimSpace(value))
+				} else {
+					properties[element.Name] = strings.TrimSpace(value)
+				}
+			}
+		case xml.EndElement:
+			if element.Name == subject || element.Name == writtenKeywords {
+				bag = nil
+			}
+		}
+	}
+
+	metadata, err := newMetadata(properties, keywords)
+
+	return metadata, written, err
+}
+
+func newMetadata(properties map[xml.Name]string, keywords []string) (Metadata, error) {
+	metadata := Metadata{}
+
+	if len(keywords) > 0 {
+		metadata.Keywords = keywords
+	}
+
+	if value := properties[dateTimeOriginal]; value != "" {
+		taken, err := parseDate(value)
+		if err != nil {
+			return Metadata{}, fmt.Errorf("parsing exif:DateTimeOriginal: %w", err)
+		}
+
+		metadata.Taken = taken
+	}
+
+	latitude, longitude := properties[gpsLatitude], properties[gpsLongitude]
+	if latitude == "" || longitude == "" {
+		return metadata, nil
+	}
+
+	var err error
+
+	if metadata.Latitude, err = parseCoordinate(latitude, 'N', 'S', 90); err != nil {
+		return Metadata{}, fmt.Errorf("parsing exif:GPSLatitude: %w", err)
+	}
+
+	if metadata.Longitude, err = parseCoordinate(longitude, 'E', 'W', 180); err != nil {
+		return Metadata{}, fmt.Errorf("parsing exif:GPSLongitude: %w", err)
+	}
+
+	metadata.Located = true
+
+	return metadata, nil
+}
+
+// parseDate parses an XMP date, which is ISO 8601 with optional seconds and zone. Dates without a zone are treated as
+// UTC.
+func parseDate(value string) (time.Time, error) {
+	layouts := []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04",
+	}
+
+	for _, layout := range layouts {
+		if taken, err := time.Parse(layout, value); err == nil {
+			return taken, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// parseCoordinate parses an XMP GPSCoordinate, which is "DDD,MM,SSk" or "DDD,MM.mmk" where k is the direction, such
+// as "51,30.432N". A coordinate in direction negative is returned as negative.
+func parseCoordinate(value string, positive, negative byte, limit float64) (float64, error) {
+	if len(value) < 2 {
+		return 0, fmt.Errorf("invalid coordinate %q", value)
+	}
+
+	direction := value[len(value)-1]
+	if direction != positive && direction != negative {
+		return 0, fmt.Errorf("invalid direction in coordinate %q", value)
+	}
+
+	parts := strings.Split(value[:len(value)-1], ",")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid coordinate %q", value)
+	}
+
+	coordinate := 0.0
+	scale := 1.0
+
+	for _, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("invalid coordinate %q", value)
+		}
+
+		coordinate += number / scale
+		scale *= 60
+	}
+
+	if coordinate > limit {
+		return 0, fmt.Errorf("coordinate %q out of range", value)
+	}
+
+	if direction == negative {
+		coordinate = -coordinate
+	}
+
+	return coordinate, nil
+}
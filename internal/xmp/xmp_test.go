@@ -0,0 +1,182 @@
+package xmp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sidecar = `<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="XMP Core 5.6.0">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    exif:DateTimeOriginal="2022-04-02T10:52:59.12+01:00"
+    exif:GPSLatitude="51,30.432N"
+    exif:GPSLongitude="0,7,39.36W">
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>London</rdf:li>
+     <rdf:li> Family </rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		packet   string
+		expected Metadata
+		err      string
+	}{
+		{
+			name:   "reads properties written as attributes",
+			packet: sidecar,
+			expected: Metadata{
+				Taken:     time.Date(2022, 04, 02, 10, 52, 59, 120000000, time.FixedZone("", 60*60)),
+				Latitude:  51.5072,
+				Longitude: -0.1276,
+				Located:   true,
+				Keywords:  []string{"London", "Family"},
+			},
+		},
+		{
+			name: "reads properties written as elements",
+			packet: `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/">
+   <exif:DateTimeOriginal>2022-04-02T10:52</exif:DateTimeOriginal>
+   <exif:GPSLatitude>33,51.5S</exif:GPSLatitude>
+   <exif:GPSLongitude>151,12.6E</exif:GPSLongitude>
+  </rdf:Description>
+</rdf:RDF></x:xmpmeta>`,
+			expected: Metadata{
+				Taken:     time.Date(2022, 04, 02, 10, 52, 0, 0, time.UTC),
+				Latitude:  -33.858333333333334,
+				Longitude: 151.21,
+				Located:   true,
+			},
+		},
+		{
+			name: "ignores a latitude without a longitude",
+			packet: `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" exif:GPSLatitude="51,30.432N"/>
+</rdf:RDF></x:xmpmeta>`,
+			expected: Metadata{},
+		},
+		{
+			name: "rejects invalid coordinate",
+			packet: `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" exif:GPSLatitude="91,0N" exif:GPSLongitude="0,0E"/>
+</rdf:RDF></x:xmpmeta>`,
+			err: `parsing exif:GPSLatitude: coordinate "91,0N" out of range`,
+		},
+		{
+			name: "rejects invalid date",
+			packet: `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" exif:DateTimeOriginal="yesterday"/>
+</rdf:RDF></x:xmpmeta>`,
+			err: `parsing exif:DateTimeOriginal: invalid date "yesterday"`,
+		},
+		{
+			name:   "rejects invalid syntax",
+			packet: `<x:xmpmeta>`,
+			err:    "decoding xmp: XML syntax error on line 1: unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := Parse(strings.NewReader(tt.packet))
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+					return
+				}
+
+				assert.NoError(t, err)
+				assert.True(t, tt.expected.Taken.Equal(got.Taken), got.Taken)
+				assert.InDelta(t, tt.expected.Latitude, got.Latitude, 1e-9)
+				assert.InDelta(t, tt.expected.Longitude, got.Longitude, 1e-9)
+				assert.Equal(t, tt.expected.Located, got.Located)
+				assert.Equal(t, tt.expected.Keywords, got.Keywords)
+			},
+		)
+	}
+}
+
+func TestPrecedence_Combine(t *testing.T) {
+	embedded := Metadata{
+		Taken:     time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+		Latitude:  1,
+		Longitude: 1,
+		Located:   true,
+		Keywords:  []string{"camera"},
+	}
+	sidecar := Metadata{
+		Taken:     time.Date(2022, 04, 02, 11, 0, 0, 0, time.UTC),
+		Latitude:  2,
+		Longitude: 2,
+		Located:   true,
+	}
+
+	tests := []struct {
+		name       string
+		precedence Precedence
+		embedded   Metadata
+		sidecar    Metadata
+		expected   Metadata
+	}{
+		{
+			name:       "prefers sidecar",
+			precedence: PreferSidecar,
+			embedded:   embedded,
+			sidecar:    sidecar,
+			expected: Metadata{
+				Taken:     sidecar.Taken,
+				Latitude:  2,
+				Longitude: 2,
+				Located:   true,
+				Keywords:  []string{"camera"},
+			},
+		},
+		{
+			name:       "prefers embedded",
+			precedence: PreferEmbedded,
+			embedded:   embedded,
+			sidecar:    sidecar,
+			expected:   embedded,
+		},
+		{
+			name:       "falls back to embedded location",
+			precedence: PreferSidecar,
+			embedded:   embedded,
+			sidecar:    Metadata{Taken: sidecar.Taken},
+			expected: Metadata{
+				Taken:     sidecar.Taken,
+				Latitude:  1,
+				Longitude: 1,
+				Located:   true,
+				Keywords:  []string{"camera"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, tt.precedence.Combine(tt.embedded, tt.sidecar))
+			},
+		)
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	precedence, err := ParsePrecedence("embedded")
+	assert.NoError(t, err)
+	assert.Equal(t, PreferEmbedded, precedence)
+
+	_, err = ParsePrecedence("newest")
+	assert.EqualError(t, err, `unknown xmp precedence "newest"`)
+}
@@ -0,0 +1,155 @@
+package grouping
+
+import (
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/categoriser"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+)
+
+// Config holds the trip thresholds, bank holidays and custom trip types, see LoadConfig. Custom trip types are checked
+// before the built-in ones, so that they can take precedence.
+type Config struct {
+	Thresholds Thresholds
+	Holidays   []time.Time
+	TripTypes  []TripType
+}
+
+// Thresholds holds the durations used to separate the built-in trip types. DayTrip is the upper bound of a day trip,
+// ShortBreak is the upper bound of an overnight trip that isn't a weekend, and Week is the upper bound of a week trip.
+// Anything that is at least Week is considered a holiday.
+type Thresholds struct {
+	DayTrip    time.Duration
+	ShortBreak time.Duration
+	Week       time.Duration
+}
+
+// Validate is used to make sure each threshold is positive and larger than the previous one, otherwise some of the
+// built-in trip types could never be matched.
+func (t Thresholds) Validate() error {
+	return categoriser.Thresholds(t).Validate()
+}
+
+// Trip is what a TripType is matched against; the photos taken at a place between Start and End.
+type Trip struct {
+	Name   string
+	Start  time.Time
+	End    time.Time
+	Photos []Photo
+}
+
+// Predicate is used to decide whether a Trip is a TripType.
+type Predicate func(trip Trip) bool
+
+// TripType defines a custom kind of trip. Match is used to determine if a Trip is the TripType, and Phrases are
+// prepended to the Trip's name when generating titles, i.e. "Skiing in" becomes "Skiing in Val d'Isère".
+type TripType struct {
+	Name    string
+	Match   Predicate
+	Phrases []string
+}
+
+// DurationBetween matches a Trip whose duration is at least min and less than max. A max of zero means there is no
+// upper bound.
+func DurationBetween(min, max time.Duration) Predicate {
+	return predicateOf(categoriser.DurationBetween(min, max))
+}
+
+// StartsOn matches a Trip where the first photo was taken on one of the given days.
+func StartsOn(days ...time.Weekday) Predicate {
+	return predicateOf(categoriser.StartsOn(days...))
+}
+
+// EndsOn matches a Trip where the last photo was taken on one of the given days.
+func EndsOn(days ...time.Weekday) Predicate {
+	return predicateOf(categoriser.EndsOn(days...))
+}
+
+// All matches a Trip only when every one of the given predicates match.
+func All(predicates ...Predicate) Predicate {
+	return func(trip Trip) bool {
+		for _, predicate := range predicates {
+			if !predicate(trip) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// LoadConfig reads trip thresholds, bank holidays and custom trip types from a JSON file.
+func LoadConfig(path string) (Config, error) {
+	config, err := categoriser.LoadConfig(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	converted := Config{
+		Thresholds: Thresholds(config.Thresholds),
+		Holidays:   config.Holidays,
+	}
+
+	for _, tripType := range config.TripTypes {
+		converted.TripTypes = append(
+			converted.TripTypes, TripType{
+				Name:    tripType.Name,
+				Match:   predicateOf(tripType.Match),
+				Phrases: tripType.Phrases,
+			},
+		)
+	}
+
+	return converted, nil
+}
+
+// internal converts the Config into the configuration used by the pipeline.
+func (c Config) internal() categoriser.Config {
+	config := categoriser.Config{
+		Thresholds: categoriser.Thresholds(c.Thresholds),
+		Holidays:   c.Holidays,
+	}
+
+	for _, tripType := range c.TripTypes {
+		converted := categoriser.TripType{
+			Name:    tripType.Name,
+			Phrases: tripType.Phrases,
+		}
+
+		if match := tripType.Match; match != nil {
+			converted.Match = func(l categoriser.Location) bool {
+				return match(tripOf(l))
+			}
+		}
+
+		config.TripTypes = append(config.TripTypes, converted)
+	}
+
+	return config
+}
+
+// predicateOf converts a predicate used by the pipeline into a Predicate.
+func predicateOf(predicate categoriser.Predicate) Predicate {
+	if predicate == nil {
+		return nil
+	}
+
+	return func(trip Trip) bool {
+		photos := make([]heap.Photo, 0, len(trip.Photos))
+		for _, photo := range trip.Photos {
+			photos = append(photos, photo.internal())
+		}
+
+		return predicate(*categoriser.NewLocationBetween(trip.Name, photos, trip.Start, trip.End))
+	}
+}
+
+// tripOf converts a Location into the Trip it's matched as.
+func tripOf(l categoriser.Location) Trip {
+	return Trip{
+		Name:   l.Name(),
+		Start:  l.StartTime(),
+		End:    l.EndTime(),
+		Photos: photosOf(l.Photos()),
+	}
+}
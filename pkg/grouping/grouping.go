@@ -0,0 +1,511 @@
+// Package grouping is the public API of photo-grouping. It runs the same pipeline as the CLI; photos are read from a
+// Source, geocoded by a Geocoder, grouped by location, or clustered when they couldn't be geocoded, and given titles.
+//
+//	pipeline := grouping.New(client, grouping.DefaultOptions())
+//
+//	result, err := pipeline.Run(ctx, grouping.CSV(file))
+//	if err != nil {
+//		return err
+//	}
+//
+//	for _, group := range result.Groups {
+//		fmt.Println(group.Name, group.TripType, group.Titles)
+//	}
+package grouping
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/categoriser"
+	"github.com/JackFazackerley/photo-grouping/internal/cluster"
+	"github.com/JackFazackerley/photo-grouping/internal/consumer"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/importer"
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	"github.com/JackFazackerley/photo-grouping/internal/tracing"
+	"github.com/JackFazackerley/photo-grouping/internal/xmp"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"googlemaps.github.io/maps"
+)
+
+// ClusterOptions configures how photos without an address are clustered. Radius is the distance in metres and Window
+// the time either side of a photo in which other photos are considered neighbours. MinPhotos is the number of photos,
+// including itself, a photo needs within its neighbourhood to start or grow a cluster.
+type ClusterOptions struct {
+	Radius    float64
+	Window    time.Duration
+	MinPhotos int
+}
+
+// AttachOptions configures how far photos without an address can be from a group to be attached to it. A photo can
+// only be attached to a group when it was taken within Window and Distance, in metres, of one of the group's photos.
+type AttachOptions struct {
+	Distance float64
+	Window   time.Duration
+}
+
+// Stats holds the number of geocoding requests made and saved. Requests is the number of requests made, Coalesced the
+// number of photos which shared an in-flight request, and Reused the number which reused the result of a nearby photo.
+// Geocoded is the number of photos which have been geocoded, whether or not an address was found, and Failed is the
+// number of photos where the request failed. Stored is the number of photos which were already geocoded, such as
+// those loaded from a store, and are included in Geocoded.
+type Stats struct {
+	Requests  int64
+	Coalesced int64
+	Reused    int64
+	Geocoded  int64
+	Failed    int64
+	Stored    int64
+}
+
+// GroupKind describes how a Group was formed.
+type GroupKind string
+
+const (
+	// KindLocation is a Group formed from photos sharing an address.
+	KindLocation GroupKind = "location"
+	// KindCluster is a Group formed from photos without an address, taken close together in space and time.
+	KindCluster GroupKind = "cluster"
+)
+
+var (
+	// ErrNoGeocoder is returned by Pipeline.Run when the Pipeline was created without a Geocoder.
+	ErrNoGeocoder = errors.New("no geocoder")
+)
+
+// Geocoder is the geocoding provider used by a Pipeline, *maps.Client satisfies it.
+type Geocoder interface {
+	ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error)
+}
+
+// Source provides the photos to group. The channel must be closed once every photo has been sent, or once ctx is
+// done.
+type Source interface {
+	Read(ctx context.Context) <-chan Photo
+}
+
+// SourceFunc allows an ordinary function to be used as a Source.
+type SourceFunc func(ctx context.Context) <-chan Photo
+
+// Read calls f(ctx).
+func (f SourceFunc) Read(ctx context.Context) <-chan Photo {
+	return f(ctx)
+}
+
+// CSV returns a Source which reads rows of timestamp, latitude and longitude from r.
+func CSV(r io.Reader) Source {
+	return internalSource(consumer.NewReaderFrom(r).ReadCSV)
+}
+
+// Locator finds where a photo was taken from its timestamp, see CSVWithLocator, FilesWithLocator, TakeoutWithLocator
+// and ApplePhotosWithLocator.
+type Locator interface {
+	Locate(timestamp time.Time) (latitude, longitude float64, ok bool)
+}
+
+// CSVWithLocator returns a Source which reads rows from r like CSV, except rows may have only a timestamp, in which case
+// the photo is located with locator, such as from a GPS track log. Rows locator can't locate are rejected.
+func CSVWithLocator(r io.Reader, locator Locator) Source {
+	return internalSource(consumer.NewReaderWithLocator(r, locator).ReadCSV)
+}
+
+// Takeout returns a Source which reads photos from a Google Takeout export of Google Photos in the directory root. Each
+// Photo's ID is the path to its file.
+func Takeout(root string) Source {
+	return internalSource(importer.NewTakeout(root).Read)
+}
+
+// TakeoutWithLocator returns a Source which reads photos from a Google Takeout export like Takeout, except photos
+// without a location are located with locator. Photos locator can't locate are skipped.
+func TakeoutWithLocator(root string, locator Locator) Source {
+	return internalSource(importer.NewTakeoutWithLocator(root, locator).Read)
+}
+
+// ApplePhotos returns a Source which reads photos from an Apple Photos library exported as JSON by osxphotos, with
+// `osxphotos query --json`. Each Photo's ID is its UUID in the library.
+func ApplePhotos(r io.Reader) Source {
+	return internalSource(importer.NewApple(r).Read)
+}
+
+// ApplePhotosWithLocator returns a Source which reads photos from an osxphotos export like ApplePhotos, except photos
+// without a location are located with locator. Photos locator can't locate are skipped.
+func ApplePhotosWithLocator(r io.Reader, locator Locator) Source {
+	return internalSource(importer.NewAppleWithLocator(r, locator).Read)
+}
+
+// XMPPrecedence decides whether a photo's XMP sidecar or its embedded XMP is used when both set the same field, see
+// Files.
+type XMPPrecedence int
+
+// The precedences between a photo's XMP sidecar and its embedded XMP.
+const (
+	// PreferSidecar uses the sidecar over the embedded XMP, as edits made in Lightroom or darktable to a photo are
+	// written to its sidecar, leaving the file untouched.
+	PreferSidecar = XMPPrecedence(xmp.PreferSidecar)
+	// PreferEmbedded uses the embedded XMP over the sidecar, for when the file was edited after the sidecar was
+	// written.
+	PreferEmbedded = XMPPrecedence(xmp.PreferEmbedded)
+)
+
+func (p XMPPrecedence) String() string {
+	return xmp.Precedence(p).String()
+}
+
+// Files returns a Source which reads the photos in the directory root, and its subdirectories, from the EXIF and XMP of
+// each file along with any XMP sidecar kept next to it, such as by Lightroom or darktable. JPEG, PNG, HEIF, TIFF and
+// most RAW formats are read, along with MP4 and QuickTime videos, which have Photo.Video set. Each Photo's ID is the path
+// to its file.
+func Files(root string, precedence XMPPrecedence) Source {
+	return FilesWithLocator(root, precedence, nil)
+}
+
+// FilesWithLocator returns a Source which reads the photos in the directory root like Files, except photos without a
+// location are located with locator. Photos locator can't locate are skipped.
+func FilesWithLocator(root string, precedence XMPPrecedence, locator Locator) Source {
+	options := importer.FilesOptions{
+		Precedence: xmp.Precedence(precedence),
+		Locator:    locator,
+	}
+
+	return internalSource(importer.NewFiles(root, options).Read)
+}
+
+// Photos returns a Source which sends each of the given photos.
+func Photos(photos ...Photo) Source {
+	return SourceFunc(
+		func(ctx context.Context) <-chan Photo {
+			photoChan := make(chan Photo)
+
+			go func() {
+				defer close(photoChan)
+
+				for _, photo := range photos {
+					select {
+					case <-ctx.Done():
+						return
+					case photoChan <- photo:
+					}
+				}
+			}()
+
+			return photoChan
+		},
+	)
+}
+
+// Options configures a Pipeline. Workers is the number of photos geocoded concurrently and ReuseRadius is the
+// distance in metres a photo can be from an already geocoded photo to reuse its result. When Explain is true, each
+// Group holds an explanation of why it was given its trip type and titles. Stream is only used by a Stream.
+type Options struct {
+	Workers     int
+	ReuseRadius float64
+	Config      Config
+	Cluster     ClusterOptions
+	Attach      AttachOptions
+	Stream      StreamOptions
+	Explain     bool
+}
+
+// DefaultOptions returns the Options used by the CLI when no flags are set.
+func DefaultOptions() Options {
+	return Options{
+		Workers:     5,
+		ReuseRadius: 200,
+		Config: Config{
+			Thresholds: Thresholds(categoriser.DefaultThresholds),
+		},
+		Cluster: ClusterOptions(cluster.DefaultOptions),
+		Attach:  AttachOptions(categoriser.DefaultAttachOptions),
+		Stream:  StreamOptions(categoriser.DefaultStreamOptions),
+	}
+}
+
+// Group is a set of photos with the titles suggested for them.
+type Group struct {
+	Name        string
+	Kind        GroupKind
+	TripType    string
+	Start       time.Time
+	End         time.Time
+	Photos      []Photo
+	Titles      []string
+	Explanation string
+}
+
+// Result is the output of Pipeline.Run. Groups are ordered by Group.Start, then Group.Name. Unplaced holds the photos
+// which couldn't be geocoded, clustered, or attached to a Group.
+type Result struct {
+	Groups   []Group
+	Unplaced []Photo
+	Stats    Stats
+}
+
+// Progress reports how far through a run a Pipeline is, see Pipeline.RunWithProgress. Progress can be safely read
+// while the run is in progress.
+type Progress struct {
+	read int64
+
+	mu       sync.RWMutex
+	consumer *consumer.Consumer
+}
+
+// ProgressSnapshot is the state of a Progress at a point in time. Read is the number of photos read from the Source,
+// Geocoded the number geocoded, whether or not an address was found, and Failed the number where geocoding failed.
+type ProgressSnapshot struct {
+	Read     int64
+	Geocoded int64
+	Failed   int64
+}
+
+// Snapshot returns the current state of the Progress.
+func (p *Progress) Snapshot() ProgressSnapshot {
+	p.mu.RLock()
+	c := p.consumer
+	p.mu.RUnlock()
+
+	snapshot := ProgressSnapshot{
+		Read: atomic.LoadInt64(&p.read),
+	}
+
+	if c != nil {
+		stats := c.Stats()
+		snapshot.Geocoded = stats.Geocoded
+		snapshot.Failed = stats.Failed
+	}
+
+	return snapshot
+}
+
+// start resets the Progress for a new run using c.
+func (p *Progress) start(c *consumer.Consumer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	atomic.StoreInt64(&p.read, 0)
+	p.consumer = c
+}
+
+// count forwards every photo from photos to the returned channel, counting each one as read.
+func (p *Progress) count(ctx context.Context, photos <-chan Photo) <-chan Photo {
+	counted := make(chan Photo)
+
+	go func() {
+		defer close(counted)
+
+		for photo := range photos {
+			atomic.AddInt64(&p.read, 1)
+
+			select {
+			case <-ctx.Done():
+				return
+			case counted <- photo:
+			}
+		}
+	}()
+
+	return counted
+}
+
+// Pipeline groups photos and suggests titles for each group. A Pipeline can be used to Run many times, and
+// concurrently.
+type Pipeline struct {
+	geocoder   Geocoder
+	options    Options
+	classifier *categoriser.Classifier
+}
+
+// New creates a Pipeline which geocodes photos using the Geocoder.
+func New(geocoder Geocoder, options Options) *Pipeline {
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+
+	return &Pipeline{
+		geocoder:   geocoder,
+		options:    options,
+		classifier: categoriser.NewClassifier(options.Config.internal()),
+	}
+}
+
+// Run reads every photo from the Source, geocodes them, then groups them. If ctx is cancelled, Run stops reading and
+// geocoding and groups whatever has been geocoded so far, returning ctx.Err().
+func (p *Pipeline) Run(ctx context.Context, source Source) (Result, error) {
+	return p.RunWithProgress(ctx, source, nil)
+}
+
+// RunWithProgress is the same as Run, but reports the number of photos read and geocoded to progress as it goes. A
+// nil progress is ignored.
+func (p *Pipeline) RunWithProgress(ctx context.Context, source Source, progress *Progress) (Result, error) {
+	if p.geocoder == nil {
+		return Result{}, ErrNoGeocoder
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "Pipeline.Run")
+	defer span.End()
+
+	c := consumer.NewConsumerWithClient(p.geocoder, p.options.ReuseRadius)
+	photoHeap := heap.New()
+
+	photos := source.Read(ctx)
+	if progress != nil {
+		progress.start(c)
+		photos = progress.count(ctx, photos)
+	}
+
+	p.geocode(ctx, c, heapSink{photoHeap}, internalPhotos(ctx, photos))
+
+	// every photo is taken off the heap while grouping, see heapSink.
+	waiting := photoHeap.Len()
+	result := p.group(ctx, c, photoHeap)
+	metrics.HeapSize.Sub(float64(waiting))
+
+	result.Stats = Stats(c.Stats())
+
+	return result, ctx.Err()
+}
+
+// heapSink pushes each geocoded photo onto the heap, counting it on metrics.HeapSize until it has been grouped.
+type heapSink struct {
+	*heap.Heap
+}
+
+func (s heapSink) Push(photo heap.Photo) {
+	s.Heap.Push(photo)
+	metrics.HeapSize.Inc()
+}
+
+// geocode starts Options.Workers Consumer.Run(s) and waits for them to finish.
+func (p *Pipeline) geocode(ctx context.Context, c *consumer.Consumer, sink consumer.Sink, photos <-chan heap.Photo) {
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < p.options.Workers; i++ {
+		wg.Add(1)
+		go c.Run(ctx, sink, photos, wg)
+	}
+
+	wg.Wait()
+}
+
+// group clusters the photos that couldn't be geocoded, groups the rest by location, then attaches any photos that
+// are left over to the nearest group.
+func (p *Pipeline) group(ctx context.Context, c *consumer.Consumer, photoHeap *heap.Heap) Result {
+	photos := photoHeap.Photos()
+
+	ctx, span := tracing.Tracer().Start(ctx, "Group", trace.WithAttributes(tracing.Photos.Int(len(photos))))
+	defer span.End()
+
+	clusters, noise := cluster.DBSCAN(cluster.Unresolved(photos), cluster.Options(p.options.Cluster))
+	if err := cluster.Label(ctx, clusters, c); err != nil {
+		log.WithError(err).Error("labelling clusters")
+	}
+
+	locations := categoriser.Group(photoHeap)
+	unplaced := categoriser.Attach(locations, noise, categoriser.AttachOptions(p.options.Attach))
+
+	result := Result{
+		Groups:   make([]Group, 0, len(locations)+len(clusters)),
+		Unplaced: make([]Photo, 0),
+	}
+
+	for _, location := range locations {
+		result.Groups = append(result.Groups, p.newGroup(location, KindLocation))
+	}
+
+	for _, cl := range clusters {
+		name := cl.Name
+		if name == "" {
+			name = cl.Coordinates()
+		}
+
+		result.Groups = append(result.Groups, p.newGroup(categoriser.NewLocation(name, cl.Photos), KindCluster))
+	}
+
+	if unplaced != nil {
+		result.Unplaced = photosOf(unplaced.Photos())
+	}
+
+	sort.SliceStable(
+		result.Groups, func(i, j int) bool {
+			if !result.Groups[i].Start.Equal(result.Groups[j].Start) {
+				return result.Groups[i].Start.Before(result.Groups[j].Start)
+			}
+			return result.Groups[i].Name < result.Groups[j].Name
+		},
+	)
+
+	span.SetAttributes(tracing.Groups.Int(len(result.Groups)))
+
+	if span.IsRecording() {
+		tracePhotos(ctx, result)
+	}
+
+	return result
+}
+
+// tracePhotos records a GroupPhoto span, within the Group span in ctx, for each photo in each group and each photo
+// left unplaced, linked to the spans which read and geocoded the photo. A photo in several groups, such as its city
+// and its country, has a span for each.
+func tracePhotos(ctx context.Context, result Result) {
+	record := func(photo Photo, attributes ...attribute.KeyValue) {
+		attributes = append(
+			attributes,
+			tracing.Timestamp.String(photo.Timestamp.Format(time.RFC3339)),
+			tracing.Latitude.Float64(photo.Latitude),
+			tracing.Longitude.Float64(photo.Longitude),
+			tracing.Status.String(photo.Status.String()),
+		)
+
+		_, span := tracing.Tracer().Start(
+			ctx, "GroupPhoto", trace.WithLinks(tracing.Links(photo.spans)...), trace.WithAttributes(attributes...),
+		)
+		span.End()
+	}
+
+	for _, group := range result.Groups {
+		for _, photo := range group.Photos {
+			record(photo, tracing.GroupName.String(group.Name), tracing.GroupKind.String(string(group.Kind)))
+		}
+	}
+
+	for _, photo := range result.Unplaced {
+		record(photo)
+	}
+}
+
+// newGroup converts a categoriser.Location into a Group, counting it on metrics.GroupsProduced.
+func (p *Pipeline) newGroup(location *categoriser.Location, kind GroupKind) Group {
+	metrics.GroupsProduced.WithLabelValues(string(kind)).Inc()
+
+	return p.viewGroup(location, kind)
+}
+
+// viewGroup converts a categoriser.Location into a Group without counting it, such as a Stream's open groups, which
+// are counted once they're emitted.
+func (p *Pipeline) viewGroup(location *categoriser.Location, kind GroupKind) Group {
+	group := Group{
+		Name:   location.Name(),
+		Kind:   kind,
+		Start:  location.StartTime(),
+		End:    location.EndTime(),
+		Photos: photosOf(location.Photos()),
+		Titles: p.classifier.GenerateTitles(*location),
+	}
+
+	if tripType, ok := p.classifier.Classify(*location); ok {
+		group.TripType = tripType.Name
+	}
+
+	if p.options.Explain {
+		group.Explanation = p.classifier.Explain(*location).String()
+	}
+
+	return group
+}
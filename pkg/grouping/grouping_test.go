@@ -0,0 +1,285 @@
+package grouping
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	"github.com/JackFazackerley/photo-grouping/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"googlemaps.github.io/maps"
+)
+
+// mockGeocoder resolves photos in London to a locality, and photos in the Lake District only to a park when the
+// request isn't limited to localities. Everything else has no results.
+type mockGeocoder struct{}
+
+func (m mockGeocoder) ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	switch {
+	case r.LatLng.Lat > 51 && r.LatLng.Lat < 52:
+		return []maps.GeocodingResult{
+			{
+				AddressComponents: []maps.AddressComponent{
+					{
+						LongName: "London",
+						Types:    []string{"locality"},
+					},
+				},
+			},
+		}, nil
+	case r.LatLng.Lat > 54 && r.LatLng.Lat < 55 && len(r.ResultType) == 0:
+		return []maps.GeocodingResult{
+			{
+				AddressComponents: []maps.AddressComponent{
+					{
+						LongName: "Lake District National Park",
+						Types:    []string{"park"},
+					},
+				},
+			},
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func TestPipeline_Run(t *testing.T) {
+	csv := strings.Join(
+		[]string{
+			"2022-04-02T10:00:00Z,51.5072,-0.1276",
+			"2022-04-02T12:00:00Z,51.5080,-0.1280",
+			"2022-04-03T09:00:00Z,54.4609,-3.0886",
+			"2022-04-03T10:00:00Z,54.4620,-3.0880",
+			"2022-04-03T11:00:00Z,54.4630,-3.0870",
+			"2022-04-05T11:00:00Z,10.0000,-30.0000",
+		}, "\n",
+	)
+
+	pipeline := New(mockGeocoder{}, DefaultOptions())
+
+	got, err := pipeline.Run(context.Background(), CSV(strings.NewReader(csv)))
+	assert.NoError(t, err)
+
+	names := make([]string, 0)
+	for _, group := range got.Groups {
+		names = append(names, group.Name)
+	}
+
+	assert.Equal(t, []string{"London", "Lake District National Park"}, names)
+
+	assert.Equal(t, KindLocation, got.Groups[0].Kind)
+	assert.Equal(t, "day", got.Groups[0].TripType)
+	assert.Len(t, got.Groups[0].Photos, 2)
+	assert.Equal(t, time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC), got.Groups[0].Start)
+	assert.Equal(t, time.Date(2022, 04, 02, 12, 0, 0, 0, time.UTC), got.Groups[0].End)
+	assert.Equal(
+		t, []string{
+			"A day out in London",
+			"A trip to London",
+			"London in April",
+			"Visiting London in April",
+		}, got.Groups[0].Titles,
+	)
+	assert.Empty(t, got.Groups[0].Explanation)
+
+	assert.Equal(t, KindCluster, got.Groups[1].Kind)
+	assert.Len(t, got.Groups[1].Photos, 3)
+
+	assert.Len(t, got.Unplaced, 1)
+	assert.Equal(t, GeocodeUnresolved, got.Unplaced[0].Status)
+
+	assert.Equal(t, int64(6), got.Stats.Requests+got.Stats.Reused+got.Stats.Coalesced)
+	assert.Less(t, got.Stats.Requests, int64(6))
+}
+
+func TestPipeline_RunExplain(t *testing.T) {
+	options := DefaultOptions()
+	options.Explain = true
+
+	photos := []Photo{
+		{
+			Timestamp: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+			Latitude:  51.5072,
+			Longitude: -0.1276,
+		},
+	}
+
+	got, err := New(mockGeocoder{}, options).Run(context.Background(), Photos(photos...))
+	assert.NoError(t, err)
+
+	assert.Len(t, got.Groups, 1)
+	assert.Contains(t, got.Groups[0].Explanation, "trip type: day")
+}
+
+func TestPipeline_RunSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	csv := strings.Join(
+		[]string{
+			"2022-04-02T10:00:00Z,51.5072,-0.1276",
+			"2022-04-02T12:00:00Z,51.5080,-0.1280",
+			"2022-04-05T11:00:00Z,10.0000,-30.0000",
+		}, "\n",
+	)
+
+	_, err := New(mockGeocoder{}, DefaultOptions()).Run(context.Background(), CSV(strings.NewReader(csv)))
+	assert.NoError(t, err)
+
+	spans := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+
+	assert.Len(t, spans["Group"], 1)
+	assert.Len(t, spans["GroupPhoto"], 3)
+
+	read := make(map[trace.SpanID]bool)
+	for _, span := range append(spans["ReadCSV"], spans["getGeocoding"]...) {
+		read[span.SpanContext().SpanID()] = true
+	}
+
+	grouped := 0
+	for _, span := range spans["GroupPhoto"] {
+		assert.Equal(t, spans["Group"][0].SpanContext().SpanID(), span.Parent().SpanID())
+
+		// each photo links to the span which read it and the one which geocoded it
+		assert.Len(t, span.Links(), 2)
+		for _, link := range span.Links() {
+			assert.True(t, read[link.SpanContext.SpanID()])
+		}
+
+		for _, attribute := range span.Attributes() {
+			if attribute.Key == tracing.GroupName {
+				assert.Equal(t, "London", attribute.Value.AsString())
+				grouped++
+			}
+		}
+	}
+
+	assert.Equal(t, 2, grouped)
+}
+
+func TestPipeline_RunHeapSize(t *testing.T) {
+	before := testutil.ToFloat64(metrics.HeapSize)
+
+	photos := []Photo{
+		{
+			Timestamp: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+			Latitude:  51.5072,
+			Longitude: -0.1276,
+		},
+	}
+
+	_, err := New(mockGeocoder{}, DefaultOptions()).Run(context.Background(), Photos(photos...))
+	assert.NoError(t, err)
+
+	// every photo counted while it was waiting to be grouped has been grouped
+	assert.Equal(t, before, testutil.ToFloat64(metrics.HeapSize))
+}
+
+func TestPipeline_RunTripType(t *testing.T) {
+	options := DefaultOptions()
+	options.Config.TripTypes = []TripType{
+		{
+			Name: "shopping",
+			Match: All(
+				StartsOn(time.Saturday),
+				func(trip Trip) bool {
+					return trip.Name == "London" && len(trip.Photos) == 2
+				},
+			),
+			Phrases: []string{"Shopping in"},
+		},
+	}
+
+	photos := []Photo{
+		{
+			Timestamp: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+			Latitude:  51.5072,
+			Longitude: -0.1276,
+		},
+		{
+			Timestamp: time.Date(2022, 04, 02, 12, 0, 0, 0, time.UTC),
+			Latitude:  51.5080,
+			Longitude: -0.1280,
+		},
+	}
+
+	got, err := New(mockGeocoder{}, options).Run(context.Background(), Photos(photos...))
+	assert.NoError(t, err)
+
+	assert.Len(t, got.Groups, 1)
+	assert.Equal(t, "shopping", got.Groups[0].TripType)
+	assert.Contains(t, got.Groups[0].Titles, "Shopping in London")
+}
+
+func TestPipeline_RunWithProgress(t *testing.T) {
+	csv := strings.Join(
+		[]string{
+			"2022-04-02T10:00:00Z,51.5072,-0.1276",
+			"2022-04-02T12:00:00Z,51.5080,-0.1280",
+			"2022-04-05T11:00:00Z,10.0000,-30.0000",
+		}, "\n",
+	)
+
+	progress := &Progress{}
+
+	_, err := New(mockGeocoder{}, DefaultOptions()).RunWithProgress(
+		context.Background(), CSV(strings.NewReader(csv)), progress,
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(
+		t, ProgressSnapshot{
+			Read:     3,
+			Geocoded: 3,
+			Failed:   0,
+		}, progress.Snapshot(),
+	)
+}
+
+func TestPipeline_RunErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		geocoder    Geocoder
+		cancel      bool
+		expectedErr error
+	}{
+		{
+			name:        "errors without geocoder",
+			geocoder:    nil,
+			expectedErr: ErrNoGeocoder,
+		},
+		{
+			name:        "returns context error when cancelled",
+			geocoder:    mockGeocoder{},
+			cancel:      true,
+			expectedErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				if tt.cancel {
+					cancel()
+				}
+
+				_, err := New(tt.geocoder, DefaultOptions()).Run(ctx, Photos())
+
+				assert.ErrorIs(t, err, tt.expectedErr)
+			},
+		)
+	}
+}
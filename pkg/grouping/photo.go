@@ -0,0 +1,139 @@
+package grouping
+
+import (
+	"context"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GeocodeStatus describes the outcome of geocoding a Photo.
+type GeocodeStatus int
+
+// The outcomes of geocoding a Photo, see Photo.Status.
+const (
+	// GeocodePending is the status of a Photo which hasn't been geocoded yet.
+	GeocodePending = GeocodeStatus(heap.GeocodePending)
+	// GeocodeResolved is the status of a Photo which has at least one address.
+	GeocodeResolved = GeocodeStatus(heap.GeocodeResolved)
+	// GeocodeUnresolved is the status of a Photo which was geocoded successfully, but had no usable addresses, i.e. a
+	// photo taken at sea.
+	GeocodeUnresolved = GeocodeStatus(heap.GeocodeUnresolved)
+	// GeocodeFailed is the status of a Photo where the request to geocode it failed.
+	GeocodeFailed = GeocodeStatus(heap.GeocodeFailed)
+)
+
+func (s GeocodeStatus) String() string {
+	return heap.GeocodeStatus(s).String()
+}
+
+// Photo is a single photo, the timestamp, latitude and longitude are provided by the Source and the rest is set
+// by geocoding.
+//
+// ID identifies the photo in the Source it was read from, such as the path to the photo's file, or its ID in a photo
+// library. Photos read from a CSV don't have an ID. Keywords holds any keywords the photo was already tagged with, such
+// as in its XMP. Video is true if the photo is a video, which are grouped alongside photos.
+//
+// Addresses holds the name of every address found for the photo, and AddressTypes the address component types
+// (locality, country, etc.) of each.
+type Photo struct {
+	ID           string
+	Keywords     []string
+	Video        bool
+	Timestamp    time.Time
+	Latitude     float64
+	Longitude    float64
+	Addresses    map[string]struct{}
+	AddressTypes map[string][]string
+	Status       GeocodeStatus
+
+	// spans holds the spans which handled the photo, see heap.Photo.Spans.
+	spans []trace.SpanContext
+}
+
+// photoOf converts a photo from the pipeline into a Photo.
+func photoOf(photo heap.Photo) Photo {
+	return Photo{
+		ID:           photo.ID,
+		Keywords:     photo.Keywords,
+		Video:        photo.Video,
+		Timestamp:    photo.Timestamp,
+		Latitude:     photo.Latitude,
+		Longitude:    photo.Longitude,
+		Addresses:    photo.Addresses,
+		AddressTypes: photo.AddressTypes,
+		Status:       GeocodeStatus(photo.Status),
+		spans:        photo.Spans,
+	}
+}
+
+// internal converts the Photo into the photo used by the pipeline.
+func (p Photo) internal() heap.Photo {
+	return heap.Photo{
+		ID:           p.ID,
+		Keywords:     p.Keywords,
+		Video:        p.Video,
+		Timestamp:    p.Timestamp,
+		Latitude:     p.Latitude,
+		Longitude:    p.Longitude,
+		Addresses:    p.Addresses,
+		AddressTypes: p.AddressTypes,
+		Status:       heap.GeocodeStatus(p.Status),
+		Spans:        p.spans,
+	}
+}
+
+// photosOf converts photos from the pipeline into Photos.
+func photosOf(photos []heap.Photo) []Photo {
+	converted := make([]Photo, 0, len(photos))
+
+	for _, photo := range photos {
+		converted = append(converted, photoOf(photo))
+	}
+
+	return converted
+}
+
+// internalSource returns a Source which sends the photos read by read, such as consumer.Reader.ReadCSV, as Photos.
+func internalSource(read func(ctx context.Context) <-chan heap.Photo) Source {
+	return SourceFunc(
+		func(ctx context.Context) <-chan Photo {
+			photos := read(ctx)
+			converted := make(chan Photo)
+
+			go func() {
+				defer close(converted)
+
+				// once ctx is done the remaining photos are drained, so that read isn't left blocked sending them.
+				for photo := range photos {
+					select {
+					case <-ctx.Done():
+					case converted <- photoOf(photo):
+					}
+				}
+			}()
+
+			return converted
+		},
+	)
+}
+
+// internalPhotos forwards every Photo from photos to the returned channel, converted into the photo used by the
+// pipeline.
+func internalPhotos(ctx context.Context, photos <-chan Photo) <-chan heap.Photo {
+	converted := make(chan heap.Photo)
+
+	go func() {
+		defer close(converted)
+
+		for photo := range photos {
+			select {
+			case <-ctx.Done():
+			case converted <- photo.internal():
+			}
+		}
+	}()
+
+	return converted
+}
@@ -0,0 +1,195 @@
+package grouping
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/categoriser"
+	"github.com/JackFazackerley/photo-grouping/internal/consumer"
+	"github.com/JackFazackerley/photo-grouping/internal/heap"
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+)
+
+// StreamOptions configures how a Stream decides a Group can no longer change, see Options.Stream. Gap is the longest
+// time between two photos in the same Group, and Lateness is how far behind the newest photo a photo can arrive and
+// still be grouped in timestamp order.
+type StreamOptions struct {
+	Gap      time.Duration
+	Lateness time.Duration
+}
+
+// Stream groups photos as they are geocoded, passing each Group to emit once no more photos can be added to it. Unlike
+// Pipeline.Run, photos can be added to a Stream any number of times, such as a phone's daily upload, and open groups
+// are updated as they arrive.
+//
+// A Stream only groups photos by location, photos which can't be geocoded aren't clustered or attached. They're held
+// instead, see Stream.Unplaced, and those whose request to geocode them failed are geocoded again by the next Add.
+type Stream struct {
+	pipeline *Pipeline
+	consumer *consumer.Consumer
+	grouper  *categoriser.Grouper
+	// pending is the number of photos held by grouper when metrics.HeapSize was last updated.
+	pending int
+
+	mu sync.Mutex
+	// unplaced holds the photos without an address to be grouped by.
+	unplaced []heap.Photo
+}
+
+// NewStream creates a Stream which passes each Group to emit once it has closed. emit must not call the Stream.
+func (p *Pipeline) NewStream(emit func(Group)) (*Stream, error) {
+	if p.geocoder == nil {
+		return nil, ErrNoGeocoder
+	}
+
+	s := &Stream{
+		pipeline: p,
+		consumer: consumer.NewConsumerWithClient(p.geocoder, p.options.ReuseRadius),
+	}
+
+	s.grouper = categoriser.NewGrouper(
+		categoriser.StreamOptions(p.options.Stream), func(location *categoriser.Location) {
+			emit(p.newGroup(location, KindLocation))
+		},
+	)
+
+	return s, nil
+}
+
+// Add reads every photo from the Source and geocodes them, emitting any groups which close as a result. Groups still
+// open once the Source is exhausted stay open, see Stream.Flush. If ctx is cancelled Add stops early and returns
+// ctx.Err().
+func (s *Stream) Add(ctx context.Context, source Source) error {
+	photos := s.retry(ctx, internalPhotos(ctx, source.Read(ctx)))
+
+	s.pipeline.geocode(ctx, s.consumer, streamSink{s}, photos)
+	s.countPending()
+
+	return ctx.Err()
+}
+
+// retry returns photos, after the held photos whose request to geocode them failed, so that they're geocoded again.
+// The failed photos which haven't been sent once ctx is done are held again.
+func (s *Stream) retry(ctx context.Context, photos <-chan heap.Photo) <-chan heap.Photo {
+	s.mu.Lock()
+	failed := make([]heap.Photo, 0)
+	unresolved := make([]heap.Photo, 0, len(s.unplaced))
+
+	for _, photo := range s.unplaced {
+		if photo.Status == heap.GeocodeFailed {
+			failed = append(failed, photo)
+		} else {
+			unresolved = append(unresolved, photo)
+		}
+	}
+
+	s.unplaced = unresolved
+	s.mu.Unlock()
+
+	retried := make(chan heap.Photo)
+
+	go func() {
+		defer close(retried)
+
+		for i, photo := range failed {
+			select {
+			case <-ctx.Done():
+				s.hold(failed[i:]...)
+				return
+			case retried <- photo:
+			}
+		}
+
+		for photo := range photos {
+			select {
+			case <-ctx.Done():
+			case retried <- photo:
+			}
+		}
+	}()
+
+	return retried
+}
+
+// hold keeps photos which can't be grouped on the Stream.
+func (s *Stream) hold(photos ...heap.Photo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unplaced = append(s.unplaced, photos...)
+}
+
+// streamSink pushes each geocoded photo to the Stream's grouper, or holds it on the Stream if it has no address to be
+// grouped by.
+type streamSink struct {
+	*Stream
+}
+
+func (s streamSink) Push(photo heap.Photo) {
+	if len(photo.Addresses) == 0 {
+		s.hold(photo)
+		return
+	}
+
+	s.grouper.Push(photo)
+}
+
+// Restore adds photos which have already been geocoded, such as those of groups which were open when a previous Stream
+// stopped, without geocoding them again. Photos without an address are held, see Stream.Unplaced.
+func (s *Stream) Restore(photos ...Photo) {
+	sink := streamSink{s}
+
+	for _, photo := range photos {
+		sink.Push(photo.internal())
+	}
+
+	s.countPending()
+}
+
+// Open returns the groups which are still open, ordered by Group.Start, then Group.Name. They aren't counted on
+// metrics.GroupsProduced until they're emitted.
+func (s *Stream) Open() []Group {
+	locations := s.grouper.Open()
+
+	groups := make([]Group, 0, len(locations))
+	for i := range locations {
+		groups = append(groups, s.pipeline.viewGroup(&locations[i], KindLocation))
+	}
+
+	return groups
+}
+
+// Pending returns the photos which have been geocoded, but are held until newer photos arrive before being added to
+// a group, ordered by timestamp. Along with the photos of open groups, and Unplaced, they can be passed to Restore.
+func (s *Stream) Pending() []Photo {
+	return photosOf(s.grouper.Pending())
+}
+
+// Unplaced returns the photos which couldn't be grouped, either because they had no usable address, or because the
+// request to geocode them failed and they're waiting to be geocoded again by the next Add. Along with the photos of
+// open groups, and Pending, they can be passed to Restore.
+func (s *Stream) Unplaced() []Photo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return photosOf(s.unplaced)
+}
+
+// Flush emits every open group, i.e. once no more photos are expected. The Stream can still be added to afterwards.
+func (s *Stream) Flush() {
+	s.grouper.Flush()
+	s.countPending()
+}
+
+// countPending updates metrics.HeapSize with the change in the number of photos held by the grouper.
+func (s *Stream) countPending() {
+	pending := s.grouper.PendingLen()
+	metrics.HeapSize.Add(float64(pending - s.pending))
+	s.pending = pending
+}
+
+// Stats returns the number of geocoding requests made, and saved, by the Stream so far.
+func (s *Stream) Stats() Stats {
+	return Stats(s.consumer.Stats())
+}
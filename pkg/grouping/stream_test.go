@@ -0,0 +1,191 @@
+package grouping
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JackFazackerley/photo-grouping/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"googlemaps.github.io/maps"
+)
+
+func TestStream(t *testing.T) {
+	emitted := make([]Group, 0)
+
+	// a single worker geocodes photos in the order they're read, so the order groups close in is known
+	options := DefaultOptions()
+	options.Workers = 1
+
+	stream, err := New(mockGeocoder{}, options).NewStream(
+		func(group Group) {
+			emitted = append(emitted, group)
+		},
+	)
+	assert.NoError(t, err)
+
+	// the first day's upload
+	err = stream.Add(
+		context.Background(), CSV(
+			strings.NewReader(
+				strings.Join(
+					[]string{
+						"2022-04-02T10:00:00Z,51.5072,-0.1276",
+						"2022-04-02T12:00:00Z,51.5080,-0.1280",
+						"2022-04-05T11:00:00Z,10.0000,-30.0000",
+					}, "\n",
+				),
+			),
+		),
+	)
+	assert.NoError(t, err)
+
+	assert.Empty(t, emitted)
+
+	// the photo at 12:00 is held behind the watermark, and looking at the open group doesn't count it as produced
+	produced := testutil.ToFloat64(metrics.GroupsProduced.WithLabelValues(string(KindLocation)))
+	stream.Open()
+	open := stream.Open()
+	assert.Equal(t, produced, testutil.ToFloat64(metrics.GroupsProduced.WithLabelValues(string(KindLocation))))
+	assert.Len(t, open, 1)
+	assert.Equal(t, "London", open[0].Name)
+	assert.Len(t, open[0].Photos, 1)
+	assert.Len(t, stream.Pending(), 1)
+
+	// the next upload extends the open group, then a later visit closes it
+	err = stream.Add(
+		context.Background(), Photos(
+			Photo{
+				Timestamp: time.Date(2022, 04, 02, 18, 0, 0, 0, time.UTC),
+				Latitude:  51.5072,
+				Longitude: -0.1276,
+			},
+			Photo{
+				Timestamp: time.Date(2022, 04, 10, 10, 0, 0, 0, time.UTC),
+				Latitude:  51.5072,
+				Longitude: -0.1276,
+			},
+		),
+	)
+	assert.NoError(t, err)
+
+	assert.Len(t, emitted, 1)
+	assert.Equal(t, KindLocation, emitted[0].Kind)
+	assert.Equal(t, "day", emitted[0].TripType)
+	assert.Len(t, emitted[0].Photos, 3)
+	assert.Equal(t, time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC), emitted[0].Start)
+	assert.Equal(t, time.Date(2022, 04, 02, 18, 0, 0, 0, time.UTC), emitted[0].End)
+
+	stream.Flush()
+
+	assert.Len(t, emitted, 2)
+	assert.Equal(t, produced+2, testutil.ToFloat64(metrics.GroupsProduced.WithLabelValues(string(KindLocation))))
+	assert.Equal(t, time.Date(2022, 04, 10, 10, 0, 0, 0, time.UTC), emitted[1].Start)
+	assert.Empty(t, stream.Open())
+
+	assert.Equal(t, int64(5), stream.Stats().Requests+stream.Stats().Reused+stream.Stats().Coalesced)
+}
+
+// flakyGeocoder fails every request until ok is set, then geocodes them like mockGeocoder.
+type flakyGeocoder struct {
+	ok *bool
+}
+
+func (f flakyGeocoder) ReverseGeocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	if !*f.ok {
+		return nil, errors.New("over query limit")
+	}
+
+	return mockGeocoder{}.ReverseGeocode(ctx, r)
+}
+
+func TestStream_Unplaced(t *testing.T) {
+	ok := false
+
+	options := DefaultOptions()
+	options.Workers = 1
+
+	stream, err := New(flakyGeocoder{ok: &ok}, options).NewStream(func(group Group) {})
+	assert.NoError(t, err)
+
+	err = stream.Add(
+		context.Background(), Photos(
+			Photo{
+				Timestamp: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+				Latitude:  51.5072,
+				Longitude: -0.1276,
+			},
+			Photo{
+				Timestamp: time.Date(2022, 04, 02, 12, 0, 0, 0, time.UTC),
+				Latitude:  51.5080,
+				Longitude: -0.1280,
+			},
+		),
+	)
+	assert.NoError(t, err)
+
+	// the photos which failed are held rather than dropped
+	assert.Empty(t, stream.Open())
+	assert.Len(t, stream.Unplaced(), 2)
+	assert.Equal(t, GeocodeFailed, stream.Unplaced()[0].Status)
+
+	// once geocoding works again, the next Add geocodes them along with the new photos
+	ok = true
+
+	err = stream.Add(
+		context.Background(), Photos(
+			Photo{
+				Timestamp: time.Date(2022, 04, 05, 11, 0, 0, 0, time.UTC),
+				Latitude:  10,
+				Longitude: -30,
+			},
+		),
+	)
+	assert.NoError(t, err)
+
+	open := stream.Open()
+	assert.Len(t, open, 1)
+	assert.Equal(t, "London", open[0].Name)
+	assert.Len(t, append(open[0].Photos, stream.Pending()...), 2)
+
+	// the photo without a locality is still held, so that it's kept along with the open groups
+	assert.Len(t, stream.Unplaced(), 1)
+	assert.Equal(t, GeocodeUnresolved, stream.Unplaced()[0].Status)
+}
+
+func TestStream_Restore(t *testing.T) {
+	emitted := make([]Group, 0)
+
+	stream, err := New(mockGeocoder{}, DefaultOptions()).NewStream(
+		func(group Group) {
+			emitted = append(emitted, group)
+		},
+	)
+	assert.NoError(t, err)
+
+	photo := Photo{
+		Timestamp: time.Date(2022, 04, 02, 10, 0, 0, 0, time.UTC),
+		Latitude:  51.5072,
+		Longitude: -0.1276,
+		Addresses: map[string]struct{}{
+			"London": {},
+		},
+		Status: GeocodeResolved,
+	}
+
+	stream.Restore(photo, photo)
+	stream.Flush()
+
+	assert.Len(t, emitted, 1)
+	assert.Equal(t, "London", emitted[0].Name)
+	assert.Len(t, emitted[0].Photos, 2)
+	assert.Equal(t, Stats{}, stream.Stats())
+}
+
+func TestStream_NoGeocoder(t *testing.T) {
+	_, err := New(nil, DefaultOptions()).NewStream(func(group Group) {})
+	assert.ErrorIs(t, err, ErrNoGeocoder)
+}